@@ -0,0 +1,149 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/go-course/bookings/internal/csvimport"
+	"github.com/go-course/bookings/internal/driver"
+	"github.com/go-course/bookings/internal/repository/dbrepo"
+)
+
+// dbFlags holds the database connection flags
+type dbFlags struct {
+	host, name, user, pass, port, ssl *string
+}
+
+// addDBFlags registers the database connection flags on fs
+func addDBFlags(fs *flag.FlagSet) *dbFlags {
+	return &dbFlags{
+		host: fs.String("dbhost", "localhost", "Database Host"),
+		name: fs.String("dbname", "", "Database Name"),
+		user: fs.String("dbuser", "", "Database User"),
+		pass: fs.String("dbpass", "", "Database Password"),
+		port: fs.String("dbport", "5432", "Database port"),
+		ssl:  fs.String("dbssl", "disable", "Database ssl settings (disable, prefer, require)"),
+	}
+}
+
+// missing reports whether a required flag is not set
+func (f *dbFlags) missing() bool {
+	return *f.name == "" || *f.user == "" || *f.pass == ""
+}
+
+// connectionString returns the dsn the flags describe
+func (f *dbFlags) connectionString() string {
+	return fmt.Sprintf(
+		"host=%s port=%s dbname=%s user=%s password=%s sslmode=%s",
+		*f.host,
+		*f.port,
+		*f.name,
+		*f.user,
+		*f.pass,
+		*f.ssl,
+	)
+}
+
+// importCommand runs the import subcommand, which checks a CSV file of bookings and, with -commit,
+// saves its valid rows. It returns the exit code
+func importCommand(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("import", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Usage = func() {
+		fmt.Fprintln(stderr, "Usage: web import [flags] bookings.csv")
+		fs.PrintDefaults()
+	}
+	db := addDBFlags(fs)
+	keys := addCipherFlags(fs)
+	mapping := fs.String("map", "", "Comma separated field=column pairs, for columns not named after their field")
+	commit := fs.Bool("commit", false, "Save the valid rows, instead of only reporting them")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if fs.NArg() != 1 || db.missing() {
+		fs.Usage()
+		return 2
+	}
+
+	fieldCipher, err := keys.cipher()
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 2
+	}
+
+	file, err := os.Open(fs.Arg(0))
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+	defer file.Close()
+
+	header, err := csvimport.ReadHeader(file)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+	m := csvimport.GuessMapping(header)
+	if *mapping != "" {
+		given, err := csvimport.ParseMapping(*mapping, header)
+		if err != nil {
+			fmt.Fprintln(stderr, err)
+			return 2
+		}
+		for field, i := range given {
+			m[field] = i
+		}
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+
+	conn, err := driver.ConnectSQL(db.connectionString())
+	if err != nil {
+		fmt.Fprintln(stderr, "Cannot connect to database:", err)
+		return 1
+	}
+	defer conn.SQL.Close()
+
+	app.FieldCipher = fieldCipher
+	repo := dbrepo.NewAuditRepo(dbrepo.NewPostgresRepo(conn.SQL, &app), 0)
+	report, err := csvimport.Run(repo, file, m, *commit)
+	printReport(stdout, header, m, report)
+	if err != nil {
+		fmt.Fprintln(stderr, "Nothing was imported:", err)
+		return 1
+	}
+	if report.Invalid > 0 && !report.Committed {
+		return 1
+	}
+	return 0
+}
+
+// printReport writes the mapping used and the rows with errors, followed by a summary
+func printReport(w io.Writer, header []string, m csvimport.Mapping, report csvimport.Report) {
+	for _, f := range csvimport.Fields {
+		column := "-"
+		if i, ok := m[f.Name]; ok {
+			column = header[i]
+		}
+		fmt.Fprintf(w, "%-12s <- %s\n", f.Name, column)
+	}
+	fmt.Fprintln(w)
+
+	for _, row := range report.Rows {
+		if !row.Valid() {
+			fmt.Fprintf(w, "line %d: %s\n", row.Line, strings.Join(row.Errors, "; "))
+		}
+	}
+
+	switch {
+	case report.Committed:
+		fmt.Fprintf(w, "Imported %d rows, skipped %d rows with errors\n", report.Valid, report.Invalid)
+	default:
+		fmt.Fprintf(w, "Dry run: %d rows can be imported, %d rows have errors\n", report.Valid, report.Invalid)
+	}
+}
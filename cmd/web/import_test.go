@@ -0,0 +1,62 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/go-course/bookings/internal/csvimport"
+	"github.com/go-course/bookings/internal/models"
+)
+
+func TestImportCommand_Usage(t *testing.T) {
+	var testCases = []struct {
+		name string
+		args []string
+	}{
+		{"No file", []string{"-dbname=x", "-dbuser=x", "-dbpass=x"}},
+		{"No database", []string{"bookings.csv"}},
+		{"Unknown flag", []string{"-nope", "bookings.csv"}},
+	}
+
+	for _, testCase := range testCases {
+		var stdout, stderr bytes.Buffer
+		if code := importCommand(testCase.args, &stdout, &stderr); code != 2 {
+			t.Errorf("importCommand for (%s): got exit code %d, want 2", testCase.name, code)
+		}
+		if !strings.Contains(stderr.String(), "Usage: web import") && !strings.Contains(stderr.String(), "not defined") {
+			t.Errorf("importCommand for (%s) should print usage, got %q", testCase.name, stderr.String())
+		}
+	}
+}
+
+func TestPrintReport(t *testing.T) {
+	var out bytes.Buffer
+	header := []string{"Guest", "Arrival"}
+	m := csvimport.Mapping{csvimport.FieldLastName: 0, csvimport.FieldStartDate: 1}
+	report := csvimport.Report{
+		Rows: []models.ImportRow{
+			{Line: 2},
+			{Line: 3, Errors: []string{"Room: Unknown room", "Overlaps line 2"}},
+		},
+		Valid:   1,
+		Invalid: 1,
+	}
+
+	printReport(&out, header, m, report)
+
+	var expected = []string{
+		"last_name    <- Guest\n",
+		"first_name   <- -\n",
+		"line 3: Room: Unknown room; Overlaps line 2\n",
+		"Dry run: 1 rows can be imported, 1 rows have errors\n",
+	}
+	for _, e := range expected {
+		if !strings.Contains(out.String(), e) {
+			t.Errorf("report does not contain %q:\n%s", e, out.String())
+		}
+	}
+	if strings.Contains(out.String(), "line 2:") {
+		t.Error("valid rows should not be listed")
+	}
+}
@@ -5,41 +5,101 @@ import (
 	"flag"
 	"fmt"
 	"os"
+	"strings"
 
 	"log"
 	"net/http"
 	"time"
 
 	"github.com/alexedwards/scs/v2"
+	"github.com/go-course/bookings/internal/channels"
 	"github.com/go-course/bookings/internal/config"
 	"github.com/go-course/bookings/internal/driver"
+	"github.com/go-course/bookings/internal/emails"
 	"github.com/go-course/bookings/internal/handlers"
 	"github.com/go-course/bookings/internal/helpers"
+	"github.com/go-course/bookings/internal/icalsync"
+	"github.com/go-course/bookings/internal/mail"
 	"github.com/go-course/bookings/internal/models"
 	"github.com/go-course/bookings/internal/render"
+	"github.com/go-course/bookings/internal/repository/dbrepo"
+	"github.com/go-course/bookings/internal/scheduler"
+	"github.com/go-course/bookings/internal/sms"
+	"github.com/go-course/bookings/internal/webhooks"
 )
 
 const portNumber = ":8080"
 
 var (
-	app      config.AppConfig
-	session  *scs.SessionManager
-	infoLog  *log.Logger
-	errorLog *log.Logger
+	app        config.AppConfig
+	session    *scs.SessionManager
+	infoLog    *log.Logger
+	errorLog   *log.Logger
+	mailWorker *mail.Worker
+	smsWorker  *sms.Worker
 )
 
 // main is the main application function
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "import" {
+		os.Exit(importCommand(os.Args[2:], os.Stdout, os.Stderr))
+	}
+	if len(os.Args) > 1 && os.Args[1] == "reencrypt" {
+		os.Exit(reencryptCommand(os.Args[2:], os.Stdout, os.Stderr))
+	}
+
 	db, err := run()
 	if err != nil {
 		log.Fatal(err)
 	}
 	defer db.SQL.Close()
 
-	defer close(app.MailChan)
+	fmt.Println("Starting mail worker")
+	go mailWorker.Start(10*time.Second, nil)
+
+	fmt.Println("Starting scheduler")
+	jobs := scheduler.New(handlers.Repo.DB, &app)
+	guestEmails := scheduler.NewGuestEmails(handlers.Repo.DB, app.EmailTemplates)
+	guestEmails.SMS = app.SMSEnabled
+	guestEmails.Register(jobs)
+	scheduler.RegisterOutOfOrder(jobs, handlers.Repo.DB)
+	go jobs.Start(time.Minute, nil)
 
-	fmt.Println("Starting Mail listener")
-	listenForMail()
+	if smsWorker != nil {
+		fmt.Println("Starting sms worker")
+		go smsWorker.Start(10*time.Second, nil)
+	}
+
+	fmt.Println("Starting webhook worker")
+	go webhooks.New(handlers.Repo.DB, &app).Start(10*time.Second, nil)
+
+	if app.ICalSyncEvery > 0 {
+		fmt.Println("Starting iCal importer")
+		importer := icalsync.New(handlers.Repo.DB, &app)
+		go importer.Start(app.ICalSyncEvery, nil)
+	}
+
+	fmt.Println("Starting trash purge")
+	go purgeTrash(dbrepo.NewAuditRepo(handlers.Repo.DB, 0), app.TrashRetention, time.Hour, nil)
+
+	if app.AnonymizeAfterYears > 0 {
+		fmt.Println("Starting reservation anonymizer")
+		go anonymizeOldReservations(dbrepo.NewAuditRepo(handlers.Repo.DB, 0), app.AnonymizeAfterYears, 24*time.Hour, nil)
+	}
+
+	if len(app.Channels) > 0 {
+		var adapters []channels.ChannelAdapter
+		for _, name := range app.Channels {
+			a, err := channels.NewAdapter(name)
+			if err != nil {
+				log.Fatal(err)
+			}
+			adapters = append(adapters, a)
+		}
+		fmt.Println("Starting channel worker")
+		worker := channels.New(handlers.Repo.DB, adapters, &app)
+		go worker.Start(app.ChannelSyncEvery, nil)
+	}
 
 	fmt.Printf("Starting application on Port %s\n", portNumber)
 	srv := &http.Server{
@@ -57,30 +117,69 @@ func run() (*driver.DB, error) {
 	gob.Register(models.Restriction{})
 	gob.Register(models.Reservation{})
 	gob.Register(models.RoomRestriction{})
-	gob.Register(map[string]int{})
 
 	// read flags
 	inProduction := flag.Bool("prod", true, "Application is in production")
 	useCache := flag.Bool("cache", true, "Use template cache")
-	dbHost := flag.String("dbhost", "localhost", "Database Host")
-	dbName := flag.String("dbname", "", "Database Name")
-	dbUser := flag.String("dbuser", "", "Database User")
-	dbPass := flag.String("dbpass", "", "Database Password")
-	dbPort := flag.String("dbport", "5432", "Database port")
-	dbSSL := flag.String("dbssl", "disable", "Database ssl settings (disable, prefer, require)")
+	dbConfig := addDBFlags(flag.CommandLine)
+	cipherConfig := addCipherFlags(flag.CommandLine)
+	icalSyncEvery := flag.Duration("icalsync", 15*time.Minute, "How often to import external iCal feeds (0 disables)")
+	channelNames := flag.String("channels", "", "Comma separated channels to push availability to (mock)")
+	channelSyncEvery := flag.Duration("channelsync", time.Minute, "How often to push availability changes to channels")
+	trashRetention := flag.Duration("trashretention", 30*24*time.Hour, "How long deleted reservations are kept before they are purged")
+	anonymizeAfter := flag.Int("anonymizeafter", 0, "Years after departure that guest details are removed from reservations (0 keeps them)")
+	mailTransport := flag.String("mailtransport", mail.TransportSMTP, "How mail is sent (smtp, file, memory)")
+	mailDir := flag.String("maildir", "./tmp/mail", "Directory the file mail transport writes .eml files to")
+	smtpHost := flag.String("smtphost", "localhost", "SMTP server host")
+	smtpPort := flag.Int("smtpport", 1025, "SMTP server port")
+	smtpUser := flag.String("smtpuser", "", "SMTP user name (empty for no authentication)")
+	smtpPass := flag.String("smtppass", "", "SMTP password")
+	smtpEncryption := flag.String("smtpencryption", mail.EncryptionNone, "SMTP encryption (none, starttls, tls)")
+	mailWorkers := flag.Int("mailworkers", 4, "How many messages are sent at once")
+	mailAttempts := flag.Int("mailattempts", 8, "How many times a message is tried before it is marked failed")
+	smsProvider := flag.String("smsprovider", "", "How text messages are sent (log, http, memory; empty disables them)")
+	smsURL := flag.String("smsurl", "", "URL of the http sms provider's send endpoint")
+	smsToken := flag.String("smstoken", "", "Bearer token for the http sms provider")
+	smsFrom := flag.String("smsfrom", "", "Number or sender id text messages are sent from")
+	smsCountry := flag.String("smscountry", "1", "Country calling code added to phone numbers entered without one")
 
 	flag.Parse()
-	if *dbName == "" || *dbUser == "" || *dbPass == "" {
+	if dbConfig.missing() {
 		fmt.Println("Missing required flags")
 		os.Exit(1)
 	}
 
-	mailChan := make(chan models.MailData)
-	app.MailChan = mailChan
+	fieldCipher, err := cipherConfig.cipher()
+	if err != nil {
+		return nil, err
+	}
+
+	mailer, err := mail.NewMailer(*mailTransport, mail.SMTPConfig{
+		Host:       *smtpHost,
+		Port:       *smtpPort,
+		Username:   *smtpUser,
+		Password:   *smtpPass,
+		Encryption: *smtpEncryption,
+	}, *mailDir)
+	if err != nil {
+		return nil, err
+	}
 
 	// Change this to true when in production
 	app.InProduction = *inProduction
 	app.UseCache = *useCache
+	app.ICalSyncEvery = *icalSyncEvery
+	app.ChannelSyncEvery = *channelSyncEvery
+	app.TrashRetention = *trashRetention
+	app.AnonymizeAfterYears = *anonymizeAfter
+	app.FieldCipher = fieldCipher
+	app.SMSEnabled = *smsProvider != ""
+	app.SMSCountryCode = *smsCountry
+	for _, name := range strings.Split(*channelNames, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			app.Channels = append(app.Channels, name)
+		}
+	}
 
 	infoLog = log.New(os.Stdout, "INFO\t", log.Ldate|log.Ltime)
 	app.InfoLog = infoLog
@@ -98,16 +197,7 @@ func run() (*driver.DB, error) {
 
 	// connect to database
 	log.Println("Connecting to database ...")
-	connectionString := fmt.Sprintf(
-		"host=%s port=%s dbname=%s user=%s password=%s sslmode=%s",
-		*dbHost,
-		*dbPort,
-		*dbName,
-		*dbUser,
-		*dbPass,
-		*dbSSL,
-	)
-	db, err := driver.ConnectSQL(connectionString)
+	db, err := driver.ConnectSQL(dbConfig.connectionString())
 	if err != nil {
 		log.Fatal("Cannot connect to database! Dying")
 	}
@@ -120,8 +210,32 @@ func run() (*driver.DB, error) {
 
 	app.TemplateCache = tc
 
+	app.EmailTemplates, err = emails.New("./email-templates", app.UseCache)
+	if err != nil {
+		return nil, err
+	}
+
 	repo := handlers.NewRepo(&app, db)
 	handlers.NewHandlers(repo)
+
+	mailWorker = mail.New(repo.DB, mailer, &app)
+	if *mailWorkers > 0 {
+		mailWorker.Workers = *mailWorkers
+	}
+	if *mailAttempts > 0 {
+		mailWorker.MaxAttempts = *mailAttempts
+	}
+	if app.SMSEnabled {
+		sender, err := sms.NewSender(*smsProvider, sms.HTTPConfig{
+			URL:   *smsURL,
+			Token: *smsToken,
+			From:  *smsFrom,
+		}, infoLog)
+		if err != nil {
+			return nil, err
+		}
+		smsWorker = sms.New(repo.DB, sender, &app)
+	}
 	render.NewRender(&app)
 	helpers.NewHelpers(&app)
 
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"time"
+)
+
+// trashPurger permanently deletes reservations from the trash
+type trashPurger interface {
+	PurgeDeletedReservations(before time.Time) (int, error)
+}
+
+// purgeTrash purges reservations deleted more than retention ago, now and then every interval, until stop is closed
+func purgeTrash(db trashPurger, retention, interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		n, err := db.PurgeDeletedReservations(time.Now().Add(-retention))
+		if err != nil {
+			errorLog.Println(err)
+		} else if n > 0 {
+			infoLog.Printf("purged %d reservations from the trash", n)
+		}
+
+		select {
+		case <-ticker.C:
+		case <-stop:
+			return
+		}
+	}
+}
+
+// reservationAnonymizer removes the personal details of old reservations
+type reservationAnonymizer interface {
+	AnonymizeReservationsBefore(before time.Time) (int, error)
+}
+
+// anonymizeOldReservations anonymizes reservations that ended more than years ago, now and then every
+// interval, until stop is closed
+func anonymizeOldReservations(db reservationAnonymizer, years int, interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		n, err := db.AnonymizeReservationsBefore(time.Now().AddDate(-years, 0, 0))
+		if err != nil {
+			errorLog.Println(err)
+		} else if n > 0 {
+			infoLog.Printf("anonymized %d reservations", n)
+		}
+
+		select {
+		case <-ticker.C:
+		case <-stop:
+			return
+		}
+	}
+}
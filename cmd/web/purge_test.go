@@ -0,0 +1,86 @@
+package main
+
+import (
+	"io"
+	"log"
+	"testing"
+	"time"
+)
+
+type fakePurger struct {
+	before chan time.Time
+}
+
+func (f *fakePurger) PurgeDeletedReservations(before time.Time) (int, error) {
+	f.before <- before
+	return 1, nil
+}
+
+func TestPurgeTrash(t *testing.T) {
+	infoLog = log.New(io.Discard, "", 0)
+	errorLog = log.New(io.Discard, "", 0)
+
+	f := &fakePurger{before: make(chan time.Time, 100)}
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		purgeTrash(f, 48*time.Hour, time.Millisecond, stop)
+		close(done)
+	}()
+
+	for i := 0; i < 2; i++ {
+		select {
+		case before := <-f.before:
+			if age := time.Since(before); age < 48*time.Hour || age > 49*time.Hour {
+				t.Errorf("purged reservations deleted before %s, want 48 hours ago", before)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("trash was not purged")
+		}
+	}
+
+	close(stop)
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Error("purgeTrash did not stop")
+	}
+}
+
+type fakeAnonymizer struct {
+	before chan time.Time
+}
+
+func (f *fakeAnonymizer) AnonymizeReservationsBefore(before time.Time) (int, error) {
+	f.before <- before
+	return 1, nil
+}
+
+func TestAnonymizeOldReservations(t *testing.T) {
+	infoLog = log.New(io.Discard, "", 0)
+	errorLog = log.New(io.Discard, "", 0)
+
+	f := &fakeAnonymizer{before: make(chan time.Time, 100)}
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		anonymizeOldReservations(f, 3, time.Millisecond, stop)
+		close(done)
+	}()
+
+	select {
+	case before := <-f.before:
+		if want := time.Now().AddDate(-3, 0, 0); before.Sub(want) > time.Minute || want.Sub(before) > time.Minute {
+			t.Errorf("anonymized reservations that ended before %s, want %s", before, want)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("reservations were not anonymized")
+	}
+
+	close(stop)
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Error("anonymizeOldReservations did not stop")
+	}
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"encoding/base64"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+
+	"github.com/go-course/bookings/internal/driver"
+	"github.com/go-course/bookings/internal/fieldcrypt"
+	"github.com/go-course/bookings/internal/repository/dbrepo"
+)
+
+// cipherFlags holds the flags of the encryption of guest contact details
+type cipherFlags struct {
+	keys, indexKey *string
+}
+
+// addCipherFlags registers the encryption flags on fs
+func addCipherFlags(fs *flag.FlagSet) *cipherFlags {
+	return &cipherFlags{
+		keys:     fs.String("encryptionkeys", "", "Comma separated id:base64 AES keys for guest contact details, the current key first (empty keeps them in plain text)"),
+		indexKey: fs.String("blindindexkey", "", "Base64 secret of the indexes used to find encrypted email addresses and phone numbers"),
+	}
+}
+
+// cipher returns the cipher the flags describe, nil if no keys are set
+func (f *cipherFlags) cipher() (*fieldcrypt.Cipher, error) {
+	if *f.keys == "" {
+		return nil, nil
+	}
+	keys, err := fieldcrypt.ParseKeys(*f.keys)
+	if err != nil {
+		return nil, err
+	}
+	indexKey, err := base64.StdEncoding.DecodeString(*f.indexKey)
+	if err != nil {
+		return nil, fmt.Errorf("blind index key: %w", err)
+	}
+	return fieldcrypt.New(keys, indexKey)
+}
+
+// reencryptCommand runs the reencrypt subcommand, which encrypts the contact details of existing reservations
+// and guests under the current key. To rotate keys, put the new key first and keep the old ones until it has run.
+// It returns the exit code
+func reencryptCommand(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("reencrypt", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Usage = func() {
+		fmt.Fprintln(stderr, "Usage: web reencrypt [flags]")
+		fs.PrintDefaults()
+	}
+	db := addDBFlags(fs)
+	keys := addCipherFlags(fs)
+	batch := fs.Int("batch", 500, "Reservations or guests updated per transaction")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if fs.NArg() != 0 || db.missing() || *keys.keys == "" || *batch < 1 {
+		fs.Usage()
+		return 2
+	}
+
+	c, err := keys.cipher()
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 2
+	}
+
+	conn, err := driver.ConnectSQL(db.connectionString())
+	if err != nil {
+		fmt.Fprintln(stderr, "Cannot connect to database:", err)
+		return 1
+	}
+	defer conn.SQL.Close()
+
+	app.FieldCipher = c
+	repo := dbrepo.NewPostgresRepo(conn.SQL, &app)
+	n, err := repo.ReencryptReservations(*batch)
+	fmt.Fprintf(stdout, "Re-encrypted %d reservations\n", n)
+	if err == nil {
+		n, err = repo.ReencryptGuests(*batch)
+		fmt.Fprintf(stdout, "Re-encrypted %d guests\n", n)
+	}
+	if errors.Is(err, fieldcrypt.ErrUnknownKey) {
+		fmt.Fprintln(stderr, "Re-encryption stopped, add the old key after the new one:", err)
+		return 1
+	}
+	if err != nil {
+		fmt.Fprintln(stderr, "Re-encryption stopped:", err)
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"strings"
+	"testing"
+)
+
+// key32 is a base64 encoded 32 byte key
+var key32 = strings.Repeat("A", 43) + "="
+
+func TestReencryptCommand_Usage(t *testing.T) {
+	db := []string{"-dbname=x", "-dbuser=x", "-dbpass=x"}
+	var testCases = []struct {
+		name string
+		args []string
+	}{
+		{"No keys", db},
+		{"No database", []string{"-encryptionkeys=k1:" + key32}},
+		{"Extra argument", append(db, "-encryptionkeys=k1:"+key32, "reservations")},
+		{"Unknown flag", []string{"-nope"}},
+	}
+
+	for _, testCase := range testCases {
+		var stdout, stderr bytes.Buffer
+		if code := reencryptCommand(testCase.args, &stdout, &stderr); code != 2 {
+			t.Errorf("reencryptCommand for (%s): got exit code %d, want 2", testCase.name, code)
+		}
+		if !strings.Contains(stderr.String(), "Usage: web reencrypt") && !strings.Contains(stderr.String(), "not defined") {
+			t.Errorf("reencryptCommand for (%s) should print usage, got %q", testCase.name, stderr.String())
+		}
+	}
+}
+
+func TestReencryptCommand_InvalidKeys(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	args := []string{"-dbname=x", "-dbuser=x", "-dbpass=x", "-encryptionkeys=k1:c2hvcnQ=", "-blindindexkey=" + key32}
+	if code := reencryptCommand(args, &stdout, &stderr); code != 2 {
+		t.Errorf("got exit code %d, want 2", code)
+	}
+	if !strings.Contains(stderr.String(), "key k1") {
+		t.Errorf("should report the invalid key, got %q", stderr.String())
+	}
+}
+
+func TestCipherFlags(t *testing.T) {
+	var testCases = []struct {
+		name      string
+		args      []string
+		expectNil bool
+		expectErr bool
+	}{
+		{"No keys", nil, true, false},
+		{"Keys", []string{"-encryptionkeys=k2:" + key32 + ",k1:" + key32, "-blindindexkey=" + key32}, false, false},
+		{"No blind index key", []string{"-encryptionkeys=k1:" + key32}, true, true},
+		{"Invalid blind index key", []string{"-encryptionkeys=k1:" + key32, "-blindindexkey=!"}, true, true},
+		{"Invalid key", []string{"-encryptionkeys=k1", "-blindindexkey=" + key32}, true, true},
+	}
+
+	for _, testCase := range testCases {
+		fs := flag.NewFlagSet("test", flag.ContinueOnError)
+		f := addCipherFlags(fs)
+		if err := fs.Parse(testCase.args); err != nil {
+			t.Fatal(err)
+		}
+
+		c, err := f.cipher()
+		if (err != nil) != testCase.expectErr {
+			t.Errorf("cipher for (%s): got error %v", testCase.name, err)
+		}
+		if (c == nil) != testCase.expectNil {
+			t.Errorf("cipher for (%s): got %v", testCase.name, c)
+		}
+	}
+}
@@ -36,18 +36,84 @@ func routes(app *config.AppConfig) http.Handler {
 	mux.Post("/user/login", handlers.Repo.PostShowLogin)
 	mux.Get("/user/logout", handlers.Repo.Logout)
 
+	mux.Get("/ical/{token}/all.ics", handlers.Repo.CalendarFeedAll)
+	mux.Get("/ical/{token}/rooms/{id}.ics", handlers.Repo.CalendarFeedRoom)
+
 	mux.Route("/admin", func(mux chi.Router) {
 		// mux.Use(Auth)
 		mux.Get("/dashboard", handlers.Repo.AdminDashboard)
 		mux.Get("/reservations-new", handlers.Repo.AdminNewReservations)
 		mux.Get("/reservations-all", handlers.Repo.AdminAllReservations)
+		mux.Get("/reservations-trash", handlers.Repo.AdminTrash)
+		mux.Post("/reservations-trash/{id}/restore", handlers.Repo.AdminRestoreReservation)
 		mux.Get("/reservations-calendar", handlers.Repo.AdminReservationsCalendar)
 		mux.Post("/reservations-calendar", handlers.Repo.AdminPostReservationsCalendar)
 
 		mux.Get("/reservations/{src}/{id}/show", handlers.Repo.AdminShowReservation)
 		mux.Post("/reservations/{src}/{id}", handlers.Repo.AdminPostShowReservation)
-		mux.Get("/process-reservation/{src}/{id}/do", handlers.Repo.AdminProcessReservation)
-		mux.Get("/delete-reservation/{src}/{id}/do", handlers.Repo.AdminDeleteReservation)
+		mux.Post("/reservations/{src}/{id}/status", handlers.Repo.AdminPostReservationStatus)
+		mux.Post("/reservations/{src}/{id}/delete", handlers.Repo.AdminDeleteReservation)
+		mux.Delete("/api/reservations/{id}", handlers.Repo.AdminAPIDeleteReservation)
+
+		mux.Get("/guests", handlers.Repo.AdminGuests)
+		mux.Get("/guests/{id}", handlers.Repo.AdminShowGuest)
+		mux.Post("/guests/{id}", handlers.Repo.AdminPostGuest)
+		mux.Post("/guests/{id}/merge", handlers.Repo.AdminMergeGuest)
+
+		mux.Get("/privacy", handlers.Repo.AdminPrivacy)
+		mux.Post("/privacy/export", handlers.Repo.AdminExportGuestData)
+		mux.Post("/privacy/erase", handlers.Repo.AdminEraseGuestData)
+
+		mux.Get("/front-desk", handlers.Repo.AdminFrontDesk)
+		mux.Get("/front-desk/{id}/check-in", handlers.Repo.AdminCheckIn)
+		mux.Post("/front-desk/{id}/check-in", handlers.Repo.AdminPostCheckIn)
+		mux.Post("/front-desk/{id}/check-out", handlers.Repo.AdminPostCheckOut)
+		mux.Get("/front-desk/{id}/card", handlers.Repo.AdminRegistrationCard)
+
+		mux.Get("/housekeeping", handlers.Repo.AdminHousekeeping)
+		mux.Post("/housekeeping/rooms/{id}/status", handlers.Repo.AdminPostRoomStatus)
+
+		mux.Get("/calendar-feeds", handlers.Repo.AdminCalendarFeeds)
+		mux.Post("/calendar-feeds", handlers.Repo.AdminPostCalendarFeeds)
+
+		mux.Get("/ical-sources", handlers.Repo.AdminICalSources)
+		mux.Post("/ical-sources", handlers.Repo.AdminPostICalSource)
+		mux.Post("/ical-sources/{id}/sync", handlers.Repo.AdminSyncICalSource)
+		mux.Post("/ical-sources/{id}/delete", handlers.Repo.AdminDeleteICalSource)
+
+		mux.Get("/channels", handlers.Repo.AdminChannels)
+		mux.Post("/channels/{name}/retry", handlers.Repo.AdminRetryChannel)
+		mux.Get("/mail", handlers.Repo.AdminMail)
+		mux.Get("/mail/{id}", handlers.Repo.AdminShowMail)
+		mux.Post("/mail/{id}/resend", handlers.Repo.AdminResendMail)
+		mux.Get("/mail/templates/{name}", handlers.Repo.AdminPreviewEmail)
+		mux.Get("/settings", handlers.Repo.AdminSettings)
+		mux.Post("/settings", handlers.Repo.AdminPostSettings)
+		mux.Get("/notifications", handlers.Repo.AdminNotifications)
+		mux.Post("/notifications", handlers.Repo.AdminPostNotifications)
+		mux.Get("/schedules", handlers.Repo.AdminSchedules)
+		mux.Get("/schedules/{name}", handlers.Repo.AdminShowSchedule)
+		mux.Post("/schedules/{name}", handlers.Repo.AdminPostSchedule)
+		mux.Get("/webhooks", handlers.Repo.AdminWebhooks)
+		mux.Post("/webhooks", handlers.Repo.AdminPostWebhook)
+		mux.Get("/webhooks/{id}", handlers.Repo.AdminShowWebhook)
+		mux.Post("/webhooks/{id}", handlers.Repo.AdminPostWebhookUpdate)
+		mux.Post("/webhooks/{id}/delete", handlers.Repo.AdminDeleteWebhook)
+		mux.Get("/webhooks/deliveries/{id}", handlers.Repo.AdminShowWebhookDelivery)
+		mux.Post("/webhooks/deliveries/{id}/redeliver", handlers.Repo.AdminRedeliverWebhook)
+
+		mux.Get("/audit", handlers.Repo.AdminAudit)
+
+		mux.Get("/export/reservations/{format}", handlers.Repo.AdminExportReservations)
+		mux.Get("/export/occupancy/{format}", handlers.Repo.AdminExportOccupancy)
+
+		mux.Get("/reports", handlers.Repo.AdminReports)
+		mux.Get("/reports/{report}", handlers.Repo.AdminReports)
+		mux.Get("/reports/{report}/{format}", handlers.Repo.AdminExportReport)
+
+		mux.Get("/import", handlers.Repo.AdminImport)
+		mux.Post("/import", handlers.Repo.AdminPostImport)
+		mux.Post("/import/run", handlers.Repo.AdminPostImportRun)
 
 	})
 
@@ -0,0 +1,207 @@
+package channels
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"time"
+
+	"github.com/go-course/bookings/internal/config"
+	"github.com/go-course/bookings/internal/models"
+)
+
+// ChannelAdapter connects the property to one channel, such as an online travel agency
+type ChannelAdapter interface {
+	// Name identifies the channel, it keys the channel's place in the outbox
+	Name() string
+	PushAvailability(ctx context.Context, days []models.RoomAvailability) error
+	PushRates(ctx context.Context, rates []Rate) error
+	PullReservations(ctx context.Context, since time.Time) ([]models.Reservation, error)
+}
+
+// Rate is the nightly price of a room on a day, in the smallest unit of the currency
+type Rate struct {
+	RoomID   int
+	Date     time.Time
+	Amount   int64
+	Currency string
+}
+
+// NewAdapter returns the adapter registered under name
+func NewAdapter(name string) (ChannelAdapter, error) {
+	switch name {
+	case "mock":
+		return NewMockAdapter(name), nil
+	}
+	return nil, fmt.Errorf("unknown channel adapter %q", name)
+}
+
+// Store is the part of the repository the worker needs
+type Store interface {
+	ChannelEventsAfter(afterPosition, limit int) ([]models.ChannelEvent, error)
+	RoomAvailabilityByDate(roomID int, start, end time.Time) ([]models.RoomAvailability, error)
+	GetChannelCursor(channel string) (models.ChannelCursor, error)
+	UpdateChannelCursor(c models.ChannelCursor) error
+}
+
+// Worker sends the changes recorded in the outbox to every channel, backing off channels that fail
+type Worker struct {
+	Store      Store
+	Adapters   []ChannelAdapter
+	BatchSize  int
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
+	InfoLog    *log.Logger
+	ErrorLog   *log.Logger
+	now        func() time.Time
+}
+
+// New creates a worker using the loggers of the app config
+func New(store Store, adapters []ChannelAdapter, a *config.AppConfig) *Worker {
+	return &Worker{
+		Store:      store,
+		Adapters:   adapters,
+		BatchSize:  100,
+		MinBackoff: 30 * time.Second,
+		MaxBackoff: time.Hour,
+		InfoLog:    a.InfoLog,
+		ErrorLog:   a.ErrorLog,
+		now:        time.Now,
+	}
+}
+
+// Start delivers to all channels now and then every interval, until stop is closed
+func (w *Worker) Start(interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		w.DeliverAll()
+		select {
+		case <-ticker.C:
+		case <-stop:
+			return
+		}
+	}
+}
+
+// DeliverAll delivers pending events to every channel, logging failures
+func (w *Worker) DeliverAll() {
+	for _, a := range w.Adapters {
+		_, _ = w.Deliver(a)
+	}
+}
+
+// Deliver sends a channel the events it has not seen yet and returns how many were delivered.
+// A channel that is backing off is skipped until its next attempt is due
+func (w *Worker) Deliver(a ChannelAdapter) (int, error) {
+	c, err := w.Store.GetChannelCursor(a.Name())
+	if err != nil {
+		w.ErrorLog.Println(err)
+		return 0, err
+	}
+
+	if w.now().Before(c.NextAttemptAt) {
+		return 0, nil
+	}
+
+	delivered := 0
+	for {
+		events, err := w.Store.ChannelEventsAfter(c.LastPosition, w.BatchSize)
+		if err != nil {
+			w.ErrorLog.Println(err)
+			return delivered, err
+		}
+		if len(events) == 0 {
+			break
+		}
+
+		if err := w.push(a, events); err != nil {
+			c.Attempts++
+			c.LastError = err.Error()
+			c.NextAttemptAt = w.now().Add(w.backoff(c.Attempts))
+			w.ErrorLog.Printf("channel %s: attempt %d failed, retrying at %s: %s",
+				a.Name(), c.Attempts, c.NextAttemptAt.Format(time.RFC3339), err)
+			if serr := w.Store.UpdateChannelCursor(c); serr != nil {
+				w.ErrorLog.Println(serr)
+			}
+			return delivered, err
+		}
+
+		c.LastPosition = events[len(events)-1].Position
+		c.Attempts = 0
+		c.LastError = ""
+		c.NextAttemptAt = time.Time{}
+		c.LastSyncedAt = w.now()
+		if err := w.Store.UpdateChannelCursor(c); err != nil {
+			w.ErrorLog.Println(err)
+			return delivered, err
+		}
+		delivered += len(events)
+
+		if len(events) < w.BatchSize {
+			break
+		}
+	}
+
+	if delivered > 0 {
+		w.InfoLog.Printf("channel %s: delivered %d changes", a.Name(), delivered)
+	}
+
+	return delivered, nil
+}
+
+// push sends the current availability of every day touched by events
+func (w *Worker) push(a ChannelAdapter, events []models.ChannelEvent) error {
+	type span struct{ start, end time.Time }
+
+	spans := make(map[int]span)
+	for _, e := range events {
+		s, ok := spans[e.RoomID]
+		if !ok {
+			spans[e.RoomID] = span{e.StartDate, e.EndDate}
+			continue
+		}
+		if e.StartDate.Before(s.start) {
+			s.start = e.StartDate
+		}
+		if e.EndDate.After(s.end) {
+			s.end = e.EndDate
+		}
+		spans[e.RoomID] = s
+	}
+
+	roomIDs := make([]int, 0, len(spans))
+	for id := range spans {
+		roomIDs = append(roomIDs, id)
+	}
+	sort.Ints(roomIDs)
+
+	var days []models.RoomAvailability
+	for _, id := range roomIDs {
+		s := spans[id]
+		d, err := w.Store.RoomAvailabilityByDate(id, s.start, s.end)
+		if err != nil {
+			return err
+		}
+		days = append(days, d...)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	return a.PushAvailability(ctx, days)
+}
+
+// backoff returns how long to wait after the given number of failed attempts
+func (w *Worker) backoff(attempts int) time.Duration {
+	d := w.MinBackoff
+	for i := 1; i < attempts && d < w.MaxBackoff; i++ {
+		d *= 2
+	}
+	if d > w.MaxBackoff {
+		d = w.MaxBackoff
+	}
+	return d
+}
@@ -0,0 +1,248 @@
+package channels
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"testing"
+	"time"
+
+	"github.com/go-course/bookings/internal/models"
+)
+
+// memoryStore keeps restrictions, the outbox and cursors in memory, recording changes like the database trigger does
+type memoryStore struct {
+	restrictions map[int]models.RoomRestriction
+	outbox       []models.ChannelEvent
+	cursors      map[string]models.ChannelCursor
+}
+
+func newMemoryStore() *memoryStore {
+	return &memoryStore{
+		restrictions: make(map[int]models.RoomRestriction),
+		cursors:      make(map[string]models.ChannelCursor),
+	}
+}
+
+func (s *memoryStore) record(action string, rr models.RoomRestriction) {
+	s.outbox = append(s.outbox, models.ChannelEvent{
+		ID:                len(s.outbox) + 1,
+		Position:          len(s.outbox) + 1,
+		Action:            action,
+		RoomID:            rr.RoomID,
+		StartDate:         rr.StartDate,
+		EndDate:           rr.EndDate,
+		RoomRestrictionID: rr.ID,
+	})
+}
+
+func (s *memoryStore) insert(rr models.RoomRestriction) {
+	s.restrictions[rr.ID] = rr
+	s.record("insert", rr)
+}
+
+func (s *memoryStore) delete(id int) {
+	rr := s.restrictions[id]
+	delete(s.restrictions, id)
+	s.record("delete", rr)
+}
+
+func (s *memoryStore) ChannelEventsAfter(afterPosition, limit int) ([]models.ChannelEvent, error) {
+	var events []models.ChannelEvent
+	for _, e := range s.outbox {
+		if e.Position > afterPosition && len(events) < limit {
+			events = append(events, e)
+		}
+	}
+	return events, nil
+}
+
+func (s *memoryStore) RoomAvailabilityByDate(roomID int, start, end time.Time) ([]models.RoomAvailability, error) {
+	var days []models.RoomAvailability
+	for d := start; d.Before(end); d = d.AddDate(0, 0, 1) {
+		available := true
+		for _, rr := range s.restrictions {
+			if rr.RoomID == roomID && !rr.StartDate.After(d) && rr.EndDate.After(d) {
+				available = false
+			}
+		}
+		days = append(days, models.RoomAvailability{RoomID: roomID, Date: d, Available: available})
+	}
+	return days, nil
+}
+
+func (s *memoryStore) GetChannelCursor(channel string) (models.ChannelCursor, error) {
+	c, ok := s.cursors[channel]
+	if !ok {
+		c = models.ChannelCursor{Channel: channel}
+		s.cursors[channel] = c
+	}
+	return c, nil
+}
+
+func (s *memoryStore) UpdateChannelCursor(c models.ChannelCursor) error {
+	s.cursors[c.Channel] = c
+	return nil
+}
+
+// clock is a settable time source
+type clock struct {
+	t time.Time
+}
+
+func (c *clock) now() time.Time {
+	return c.t
+}
+
+func newTestWorker(store Store, c *clock, adapters ...ChannelAdapter) *Worker {
+	return &Worker{
+		Store:      store,
+		Adapters:   adapters,
+		BatchSize:  2,
+		MinBackoff: time.Minute,
+		MaxBackoff: 10 * time.Minute,
+		InfoLog:    log.New(io.Discard, "", 0),
+		ErrorLog:   log.New(io.Discard, "", 0),
+		now:        c.now,
+	}
+}
+
+func day(s string) time.Time {
+	t, _ := time.Parse("2006-01-02", s)
+	return t
+}
+
+func TestWorker_Deliver(t *testing.T) {
+	store := newMemoryStore()
+	store.insert(models.RoomRestriction{ID: 1, RoomID: 1, StartDate: day("2050-01-01"), EndDate: day("2050-01-03")})
+	store.insert(models.RoomRestriction{ID: 2, RoomID: 2, StartDate: day("2050-01-02"), EndDate: day("2050-01-03")})
+	store.insert(models.RoomRestriction{ID: 3, RoomID: 1, StartDate: day("2050-01-05"), EndDate: day("2050-01-06")})
+	store.delete(1)
+
+	c := &clock{t: day("2049-12-01")}
+	mock := NewMockAdapter("mock")
+	w := newTestWorker(store, c, mock)
+
+	n, err := w.Deliver(mock)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 4 {
+		t.Errorf("expected 4 events delivered, got %d", n)
+	}
+	if mock.Pushes() != 2 {
+		t.Errorf("expected 2 batches pushed, got %d", mock.Pushes())
+	}
+
+	var expected = []struct {
+		roomID    int
+		date      string
+		available bool
+	}{
+		{1, "2050-01-01", true},
+		{1, "2050-01-02", true},
+		{1, "2050-01-05", false},
+		{2, "2050-01-02", false},
+	}
+	for _, e := range expected {
+		available, ok := mock.Available(e.roomID, day(e.date))
+		if !ok || available != e.available {
+			t.Errorf("room %d on %s: got available %t (pushed %t), want %t", e.roomID, e.date, available, ok, e.available)
+		}
+	}
+
+	cursor := store.cursors["mock"]
+	if cursor.LastPosition != 4 || !cursor.LastSyncedAt.Equal(c.t) {
+		t.Errorf("cursor not advanced: %+v", cursor)
+	}
+
+	// nothing new, nothing pushed
+	n, _ = w.Deliver(mock)
+	if n != 0 || mock.Pushes() != 2 {
+		t.Errorf("expected no delivery without new events, got %d", n)
+	}
+}
+
+func TestWorker_DeliverBacksOff(t *testing.T) {
+	store := newMemoryStore()
+	store.insert(models.RoomRestriction{ID: 1, RoomID: 1, StartDate: day("2050-01-01"), EndDate: day("2050-01-02")})
+
+	c := &clock{t: day("2049-12-01")}
+	mock := NewMockAdapter("mock")
+	mock.SetError(errors.New("channel unavailable"))
+	w := newTestWorker(store, c, mock)
+
+	var expected = []time.Duration{time.Minute, 2 * time.Minute, 4 * time.Minute, 8 * time.Minute, 10 * time.Minute}
+	for i, wait := range expected {
+		if _, err := w.Deliver(mock); err == nil {
+			t.Fatalf("attempt %d: expected error", i+1)
+		}
+		cursor := store.cursors["mock"]
+		if cursor.Attempts != i+1 || cursor.LastError != "channel unavailable" || cursor.LastPosition != 0 {
+			t.Errorf("attempt %d: wrong cursor %+v", i+1, cursor)
+		}
+		if got := cursor.NextAttemptAt.Sub(c.t); got != wait {
+			t.Errorf("attempt %d: got backoff %s, want %s", i+1, got, wait)
+		}
+
+		// not retried before the backoff is over
+		c.t = c.t.Add(wait - time.Second)
+		if n, err := w.Deliver(mock); n != 0 || err != nil || store.cursors["mock"].Attempts != i+1 {
+			t.Errorf("attempt %d: retried during backoff", i+1)
+		}
+		c.t = c.t.Add(time.Second)
+	}
+
+	mock.SetError(nil)
+	n, err := w.Deliver(mock)
+	if err != nil || n != 1 {
+		t.Fatalf("expected recovery, got %d, %v", n, err)
+	}
+	cursor := store.cursors["mock"]
+	if cursor.Attempts != 0 || cursor.LastError != "" || !cursor.NextAttemptAt.IsZero() {
+		t.Errorf("cursor not reset after success: %+v", cursor)
+	}
+}
+
+func TestWorker_DeliverAllKeepsChannelsApart(t *testing.T) {
+	store := newMemoryStore()
+	store.insert(models.RoomRestriction{ID: 1, RoomID: 1, StartDate: day("2050-01-01"), EndDate: day("2050-01-02")})
+
+	c := &clock{t: day("2049-12-01")}
+	up := NewMockAdapter("up")
+	down := NewMockAdapter("down")
+	down.SetError(errors.New("channel unavailable"))
+
+	newTestWorker(store, c, down, up).DeliverAll()
+
+	if store.cursors["up"].LastPosition != 1 {
+		t.Error("a failing channel should not hold back the others")
+	}
+	if store.cursors["down"].LastPosition != 0 || store.cursors["down"].Attempts != 1 {
+		t.Errorf("failing channel: wrong cursor %+v", store.cursors["down"])
+	}
+}
+
+func TestMockAdapter_PullReservations(t *testing.T) {
+	mock := NewMockAdapter("mock")
+	mock.AddReservation(models.Reservation{ID: 1, CreatedAt: day("2050-01-01")})
+	mock.AddReservation(models.Reservation{ID: 2, CreatedAt: day("2050-01-03")})
+
+	res, err := mock.PullReservations(context.Background(), day("2050-01-02"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res) != 1 || res[0].ID != 2 {
+		t.Errorf("expected reservation 2 only, got %+v", res)
+	}
+}
+
+func TestNewAdapter(t *testing.T) {
+	if a, err := NewAdapter("mock"); err != nil || a.Name() != "mock" {
+		t.Errorf("expected mock adapter, got %v, %v", a, err)
+	}
+	if _, err := NewAdapter("unknown"); err == nil {
+		t.Error("expected error for unknown adapter")
+	}
+}
@@ -0,0 +1,119 @@
+package channels
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/go-course/bookings/internal/models"
+)
+
+// MockAdapter is a channel that keeps what it is sent in memory, for development and tests
+type MockAdapter struct {
+	name         string
+	mu           sync.Mutex
+	err          error
+	availability map[int]map[string]bool
+	rates        []Rate
+	reservations []models.Reservation
+	pushes       int
+}
+
+// NewMockAdapter creates an empty mock channel
+func NewMockAdapter(name string) *MockAdapter {
+	return &MockAdapter{
+		name:         name,
+		availability: make(map[int]map[string]bool),
+	}
+}
+
+// Name returns the name of the channel
+func (m *MockAdapter) Name() string {
+	return m.name
+}
+
+// PushAvailability stores the availability of each day
+func (m *MockAdapter) PushAvailability(ctx context.Context, days []models.RoomAvailability) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.err != nil {
+		return m.err
+	}
+
+	for _, d := range days {
+		if m.availability[d.RoomID] == nil {
+			m.availability[d.RoomID] = make(map[string]bool)
+		}
+		m.availability[d.RoomID][d.Date.Format("2006-01-02")] = d.Available
+	}
+	m.pushes++
+
+	return nil
+}
+
+// PushRates stores the rates
+func (m *MockAdapter) PushRates(ctx context.Context, rates []Rate) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.err != nil {
+		return m.err
+	}
+
+	m.rates = append(m.rates, rates...)
+
+	return nil
+}
+
+// PullReservations returns the reservations added with AddReservation that were made since the given time
+func (m *MockAdapter) PullReservations(ctx context.Context, since time.Time) ([]models.Reservation, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.err != nil {
+		return nil, m.err
+	}
+
+	var reservations []models.Reservation
+	for _, r := range m.reservations {
+		if !r.CreatedAt.Before(since) {
+			reservations = append(reservations, r)
+		}
+	}
+
+	return reservations, nil
+}
+
+// AddReservation makes a reservation on the channel
+func (m *MockAdapter) AddReservation(r models.Reservation) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.reservations = append(m.reservations, r)
+}
+
+// SetError makes every call fail with err, or succeed again when err is nil
+func (m *MockAdapter) SetError(err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.err = err
+}
+
+// Available reports the last availability pushed for a room on a day, and whether any was pushed
+func (m *MockAdapter) Available(roomID int, date time.Time) (available, ok bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	available, ok = m.availability[roomID][date.Format("2006-01-02")]
+	return available, ok
+}
+
+// Pushes returns how many availability pushes succeeded
+func (m *MockAdapter) Pushes() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.pushes
+}
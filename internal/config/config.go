@@ -3,18 +3,30 @@ package config
 import (
 	"html/template"
 	"log"
+	"time"
 
 	"github.com/alexedwards/scs/v2"
-	"github.com/go-course/bookings/internal/models"
+	"github.com/go-course/bookings/internal/emails"
+	"github.com/go-course/bookings/internal/fieldcrypt"
 )
 
 // AppConfig holds the application config
 type AppConfig struct {
-	UseCache      bool
-	TemplateCache map[string]*template.Template
-	InfoLog       *log.Logger
-	ErrorLog      *log.Logger
-	InProduction  bool
-	Session       *scs.SessionManager
-	MailChan      chan models.MailData
+	UseCache            bool
+	TemplateCache       map[string]*template.Template
+	InfoLog             *log.Logger
+	ErrorLog            *log.Logger
+	InProduction        bool
+	Session             *scs.SessionManager
+	ICalSyncEvery       time.Duration
+	Channels            []string
+	ChannelSyncEvery    time.Duration
+	TrashRetention      time.Duration
+	AnonymizeAfterYears int
+	FieldCipher         *fieldcrypt.Cipher
+	EmailTemplates      *emails.Templates
+	// SMSEnabled is whether guests can opt in to text messages
+	SMSEnabled bool
+	// SMSCountryCode is the calling code of phone numbers entered without one
+	SMSCountryCode string
 }
@@ -0,0 +1,355 @@
+// Package csvimport loads historical reservations and owner blocks from CSV files. Columns are mapped
+// to fields, every row is validated and checked for availability, and the valid rows are either only
+// reported, in a dry run, or saved together in one transaction
+package csvimport
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-course/bookings/internal/forms"
+	"github.com/go-course/bookings/internal/models"
+)
+
+// Fields that columns can be mapped to
+const (
+	FieldType      = "type"
+	FieldFirstName = "first_name"
+	FieldLastName  = "last_name"
+	FieldEmail     = "email"
+	FieldPhone     = "phone"
+	FieldStartDate = "start_date"
+	FieldEndDate   = "end_date"
+	FieldRoom      = "room"
+	FieldStatus    = "status"
+)
+
+// Row types. Rows without a type are reservations
+const (
+	TypeReservation = "reservation"
+	TypeBlock       = "block"
+)
+
+// Field describes a field columns can be mapped to
+type Field struct {
+	Name  string
+	Label string
+	Help  string
+}
+
+// Fields lists the fields in the order they are shown
+var Fields = []Field{
+	{FieldType, "Type", "reservation or block, reservation when empty"},
+	{FieldFirstName, "First Name", "required for reservations"},
+	{FieldLastName, "Last Name", "required for reservations"},
+	{FieldEmail, "Email", "required for reservations"},
+	{FieldPhone, "Phone", ""},
+	{FieldStartDate, "Arrival", "required, as 2006-01-02"},
+	{FieldEndDate, "Departure", "required for reservations, blocks default to one night"},
+	{FieldRoom, "Room", "required, room id or name"},
+	{FieldStatus, "Status", "confirmed when empty"},
+}
+
+// aliases are other column names recognized by GuessMapping
+var aliases = map[string]string{
+	"firstname":    FieldFirstName,
+	"first":        FieldFirstName,
+	"lastname":     FieldLastName,
+	"last":         FieldLastName,
+	"surname":      FieldLastName,
+	"mail":         FieldEmail,
+	"emailaddress": FieldEmail,
+	"telephone":    FieldPhone,
+	"tel":          FieldPhone,
+	"arrival":      FieldStartDate,
+	"checkin":      FieldStartDate,
+	"from":         FieldStartDate,
+	"start":        FieldStartDate,
+	"startdate":    FieldStartDate,
+	"departure":    FieldEndDate,
+	"checkout":     FieldEndDate,
+	"to":           FieldEndDate,
+	"end":          FieldEndDate,
+	"enddate":      FieldEndDate,
+	"roomid":       FieldRoom,
+	"roomname":     FieldRoom,
+	"kind":         FieldType,
+}
+
+// Mapping maps field names to zero based column indexes. Unmapped fields are left empty
+type Mapping map[string]int
+
+// Store is what an import needs from the database
+type Store interface {
+	AllRooms() ([]models.Room, error)
+	SearchAvailabilityByDatesByRoomID(start, end time.Time, roomID int) (bool, error)
+	ImportBookings(rows []models.ImportRow) error
+}
+
+// Report is the outcome of an import
+type Report struct {
+	Rows      []models.ImportRow
+	Valid     int
+	Invalid   int
+	Committed bool
+}
+
+// ReadHeader returns the column names in the first line of r
+func ReadHeader(r io.Reader) ([]string, error) {
+	header, err := csv.NewReader(r).Read()
+	if err == io.EOF {
+		return nil, errors.New("the file is empty")
+	}
+	return header, err
+}
+
+// GuessMapping maps the columns of header named after a field, or one of its usual names
+func GuessMapping(header []string) Mapping {
+	m := make(Mapping)
+	for i, column := range header {
+		key := strings.Map(func(r rune) rune {
+			if r == ' ' || r == '_' || r == '-' {
+				return -1
+			}
+			return r
+		}, strings.ToLower(strings.TrimSpace(column)))
+
+		name := aliases[key]
+		for _, f := range Fields {
+			if strings.ReplaceAll(f.Name, "_", "") == key {
+				name = f.Name
+			}
+		}
+		if _, taken := m[name]; name != "" && !taken {
+			m[name] = i
+		}
+	}
+	return m
+}
+
+// ParseMapping reads a mapping of field=column pairs, where column is a column name of header
+func ParseMapping(s string, header []string) (Mapping, error) {
+	m := make(Mapping)
+	for _, pair := range strings.Split(s, ",") {
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+		field, column, ok := strings.Cut(pair, "=")
+		field, column = strings.TrimSpace(field), strings.TrimSpace(column)
+		if !ok || !validField(field) {
+			return nil, fmt.Errorf("invalid mapping %q", pair)
+		}
+		i := indexOf(header, column)
+		if i < 0 {
+			return nil, fmt.Errorf("no column named %q", column)
+		}
+		m[field] = i
+	}
+	return m, nil
+}
+
+// Run reads the rows of r, skipping its header, with the columns mapped by m, and checks them.
+// When commit is true the valid rows are saved, all or none
+func Run(store Store, r io.Reader, m Mapping, commit bool) (Report, error) {
+	var report Report
+
+	rooms, err := store.AllRooms()
+	if err != nil {
+		return report, err
+	}
+
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	if _, err := cr.Read(); err != nil {
+		if err == io.EOF {
+			return report, errors.New("the file is empty")
+		}
+		return report, err
+	}
+
+	for line := 2; ; line++ {
+		record, err := cr.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return report, err
+		}
+		report.Rows = append(report.Rows, parseRow(line, record, m, rooms))
+	}
+
+	if err := checkAvailability(store, report.Rows); err != nil {
+		return report, err
+	}
+
+	for _, row := range report.Rows {
+		if row.Valid() {
+			report.Valid++
+		} else {
+			report.Invalid++
+		}
+	}
+
+	if commit && report.Valid > 0 {
+		if err := store.ImportBookings(report.Rows); err != nil {
+			return report, err
+		}
+		report.Committed = true
+	}
+
+	return report, nil
+}
+
+// parseRow validates a record with the rules of the reservation form
+func parseRow(line int, record []string, m Mapping, rooms []models.Room) models.ImportRow {
+	values := url.Values{}
+	for field, i := range m {
+		if i < len(record) {
+			values.Set(field, strings.TrimSpace(record[i]))
+		}
+	}
+
+	row := models.ImportRow{Line: line}
+	form := forms.New(values)
+
+	switch strings.ToLower(form.Get(FieldType)) {
+	case "", TypeReservation:
+		form.Required(FieldFirstName, FieldLastName, FieldEmail, FieldStartDate, FieldEndDate, FieldRoom)
+		if form.Has(FieldFirstName) {
+			form.MinLength(FieldFirstName, 3)
+		}
+		if form.Has(FieldEmail) {
+			form.IsEmail(FieldEmail)
+		}
+	case TypeBlock:
+		row.Block = true
+		form.Required(FieldStartDate, FieldRoom)
+	default:
+		form.Errors.Add(FieldType, "Unknown type")
+	}
+
+	res := models.Reservation{
+		FirstName: form.Get(FieldFirstName),
+		LastName:  form.Get(FieldLastName),
+		Email:     form.Get(FieldEmail),
+		Phone:     form.Get(FieldPhone),
+		Status:    strings.ToLower(form.Get(FieldStatus)),
+	}
+
+	if form.Has(FieldStartDate) {
+		start, err := time.Parse("2006-01-02", form.Get(FieldStartDate))
+		if err != nil {
+			form.Errors.Add(FieldStartDate, "Invalid date")
+		}
+		res.StartDate = start
+	}
+	if form.Has(FieldEndDate) {
+		end, err := time.Parse("2006-01-02", form.Get(FieldEndDate))
+		if err != nil {
+			form.Errors.Add(FieldEndDate, "Invalid date")
+		}
+		res.EndDate = end
+	} else if row.Block {
+		res.EndDate = res.StartDate.AddDate(0, 0, 1)
+	}
+	if !res.StartDate.IsZero() && !res.EndDate.IsZero() && !res.EndDate.After(res.StartDate) {
+		form.Errors.Add(FieldEndDate, "Departure must be after arrival")
+	}
+
+	if form.Has(FieldRoom) {
+		room, ok := findRoom(rooms, form.Get(FieldRoom))
+		if !ok {
+			form.Errors.Add(FieldRoom, "Unknown room")
+		}
+		res.RoomID = room.ID
+		res.Room = room
+	}
+
+	if res.Status == "" {
+		res.Status = models.ReservationConfirmed
+	}
+	if !row.Block && !models.ValidReservationStatus(res.Status) {
+		form.Errors.Add(FieldStatus, "Unknown status")
+	}
+
+	for _, f := range Fields {
+		for _, e := range form.Errors[f.Name] {
+			row.Errors = append(row.Errors, fmt.Sprintf("%s: %s", f.Label, e))
+		}
+	}
+
+	row.Reservation = res
+	return row
+}
+
+// checkAvailability marks the valid rows whose room is taken, by an earlier row or in the database
+func checkAvailability(store Store, rows []models.ImportRow) error {
+	for i := range rows {
+		row := &rows[i]
+		if !row.Valid() || !holdsRoom(*row) {
+			continue
+		}
+		res := row.Reservation
+
+		for _, earlier := range rows[:i] {
+			e := earlier.Reservation
+			if earlier.Valid() && holdsRoom(earlier) && e.RoomID == res.RoomID &&
+				e.StartDate.Before(res.EndDate) && e.EndDate.After(res.StartDate) {
+				row.Errors = append(row.Errors, fmt.Sprintf("Overlaps line %d", earlier.Line))
+				break
+			}
+		}
+		if !row.Valid() {
+			continue
+		}
+
+		available, err := store.SearchAvailabilityByDatesByRoomID(res.StartDate, res.EndDate, res.RoomID)
+		if err != nil {
+			return err
+		}
+		if !available {
+			row.Errors = append(row.Errors, "Room is already booked for these dates")
+		}
+	}
+	return nil
+}
+
+// holdsRoom reports whether the row takes its room when imported
+func holdsRoom(row models.ImportRow) bool {
+	return row.Block || row.Reservation.Status != models.ReservationCancelled
+}
+
+// findRoom finds a room by id or by name, ignoring case
+func findRoom(rooms []models.Room, s string) (models.Room, bool) {
+	id, _ := strconv.Atoi(s)
+	for _, room := range rooms {
+		if (id > 0 && room.ID == id) || strings.EqualFold(room.RoomName, s) {
+			return room, true
+		}
+	}
+	return models.Room{}, false
+}
+
+func validField(name string) bool {
+	for _, f := range Fields {
+		if f.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+func indexOf(header []string, column string) int {
+	for i, c := range header {
+		if strings.EqualFold(strings.TrimSpace(c), column) {
+			return i
+		}
+	}
+	return -1
+}
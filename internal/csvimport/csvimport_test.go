@@ -0,0 +1,166 @@
+package csvimport
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-course/bookings/internal/models"
+)
+
+// memoryStore has two rooms, with room 1 booked in the first week of March 2050
+type memoryStore struct {
+	imported []models.ImportRow
+	err      error
+}
+
+func (s *memoryStore) AllRooms() ([]models.Room, error) {
+	return []models.Room{{ID: 1, RoomName: "Generals Quarters"}, {ID: 2, RoomName: "Majors Suite"}}, nil
+}
+
+func (s *memoryStore) SearchAvailabilityByDatesByRoomID(start, end time.Time, roomID int) (bool, error) {
+	bookedFrom, _ := time.Parse("2006-01-02", "2050-03-01")
+	bookedTo, _ := time.Parse("2006-01-02", "2050-03-08")
+	return roomID != 1 || !start.Before(bookedTo) || !end.After(bookedFrom), nil
+}
+
+func (s *memoryStore) ImportBookings(rows []models.ImportRow) error {
+	if s.err != nil {
+		return s.err
+	}
+	s.imported = rows
+	return nil
+}
+
+const testCSV = `Kind,First Name,Surname,E-mail,Phone,Arrival,Departure,Room,Status
+,John,Smith,john@here.com,555,2050-01-01,2050-01-03,Generals Quarters,
+,Jane,Doe,jane@here.com,,2050-01-02,2050-01-04,1,checked-out
+block,,,,,2050-02-01,,majors suite,
+,Al,Short,not-an-email,,2050-13-01,2050-01-01,Penthouse,processed
+,Bob,Booked,bob@here.com,,2050-03-02,2050-03-04,1,
+,Ann,Gone,ann@here.com,,2050-01-02,2050-01-03,1,cancelled
+holiday,,,,,2050-02-01,,2,
+`
+
+func TestGuessMapping(t *testing.T) {
+	header, err := ReadHeader(strings.NewReader(testCSV))
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := GuessMapping(header)
+
+	want := Mapping{
+		FieldType:      0,
+		FieldFirstName: 1,
+		FieldLastName:  2,
+		FieldEmail:     3,
+		FieldPhone:     4,
+		FieldStartDate: 5,
+		FieldEndDate:   6,
+		FieldRoom:      7,
+		FieldStatus:    8,
+	}
+	for field, i := range want {
+		if got, ok := m[field]; !ok || got != i {
+			t.Errorf("%s: got column %d (mapped %v), want %d", field, got, ok, i)
+		}
+	}
+}
+
+func TestParseMapping(t *testing.T) {
+	header := []string{"Guest", "Arrival"}
+
+	m, err := ParseMapping("last_name=guest, start_date=Arrival", header)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m[FieldLastName] != 0 || m[FieldStartDate] != 1 {
+		t.Errorf("wrong mapping: %v", m)
+	}
+
+	for _, s := range []string{"nickname=Guest", "last_name=Name", "last_name"} {
+		if _, err := ParseMapping(s, header); err == nil {
+			t.Errorf("expected error for %q", s)
+		}
+	}
+}
+
+func TestRun(t *testing.T) {
+	header, _ := ReadHeader(strings.NewReader(testCSV))
+	store := &memoryStore{}
+
+	report, err := Run(store, strings.NewReader(testCSV), GuessMapping(header), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if store.imported != nil {
+		t.Error("a dry run should not import anything")
+	}
+	if report.Valid != 3 || report.Invalid != 4 || report.Committed {
+		t.Errorf("got %d valid, %d invalid, committed %v; want 3 valid, 4 invalid", report.Valid, report.Invalid, report.Committed)
+	}
+
+	var testCases = []struct {
+		line   int
+		errors []string
+	}{
+		{2, nil},
+		{3, []string{"Overlaps line 2"}},
+		{4, nil},
+		{5, []string{
+			"First Name: This field must be atleast 3 characters long",
+			"Email: Invalid email address",
+			"Arrival: Invalid date",
+			"Room: Unknown room",
+			"Status: Unknown status",
+		}},
+		{6, []string{"Room is already booked for these dates"}},
+		// cancelled reservations don't take the room
+		{7, nil},
+		{8, []string{"Type: Unknown type"}},
+	}
+
+	for i, testCase := range testCases {
+		row := report.Rows[i]
+		if row.Line != testCase.line {
+			t.Fatalf("row %d: got line %d, want %d", i, row.Line, testCase.line)
+		}
+		if strings.Join(row.Errors, "|") != strings.Join(testCase.errors, "|") {
+			t.Errorf("line %d: got errors %q, want %q", row.Line, row.Errors, testCase.errors)
+		}
+	}
+
+	block := report.Rows[2]
+	if !block.Block || block.Reservation.RoomID != 2 || block.Reservation.EndDate.Sub(block.Reservation.StartDate) != 24*time.Hour {
+		t.Errorf("wrong block: %+v", block)
+	}
+	if report.Rows[0].Reservation.Status != models.ReservationConfirmed {
+		t.Errorf("status should default to confirmed, got %q", report.Rows[0].Reservation.Status)
+	}
+}
+
+func TestRun_Commit(t *testing.T) {
+	header, _ := ReadHeader(strings.NewReader(testCSV))
+	store := &memoryStore{}
+
+	report, err := Run(store, strings.NewReader(testCSV), GuessMapping(header), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !report.Committed || len(store.imported) != len(report.Rows) {
+		t.Errorf("rows were not committed: %+v", report)
+	}
+
+	store.err = errors.New("room taken meanwhile")
+	report, err = Run(store, strings.NewReader(testCSV), GuessMapping(header), true)
+	if err == nil || report.Committed {
+		t.Error("a failed commit should be reported")
+	}
+}
+
+func TestRun_Empty(t *testing.T) {
+	if _, err := Run(&memoryStore{}, strings.NewReader(""), Mapping{}, false); err == nil {
+		t.Error("expected error for an empty file")
+	}
+}
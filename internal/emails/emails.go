@@ -0,0 +1,254 @@
+// Package emails renders the transactional emails from the templates in email-templates. Each email has an
+// HTML part rendered with html/template from <name>.html and a plain text part rendered with text/template
+// from <name>.txt, which also defines the subject. Emails that guests can also get by text message have an
+// SMS part rendered from <name>.sms
+package emails
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	htmltemplate "html/template"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	texttemplate "text/template"
+	"time"
+
+	"github.com/go-course/bookings/internal/models"
+)
+
+// Names of the email templates
+const (
+	Confirmation      = "confirmation"
+	StaffNotification = "staff-notification"
+	Cancellation      = "cancellation"
+	Reminder          = "reminder"
+	FollowUp          = "follow-up"
+	PasswordReset     = "password-reset"
+)
+
+// Names lists the email templates
+var Names = []string{Confirmation, StaffNotification, Cancellation, Reminder, FollowUp, PasswordReset}
+
+// ErrUnknownTemplate is returned when rendering a template that isn't in Names
+var ErrUnknownTemplate = errors.New("unknown email template")
+
+var functions = map[string]interface{}{
+	"humanDate": func(t time.Time) string {
+		return t.Format("2006-01-02")
+	},
+	"formatDate": func(t time.Time, format string) string {
+		return t.Format(format)
+	},
+}
+
+// Data is what the templates are rendered with. Event is the notification event of staff emails
+type Data struct {
+	Reservation models.Reservation
+	User        models.User
+	Event       string
+	// Link is the page the email points to, like the one to reset a password
+	Link string
+}
+
+// Email is a rendered template. SMS is empty for templates without an SMS part
+type Email struct {
+	Name    string
+	Subject string
+	HTML    string
+	Text    string
+	SMS     string
+}
+
+// MailData returns the message sending the email from from to to
+func (e Email) MailData(from, to string) models.MailData {
+	return models.MailData{
+		To:       to,
+		From:     from,
+		Subject:  e.Subject,
+		Content:  e.HTML,
+		Text:     e.Text,
+		Template: e.Name,
+	}
+}
+
+// SMSData returns the text message sending the SMS part to the phone number to
+func (e Email) SMSData(to string) models.SMSData {
+	return models.SMSData{
+		To:       to,
+		Body:     e.SMS,
+		Template: e.Name,
+	}
+}
+
+// view is what the HTML part is rendered with, the subject being used as its title
+type view struct {
+	Data
+	Subject string
+}
+
+// parsed holds the parsed parts of an email template, sms being nil if it has no SMS part
+type parsed struct {
+	html *htmltemplate.Template
+	text *texttemplate.Template
+	sms  *texttemplate.Template
+}
+
+// Templates renders the templates in Dir. With UseCache they are parsed once, otherwise on every render so
+// that changes show up without a restart
+type Templates struct {
+	Dir      string
+	UseCache bool
+
+	mu    sync.Mutex
+	cache map[string]parsed
+}
+
+// New parses the templates in dir, failing if any is missing or invalid
+func New(dir string, useCache bool) (*Templates, error) {
+	t := &Templates{Dir: dir, UseCache: useCache}
+	cache, err := t.parseAll()
+	if err != nil {
+		return nil, err
+	}
+	t.cache = cache
+	return t, nil
+}
+
+// Render renders the named template with data
+func (t *Templates) Render(name string, data Data) (Email, error) {
+	tmpl, err := t.lookup(name)
+	if err != nil {
+		return Email{}, err
+	}
+
+	var subject, text, html bytes.Buffer
+	if err := tmpl.text.ExecuteTemplate(&subject, "subject", data); err != nil {
+		return Email{}, err
+	}
+	if err := tmpl.text.Execute(&text, data); err != nil {
+		return Email{}, err
+	}
+	email := Email{
+		Name:    name,
+		Subject: strings.TrimSpace(subject.String()),
+		Text:    strings.TrimSpace(text.String()) + "\n",
+	}
+	if err := tmpl.html.Execute(&html, view{Data: data, Subject: email.Subject}); err != nil {
+		return Email{}, err
+	}
+	email.HTML = strings.TrimSpace(html.String()) + "\n"
+
+	if tmpl.sms != nil {
+		var sms bytes.Buffer
+		if err := tmpl.sms.Execute(&sms, data); err != nil {
+			return Email{}, err
+		}
+		email.SMS = strings.Join(strings.Fields(sms.String()), " ")
+	}
+
+	return email, nil
+}
+
+// Preview renders the named template with Sample
+func (t *Templates) Preview(name string) (Email, error) {
+	return t.Render(name, Sample())
+}
+
+// lookup returns the parsed template of name, parsing it again unless the cache is used
+func (t *Templates) lookup(name string) (parsed, error) {
+	if !known(name) {
+		return parsed{}, fmt.Errorf("%w: %q", ErrUnknownTemplate, name)
+	}
+	if !t.UseCache {
+		return t.parse(name)
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.cache == nil {
+		cache, err := t.parseAll()
+		if err != nil {
+			return parsed{}, err
+		}
+		t.cache = cache
+	}
+	return t.cache[name], nil
+}
+
+// parseAll parses every template in Names
+func (t *Templates) parseAll() (map[string]parsed, error) {
+	cache := make(map[string]parsed)
+	for _, name := range Names {
+		tmpl, err := t.parse(name)
+		if err != nil {
+			return nil, err
+		}
+		cache[name] = tmpl
+	}
+	return cache, nil
+}
+
+// parse parses both parts of the named template with their layouts
+func (t *Templates) parse(name string) (parsed, error) {
+	html, err := htmltemplate.New(name+".html").Funcs(functions).ParseFiles(
+		filepath.Join(t.Dir, name+".html"),
+		filepath.Join(t.Dir, "base.layout.html"),
+	)
+	if err != nil {
+		return parsed{}, err
+	}
+
+	text, err := texttemplate.New(name+".txt").Funcs(functions).ParseFiles(
+		filepath.Join(t.Dir, name+".txt"),
+		filepath.Join(t.Dir, "base.layout.txt"),
+	)
+	if err != nil {
+		return parsed{}, err
+	}
+	if text.Lookup("subject") == nil {
+		return parsed{}, fmt.Errorf("email template %s.txt doesn't define a subject", name)
+	}
+
+	p := parsed{html: html, text: text}
+	file := filepath.Join(t.Dir, name+".sms")
+	if _, err := os.Stat(file); err == nil {
+		p.sms, err = texttemplate.New(name + ".sms").Funcs(functions).ParseFiles(file)
+		if err != nil {
+			return parsed{}, err
+		}
+	}
+
+	return p, nil
+}
+
+// known reports whether name is one of Names
+func known(name string) bool {
+	for _, n := range Names {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Sample returns the data the templates are previewed with
+func Sample() Data {
+	start := time.Now().AddDate(0, 0, 7).Truncate(24 * time.Hour)
+	return Data{
+		Reservation: models.Reservation{
+			ID:        1,
+			FirstName: "John",
+			LastName:  "Smith",
+			Email:     "john@smith.com",
+			StartDate: start,
+			EndDate:   start.AddDate(0, 0, 3),
+			Room:      models.Room{RoomName: "Generals Quarters"},
+		},
+		User:  models.User{FirstName: "Admin", Email: "admin@admin.com"},
+		Event: models.EventNewBooking,
+		Link:  "http://localhost:8080/user/reset-password?token=sample",
+	}
+}
@@ -0,0 +1,182 @@
+package emails
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-course/bookings/internal/models"
+)
+
+var data = Data{
+	Reservation: models.Reservation{
+		FirstName: "<b>John</b>",
+		LastName:  "O'Brien",
+		StartDate: time.Date(2050, 1, 1, 0, 0, 0, 0, time.UTC),
+		EndDate:   time.Date(2050, 1, 3, 0, 0, 0, 0, time.UTC),
+		Room:      models.Room{RoomName: "Generals Quarters"},
+	},
+}
+
+func TestTemplates_Render(t *testing.T) {
+	templates, err := New("../../email-templates", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	email, err := templates.Render(Confirmation, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if email.Subject != "Reservation Confirmation" {
+		t.Errorf("wrong subject %q", email.Subject)
+	}
+	for _, s := range []string{"<title>Reservation Confirmation</title>", "Dear &lt;b&gt;John&lt;/b&gt;", "from 2050-01-01 to 2050-01-03"} {
+		if !strings.Contains(email.HTML, s) {
+			t.Errorf("the HTML part should contain %q", s)
+		}
+	}
+	if strings.Contains(email.HTML, "<b>John</b>") {
+		t.Error("guest names should be escaped in the HTML part")
+	}
+	for _, s := range []string{"Dear <b>John</b>,", "for room: Generals Quarters", "Fort Smythe"} {
+		if !strings.Contains(email.Text, s) {
+			t.Errorf("the text part should contain %q:\n%s", s, email.Text)
+		}
+	}
+	if strings.Contains(email.Text, "<html") {
+		t.Error("the text part should not contain HTML")
+	}
+
+	staff, err := templates.Render(StaffNotification, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(staff.Text, "by <b>John</b> O'Brien") || !strings.Contains(staff.HTML, "O&#39;Brien") {
+		t.Errorf("wrong staff notification:\n%s", staff.Text)
+	}
+
+	var events = []struct {
+		event   string
+		subject string
+		text    string
+	}{
+		{models.EventNewBooking, "Reservation Notification", "has been made from 2050-01-01 to 2050-01-03"},
+		{models.EventCancellation, "Reservation Cancelled", "from 2050-01-01 to 2050-01-03 has been cancelled"},
+		{models.EventModification, "Reservation Changed", "from 2050-01-01 to 2050-01-03 has been changed"},
+	}
+	for _, e := range events {
+		d := data
+		d.Event = e.event
+		email, err := templates.Render(StaffNotification, d)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if email.Subject != e.subject || !strings.Contains(email.Text, e.text) {
+			t.Errorf("wrong staff notification of %s: %q\n%s", e.event, email.Subject, email.Text)
+		}
+	}
+
+	var guests = []struct {
+		name    string
+		subject string
+		text    string
+	}{
+		{Reminder, "Your stay starts on 2050-01-01", "Check-in is from 3:00 pm on 2050-01-01"},
+		{FollowUp, "Thank you for staying with us", "Thank you for your stay in room Generals Quarters"},
+	}
+	for _, g := range guests {
+		email, err := templates.Render(g.name, data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if email.Subject != g.subject || !strings.Contains(email.Text, g.text) {
+			t.Errorf("wrong %s email: %q\n%s", g.name, email.Subject, email.Text)
+		}
+	}
+
+	var texts = []struct {
+		name string
+		sms  string
+	}{
+		{Confirmation, "your reservation 0 for Generals Quarters from 2050-01-01 to 2050-01-03 is confirmed"},
+		{Reminder, "see you on 2050-01-01! Check-in is from 3:00 pm"},
+		{Cancellation, ""},
+	}
+	for _, text := range texts {
+		email, err := templates.Render(text.name, data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if text.sms == "" && email.SMS != "" || !strings.Contains(email.SMS, text.sms) || strings.Contains(email.SMS, "\n") {
+			t.Errorf("wrong SMS part of %s: %q", text.name, email.SMS)
+		}
+	}
+	if msg := (Email{Name: Reminder, SMS: "See you"}).SMSData("+15555550123"); msg.To != "+15555550123" || msg.Body != "See you" || msg.Template != Reminder {
+		t.Errorf("wrong text message %+v", msg)
+	}
+
+	if _, err := templates.Render("welcome", data); !errors.Is(err, ErrUnknownTemplate) {
+		t.Errorf("expected ErrUnknownTemplate, got %v", err)
+	}
+}
+
+func TestTemplates_Preview(t *testing.T) {
+	templates, err := New("../../email-templates", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, name := range Names {
+		email, err := templates.Preview(name)
+		if err != nil {
+			t.Errorf("can't preview %s: %s", name, err)
+			continue
+		}
+		if email.Subject == "" || email.HTML == "" || email.Text == "" {
+			t.Errorf("preview of %s has an empty part: %+v", name, email)
+		}
+	}
+}
+
+func TestTemplates_Cache(t *testing.T) {
+	dir := t.TempDir()
+	for _, pattern := range []string{"../../email-templates/*.html", "../../email-templates/*.txt"} {
+		files, _ := filepath.Glob(pattern)
+		for _, file := range files {
+			b, _ := os.ReadFile(file)
+			if err := os.WriteFile(filepath.Join(dir, filepath.Base(file)), b, 0o644); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	cached, err := New(dir, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	uncached, err := New(dir, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = os.WriteFile(filepath.Join(dir, "cancellation.txt"), []byte(`{{define "subject"}}Changed{{end}}changed`), 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if email, _ := cached.Render(Cancellation, data); email.Subject != "Reservation Cancelled" {
+		t.Errorf("the cached template should be used, got subject %q", email.Subject)
+	}
+	if email, _ := uncached.Render(Cancellation, data); email.Subject != "Changed" {
+		t.Errorf("the template should be parsed again without the cache, got subject %q", email.Subject)
+	}
+
+	os.Remove(filepath.Join(dir, "reminder.html"))
+	if _, err := New(dir, true); err == nil {
+		t.Error("a missing template should fail New")
+	}
+}
@@ -0,0 +1,97 @@
+// Package export writes tables row by row as CSV or XLSX, so large exports never sit in memory
+package export
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// Formats that exports can be written in
+const (
+	CSV  = "csv"
+	XLSX = "xlsx"
+)
+
+// Writer writes a table one row at a time. Cells can be strings, ints, float64s or times;
+// zero times are written as empty cells. Close must be called once all rows are written
+type Writer interface {
+	WriteRow(cells ...interface{}) error
+	Close() error
+}
+
+// New returns a Writer for format, writing to w. sheet names the worksheet of XLSX files
+func New(format string, w io.Writer, sheet string) (Writer, error) {
+	switch format {
+	case CSV:
+		return NewCSV(w), nil
+	case XLSX:
+		return NewXLSX(w, sheet)
+	}
+	return nil, fmt.Errorf("unknown export format %q", format)
+}
+
+// ContentType returns the media type of format
+func ContentType(format string) string {
+	if format == XLSX {
+		return "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+	}
+	return "text/csv; charset=utf-8"
+}
+
+// ValidFormat reports whether format is a known export format
+func ValidFormat(format string) bool {
+	return format == CSV || format == XLSX
+}
+
+// CSVWriter writes rows as comma separated values
+type CSVWriter struct {
+	w *csv.Writer
+}
+
+// NewCSV returns a CSVWriter writing to w
+func NewCSV(w io.Writer) *CSVWriter {
+	return &CSVWriter{w: csv.NewWriter(w)}
+}
+
+// WriteRow writes one record. Dates are written as 2006-01-02
+func (c *CSVWriter) WriteRow(cells ...interface{}) error {
+	record := make([]string, len(cells))
+	for i, cell := range cells {
+		switch v := cell.(type) {
+		case string:
+			record[i] = safeText(v)
+		case time.Time:
+			if !v.IsZero() {
+				record[i] = v.Format("2006-01-02")
+			}
+		case float64:
+			record[i] = fmt.Sprintf("%.2f", v)
+		default:
+			record[i] = fmt.Sprint(v)
+		}
+	}
+	if err := c.w.Write(record); err != nil {
+		return err
+	}
+	// flush often, so the rows reach the client as they are read
+	c.w.Flush()
+	return c.w.Error()
+}
+
+// Close flushes any buffered rows
+func (c *CSVWriter) Close() error {
+	c.w.Flush()
+	return c.w.Error()
+}
+
+// safeText returns s so that spreadsheets opening a CSV file show it as text: strings starting with a
+// character that makes them a formula, such as a guest name of =HYPERLINK(...), are prefixed with a quote
+func safeText(s string) string {
+	if s != "" && strings.ContainsRune("=+-@\t\r", rune(s[0])) {
+		return "'" + s
+	}
+	return s
+}
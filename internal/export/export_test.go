@@ -0,0 +1,133 @@
+package export
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/csv"
+	"encoding/xml"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCSVWriter(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewCSV(&buf)
+	date, _ := time.Parse("2006-01-02", "2050-01-02")
+
+	w.WriteRow("ID", "Name", "Arrival", "Departure", "Occupancy")
+	w.WriteRow(1, "Smith, Jr", date, time.Time{}, 0.5)
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	records, err := csv.NewReader(&buf).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"1", "Smith, Jr", "2050-01-02", "", "0.50"}
+	if len(records) != 2 || strings.Join(records[1], "|") != strings.Join(want, "|") {
+		t.Errorf("got %q, want %q", records, want)
+	}
+}
+
+func TestXLSXWriter(t *testing.T) {
+	var buf bytes.Buffer
+	w, err := NewXLSX(&buf, "Reservations: 2050/01")
+	if err != nil {
+		t.Fatal(err)
+	}
+	date, _ := time.Parse("2006-01-02", "2050-01-02")
+
+	w.WriteRow("ID", "Name", "Arrival")
+	w.WriteRow(7, `<Smith & "Sons">`, date)
+	w.WriteRow(8, "=HYPERLINK(\"http://evil.example\")", "+15555550123")
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	parts := make(map[string]string)
+	for _, f := range zr.File {
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, _ := io.ReadAll(rc)
+		rc.Close()
+		parts[f.Name] = string(b)
+
+		// every part must be well formed
+		if err := xml.Unmarshal(b, new(interface{})); err != nil {
+			t.Errorf("%s is not valid xml: %s", f.Name, err)
+		}
+	}
+
+	for _, name := range []string{"[Content_Types].xml", "_rels/.rels", "xl/workbook.xml", "xl/styles.xml", "xl/worksheets/sheet1.xml"} {
+		if _, ok := parts[name]; !ok {
+			t.Errorf("workbook is missing %s", name)
+		}
+	}
+	if !strings.Contains(parts["xl/workbook.xml"], `name="Reservations- 2050-01"`) {
+		t.Errorf("sheet name not cleaned: %s", parts["xl/workbook.xml"])
+	}
+
+	sheet := parts["xl/worksheets/sheet1.xml"]
+	var expected = []string{
+		`<c r="A2"><v>7</v></c>`,
+		`<t xml:space="preserve">&lt;Smith &amp; &#34;Sons&#34;&gt;</t>`,
+		// days since 1899-12-30
+		`<c r="C2" s="1"><v>54790</v></c>`,
+		// strings are inline text, never formulas, so they need no quote
+		`<c r="B3" t="inlineStr"><is><t xml:space="preserve">=HYPERLINK(&#34;http://evil.example&#34;)</t></is></c>`,
+		`<c r="C3" t="inlineStr"><is><t xml:space="preserve">+15555550123</t></is></c>`,
+	}
+	for _, e := range expected {
+		if !strings.Contains(sheet, e) {
+			t.Errorf("sheet does not contain %q", e)
+		}
+	}
+}
+
+func TestCSVWriter_Formulas(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewCSV(&buf)
+
+	w.WriteRow("=1+2", "+1 555 0100", "-2", "@SUM(A1)", "\tcmd", "\rcmd", "Smith", -3)
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	records, err := csv.NewReader(&buf).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"'=1+2", "'+1 555 0100", "'-2", "'@SUM(A1)", "'\tcmd", "'\rcmd", "Smith", "-3"}
+	if len(records) != 1 || strings.Join(records[0], "|") != strings.Join(want, "|") {
+		t.Errorf("got %q, want %q", records, want)
+	}
+}
+
+func TestCellRef(t *testing.T) {
+	var testCases = []struct {
+		col  int
+		row  int
+		want string
+	}{
+		{0, 1, "A1"},
+		{25, 2, "Z2"},
+		{26, 3, "AA3"},
+		{701, 4, "ZZ4"},
+		{702, 5, "AAA5"},
+	}
+
+	for _, testCase := range testCases {
+		if got := cellRef(testCase.col, testCase.row); got != testCase.want {
+			t.Errorf("cellRef(%d, %d): got %s, want %s", testCase.col, testCase.row, got, testCase.want)
+		}
+	}
+}
@@ -0,0 +1,188 @@
+// Package fieldcrypt encrypts personal details stored in the database with AES-GCM. Values are stored as
+// "enc:<key id>:<base64 nonce and ciphertext>", so old keys can be kept for reading while rows are
+// re-encrypted under a new one
+package fieldcrypt
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/go-course/bookings/internal/models"
+)
+
+// prefix marks an encrypted value. Values without it are plain text written before encryption was enabled
+const prefix = "enc:"
+
+// ErrUnknownKey is returned when decrypting a value encrypted with a key that is not configured
+var ErrUnknownKey = errors.New("value is encrypted with an unknown key")
+
+// Key is an encryption key and the id stored with the values it encrypts
+type Key struct {
+	ID     string
+	Secret []byte
+}
+
+// Cipher encrypts values with its current key and decrypts values encrypted with any of its keys.
+// A nil Cipher leaves values in plain text
+type Cipher struct {
+	current  string
+	aeads    map[string]cipher.AEAD
+	indexKey []byte
+}
+
+// New returns a Cipher encrypting with the first of keys. indexKey is the secret of the blind index, and
+// is kept apart from the keys so the index survives key rotation
+func New(keys []Key, indexKey []byte) (*Cipher, error) {
+	if len(keys) == 0 {
+		return nil, errors.New("no encryption keys")
+	}
+	if len(indexKey) < 16 {
+		return nil, errors.New("blind index key must be at least 16 bytes")
+	}
+
+	c := &Cipher{
+		current:  keys[0].ID,
+		aeads:    make(map[string]cipher.AEAD),
+		indexKey: indexKey,
+	}
+	for _, k := range keys {
+		if k.ID == "" || strings.Contains(k.ID, ":") {
+			return nil, fmt.Errorf("invalid key id %q", k.ID)
+		}
+		if _, ok := c.aeads[k.ID]; ok {
+			return nil, fmt.Errorf("duplicate key id %q", k.ID)
+		}
+		block, err := aes.NewCipher(k.Secret)
+		if err != nil {
+			return nil, fmt.Errorf("key %s: %w", k.ID, err)
+		}
+		aead, err := cipher.NewGCM(block)
+		if err != nil {
+			return nil, fmt.Errorf("key %s: %w", k.ID, err)
+		}
+		c.aeads[k.ID] = aead
+	}
+
+	return c, nil
+}
+
+// ParseKeys parses comma separated id:base64 pairs, the current key first
+func ParseKeys(s string) ([]Key, error) {
+	var keys []Key
+	for _, pair := range strings.Split(s, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		id, encoded, ok := strings.Cut(pair, ":")
+		if !ok {
+			return nil, fmt.Errorf("key %q is not id:base64", pair)
+		}
+		secret, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return nil, fmt.Errorf("key %s: %w", id, err)
+		}
+		keys = append(keys, Key{ID: id, Secret: secret})
+	}
+	return keys, nil
+}
+
+// Encrypt encrypts s with the current key. Empty values stay empty
+func (c *Cipher) Encrypt(s string) (string, error) {
+	if c == nil || s == "" {
+		return s, nil
+	}
+
+	aead := c.aeads[c.current]
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	sealed := aead.Seal(nonce, nonce, []byte(s), nil)
+
+	return prefix + c.current + ":" + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// Decrypt returns the plain text of s. Values that are not encrypted are returned as they are
+func (c *Cipher) Decrypt(s string) (string, error) {
+	if !Encrypted(s) {
+		return s, nil
+	}
+
+	id, encoded, ok := strings.Cut(strings.TrimPrefix(s, prefix), ":")
+	if !ok {
+		return "", errors.New("malformed encrypted value")
+	}
+	if c == nil {
+		return "", ErrUnknownKey
+	}
+	aead, ok := c.aeads[id]
+	if !ok {
+		return "", fmt.Errorf("%w %q", ErrUnknownKey, id)
+	}
+
+	sealed, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return "", err
+	}
+	if len(sealed) < aead.NonceSize() {
+		return "", errors.New("malformed encrypted value")
+	}
+	plain, err := aead.Open(nil, sealed[:aead.NonceSize()], sealed[aead.NonceSize():], nil)
+	if err != nil {
+		return "", err
+	}
+
+	return string(plain), nil
+}
+
+// Current reports whether s is empty or encrypted with the current key, so needs no re-encryption
+func (c *Cipher) Current(s string) bool {
+	if c == nil || s == "" {
+		return !Encrypted(s)
+	}
+	return strings.HasPrefix(s, prefix+c.current+":")
+}
+
+// BlindIndex returns a keyed hash of the normalized email address, for finding encrypted addresses
+// without decrypting them. It is empty for a nil Cipher or an empty address
+func (c *Cipher) BlindIndex(email string) string {
+	email = models.NormalizeEmail(email)
+	if c == nil || email == "" {
+		return ""
+	}
+
+	return c.index(email)
+}
+
+// PhoneIndex returns a keyed hash of the normalized phone number, for finding encrypted numbers
+// without decrypting them. It is empty for a nil Cipher or a number without digits
+func (c *Cipher) PhoneIndex(phone string) string {
+	phone = models.NormalizePhone(phone)
+	if c == nil || phone == "" {
+		return ""
+	}
+
+	// the prefix keeps a number from sharing the index of an equal email address
+	return c.index("phone:" + phone)
+}
+
+// index returns the keyed hash of s
+func (c *Cipher) index(s string) string {
+	mac := hmac.New(sha256.New, c.indexKey)
+	mac.Write([]byte(s))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Encrypted reports whether s is an encrypted value
+func Encrypted(s string) bool {
+	return strings.HasPrefix(s, prefix)
+}
@@ -0,0 +1,199 @@
+package fieldcrypt
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func testCipher(t *testing.T, keys ...Key) *Cipher {
+	t.Helper()
+	c, err := New(keys, bytes.Repeat([]byte("i"), 32))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+var (
+	oldKey = Key{ID: "k1", Secret: bytes.Repeat([]byte("a"), 32)}
+	newKey = Key{ID: "k2", Secret: bytes.Repeat([]byte("b"), 32)}
+)
+
+func TestCipher_RoundTrip(t *testing.T) {
+	c := testCipher(t, oldKey)
+
+	sealed, err := c.Encrypt("john@smith.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(sealed, "enc:k1:") || strings.Contains(sealed, "john") {
+		t.Errorf("unexpected encrypted value %q", sealed)
+	}
+
+	again, _ := c.Encrypt("john@smith.com")
+	if again == sealed {
+		t.Error("encrypting twice should use different nonces")
+	}
+
+	plain, err := c.Decrypt(sealed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if plain != "john@smith.com" {
+		t.Errorf("got %q, want john@smith.com", plain)
+	}
+}
+
+func TestCipher_Rotation(t *testing.T) {
+	sealed, _ := testCipher(t, oldKey).Encrypt("555-555-5555")
+
+	rotated := testCipher(t, newKey, oldKey)
+	if rotated.Current(sealed) {
+		t.Error("value under the old key should not be current")
+	}
+	plain, err := rotated.Decrypt(sealed)
+	if err != nil || plain != "555-555-5555" {
+		t.Errorf("got %q, %v, want 555-555-5555", plain, err)
+	}
+	resealed, _ := rotated.Encrypt(plain)
+	if !rotated.Current(resealed) {
+		t.Errorf("%q should be current", resealed)
+	}
+
+	_, err = testCipher(t, newKey).Decrypt(sealed)
+	if !errors.Is(err, ErrUnknownKey) {
+		t.Errorf("got %v, want ErrUnknownKey", err)
+	}
+}
+
+func TestCipher_PlainText(t *testing.T) {
+	c := testCipher(t, oldKey)
+
+	for _, s := range []string{"", "john@smith.com"} {
+		plain, err := c.Decrypt(s)
+		if err != nil || plain != s {
+			t.Errorf("Decrypt(%q): got %q, %v", s, plain, err)
+		}
+	}
+	if !c.Current("") {
+		t.Error("empty values need no re-encryption")
+	}
+	if c.Current("john@smith.com") {
+		t.Error("plain text values should be encrypted")
+	}
+	if sealed, _ := c.Encrypt(""); sealed != "" {
+		t.Errorf("empty values should stay empty, got %q", sealed)
+	}
+}
+
+func TestCipher_Tampered(t *testing.T) {
+	c := testCipher(t, oldKey)
+	sealed, _ := c.Encrypt("john@smith.com")
+
+	tampered := sealed[:len(sealed)-2] + "AA"
+	if tampered == sealed {
+		tampered = sealed[:len(sealed)-2] + "BB"
+	}
+	if _, err := c.Decrypt(tampered); err == nil {
+		t.Error("tampered value should not decrypt")
+	}
+	if _, err := c.Decrypt("enc:k1"); err == nil {
+		t.Error("malformed value should not decrypt")
+	}
+}
+
+func TestCipher_Nil(t *testing.T) {
+	var c *Cipher
+
+	sealed, err := c.Encrypt("john@smith.com")
+	if err != nil || sealed != "john@smith.com" {
+		t.Errorf("nil cipher should not encrypt, got %q, %v", sealed, err)
+	}
+	if c.BlindIndex("john@smith.com") != "" {
+		t.Error("nil cipher should have no blind index")
+	}
+	if _, err := c.Decrypt("enc:k1:AAAA"); !errors.Is(err, ErrUnknownKey) {
+		t.Errorf("got %v, want ErrUnknownKey", err)
+	}
+}
+
+func TestCipher_BlindIndex(t *testing.T) {
+	c := testCipher(t, oldKey)
+
+	index := c.BlindIndex(" John@Smith.com ")
+	if len(index) != 64 {
+		t.Errorf("unexpected index %q", index)
+	}
+	if index != c.BlindIndex("john@smith.com") {
+		t.Error("index should not depend on case or spaces")
+	}
+	if index == c.BlindIndex("jane@smith.com") {
+		t.Error("different addresses should have different indexes")
+	}
+	if c.BlindIndex("") != "" {
+		t.Error("empty address should have no index")
+	}
+	if index != testCipher(t, newKey, oldKey).BlindIndex("john@smith.com") {
+		t.Error("index should survive key rotation")
+	}
+}
+
+func TestCipher_PhoneIndex(t *testing.T) {
+	c := testCipher(t, oldKey)
+
+	index := c.PhoneIndex("555-555-5555")
+	if len(index) != 64 || index != c.PhoneIndex("(555) 555 5555") {
+		t.Errorf("index should only depend on the digits, got %q", index)
+	}
+	if index == c.BlindIndex("5555555555") {
+		t.Error("phone and email indexes should differ")
+	}
+	if c.PhoneIndex("n/a") != "" {
+		t.Error("number without digits should have no index")
+	}
+
+	var none *Cipher
+	if none.PhoneIndex("555-555-5555") != "" {
+		t.Error("nil cipher should have no index")
+	}
+}
+
+func TestNew_Invalid(t *testing.T) {
+	indexKey := bytes.Repeat([]byte("i"), 32)
+	var testCases = []struct {
+		name     string
+		keys     []Key
+		indexKey []byte
+	}{
+		{"No keys", nil, indexKey},
+		{"Short secret", []Key{{ID: "k1", Secret: []byte("short")}}, indexKey},
+		{"Empty id", []Key{{Secret: oldKey.Secret}}, indexKey},
+		{"Colon in id", []Key{{ID: "k:1", Secret: oldKey.Secret}}, indexKey},
+		{"Duplicate id", []Key{oldKey, oldKey}, indexKey},
+		{"Short index key", []Key{oldKey}, []byte("short")},
+	}
+
+	for _, testCase := range testCases {
+		if _, err := New(testCase.keys, testCase.indexKey); err == nil {
+			t.Errorf("New for (%s) should fail", testCase.name)
+		}
+	}
+}
+
+func TestParseKeys(t *testing.T) {
+	keys, err := ParseKeys("k2:YmJi, k1:YWFh")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(keys) != 2 || keys[0].ID != "k2" || string(keys[0].Secret) != "bbb" || keys[1].ID != "k1" {
+		t.Errorf("unexpected keys %+v", keys)
+	}
+
+	for _, s := range []string{"k1", "k1:not base64!"} {
+		if _, err := ParseKeys(s); err == nil {
+			t.Errorf("ParseKeys(%q) should fail", s)
+		}
+	}
+}
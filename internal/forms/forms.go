@@ -3,7 +3,9 @@ package forms
 import (
 	"fmt"
 	"net/url"
+	"strconv"
 	"strings"
+	"unicode"
 
 	"github.com/asaskevich/govalidator"
 )
@@ -31,7 +33,6 @@ func (f *Form) Has(field string) bool {
 // MinLength checks for string minimum length
 func (f *Form) MinLength(field string, length int) bool {
 	x := f.Get(field)
-	fmt.Print(x)
 	if len(x) < length {
 		f.Errors.Add(field, fmt.Sprintf("This field must be atleast %d characters long", length))
 		return false
@@ -59,3 +60,83 @@ func (f *Form) IsEmail(field string) {
 		f.Errors.Add(field, "Invalid email address")
 	}
 }
+
+// EmailList returns the email addresses of a field separated by commas, spaces or new lines, adding an
+// error if any is invalid
+func (f *Form) EmailList(field string) []string {
+	var emails []string
+	for _, email := range strings.FieldsFunc(f.Get(field), func(r rune) bool {
+		return r == ',' || r == ';' || unicode.IsSpace(r)
+	}) {
+		if !govalidator.IsEmail(email) {
+			f.Errors.Add(field, fmt.Sprintf("Invalid email address %s", email))
+			continue
+		}
+		emails = append(emails, email)
+	}
+	return emails
+}
+
+// IsURL checks for a valid http or https url
+func (f *Form) IsURL(field string) {
+	x := f.Get(field)
+	if !govalidator.IsRequestURL(x) || !(strings.HasPrefix(x, "http://") || strings.HasPrefix(x, "https://")) {
+		f.Errors.Add(field, "Invalid url")
+	}
+}
+
+// IntBetween returns the whole number of a field, adding an error if it isn't one from min to max
+func (f *Form) IntBetween(field string, min, max int) int {
+	n, err := strconv.Atoi(strings.TrimSpace(f.Get(field)))
+	if err != nil || n < min || n > max {
+		f.Errors.Add(field, fmt.Sprintf("Must be a whole number from %d to %d", min, max))
+		return 0
+	}
+	return n
+}
+
+// Phone returns the phone number of a field in E.164 form, taking numbers without a calling code to be of
+// countryCode, and puts it back in the field. It adds an error if the number is invalid
+func (f *Form) Phone(field, countryCode string) string {
+	phone, ok := E164(f.Get(field), countryCode)
+	if !ok {
+		f.Errors.Add(field, "Invalid phone number")
+		return f.Get(field)
+	}
+	f.Set(field, phone)
+	return phone
+}
+
+// E164 returns a phone number in E.164 form, like +15555550123, and whether it is valid. Numbers starting with + or 00 are taken as
+// international ones, others as national numbers of countryCode, dropping their leading trunk 0. Spaces,
+// dots, dashes and parentheses are allowed between the digits
+func E164(phone, countryCode string) (string, bool) {
+	s := strings.TrimSpace(phone)
+	international := strings.HasPrefix(s, "+")
+	if international {
+		s = s[1:]
+	}
+
+	var digits strings.Builder
+	for _, r := range s {
+		switch {
+		case r >= '0' && r <= '9':
+			digits.WriteRune(r)
+		case strings.ContainsRune(" .-()", r):
+		default:
+			return "", false
+		}
+	}
+
+	d := digits.String()
+	if !international && strings.HasPrefix(d, "00") {
+		d, international = d[2:], true
+	}
+	if !international {
+		d = countryCode + strings.TrimPrefix(d, "0")
+	}
+	if len(d) < 8 || len(d) > 15 || d[0] == '0' {
+		return "", false
+	}
+	return "+" + d, true
+}
@@ -107,3 +107,117 @@ func TestForm_IsEmail(t *testing.T) {
 		t.Error("form shows valid when email criteria is invalid. (NOT EMPTY)")
 	}
 }
+
+func TestForm_EmailList(t *testing.T) {
+	postedData := url.Values{}
+	postedData.Add("a", "rajiv@mkcl.org, desk@fortsmythe.com\nmanager@fortsmythe.com")
+	postedData.Add("b", "rajiv@mkcl.org, rajiv@.org")
+	form := New(postedData)
+
+	emails := form.EmailList("a")
+	if !form.Valid() || len(emails) != 3 || emails[2] != "manager@fortsmythe.com" {
+		t.Errorf("wrong email list %v, errors %v", emails, form.Errors)
+	}
+
+	if emails := form.EmailList("empty"); len(emails) != 0 || !form.Valid() {
+		t.Error("an empty field should be an empty list")
+	}
+
+	form.EmailList("b")
+	if form.Errors.Get("b") != "Invalid email address rajiv@.org" {
+		t.Errorf("expected an invalid address error, got %q", form.Errors.Get("b"))
+	}
+}
+
+func TestForm_IsURL(t *testing.T) {
+	form := New(url.Values{})
+	form.IsURL("a")
+	if form.Valid() {
+		t.Error("form shows valid when url criteria is invalid. (EMPTY)")
+	}
+
+	postedData := url.Values{}
+	postedData.Add("a", "https://www.example.com/calendar/room-1.ics?s=abc")
+	postedData.Add("b", "ftp://example.com/room.ics")
+	postedData.Add("c", "not a url")
+	form = New(postedData)
+	form.IsURL("a")
+	if !form.Valid() {
+		t.Error("form does not show valid even when url criteria is valid.")
+	}
+
+	form.IsURL("b")
+	if form.Errors.Get("b") == "" {
+		t.Error("form shows valid for a non http url")
+	}
+
+	form.IsURL("c")
+	if form.Errors.Get("c") == "" {
+		t.Error("form shows valid when url criteria is invalid. (NOT EMPTY)")
+	}
+}
+
+func TestForm_IntBetween(t *testing.T) {
+	postedData := url.Values{}
+	postedData.Add("a", " 3 ")
+	postedData.Add("b", "31")
+	postedData.Add("c", "three")
+	form := New(postedData)
+
+	if n := form.IntBetween("a", 0, 30); n != 3 || !form.Valid() {
+		t.Errorf("got %d for a valid number, errors %v", n, form.Errors)
+	}
+	if form.IntBetween("b", 0, 30); form.Errors.Get("b") != "Must be a whole number from 0 to 30" {
+		t.Errorf("wrong error for a number out of range: %q", form.Errors.Get("b"))
+	}
+	if form.IntBetween("c", 0, 30); form.Errors.Get("c") == "" {
+		t.Error("form shows valid for a field that isn't a number")
+	}
+	if form.IntBetween("d", 0, 30); form.Errors.Get("d") == "" {
+		t.Error("form shows valid for a missing number")
+	}
+}
+
+func TestForm_Phone(t *testing.T) {
+	postedData := url.Values{}
+	postedData.Add("a", "(555) 555-0123")
+	postedData.Add("b", "call me")
+	form := New(postedData)
+
+	if phone := form.Phone("a", "1"); phone != "+15555550123" || !form.Valid() {
+		t.Errorf("got %q for a valid phone number, errors %v", phone, form.Errors)
+	}
+	if form.Get("a") != "+15555550123" {
+		t.Errorf("the field should hold the normalized number, got %q", form.Get("a"))
+	}
+
+	if phone := form.Phone("b", "1"); phone != "call me" || form.Errors.Get("b") != "Invalid phone number" {
+		t.Errorf("got %q, %q for an invalid phone number", phone, form.Errors.Get("b"))
+	}
+}
+
+func TestE164(t *testing.T) {
+	var testCases = []struct {
+		name     string
+		phone    string
+		expected string
+		valid    bool
+	}{
+		{"National", "(555) 555-0123", "+15555550123", true},
+		{"International", "+44 7700 900123", "+447700900123", true},
+		{"International with 00", "0044 7700.900.123", "+447700900123", true},
+		{"Trunk prefix", "07700 900123", "+17700900123", true},
+		{"Letters", "555-CALL-NOW", "", false},
+		{"Plus in the middle", "555+0123", "", false},
+		{"Too short", "+1 555", "", false},
+		{"Too long", "+1 555 555 0123 45678", "", false},
+		{"Empty", "", "", false},
+	}
+
+	for _, testCase := range testCases {
+		got, ok := E164(testCase.phone, "1")
+		if ok != testCase.valid || got != testCase.expected {
+			t.Errorf("E164 for (%s): got %q, %v, want %q, %v", testCase.name, got, ok, testCase.expected, testCase.valid)
+		}
+	}
+}
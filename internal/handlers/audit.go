@@ -0,0 +1,80 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-course/bookings/internal/forms"
+	"github.com/go-course/bookings/internal/helpers"
+	"github.com/go-course/bookings/internal/models"
+	"github.com/go-course/bookings/internal/render"
+	"github.com/go-course/bookings/internal/repository"
+	"github.com/go-course/bookings/internal/repository/dbrepo"
+)
+
+// auditEntities are the entities the audit log can be filtered by
+var auditEntities = []string{"reservation", "room", "ical_source", "channel", "user"}
+
+// auditDB returns the repository recording the changes made through it as made by the logged in user
+func (m *Repository) auditDB(r *http.Request) repository.DatabaseRepo {
+	return dbrepo.NewAuditRepo(m.DB, m.App.Session.GetInt(r.Context(), "user_id"))
+}
+
+// AdminAudit shows the audit log, filtered by user, entity and date range
+func (m *Repository) AdminAudit(w http.ResponseWriter, r *http.Request) {
+	form := forms.New(r.URL.Query())
+	var filter models.AuditFilter
+
+	if v := form.Get("user_id"); v != "" {
+		id, err := strconv.Atoi(v)
+		if err != nil {
+			form.Errors.Add("user_id", "Choose a user")
+		}
+		filter.UserID = id
+	}
+
+	filter.Entity = form.Get("entity")
+
+	if v := form.Get("from"); v != "" {
+		from, err := time.Parse("2006-01-02", v)
+		if err != nil {
+			form.Errors.Add("from", "Invalid date")
+		}
+		filter.From = from
+	}
+	if v := form.Get("to"); v != "" {
+		to, err := time.Parse("2006-01-02", v)
+		if err != nil {
+			form.Errors.Add("to", "Invalid date")
+		}
+		// include the whole last day
+		filter.To = to.AddDate(0, 0, 1)
+	}
+
+	var events []models.AuditEvent
+	if form.Valid() {
+		var err error
+		events, err = m.DB.AuditEvents(filter)
+		if err != nil {
+			helpers.ServerError(w, err)
+			return
+		}
+	}
+
+	users, err := m.DB.ListUsers()
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	data := make(map[string]interface{})
+	data["events"] = events
+	data["users"] = users
+	data["entities"] = auditEntities
+
+	render.Template(w, r, "admin-audit.page.tmpl", &models.TemplateData{
+		Data: data,
+		Form: form,
+	})
+}
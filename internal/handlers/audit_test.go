@@ -0,0 +1,43 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRepository_AdminAudit(t *testing.T) {
+	var testCases = []struct {
+		name    string
+		url     string
+		want    int
+		html    string
+		missing string
+	}{
+		{"All events", "/admin/audit", http.StatusOK, "reservation.update", ""},
+		{"Filtered", "/admin/audit?user_id=1&entity=reservation&from=2050-01-01&to=2050-01-31", http.StatusOK, "reservation.update", ""},
+		{"Filtered out", "/admin/audit?entity=room", http.StatusOK, "No changes found.", "reservation.update"},
+		{"Invalid date", "/admin/audit?from=yesterday", http.StatusOK, "Invalid date", "reservation.update"},
+		{"Invalid user", "/admin/audit?user_id=admin", http.StatusOK, "Choose a user", "reservation.update"},
+	}
+
+	for _, testCase := range testCases {
+		req, _ := http.NewRequest("GET", testCase.url, nil)
+		ctx := getCtx(req)
+		req = req.WithContext(ctx)
+		rr := httptest.NewRecorder()
+		handler := http.HandlerFunc(Repo.AdminAudit)
+		handler.ServeHTTP(rr, req)
+
+		if rr.Code != testCase.want {
+			t.Errorf("AdminAudit handler returned wrong status for (%s): got %d, want %d", testCase.name, rr.Code, testCase.want)
+		}
+		if !strings.Contains(rr.Body.String(), testCase.html) {
+			t.Errorf("AdminAudit handler for (%s) should render %q", testCase.name, testCase.html)
+		}
+		if testCase.missing != "" && strings.Contains(rr.Body.String(), testCase.missing) {
+			t.Errorf("AdminAudit handler for (%s) should not render %q", testCase.name, testCase.missing)
+		}
+	}
+}
@@ -0,0 +1,44 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-course/bookings/internal/helpers"
+	"github.com/go-course/bookings/internal/models"
+	"github.com/go-course/bookings/internal/render"
+)
+
+// AdminChannels shows how far each channel is with receiving availability changes
+func (m *Repository) AdminChannels(w http.ResponseWriter, r *http.Request) {
+	cursors, err := m.DB.AllChannelCursors()
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	data := make(map[string]interface{})
+	data["channels"] = cursors
+
+	render.Template(w, r, "admin-channels.page.tmpl", &models.TemplateData{
+		Data: data,
+	})
+}
+
+// AdminRetryChannel makes the worker retry a channel that is backing off on its next run
+func (m *Repository) AdminRetryChannel(w http.ResponseWriter, r *http.Request) {
+	err := m.auditDB(r).RetryChannelNow(chi.URLParam(r, "name"))
+	if errors.Is(err, sql.ErrNoRows) {
+		helpers.ClientError(w, http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	m.App.Session.Put(r.Context(), "flash", "The channel will be retried shortly")
+	http.Redirect(w, r, "/admin/channels", http.StatusSeeOther)
+}
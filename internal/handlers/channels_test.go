@@ -0,0 +1,60 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+)
+
+func TestRepository_AdminChannels(t *testing.T) {
+	req, _ := http.NewRequest("GET", "/admin/channels", nil)
+	ctx := getCtx(req)
+	req = req.WithContext(ctx)
+	rr := httptest.NewRecorder()
+	handler := http.HandlerFunc(Repo.AdminChannels)
+	handler.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Errorf("AdminChannels handler returned wrong status: got %d, want %d", rr.Code, http.StatusOK)
+	}
+	for _, s := range []string{"mock", "channel unavailable", "/admin/channels/mock/retry"} {
+		if !strings.Contains(rr.Body.String(), s) {
+			t.Errorf("AdminChannels handler should render %q", s)
+		}
+	}
+}
+
+func TestRepository_AdminRetryChannel(t *testing.T) {
+	var testCases = []struct {
+		name    string
+		channel string
+		want    int
+		flash   string
+	}{
+		{"Retry", "mock", http.StatusSeeOther, "The channel will be retried shortly"},
+		{"Unknown channel", "missing", http.StatusNotFound, ""},
+		{"Database error", "broken", http.StatusInternalServerError, ""},
+	}
+
+	for _, testCase := range testCases {
+		req, _ := http.NewRequest("POST", "/admin/channels/"+testCase.channel+"/retry", nil)
+		rctx := chi.NewRouteContext()
+		rctx.URLParams.Add("name", testCase.channel)
+		ctx := getCtx(req)
+		req = req.WithContext(context.WithValue(ctx, chi.RouteCtxKey, rctx))
+		rr := httptest.NewRecorder()
+		handler := http.HandlerFunc(Repo.AdminRetryChannel)
+		handler.ServeHTTP(rr, req)
+
+		if rr.Code != testCase.want {
+			t.Errorf("AdminRetryChannel handler returned wrong status for (%s): got %d, want %d", testCase.name, rr.Code, testCase.want)
+		}
+		if flash := session.PopString(req.Context(), "flash"); flash != testCase.flash {
+			t.Errorf("AdminRetryChannel handler flashed wrong message for (%s): got %q, want %q", testCase.name, flash, testCase.flash)
+		}
+	}
+}
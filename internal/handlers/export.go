@@ -0,0 +1,109 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-course/bookings/internal/export"
+	"github.com/go-course/bookings/internal/forms"
+	"github.com/go-course/bookings/internal/helpers"
+	"github.com/go-course/bookings/internal/models"
+)
+
+// maxOccupancyDays bounds the period of an occupancy export
+const maxOccupancyDays = 366
+
+// AdminExportReservations streams the reservations selected by the url query, filtered like the
+// reservation lists, as CSV or XLSX
+func (m *Repository) AdminExportReservations(w http.ResponseWriter, r *http.Request) {
+	format := chi.URLParam(r, "format")
+	if !export.ValidFormat(format) {
+		helpers.ClientError(w, http.StatusNotFound)
+		return
+	}
+
+	form := forms.New(r.URL.Query())
+	q := parseReservationQuery(form)
+	q.Status = form.Get("status")
+	if !form.Valid() || (q.Status != "" && !models.ValidReservationStatus(q.Status)) {
+		helpers.ClientError(w, http.StatusBadRequest)
+		return
+	}
+
+	out, ok := m.startExport(w, format, "reservations", "Reservations")
+	if !ok {
+		return
+	}
+
+	out.WriteRow("ID", "First Name", "Last Name", "Email", "Phone", "Room", "Arrival", "Departure", "Nights", "Status", "Created")
+	err := m.DB.EachReservation(q, func(res models.Reservation) error {
+		nights := int(res.EndDate.Sub(res.StartDate).Hours() / 24)
+		return out.WriteRow(res.ID, res.FirstName, res.LastName, res.Email, res.Phone, res.Room.RoomName,
+			res.StartDate, res.EndDate, nights, res.Status, res.CreatedAt)
+	})
+	m.finishExport(out, err)
+}
+
+// AdminExportOccupancy streams the occupancy of each room between the from and to dates, both included,
+// as CSV or XLSX
+func (m *Repository) AdminExportOccupancy(w http.ResponseWriter, r *http.Request) {
+	format := chi.URLParam(r, "format")
+	if !export.ValidFormat(format) {
+		helpers.ClientError(w, http.StatusNotFound)
+		return
+	}
+
+	from, err := time.Parse("2006-01-02", r.URL.Query().Get("from"))
+	if err != nil {
+		helpers.ClientError(w, http.StatusBadRequest)
+		return
+	}
+	to, err := time.Parse("2006-01-02", r.URL.Query().Get("to"))
+	if err != nil || to.Before(from) || to.Sub(from).Hours()/24 >= maxOccupancyDays {
+		helpers.ClientError(w, http.StatusBadRequest)
+		return
+	}
+	end := to.AddDate(0, 0, 1)
+
+	out, ok := m.startExport(w, format, "occupancy", "Occupancy")
+	if !ok {
+		return
+	}
+
+	out.WriteRow("Room", "From", "To", "Nights", "Reserved", "External", "Blocked", "Reservations", "Occupancy")
+	err = m.DB.EachRoomOccupancy(from, end, func(o models.RoomOccupancy) error {
+		return out.WriteRow(o.Room.RoomName, from, to, o.Nights, o.ReservedNights, o.ExternalNights,
+			o.BlockedNights, o.Reservations, o.Rate())
+	})
+	m.finishExport(out, err)
+}
+
+// startExport writes the headers of a download named after name and today's date, and returns the
+// writer for its rows
+func (m *Repository) startExport(w http.ResponseWriter, format, name, sheet string) (export.Writer, bool) {
+	filename := fmt.Sprintf("%s-%s.%s", name, time.Now().Format("2006-01-02"), format)
+	w.Header().Set("Content-Type", export.ContentType(format))
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, filename))
+	w.Header().Set("Cache-Control", "no-store")
+
+	out, err := export.New(format, w, sheet)
+	if err != nil {
+		helpers.ServerError(w, err)
+		return nil, false
+	}
+	return out, true
+}
+
+// finishExport closes out. Rows have already been sent, so errors can only be logged, and leave the
+// download truncated
+func (m *Repository) finishExport(out export.Writer, err error) {
+	if err != nil {
+		m.App.ErrorLog.Println("export failed:", err)
+		return
+	}
+	if err := out.Close(); err != nil {
+		m.App.ErrorLog.Println("export failed:", err)
+	}
+}
@@ -0,0 +1,107 @@
+package handlers
+
+import (
+	"context"
+	"encoding/csv"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// exportRequest serves url with the format route param set
+func exportRequest(handler http.HandlerFunc, url, format string) *httptest.ResponseRecorder {
+	req, _ := http.NewRequest("GET", url, nil)
+	rctx := chi.NewRouteContext()
+	rctx.URLParams.Add("format", format)
+	ctx := getCtx(req)
+	req = req.WithContext(context.WithValue(ctx, chi.RouteCtxKey, rctx))
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	return rr
+}
+
+func TestRepository_AdminExportReservations(t *testing.T) {
+	var testCases = []struct {
+		name        string
+		url         string
+		format      string
+		want        int
+		contentType string
+		rows        int
+	}{
+		{"CSV", "/admin/export/reservations/csv?status=confirmed", "csv", http.StatusOK, "text/csv; charset=utf-8", 4},
+		{"XLSX", "/admin/export/reservations/xlsx", "xlsx", http.StatusOK, "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet", 0},
+		{"No matches", "/admin/export/reservations/csv?q=nobody", "csv", http.StatusOK, "text/csv; charset=utf-8", 1},
+		{"Unknown format", "/admin/export/reservations/pdf", "pdf", http.StatusNotFound, "", 0},
+		{"Invalid date", "/admin/export/reservations/csv?from=yesterday", "csv", http.StatusBadRequest, "", 0},
+		{"Invalid status", "/admin/export/reservations/csv?status=processed", "csv", http.StatusBadRequest, "", 0},
+	}
+
+	for _, testCase := range testCases {
+		rr := exportRequest(Repo.AdminExportReservations, testCase.url, testCase.format)
+
+		if rr.Code != testCase.want {
+			t.Errorf("AdminExportReservations handler returned wrong status for (%s): got %d, want %d", testCase.name, rr.Code, testCase.want)
+			continue
+		}
+		if testCase.want != http.StatusOK {
+			continue
+		}
+		if ct := rr.Header().Get("Content-Type"); ct != testCase.contentType {
+			t.Errorf("wrong content type for (%s): got %q", testCase.name, ct)
+		}
+		if cd := rr.Header().Get("Content-Disposition"); !strings.HasPrefix(cd, `attachment; filename="reservations-`) {
+			t.Errorf("wrong content disposition for (%s): got %q", testCase.name, cd)
+		}
+		if testCase.format == "csv" {
+			records, err := csv.NewReader(rr.Body).ReadAll()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(records) != testCase.rows {
+				t.Errorf("wrong number of rows for (%s): got %d, want %d", testCase.name, len(records), testCase.rows)
+			}
+			if len(records) > 1 && strings.Join(records[1], ",") != "1,John,Smith,,,Generals Quarters,2050-01-01,2050-01-03,2,confirmed," {
+				t.Errorf("wrong row for (%s): %q", testCase.name, records[1])
+			}
+		}
+	}
+}
+
+func TestRepository_AdminExportOccupancy(t *testing.T) {
+	var testCases = []struct {
+		name   string
+		url    string
+		format string
+		want   int
+	}{
+		{"CSV", "/admin/export/occupancy/csv?from=2050-01-01&to=2050-01-31", "csv", http.StatusOK},
+		{"XLSX", "/admin/export/occupancy/xlsx?from=2050-01-01&to=2050-01-31", "xlsx", http.StatusOK},
+		{"Missing dates", "/admin/export/occupancy/csv", "csv", http.StatusBadRequest},
+		{"Reversed dates", "/admin/export/occupancy/csv?from=2050-02-01&to=2050-01-01", "csv", http.StatusBadRequest},
+		{"Too long", "/admin/export/occupancy/csv?from=2050-01-01&to=2051-12-31", "csv", http.StatusBadRequest},
+		{"Unknown format", "/admin/export/occupancy/pdf?from=2050-01-01&to=2050-01-31", "pdf", http.StatusNotFound},
+	}
+
+	for _, testCase := range testCases {
+		rr := exportRequest(Repo.AdminExportOccupancy, testCase.url, testCase.format)
+
+		if rr.Code != testCase.want {
+			t.Errorf("AdminExportOccupancy handler returned wrong status for (%s): got %d, want %d", testCase.name, rr.Code, testCase.want)
+		}
+	}
+
+	rr := exportRequest(Repo.AdminExportOccupancy, "/admin/export/occupancy/csv?from=2050-01-01&to=2050-01-31", "csv")
+	records, err := csv.NewReader(rr.Body).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 31 nights, 1 blocked, 15 booked
+	want := "Generals Quarters,2050-01-01,2050-01-31,31,10,5,1,4,0.50"
+	if len(records) != 2 || strings.Join(records[1], ",") != want {
+		t.Errorf("wrong occupancy rows: got %q, want %q", records, want)
+	}
+}
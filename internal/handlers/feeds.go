@@ -0,0 +1,165 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-course/bookings/internal/helpers"
+	"github.com/go-course/bookings/internal/ics"
+	"github.com/go-course/bookings/internal/models"
+	"github.com/go-course/bookings/internal/render"
+)
+
+// feedHistoryDays is how many days of past bookings calendar feeds include
+const feedHistoryDays = 90
+
+// CalendarFeedAll serves the iCalendar feed with reservations and blocks for all rooms
+func (m *Repository) CalendarFeedAll(w http.ResponseWriter, r *http.Request) {
+	if !m.feedTokenValid(r) {
+		helpers.ClientError(w, http.StatusUnauthorized)
+		return
+	}
+
+	m.serveCalendarFeed(w, 0, "All rooms")
+}
+
+// CalendarFeedRoom serves the iCalendar feed with reservations and blocks for one room
+func (m *Repository) CalendarFeedRoom(w http.ResponseWriter, r *http.Request) {
+	if !m.feedTokenValid(r) {
+		helpers.ClientError(w, http.StatusUnauthorized)
+		return
+	}
+
+	roomID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		helpers.ClientError(w, http.StatusNotFound)
+		return
+	}
+
+	room, err := m.DB.GetRoomByID(roomID)
+	if err != nil {
+		helpers.ClientError(w, http.StatusNotFound)
+		return
+	}
+
+	m.serveCalendarFeed(w, roomID, room.RoomName)
+}
+
+// feedTokenValid checks the token in the feed url belongs to a user
+func (m *Repository) feedTokenValid(r *http.Request) bool {
+	_, err := m.DB.GetUserByFeedToken(chi.URLParam(r, "token"))
+	return err == nil
+}
+
+// serveCalendarFeed writes the feed for roomID, or all rooms when roomID is 0
+func (m *Repository) serveCalendarFeed(w http.ResponseWriter, roomID int, name string) {
+	since := time.Now().AddDate(0, 0, -feedHistoryDays)
+	restrictions, err := m.DB.GetRestrictionsForFeed(roomID, since)
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	cal := ics.Calendar{Name: name}
+	for _, rr := range restrictions {
+		cal.Events = append(cal.Events, ics.RestrictionEvent(rr))
+	}
+
+	w.Header().Set("Content-Type", "text/calendar; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	if err := cal.Write(w); err != nil {
+		m.App.ErrorLog.Println(err)
+	}
+}
+
+// AdminCalendarFeeds shows the calendar feed links of the logged in user
+func (m *Repository) AdminCalendarFeeds(w http.ResponseWriter, r *http.Request) {
+	userID, ok := m.App.Session.Get(r.Context(), "user_id").(int)
+	if !ok {
+		m.App.Session.Put(r.Context(), "error", "Log in first!")
+		http.Redirect(w, r, "/user/login", http.StatusSeeOther)
+		return
+	}
+
+	user, err := m.DB.GetUserById(userID)
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	if user.FeedToken == "" {
+		user.FeedToken, err = helpers.RandomToken(32)
+		if err != nil {
+			helpers.ServerError(w, err)
+			return
+		}
+		err = m.DB.UpdateFeedTokenForUser(userID, user.FeedToken)
+		if err != nil {
+			helpers.ServerError(w, err)
+			return
+		}
+	}
+
+	rooms, err := m.DB.AllRooms()
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	scheme := "http"
+	if r.TLS != nil || m.App.InProduction {
+		scheme = "https"
+	}
+
+	stringMap := make(map[string]string)
+	stringMap["feed_base"] = fmt.Sprintf("%s://%s/ical/%s", scheme, r.Host, user.FeedToken)
+
+	data := make(map[string]interface{})
+	data["rooms"] = rooms
+
+	render.Template(w, r, "admin-calendar-feeds.page.tmpl", &models.TemplateData{
+		StringMap: stringMap,
+		Data:      data,
+	})
+}
+
+// AdminPostCalendarFeeds replaces the feed token of the logged in user, invalidating old links
+func (m *Repository) AdminPostCalendarFeeds(w http.ResponseWriter, r *http.Request) {
+	userID, ok := m.App.Session.Get(r.Context(), "user_id").(int)
+	if !ok {
+		m.App.Session.Put(r.Context(), "error", "Log in first!")
+		http.Redirect(w, r, "/user/login", http.StatusSeeOther)
+		return
+	}
+
+	token, err := helpers.RandomToken(32)
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	err = m.auditDB(r).UpdateFeedTokenForUser(userID, token)
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	m.App.Session.Put(r.Context(), "flash", "Feed links regenerated, old links no longer work")
+	http.Redirect(w, r, "/admin/calendar-feeds", http.StatusSeeOther)
+}
+
+// reservationICS returns a calendar file with a single reservation, to attach to the guest confirmation
+func reservationICS(res models.Reservation) models.MailAttachment {
+	cal := ics.Calendar{
+		Method: "PUBLISH",
+		Events: []ics.Event{ics.ReservationEvent(res)},
+	}
+	return models.MailAttachment{
+		Name:     "reservation.ics",
+		MimeType: "text/calendar",
+		Data:     cal.Bytes(),
+	}
+}
@@ -0,0 +1,111 @@
+package handlers
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-course/bookings/internal/models"
+)
+
+func TestRepository_CalendarFeeds(t *testing.T) {
+	routes := getRoutes()
+	ts := httptest.NewTLSServer(routes)
+	defer ts.Close()
+
+	var testCases = []struct {
+		name     string
+		url      string
+		want     int
+		contains []string
+	}{
+		{
+			name: "All rooms",
+			url:  "/ical/valid-token/all.ics",
+			want: http.StatusOK,
+			contains: []string{
+				"X-WR-CALNAME:All rooms",
+				"UID:reservation-1@bookings.go-course",
+				"UID:block-2@bookings.go-course",
+			},
+		},
+		{
+			name:     "Single room",
+			url:      "/ical/valid-token/rooms/1.ics",
+			want:     http.StatusOK,
+			contains: []string{"BEGIN:VCALENDAR", "CATEGORIES:BLOCK"},
+		},
+		{"Invalid token", "/ical/wrong-token/all.ics", http.StatusUnauthorized, nil},
+		{"Invalid token for room", "/ical/wrong-token/rooms/1.ics", http.StatusUnauthorized, nil},
+		{"Non existent room", "/ical/valid-token/rooms/3.ics", http.StatusNotFound, nil},
+		{"Invalid room id", "/ical/valid-token/rooms/as.ics", http.StatusNotFound, nil},
+	}
+
+	for _, testCase := range testCases {
+		resp, err := ts.Client().Get(ts.URL + testCase.url)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+
+		if resp.StatusCode != testCase.want {
+			t.Errorf("calendar feed returned wrong status for (%s): got %d, want %d", testCase.name, resp.StatusCode, testCase.want)
+			continue
+		}
+		if testCase.want == http.StatusOK && !strings.HasPrefix(resp.Header.Get("Content-Type"), "text/calendar") {
+			t.Errorf("calendar feed returned wrong content type for (%s): %s", testCase.name, resp.Header.Get("Content-Type"))
+		}
+		for _, c := range testCase.contains {
+			if !strings.Contains(string(body), c) {
+				t.Errorf("calendar feed for (%s) does not contain %q", testCase.name, c)
+			}
+		}
+	}
+}
+
+func TestRepository_AdminCalendarFeeds(t *testing.T) {
+	var testCases = []struct {
+		name    string
+		method  string
+		userID  int
+		handler http.HandlerFunc
+		want    int
+	}{
+		{"Show links", "GET", 1, Repo.AdminCalendarFeeds, http.StatusOK},
+		{"Show links when logged out", "GET", 0, Repo.AdminCalendarFeeds, http.StatusSeeOther},
+		{"Regenerate links", "POST", 1, Repo.AdminPostCalendarFeeds, http.StatusSeeOther},
+		{"Regenerate links when logged out", "POST", 0, Repo.AdminPostCalendarFeeds, http.StatusSeeOther},
+		{"Regenerate links for unknown user", "POST", 3, Repo.AdminPostCalendarFeeds, http.StatusInternalServerError},
+	}
+
+	for _, testCase := range testCases {
+		req, _ := http.NewRequest(testCase.method, "/admin/calendar-feeds", nil)
+		ctx := getCtx(req)
+		req = req.WithContext(ctx)
+		if testCase.userID > 0 {
+			session.Put(ctx, "user_id", testCase.userID)
+		}
+		rr := httptest.NewRecorder()
+		testCase.handler.ServeHTTP(rr, req)
+
+		if rr.Code != testCase.want {
+			t.Errorf("calendar feeds handler returned wrong status for (%s): got %d, want %d", testCase.name, rr.Code, testCase.want)
+		}
+	}
+}
+
+func TestReservationICS(t *testing.T) {
+	a := reservationICS(models.Reservation{ID: 7, FirstName: "John", LastName: "Smith"})
+	if a.MimeType != "text/calendar" || a.Name != "reservation.ics" {
+		t.Errorf("wrong attachment metadata: %s %s", a.Name, a.MimeType)
+	}
+	if !strings.Contains(string(a.Data), "UID:reservation-7@bookings.go-course") {
+		t.Error("attachment does not contain reservation uid")
+	}
+	if !strings.Contains(string(a.Data), "METHOD:PUBLISH") {
+		t.Error("attachment does not contain method")
+	}
+}
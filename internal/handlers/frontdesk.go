@@ -0,0 +1,260 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-course/bookings/internal/forms"
+	"github.com/go-course/bookings/internal/helpers"
+	"github.com/go-course/bookings/internal/models"
+	"github.com/go-course/bookings/internal/render"
+)
+
+// AdminFrontDesk shows the arrivals and departures of a day, today by default
+func (m *Repository) AdminFrontDesk(w http.ResponseWriter, r *http.Request) {
+	day, ok := dayParam(r.URL.Query().Get("date"))
+	if !ok {
+		helpers.ClientError(w, http.StatusBadRequest)
+		return
+	}
+
+	reservations, err := m.DB.FrontDeskReservations(day)
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	var arrivals, departures []models.Reservation
+	for _, res := range reservations {
+		if res.StartDate.Equal(day) {
+			arrivals = append(arrivals, res)
+		} else {
+			departures = append(departures, res)
+		}
+	}
+
+	data := make(map[string]interface{})
+	data["arrivals"] = arrivals
+	data["departures"] = departures
+
+	stringMap := make(map[string]string)
+	stringMap["date"] = day.Format("2006-01-02")
+	stringMap["prev"] = "/admin/front-desk?date=" + day.AddDate(0, 0, -1).Format("2006-01-02")
+	stringMap["next"] = "/admin/front-desk?date=" + day.AddDate(0, 0, 1).Format("2006-01-02")
+
+	render.Template(w, r, "admin-front-desk.page.tmpl", &models.TemplateData{
+		StringMap: stringMap,
+		Data:      data,
+	})
+}
+
+// AdminCheckIn shows the registration card form of a reservation
+func (m *Repository) AdminCheckIn(w http.ResponseWriter, r *http.Request) {
+	res, ok := m.frontDeskReservation(w, r)
+	if !ok {
+		return
+	}
+
+	card, err := m.DB.GetRegistrationCard(res.ID)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	form := forms.New(url.Values{
+		"address":       {card.Address},
+		"id_type":       {card.IDType},
+		"id_number":     {card.IDNumber},
+		"vehicle_plate": {card.VehiclePlate},
+		"date":          {r.URL.Query().Get("date")},
+	})
+	m.renderCheckIn(w, r, res, form)
+}
+
+// AdminPostCheckIn checks a guest in with their registration card. For guests already checked in it
+// only updates the card
+func (m *Repository) AdminPostCheckIn(w http.ResponseWriter, r *http.Request) {
+	err := r.ParseForm()
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	res, ok := m.frontDeskReservation(w, r)
+	if !ok {
+		return
+	}
+
+	form := forms.New(r.PostForm)
+	form.Required("address", "id_type", "id_number")
+	if form.Has("id_type") && !models.ValidIDType(form.Get("id_type")) {
+		form.Errors.Add("id_type", "Choose an identity document")
+	}
+	if !form.Valid() {
+		m.renderCheckIn(w, r, res, form)
+		return
+	}
+
+	card := models.RegistrationCard{
+		ReservationID: res.ID,
+		Address:       strings.TrimSpace(form.Get("address")),
+		IDType:        form.Get("id_type"),
+		IDNumber:      strings.TrimSpace(form.Get("id_number")),
+		VehiclePlate:  strings.ToUpper(strings.TrimSpace(form.Get("vehicle_plate"))),
+	}
+
+	if res.Status == models.ReservationCheckedIn {
+		err = m.auditDB(r).SaveRegistrationCard(card)
+		if err != nil {
+			helpers.ServerError(w, err)
+			return
+		}
+		m.App.Session.Put(r.Context(), "flash", "Registration card saved")
+	} else {
+		userID := m.App.Session.GetInt(r.Context(), "user_id")
+		err = m.auditDB(r).CheckIn(res.ID, userID, card)
+		var transitionErr *models.TransitionError
+		switch {
+		case errors.As(err, &transitionErr):
+			m.App.Session.Put(r.Context(), "error", "Guest not checked in: "+transitionErr.Error())
+		case err != nil:
+			helpers.ServerError(w, err)
+			return
+		default:
+			m.App.Session.Put(r.Context(), "flash", "Guest checked in")
+			m.publishUpdate(res.ID)
+		}
+	}
+
+	http.Redirect(w, r, frontDeskURL(form.Get("date")), http.StatusSeeOther)
+}
+
+// AdminPostCheckOut checks a guest out
+func (m *Repository) AdminPostCheckOut(w http.ResponseWriter, r *http.Request) {
+	err := r.ParseForm()
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		helpers.ClientError(w, http.StatusNotFound)
+		return
+	}
+
+	userID := m.App.Session.GetInt(r.Context(), "user_id")
+	err = m.auditDB(r).CheckOut(id, userID)
+	var transitionErr *models.TransitionError
+	switch {
+	case errors.As(err, &transitionErr):
+		m.App.Session.Put(r.Context(), "error", "Guest not checked out: "+transitionErr.Error())
+	case err != nil:
+		helpers.ServerError(w, err)
+		return
+	default:
+		m.App.Session.Put(r.Context(), "flash", "Guest checked out")
+		m.publishUpdate(id)
+	}
+
+	http.Redirect(w, r, frontDeskURL(r.Form.Get("date")), http.StatusSeeOther)
+}
+
+// AdminRegistrationCard shows a printable registration card
+func (m *Repository) AdminRegistrationCard(w http.ResponseWriter, r *http.Request) {
+	res, ok := m.frontDeskReservation(w, r)
+	if !ok {
+		return
+	}
+
+	card, err := m.DB.GetRegistrationCard(res.ID)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	data := make(map[string]interface{})
+	data["reservation"] = res
+	data["card"] = card
+
+	if res.CheckedInBy > 0 {
+		user, err := m.DB.GetUserById(res.CheckedInBy)
+		if err != nil {
+			helpers.ServerError(w, err)
+			return
+		}
+		data["checked_in_by"] = user
+	}
+
+	stringMap := make(map[string]string)
+	for _, t := range models.IDTypes {
+		if t.Value == card.IDType {
+			stringMap["id_type"] = t.Label
+		}
+	}
+
+	render.Template(w, r, "admin-registration-card.page.tmpl", &models.TemplateData{
+		StringMap: stringMap,
+		Data:      data,
+	})
+}
+
+// renderCheckIn shows the check-in form of a reservation
+func (m *Repository) renderCheckIn(w http.ResponseWriter, r *http.Request, res models.Reservation, form *forms.Form) {
+	data := make(map[string]interface{})
+	data["reservation"] = res
+	data["id_types"] = models.IDTypes
+
+	render.Template(w, r, "admin-check-in.page.tmpl", &models.TemplateData{
+		Data: data,
+		Form: form,
+	})
+}
+
+// frontDeskReservation loads the reservation in the url, writing a not found response if there is none
+func (m *Repository) frontDeskReservation(w http.ResponseWriter, r *http.Request) (models.Reservation, bool) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		helpers.ClientError(w, http.StatusNotFound)
+		return models.Reservation{}, false
+	}
+
+	res, err := m.DB.GetReservationById(id)
+	if errors.Is(err, sql.ErrNoRows) {
+		helpers.ClientError(w, http.StatusNotFound)
+		return res, false
+	}
+	if err != nil {
+		helpers.ServerError(w, err)
+		return res, false
+	}
+
+	return res, true
+}
+
+// dayParam parses the day picked on a daily page, today if none was picked
+func dayParam(v string) (time.Time, bool) {
+	if v == "" {
+		now := time.Now()
+		return time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC), true
+	}
+	day, err := time.Parse("2006-01-02", v)
+	if err != nil {
+		return day, false
+	}
+	return day, true
+}
+
+// frontDeskURL returns the front desk page of a day, today if date is empty
+func frontDeskURL(date string) string {
+	if date == "" {
+		return "/admin/front-desk"
+	}
+	return "/admin/front-desk?" + url.Values{"date": {date}}.Encode()
+}
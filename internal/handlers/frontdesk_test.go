@@ -0,0 +1,189 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+)
+
+func TestRepository_AdminFrontDesk(t *testing.T) {
+	var testCases = []struct {
+		name  string
+		query string
+		want  int
+	}{
+		{"Today", "", http.StatusOK},
+		{"Chosen day", "?date=2050-01-10", http.StatusOK},
+		{"Invalid date", "?date=soon", http.StatusBadRequest},
+	}
+
+	for _, testCase := range testCases {
+		req, _ := http.NewRequest("GET", "/admin/front-desk"+testCase.query, nil)
+		ctx := getCtx(req)
+		req = req.WithContext(ctx)
+		rr := httptest.NewRecorder()
+		handler := http.HandlerFunc(Repo.AdminFrontDesk)
+		handler.ServeHTTP(rr, req)
+
+		if rr.Code != testCase.want {
+			t.Errorf("AdminFrontDesk handler returned wrong status for (%s): got %d, want %d", testCase.name, rr.Code, testCase.want)
+		}
+	}
+
+	req, _ := http.NewRequest("GET", "/admin/front-desk?date=2050-01-10", nil)
+	req = req.WithContext(getCtx(req))
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(Repo.AdminFrontDesk).ServeHTTP(rr, req)
+
+	for _, s := range []string{
+		"John Smith",
+		"/admin/front-desk/1/check-in?date=2050-01-10",
+		"Jane Doe",
+		"/admin/front-desk/2/check-out",
+	} {
+		if !strings.Contains(rr.Body.String(), s) {
+			t.Errorf("AdminFrontDesk handler should render %q", s)
+		}
+	}
+}
+
+func TestRepository_AdminCheckIn(t *testing.T) {
+	var testCases = []struct {
+		name string
+		id   string
+		want int
+	}{
+		{"With card", "1", http.StatusOK},
+		{"Without card", "2", http.StatusOK},
+		{"Invalid id", "x", http.StatusNotFound},
+		{"Database error", "3", http.StatusInternalServerError},
+	}
+
+	for _, testCase := range testCases {
+		req, _ := http.NewRequest("GET", "/admin/front-desk/"+testCase.id+"/check-in", nil)
+		rctx := chi.NewRouteContext()
+		rctx.URLParams.Add("id", testCase.id)
+		ctx := getCtx(req)
+		req = req.WithContext(context.WithValue(ctx, chi.RouteCtxKey, rctx))
+		rr := httptest.NewRecorder()
+		handler := http.HandlerFunc(Repo.AdminCheckIn)
+		handler.ServeHTTP(rr, req)
+
+		if rr.Code != testCase.want {
+			t.Errorf("AdminCheckIn handler returned wrong status for (%s): got %d, want %d", testCase.name, rr.Code, testCase.want)
+		}
+		if testCase.id == "1" && !strings.Contains(rr.Body.String(), "X1234567") {
+			t.Errorf("AdminCheckIn handler should fill in the saved registration card")
+		}
+	}
+}
+
+func TestRepository_AdminPostCheckIn(t *testing.T) {
+	var testCases = []struct {
+		name     string
+		id       string
+		idType   string
+		idNumber string
+		want     int
+		location string
+		flash    string
+		error    string
+	}{
+		{"Check in", "1", "passport", "X1234567", http.StatusSeeOther, "/admin/front-desk?date=2050-01-10", "Guest checked in", ""},
+		{"Not allowed", "2", "passport", "X1234567", http.StatusSeeOther, "/admin/front-desk?date=2050-01-10", "", "Guest not checked in: a cancelled reservation can't be changed to checked-in"},
+		{"Missing document", "1", "passport", "", http.StatusOK, "", "", ""},
+		{"Invalid document", "1", "library-card", "X1234567", http.StatusOK, "", "", ""},
+		{"Invalid id", "x", "passport", "X1234567", http.StatusNotFound, "", "", ""},
+		{"Database error", "3", "passport", "X1234567", http.StatusInternalServerError, "", "", ""},
+	}
+
+	for _, testCase := range testCases {
+		postedData := url.Values{}
+		postedData.Add("address", "1 Main Street")
+		postedData.Add("id_type", testCase.idType)
+		postedData.Add("id_number", testCase.idNumber)
+		postedData.Add("vehicle_plate", "ab-123-cd")
+		postedData.Add("date", "2050-01-10")
+
+		req, _ := http.NewRequest("POST", "/admin/front-desk/"+testCase.id+"/check-in", strings.NewReader(postedData.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		rctx := chi.NewRouteContext()
+		rctx.URLParams.Add("id", testCase.id)
+		ctx := getCtx(req)
+		req = req.WithContext(context.WithValue(ctx, chi.RouteCtxKey, rctx))
+		rr := httptest.NewRecorder()
+		handler := http.HandlerFunc(Repo.AdminPostCheckIn)
+		handler.ServeHTTP(rr, req)
+
+		if rr.Code != testCase.want {
+			t.Errorf("AdminPostCheckIn handler returned wrong status for (%s): got %d, want %d", testCase.name, rr.Code, testCase.want)
+		}
+		if location := rr.Header().Get("Location"); location != testCase.location {
+			t.Errorf("AdminPostCheckIn handler redirected to wrong location for (%s): got %q, want %q", testCase.name, location, testCase.location)
+		}
+		if flash := session.PopString(req.Context(), "flash"); flash != testCase.flash {
+			t.Errorf("AdminPostCheckIn handler flashed wrong message for (%s): got %q, want %q", testCase.name, flash, testCase.flash)
+		}
+		if msg := session.PopString(req.Context(), "error"); msg != testCase.error {
+			t.Errorf("AdminPostCheckIn handler flashed wrong error for (%s): got %q, want %q", testCase.name, msg, testCase.error)
+		}
+	}
+}
+
+func TestRepository_AdminPostCheckOut(t *testing.T) {
+	var testCases = []struct {
+		name  string
+		id    string
+		want  int
+		flash string
+	}{
+		{"Check out", "1", http.StatusSeeOther, "Guest checked out"},
+		{"Not allowed", "2", http.StatusSeeOther, ""},
+		{"Invalid id", "x", http.StatusNotFound, ""},
+		{"Database error", "3", http.StatusInternalServerError, ""},
+	}
+
+	for _, testCase := range testCases {
+		req, _ := http.NewRequest("POST", "/admin/front-desk/"+testCase.id+"/check-out", strings.NewReader(""))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		rctx := chi.NewRouteContext()
+		rctx.URLParams.Add("id", testCase.id)
+		ctx := getCtx(req)
+		req = req.WithContext(context.WithValue(ctx, chi.RouteCtxKey, rctx))
+		rr := httptest.NewRecorder()
+		handler := http.HandlerFunc(Repo.AdminPostCheckOut)
+		handler.ServeHTTP(rr, req)
+
+		if rr.Code != testCase.want {
+			t.Errorf("AdminPostCheckOut handler returned wrong status for (%s): got %d, want %d", testCase.name, rr.Code, testCase.want)
+		}
+		if flash := session.PopString(req.Context(), "flash"); flash != testCase.flash {
+			t.Errorf("AdminPostCheckOut handler flashed wrong message for (%s): got %q, want %q", testCase.name, flash, testCase.flash)
+		}
+	}
+}
+
+func TestRepository_AdminRegistrationCard(t *testing.T) {
+	req, _ := http.NewRequest("GET", "/admin/front-desk/1/card", nil)
+	rctx := chi.NewRouteContext()
+	rctx.URLParams.Add("id", "1")
+	ctx := getCtx(req)
+	req = req.WithContext(context.WithValue(ctx, chi.RouteCtxKey, rctx))
+	rr := httptest.NewRecorder()
+	handler := http.HandlerFunc(Repo.AdminRegistrationCard)
+	handler.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Errorf("AdminRegistrationCard handler returned wrong status: got %d, want %d", rr.Code, http.StatusOK)
+	}
+	for _, s := range []string{"Registration Card", "Passport", "X1234567", "AB-123-CD"} {
+		if !strings.Contains(rr.Body.String(), s) {
+			t.Errorf("AdminRegistrationCard handler should render %q", s)
+		}
+	}
+}
@@ -0,0 +1,181 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-course/bookings/internal/forms"
+	"github.com/go-course/bookings/internal/helpers"
+	"github.com/go-course/bookings/internal/models"
+	"github.com/go-course/bookings/internal/render"
+)
+
+// guestSearchLimit bounds the guests listed at once
+const guestSearchLimit = 100
+
+// AdminGuests lists the guests matching the search in the url query, most recent stays first
+func (m *Repository) AdminGuests(w http.ResponseWriter, r *http.Request) {
+	q := strings.TrimSpace(r.URL.Query().Get("q"))
+
+	guests, err := m.DB.SearchGuests(q, guestSearchLimit)
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	data := make(map[string]interface{})
+	data["guests"] = guests
+
+	stringMap := make(map[string]string)
+	stringMap["q"] = q
+
+	render.Template(w, r, "admin-guests.page.tmpl", &models.TemplateData{
+		StringMap: stringMap,
+		Data:      data,
+	})
+}
+
+// AdminShowGuest shows a guest profile with their stays and possible duplicates
+func (m *Repository) AdminShowGuest(w http.ResponseWriter, r *http.Request) {
+	guest, ok := m.guestFromURL(w, r)
+	if !ok {
+		return
+	}
+
+	form := forms.New(url.Values{
+		"first_name": {guest.FirstName},
+		"last_name":  {guest.LastName},
+		"email":      {guest.Email},
+		"phone":      {guest.Phone},
+		"notes":      {guest.Notes},
+	})
+	m.renderGuest(w, r, guest, form)
+}
+
+// AdminPostGuest updates the details and notes of a guest
+func (m *Repository) AdminPostGuest(w http.ResponseWriter, r *http.Request) {
+	err := r.ParseForm()
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	guest, ok := m.guestFromURL(w, r)
+	if !ok {
+		return
+	}
+
+	form := forms.New(r.PostForm)
+	form.Required("first_name", "last_name")
+	if form.Has("email") {
+		form.IsEmail("email")
+	}
+	if !form.Valid() {
+		m.renderGuest(w, r, guest, form)
+		return
+	}
+
+	guest.FirstName = strings.TrimSpace(form.Get("first_name"))
+	guest.LastName = strings.TrimSpace(form.Get("last_name"))
+	guest.Email = form.Get("email")
+	guest.Phone = form.Get("phone")
+	guest.Notes = strings.TrimSpace(form.Get("notes"))
+
+	err = m.auditDB(r).UpdateGuest(guest)
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	m.App.Session.Put(r.Context(), "flash", "Guest saved")
+	http.Redirect(w, r, fmt.Sprintf("/admin/guests/%d", guest.ID), http.StatusSeeOther)
+}
+
+// AdminMergeGuest merges a duplicate profile into the guest in the url
+func (m *Repository) AdminMergeGuest(w http.ResponseWriter, r *http.Request) {
+	err := r.ParseForm()
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		helpers.ClientError(w, http.StatusNotFound)
+		return
+	}
+	target := fmt.Sprintf("/admin/guests/%d", id)
+
+	duplicateID, err := strconv.Atoi(r.Form.Get("duplicate_id"))
+	if err != nil || duplicateID == id {
+		m.App.Session.Put(r.Context(), "error", "Choose another guest to merge")
+		http.Redirect(w, r, target, http.StatusSeeOther)
+		return
+	}
+
+	err = m.auditDB(r).MergeGuests(id, duplicateID)
+	if errors.Is(err, sql.ErrNoRows) {
+		m.App.Session.Put(r.Context(), "error", "Guest not found")
+		http.Redirect(w, r, target, http.StatusSeeOther)
+		return
+	}
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	m.App.Session.Put(r.Context(), "flash", fmt.Sprintf("Guest #%d merged into this profile", duplicateID))
+	http.Redirect(w, r, target, http.StatusSeeOther)
+}
+
+// renderGuest shows the profile page of a guest
+func (m *Repository) renderGuest(w http.ResponseWriter, r *http.Request, guest models.Guest, form *forms.Form) {
+	stays, err := m.DB.GuestReservations(guest.ID)
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	duplicates, err := m.DB.PossibleDuplicateGuests(guest)
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	data := make(map[string]interface{})
+	data["guest"] = guest
+	data["stays"] = stays
+	data["duplicates"] = duplicates
+
+	render.Template(w, r, "admin-guest.page.tmpl", &models.TemplateData{
+		Data: data,
+		Form: form,
+	})
+}
+
+// guestFromURL loads the guest in the url, writing a not found response if there is none
+func (m *Repository) guestFromURL(w http.ResponseWriter, r *http.Request) (models.Guest, bool) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		helpers.ClientError(w, http.StatusNotFound)
+		return models.Guest{}, false
+	}
+
+	guest, err := m.DB.GetGuestByID(id)
+	if errors.Is(err, sql.ErrNoRows) {
+		helpers.ClientError(w, http.StatusNotFound)
+		return guest, false
+	}
+	if err != nil {
+		helpers.ServerError(w, err)
+		return guest, false
+	}
+
+	return guest, true
+}
@@ -0,0 +1,159 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+)
+
+func TestRepository_AdminGuests(t *testing.T) {
+	var testCases = []struct {
+		name  string
+		query string
+		want  []string
+	}{
+		{"All guests", "", []string{"/admin/guests/1", "John Smith", "/admin/guests/2"}},
+		{"No match", "?q=nobody", []string{"No guests found"}},
+	}
+
+	for _, testCase := range testCases {
+		req, _ := http.NewRequest("GET", "/admin/guests"+testCase.query, nil)
+		ctx := getCtx(req)
+		req = req.WithContext(ctx)
+		rr := httptest.NewRecorder()
+		handler := http.HandlerFunc(Repo.AdminGuests)
+		handler.ServeHTTP(rr, req)
+
+		if rr.Code != http.StatusOK {
+			t.Errorf("AdminGuests handler returned wrong status for (%s): got %d, want %d", testCase.name, rr.Code, http.StatusOK)
+		}
+		for _, s := range testCase.want {
+			if !strings.Contains(rr.Body.String(), s) {
+				t.Errorf("AdminGuests handler should render %q for (%s)", s, testCase.name)
+			}
+		}
+	}
+}
+
+func TestRepository_AdminShowGuest(t *testing.T) {
+	var testCases = []struct {
+		name string
+		id   string
+		want int
+	}{
+		{"Guest", "1", http.StatusOK},
+		{"Unknown guest", "4", http.StatusNotFound},
+		{"Invalid id", "x", http.StatusNotFound},
+		{"Database error", "3", http.StatusInternalServerError},
+	}
+
+	for _, testCase := range testCases {
+		req, _ := http.NewRequest("GET", "/admin/guests/"+testCase.id, nil)
+		rctx := chi.NewRouteContext()
+		rctx.URLParams.Add("id", testCase.id)
+		ctx := getCtx(req)
+		req = req.WithContext(context.WithValue(ctx, chi.RouteCtxKey, rctx))
+		rr := httptest.NewRecorder()
+		handler := http.HandlerFunc(Repo.AdminShowGuest)
+		handler.ServeHTTP(rr, req)
+
+		if rr.Code != testCase.want {
+			t.Errorf("AdminShowGuest handler returned wrong status for (%s): got %d, want %d", testCase.name, rr.Code, testCase.want)
+		}
+		if rr.Code != http.StatusOK {
+			continue
+		}
+		for _, s := range []string{"Likes a quiet room", "Generals Quarters", "/admin/reservations/all/1/show", "#2 Jon Smith"} {
+			if !strings.Contains(rr.Body.String(), s) {
+				t.Errorf("AdminShowGuest handler should render %q", s)
+			}
+		}
+	}
+}
+
+func TestRepository_AdminPostGuest(t *testing.T) {
+	var testCases = []struct {
+		name     string
+		id       string
+		email    string
+		want     int
+		location string
+	}{
+		{"Save", "1", "john@smith.com", http.StatusSeeOther, "/admin/guests/1"},
+		{"No email", "2", "", http.StatusSeeOther, "/admin/guests/2"},
+		{"Invalid email", "1", "john", http.StatusOK, ""},
+		{"Unknown guest", "4", "john@smith.com", http.StatusNotFound, ""},
+	}
+
+	for _, testCase := range testCases {
+		postedData := url.Values{}
+		postedData.Add("first_name", "John")
+		postedData.Add("last_name", "Smith")
+		postedData.Add("email", testCase.email)
+		postedData.Add("notes", "Allergic to feathers")
+
+		req, _ := http.NewRequest("POST", "/admin/guests/"+testCase.id, strings.NewReader(postedData.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		rctx := chi.NewRouteContext()
+		rctx.URLParams.Add("id", testCase.id)
+		ctx := getCtx(req)
+		req = req.WithContext(context.WithValue(ctx, chi.RouteCtxKey, rctx))
+		rr := httptest.NewRecorder()
+		handler := http.HandlerFunc(Repo.AdminPostGuest)
+		handler.ServeHTTP(rr, req)
+
+		if rr.Code != testCase.want {
+			t.Errorf("AdminPostGuest handler returned wrong status for (%s): got %d, want %d", testCase.name, rr.Code, testCase.want)
+		}
+		if location := rr.Header().Get("Location"); location != testCase.location {
+			t.Errorf("AdminPostGuest handler redirected to wrong location for (%s): got %q, want %q", testCase.name, location, testCase.location)
+		}
+	}
+}
+
+func TestRepository_AdminMergeGuest(t *testing.T) {
+	var testCases = []struct {
+		name        string
+		id          string
+		duplicateID string
+		want        int
+		flash       string
+		error       string
+	}{
+		{"Merge", "1", "2", http.StatusSeeOther, "Guest #2 merged into this profile", ""},
+		{"Into itself", "1", "1", http.StatusSeeOther, "", "Choose another guest to merge"},
+		{"No duplicate", "1", "", http.StatusSeeOther, "", "Choose another guest to merge"},
+		{"Unknown guest", "1", "7", http.StatusSeeOther, "", "Guest not found"},
+		{"Invalid id", "x", "2", http.StatusNotFound, "", ""},
+	}
+
+	for _, testCase := range testCases {
+		postedData := url.Values{}
+		postedData.Add("duplicate_id", testCase.duplicateID)
+
+		req, _ := http.NewRequest("POST", "/admin/guests/"+testCase.id+"/merge", strings.NewReader(postedData.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		rctx := chi.NewRouteContext()
+		rctx.URLParams.Add("id", testCase.id)
+		ctx := getCtx(req)
+		req = req.WithContext(context.WithValue(ctx, chi.RouteCtxKey, rctx))
+		rr := httptest.NewRecorder()
+		handler := http.HandlerFunc(Repo.AdminMergeGuest)
+		handler.ServeHTTP(rr, req)
+
+		if rr.Code != testCase.want {
+			t.Errorf("AdminMergeGuest handler returned wrong status for (%s): got %d, want %d", testCase.name, rr.Code, testCase.want)
+		}
+		if flash := session.PopString(req.Context(), "flash"); flash != testCase.flash {
+			t.Errorf("AdminMergeGuest handler flashed wrong message for (%s): got %q, want %q", testCase.name, flash, testCase.flash)
+		}
+		if msg := session.PopString(req.Context(), "error"); msg != testCase.error {
+			t.Errorf("AdminMergeGuest handler flashed wrong error for (%s): got %q, want %q", testCase.name, msg, testCase.error)
+		}
+	}
+}
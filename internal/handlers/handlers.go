@@ -1,16 +1,21 @@
 package handlers
 
 import (
+	"database/sql"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"math"
 	"net/http"
+	"net/url"
 	"strconv"
 	"strings"
 	"time"
 
+	"github.com/go-chi/chi/v5"
 	"github.com/go-course/bookings/internal/config"
 	"github.com/go-course/bookings/internal/driver"
+	"github.com/go-course/bookings/internal/emails"
 	"github.com/go-course/bookings/internal/forms"
 	"github.com/go-course/bookings/internal/helpers"
 	"github.com/go-course/bookings/internal/models"
@@ -86,6 +91,7 @@ func (m *Repository) Reservation(w http.ResponseWriter, r *http.Request) {
 	stringMap["start_date"] = sd
 	stringMap["end_date"] = ed
 	data["reservation"] = res
+	data["sms"] = m.App.SMSEnabled
 	render.Template(w, r, "make-reservation.page.tmpl", &models.TemplateData{
 		Form:      forms.New(nil),
 		Data:      data,
@@ -128,6 +134,7 @@ func (m *Repository) PostReservation(w http.ResponseWriter, r *http.Request) {
 	if err != nil {
 		m.App.Session.Put(r.Context(), "error", "Can't find room")
 		http.Redirect(w, r, "/", http.StatusTemporaryRedirect)
+		return
 	}
 
 	var reservation = models.Reservation{
@@ -145,6 +152,14 @@ func (m *Repository) PostReservation(w http.ResponseWriter, r *http.Request) {
 	form.Required("first_name", "last_name", "email")
 	form.MinLength("first_name", 3)
 	form.IsEmail("email")
+	// a guest who wants text messages has to give a number they can be sent to
+	reservation.SMSOptIn = m.App.SMSEnabled && form.Has("sms_opt_in")
+	if reservation.SMSOptIn {
+		form.Required("phone")
+	}
+	if form.Has("phone") {
+		reservation.Phone = form.Phone("phone", m.App.SMSCountryCode)
+	}
 
 	if !form.Valid() {
 		stringMap := make(map[string]string)
@@ -152,6 +167,7 @@ func (m *Repository) PostReservation(w http.ResponseWriter, r *http.Request) {
 		stringMap["end_date"] = ed
 		data := make(map[string]interface{})
 		data["reservation"] = reservation
+		data["sms"] = m.App.SMSEnabled
 		render.Template(w, r, "make-reservation.page.tmpl", &models.TemplateData{
 			Form:      form,
 			Data:      data,
@@ -160,75 +176,30 @@ func (m *Repository) PostReservation(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	newReservationID, err := m.DB.InsertReservation(reservation)
+	n, err := m.notification(models.EventNewBooking)
 	if err != nil {
-		m.App.Session.Put(r.Context(), "error", "can't insert reservation into database")
+		m.App.Session.Put(r.Context(), "error", "can't load notification settings")
 		http.Redirect(w, r, "/", http.StatusTemporaryRedirect)
 		return
 	}
 
-	restriction := models.RoomRestriction{
-		StartDate:     reservation.StartDate,
-		EndDate:       reservation.EndDate,
-		RoomID:        reservation.RoomID,
-		ReservationID: newReservationID,
-		RestrictionID: 1,
-	}
-
-	err = m.DB.InsertRoomRestriction(restriction)
+	// the reservation, its guest, its room restriction and the notifications are saved together
+	reservation.ID, err = m.DB.BookReservation(reservation, func(res models.Reservation) ([]models.MailData, models.SMSData, error) {
+		messages, err := m.reservationMail(res, n)
+		if err != nil {
+			return nil, models.SMSData{}, err
+		}
+		text, err := m.reservationText(res)
+		return messages, text, err
+	})
 	if err != nil {
-		m.App.Session.Put(r.Context(), "error", "can't insert room restriction")
+		m.App.Session.Put(r.Context(), "error", "can't insert reservation into database")
 		http.Redirect(w, r, "/", http.StatusTemporaryRedirect)
 		return
 	}
-
-	room, err = m.DB.GetRoomByID(reservation.RoomID)
-	if err != nil {
-		m.App.Session.Put(r.Context(), "error", "Can't find room")
-		http.Redirect(w, r, "/", http.StatusTemporaryRedirect)
-	}
-
-	reservation.Room.RoomName = room.RoomName
-
-	// send notifications - first to guest
-	htmlMessage := fmt.Sprintf(`
-		<h2>Reservation Confirmation</h2><br/>
-		Dear %s, <br/>
-		This is to confirm your reservation from %s to %s for room: <strong>%s</strong>
-	`,
-		reservation.FirstName,
-		reservation.StartDate.Format("2006-01-02"),
-		reservation.EndDate.Format("2006-01-02"),
-		reservation.Room.RoomName,
-	)
-	msg := models.MailData{
-		To:       reservation.Email,
-		From:     "rajiv@mkcl.org",
-		Subject:  "Reservation Confirmation",
-		Content:  htmlMessage,
-		Template: "basic.html",
-	}
-
-	m.App.MailChan <- msg
-
-	// Send to property owner
-	htmlMessage = fmt.Sprintf(`
-		<h2>Reservation Notification</h2><br/>
-		This is to notify you that reservation for room <strong>%s</strong> has been made from %s to %s
-	`,
-		reservation.Room.RoomName,
-		reservation.StartDate.Format("2006-01-02"),
-		reservation.EndDate.Format("2006-01-02"),
-	)
-	msg = models.MailData{
-		To:       "rajiv@mkcl.org",
-		From:     "rajiv@mkcl.org",
-		Subject:  "Reservation Notification",
-		Content:  htmlMessage,
-		Template: "basic.html",
-	}
-
-	m.App.MailChan <- msg
+	// new reservations start out pending
+	reservation.Status = models.ReservationPending
+	m.publishReservation(models.WebhookReservationCreated, reservation)
 
 	m.App.Session.Put(r.Context(), "reservation", reservation)
 	http.Redirect(w, r, "/reservation-summary", http.StatusSeeOther)
@@ -509,41 +480,101 @@ func (m *Repository) Logout(w http.ResponseWriter, r *http.Request) {
 	http.Redirect(w, r, "/user/login", http.StatusSeeOther)
 }
 
-// AdminDashboard displays the dashboard for admin
+// dashboardWindows are the numbers of days from today the dashboard shows room occupancy for
+var dashboardWindows = []int{30, 90}
+
+// AdminDashboard displays today's arrivals and departures, room occupancy and booking lead times
 func (m *Repository) AdminDashboard(w http.ResponseWriter, r *http.Request) {
-	render.Template(w, r, "admin-dashboard.page.tmpl", &models.TemplateData{})
-}
+	now := time.Now()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
 
-// AdminNewReservations renders all the new reservations
-func (m *Repository) AdminNewReservations(w http.ResponseWriter, r *http.Request) {
-	reservation, err := m.DB.AllNewReservations()
+	counts, err := m.DB.DashboardCounts(today)
 	if err != nil {
-		m.App.ErrorLog.Println(err)
+		helpers.ServerError(w, err)
 		return
 	}
 
-	data := make(map[string]interface{})
-	data["reservations"] = reservation
-	render.Template(w, r, "admin-new-reservations.page.tmpl", &models.TemplateData{
-		Data: data,
-	})
-}
+	rooms, err := m.DB.AllRooms()
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+	var roomNames []string
+	index := make(map[int]int)
+	for i, room := range rooms {
+		roomNames = append(roomNames, room.RoomName)
+		index[room.ID] = i
+	}
 
-// AdminAllReservations renders all reservations
-func (m *Repository) AdminAllReservations(w http.ResponseWriter, r *http.Request) {
-	reservation, err := m.DB.AllReservations()
+	// occupancy of each room over each window, in percent
+	occupancy := make([][]float64, len(dashboardWindows))
+	for i, days := range dashboardWindows {
+		occupancy[i] = make([]float64, len(rooms))
+		err := m.DB.EachRoomOccupancy(today, today.AddDate(0, 0, days), func(o models.RoomOccupancy) error {
+			if j, ok := index[o.Room.ID]; ok {
+				occupancy[i][j] = math.Round(o.Rate()*1000) / 10
+			}
+			return nil
+		})
+		if err != nil {
+			helpers.ServerError(w, err)
+			return
+		}
+	}
+
+	leadTimes, err := m.DB.LeadTimes(today.AddDate(-1, 0, 0))
 	if err != nil {
-		m.App.ErrorLog.Println(err)
+		helpers.ServerError(w, err)
 		return
 	}
 
+	var windows, leadLabels []string
+	var leadCounts []int
+	for _, days := range dashboardWindows {
+		windows = append(windows, fmt.Sprintf("Next %d days", days))
+	}
+	for _, b := range leadTimes {
+		leadLabels = append(leadLabels, b.Label)
+		leadCounts = append(leadCounts, b.Count)
+	}
+
 	data := make(map[string]interface{})
-	data["reservations"] = reservation
-	render.Template(w, r, "admin-all-reservations.page.tmpl", &models.TemplateData{
-		Data: data,
+	data["counts"] = counts
+	data["rooms"] = roomNames
+	data["windows"] = windows
+	data["occupancy"] = occupancy
+	data["lead_times"] = leadTimes
+	data["chart"] = map[string]interface{}{
+		"rooms":      roomNames,
+		"windows":    windows,
+		"occupancy":  occupancy,
+		"leadLabels": leadLabels,
+		"leadCounts": leadCounts,
+	}
+
+	// the occupancy export defaults to last month
+	firstOfMonth := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location())
+
+	stringMap := make(map[string]string)
+	stringMap["occupancy_from"] = firstOfMonth.AddDate(0, -1, 0).Format("2006-01-02")
+	stringMap["occupancy_to"] = firstOfMonth.AddDate(0, 0, -1).Format("2006-01-02")
+
+	render.Template(w, r, "admin-dashboard.page.tmpl", &models.TemplateData{
+		StringMap: stringMap,
+		Data:      data,
 	})
 }
 
+// AdminNewReservations renders the pending reservations
+func (m *Repository) AdminNewReservations(w http.ResponseWriter, r *http.Request) {
+	m.renderReservationList(w, r, "admin-new-reservations.page.tmpl", "new", models.ReservationPending)
+}
+
+// AdminAllReservations renders all reservations
+func (m *Repository) AdminAllReservations(w http.ResponseWriter, r *http.Request) {
+	m.renderReservationList(w, r, "admin-all-reservations.page.tmpl", "all", "")
+}
+
 // AdminReservationsCalendar displays the reservation calendar
 func (m *Repository) AdminReservationsCalendar(w http.ResponseWriter, r *http.Request) {
 	now := time.Now()
@@ -591,12 +622,27 @@ func (m *Repository) AdminReservationsCalendar(w http.ResponseWriter, r *http.Re
 
 	data["rooms"] = rooms
 
+	statuses, err := m.DB.RoomStatuses()
+	if err != nil {
+		m.App.ErrorLog.Println(err)
+		return
+	}
+	statusMap := make(map[int]models.RoomStatus)
+	for _, s := range statuses {
+		statusMap[s.RoomID] = s
+	}
+	data["room_statuses"] = statusMap
+
 	for _, x := range rooms {
 		reservationMap := make(map[string]int)
 		blockMap := make(map[string]int)
+		versionMap := make(map[string]int)
+		externalMap := make(map[string]int)
 		for d := firstOfMonth; !d.After(lastOfMonth); d = d.AddDate(0, 0, 1) {
 			reservationMap[d.Format("2006-01-2")] = 0
 			blockMap[d.Format("2006-01-2")] = 0
+			versionMap[d.Format("2006-01-2")] = 0
+			externalMap[d.Format("2006-01-2")] = 0
 		}
 
 		restrictions, err := m.DB.GetRestrictionsForRoomByDate(x.ID, firstOfMonth, lastOfMonth)
@@ -610,13 +656,19 @@ func (m *Repository) AdminReservationsCalendar(w http.ResponseWriter, r *http.Re
 				for d := r.StartDate; !d.After(r.EndDate); d = d.AddDate(0, 0, 1) {
 					reservationMap[d.Format("2006-01-2")] = r.ReservationID
 				}
+			} else if r.RestrictionID == models.RestrictionExternalBooking {
+				for d := r.StartDate; d.Before(r.EndDate); d = d.AddDate(0, 0, 1) {
+					externalMap[d.Format("2006-01-2")] = r.SourceID
+				}
 			} else {
 				blockMap[r.StartDate.Format("2006-01-2")] = r.ID
+				versionMap[r.StartDate.Format("2006-01-2")] = r.Version
 			}
 		}
 		data[fmt.Sprintf("reservation_map_%d", x.ID)] = reservationMap
 		data[fmt.Sprintf("block_map_%d", x.ID)] = blockMap
-		m.App.Session.Put(r.Context(), fmt.Sprintf("block_map_%d", x.ID), blockMap)
+		data[fmt.Sprintf("block_version_map_%d", x.ID)] = versionMap
+		data[fmt.Sprintf("external_map_%d", x.ID)] = externalMap
 	}
 
 	render.Template(w, r, "admin-reservations-calendar.page.tmpl", &models.TemplateData{
@@ -626,7 +678,7 @@ func (m *Repository) AdminReservationsCalendar(w http.ResponseWriter, r *http.Re
 	})
 }
 
-// AdminPostReservationsCalendar updates the changes that are added to the calendar
+// AdminPostReservationsCalendar applies the block changes posted from the calendar
 func (m *Repository) AdminPostReservationsCalendar(w http.ResponseWriter, r *http.Request) {
 	err := r.ParseForm()
 	if err != nil {
@@ -636,61 +688,108 @@ func (m *Repository) AdminPostReservationsCalendar(w http.ResponseWriter, r *htt
 
 	year, _ := strconv.Atoi(r.Form.Get("y"))
 	month, _ := strconv.Atoi(r.Form.Get("m"))
+	redirectTo := fmt.Sprintf("/admin/reservations-calendar?y=%d&m=%d", year, month)
 
-	rooms, err := m.DB.AllRooms()
+	changes, err := calendarBlockChanges(r.PostForm)
 	if err != nil {
-		helpers.ServerError(w, err)
+		m.App.Session.Put(r.Context(), "error", err.Error())
+		http.Redirect(w, r, redirectTo, http.StatusSeeOther)
 		return
 	}
 
-	form := forms.New(r.PostForm)
-
-	for _, x := range rooms {
-		curMap, ok := m.App.Session.Get(r.Context(), fmt.Sprintf("block_map_%d", x.ID)).(map[string]int)
-		if !ok {
-			helpers.ServerError(w, errors.New("nothing in session"))
-			return
-		}
+	conflicts, err := m.auditDB(r).ApplyBlockChanges(changes)
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
 
-		for name, value := range curMap {
-			if val, ok := curMap[name]; ok {
-				if val > 0 {
-					if !form.Has(fmt.Sprintf("remove_block_%d_%s", x.ID, name)) {
-						err := m.DB.DeleteBlockByID(value)
-						if err != nil {
-							helpers.ServerError(w, err)
-						}
-					}
-				}
-			}
+	if len(conflicts) > 0 {
+		var msgs []string
+		for _, c := range conflicts {
+			msgs = append(msgs, fmt.Sprintf("room %d on %s: %s", c.Change.RoomID, c.Change.Date.Format("2006-01-02"), c.Reason))
 		}
+		m.App.Session.Put(r.Context(), "error", "No changes saved, the calendar was changed meanwhile ("+strings.Join(msgs, "; ")+")")
+		http.Redirect(w, r, redirectTo, http.StatusSeeOther)
+		return
 	}
+	m.publishBlocks(changes)
 
-	for name := range r.PostForm {
-		if strings.HasPrefix(name, "add_block_") {
-			exploded := strings.Split(name, "_")
-			roomID, _ := strconv.Atoi(exploded[2])
-			t, _ := time.Parse("2006-01-02", exploded[3])
-			err := m.DB.InsertBlockForRoom(roomID, t)
+	m.App.Session.Put(r.Context(), "flash", "Changes saved")
+	http.Redirect(w, r, redirectTo, http.StatusSeeOther)
+}
+
+// calendarBlockChanges turns the posted calendar form into explicit block changes. Every block shown
+// on the calendar is posted back as orig_block_{room}_{date}={id}:{version}, and every checked day as
+// block_{room}_{date}, so the diff between the two is what the admin changed.
+func calendarBlockChanges(form url.Values) ([]models.BlockChange, error) {
+	var changes []models.BlockChange
+
+	for name := range form {
+		switch {
+		case strings.HasPrefix(name, "orig_block_"):
+			roomID, date, err := parseCalendarField(strings.TrimPrefix(name, "orig_block_"))
 			if err != nil {
-				helpers.ServerError(w, err)
+				return nil, err
+			}
+			if form.Get(strings.TrimPrefix(name, "orig_")) != "" {
+				continue
 			}
+			var blockID, version int
+			if _, err := fmt.Sscanf(form.Get(name), "%d:%d", &blockID, &version); err != nil {
+				return nil, fmt.Errorf("invalid block %s", name)
+			}
+			changes = append(changes, models.BlockChange{
+				Action:  models.BlockActionRemove,
+				RoomID:  roomID,
+				Date:    date,
+				BlockID: blockID,
+				Version: version,
+			})
+
+		case strings.HasPrefix(name, "block_"):
+			if form.Get("orig_"+name) != "" {
+				continue
+			}
+			roomID, date, err := parseCalendarField(strings.TrimPrefix(name, "block_"))
+			if err != nil {
+				return nil, err
+			}
+			changes = append(changes, models.BlockChange{
+				Action: models.BlockActionAdd,
+				RoomID: roomID,
+				Date:   date,
+			})
 		}
 	}
 
-	m.App.Session.Put(r.Context(), "flash", "Changes saved")
-	http.Redirect(w, r, fmt.Sprintf("/admin/reservations-calendar?y=%d&m=%d", year, month), http.StatusSeeOther)
+	return changes, nil
+}
+
+// parseCalendarField parses the {room}_{date} suffix of a calendar form field
+func parseCalendarField(suffix string) (int, time.Time, error) {
+	exploded := strings.SplitN(suffix, "_", 2)
+	if len(exploded) != 2 {
+		return 0, time.Time{}, fmt.Errorf("invalid calendar field %s", suffix)
+	}
+	roomID, err := strconv.Atoi(exploded[0])
+	if err != nil {
+		return 0, time.Time{}, fmt.Errorf("invalid room in calendar field %s", suffix)
+	}
+	date, err := time.Parse("2006-01-2", exploded[1])
+	if err != nil {
+		return 0, time.Time{}, fmt.Errorf("invalid date in calendar field %s", suffix)
+	}
+	return roomID, date, nil
 }
 
 // AdminShowReservation displays selected reservation
 func (m *Repository) AdminShowReservation(w http.ResponseWriter, r *http.Request) {
-	exploded := strings.Split(r.RequestURI, "/")
-	id, err := strconv.Atoi(exploded[4])
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
 	if err != nil {
 		helpers.ServerError(w, err)
 		return
 	}
-	src := exploded[3]
+	src := chi.URLParam(r, "src")
 	stringMap := make(map[string]string)
 	stringMap["src"] = src
 
@@ -703,6 +802,7 @@ func (m *Repository) AdminShowReservation(w http.ResponseWriter, r *http.Request
 	res, err := m.DB.GetReservationById(id)
 	if err != nil {
 		helpers.ServerError(w, err)
+		return
 	}
 
 	data := make(map[string]interface{})
@@ -722,15 +822,12 @@ func (m *Repository) AdminPostShowReservation(w http.ResponseWriter, r *http.Req
 		helpers.ServerError(w, err)
 		return
 	}
-	exploded := strings.Split(r.URL.Path, "/")
-	id, err := strconv.Atoi(exploded[4])
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
 	if err != nil {
 		helpers.ServerError(w, err)
 		return
 	}
-	src := exploded[3]
-	stringMap := make(map[string]string)
-	stringMap["src"] = src
+	src := chi.URLParam(r, "src")
 
 	res, err := m.DB.GetReservationById(id)
 	if err != nil {
@@ -743,11 +840,31 @@ func (m *Repository) AdminPostShowReservation(w http.ResponseWriter, r *http.Req
 	res.Email = r.Form.Get("email")
 	res.Phone = r.Form.Get("phone")
 
-	err = m.DB.UpdateReservation(res)
+	form := forms.New(r.PostForm)
+	if form.Has("phone") {
+		res.Phone = form.Phone("phone", m.App.SMSCountryCode)
+	}
+	if !form.Valid() {
+		stringMap := make(map[string]string)
+		stringMap["src"] = src
+		stringMap["year"] = r.Form.Get("year")
+		stringMap["month"] = r.Form.Get("month")
+		data := make(map[string]interface{})
+		data["reservation"] = res
+		render.Template(w, r, "admin-reservations-show.page.tmpl", &models.TemplateData{
+			StringMap: stringMap,
+			Data:      data,
+			Form:      form,
+		})
+		return
+	}
+
+	err = m.auditDB(r).UpdateReservation(res)
 	if err != nil {
 		helpers.ServerError(w, err)
 		return
 	}
+	m.notify(models.EventModification, "", id)
 
 	month := r.Form.Get("month")
 	year := r.Form.Get("year")
@@ -762,26 +879,50 @@ func (m *Repository) AdminPostShowReservation(w http.ResponseWriter, r *http.Req
 
 }
 
-// AdminProcessReservation displays process reservation
-func (m *Repository) AdminProcessReservation(w http.ResponseWriter, r *http.Request) {
-	exploded := strings.Split(r.URL.Path, "/")
-	id, err := strconv.Atoi(exploded[4])
+// AdminPostReservationStatus moves a reservation to the posted status
+func (m *Repository) AdminPostReservationStatus(w http.ResponseWriter, r *http.Request) {
+	err := r.ParseForm()
 	if err != nil {
 		helpers.ServerError(w, err)
 		return
 	}
-	src := exploded[3]
 
-	err = m.DB.UpdateProcessedForReservation(id, 1)
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
 	if err != nil {
+		helpers.ClientError(w, http.StatusNotFound)
+		return
+	}
+	src := chi.URLParam(r, "src")
+
+	status := r.Form.Get("status")
+	if !models.ValidReservationStatus(status) {
+		helpers.ClientError(w, http.StatusBadRequest)
+		return
+	}
+
+	err = m.auditDB(r).UpdateReservationStatus(id, status)
+	var transitionErr *models.TransitionError
+	switch {
+	case errors.As(err, &transitionErr):
+		m.App.Session.Put(r.Context(), "error", "Status not changed: "+transitionErr.Error())
+	case errors.Is(err, sql.ErrNoRows):
+		helpers.ClientError(w, http.StatusNotFound)
+		return
+	case err != nil:
 		helpers.ServerError(w, err)
 		return
+	default:
+		m.App.Session.Put(r.Context(), "flash", "Reservation marked as "+status)
+		if status == models.ReservationCancelled {
+			m.notify(models.EventCancellation, emails.Cancellation, id)
+		} else {
+			m.publishUpdate(id)
+		}
 	}
 
-	year := r.URL.Query().Get("y")
-	month := r.URL.Query().Get("m")
+	year := r.Form.Get("year")
+	month := r.Form.Get("month")
 
-	m.App.Session.Put(r.Context(), "flash", "Reservation marked as processed")
 	if year == "" {
 		http.Redirect(w, r, "/admin/reservations-"+src, http.StatusSeeOther)
 	} else {
@@ -789,29 +930,55 @@ func (m *Repository) AdminProcessReservation(w http.ResponseWriter, r *http.Requ
 	}
 }
 
-// AdminDeleteReservation displays process reservation
+// AdminDeleteReservation moves a reservation to the trash
 func (m *Repository) AdminDeleteReservation(w http.ResponseWriter, r *http.Request) {
-	exploded := strings.Split(r.URL.Path, "/")
-	id, err := strconv.Atoi(exploded[4])
+	err := r.ParseForm()
 	if err != nil {
 		helpers.ServerError(w, err)
 		return
 	}
-	src := exploded[3]
 
-	err = m.DB.DeleteReservation(id)
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
 	if err != nil {
 		helpers.ServerError(w, err)
 		return
 	}
+	src := chi.URLParam(r, "src")
 
-	year := r.URL.Query().Get("y")
-	month := r.URL.Query().Get("m")
+	err = m.deleteReservation(r, id)
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	year := r.Form.Get("year")
+	month := r.Form.Get("month")
 
-	m.App.Session.Put(r.Context(), "flash", "Reservation marked as processed")
+	m.App.Session.Put(r.Context(), "flash", "Reservation moved to trash")
 	if year == "" {
 		http.Redirect(w, r, "/admin/reservations-"+src, http.StatusSeeOther)
 	} else {
 		http.Redirect(w, r, "/admin/reservations-calendar?y="+year+"&m="+month, http.StatusSeeOther)
 	}
 }
+
+// AdminAPIDeleteReservation moves a reservation to the trash, answering 204 No Content
+func (m *Repository) AdminAPIDeleteReservation(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		helpers.ClientError(w, http.StatusNotFound)
+		return
+	}
+
+	err = m.deleteReservation(r, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		helpers.ClientError(w, http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -11,6 +11,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/go-chi/chi/v5"
 	"github.com/go-course/bookings/internal/models"
 )
 
@@ -35,6 +36,8 @@ func TestGetHandlers(t *testing.T) {
 		{"Show login Page", "/user/login", "GET", http.StatusOK},
 		{"Dashboard page", "/admin/dashboard", "GET", http.StatusOK},
 		{"All reservations", "/admin/reservations-all", "GET", http.StatusOK},
+		{"Reservations by status", "/admin/reservations-all?status=confirmed", "GET", http.StatusOK},
+		{"Reservations by unknown status", "/admin/reservations-all?status=processed", "GET", http.StatusBadRequest},
 		{"New reservations", "/admin/reservations-new", "GET", http.StatusOK},
 		{"Show reservations", "/admin/reservations/new/1/show", "GET", http.StatusOK},
 		{"Invalid Reservation id for Show reservations", "/admin/reservations/new/3/show", "GET", http.StatusInternalServerError},
@@ -142,6 +145,11 @@ func TestRepository_PostReservation(t *testing.T) {
 			reqBody: strings.NewReader("start_date=2050-01-01&end_date=2050-01-02&first_name=J&last_name=Smith&email=rajiv@mkcl.org&phone=123456789&room_id=1"),
 			want:    http.StatusOK,
 		},
+		{
+			name:    "Unable to save guest",
+			reqBody: strings.NewReader("start_date=2050-01-01&end_date=2050-01-02&first_name=John&last_name=Smith&email=guest@error.com&phone=123456789&room_id=1"),
+			want:    http.StatusTemporaryRedirect,
+		},
 		{
 			name:    "Unable to add reservation",
 			reqBody: strings.NewReader("start_date=2050-01-01&end_date=2050-01-02&first_name=John&last_name=Smith&email=rajiv@mkcl.org&phone=123456789&room_id=2"),
@@ -507,62 +515,64 @@ func TestRepository_PostShowLogin(t *testing.T) {
 
 func TestRepository_AdminPostReservationsCalendar(t *testing.T) {
 	var testCases = []struct {
-		name        string
-		postData    url.Values
-		sessionData map[string]int
-		want        int
+		name     string
+		postData url.Values
+		want     int
+		flash    string
+		error    string
 	}{
 		{
 			name: "valid case",
 			postData: url.Values{
-				"y":                         {"2050"},
-				"m":                         {"01"},
-				"add_block_1_2050-01-01":    {"1"},
-				"remove_block_1_2050-01-04": {"1"},
-				"remove_block_1_2050-01-06": {"2"},
+				"y":                       {"2050"},
+				"m":                       {"01"},
+				"block_1_2050-01-1":       {"1"},
+				"orig_block_1_2050-01-4":  {"1:1"},
+				"orig_block_1_2050-01-6":  {"2:1"},
+				"block_1_2050-01-6":       {"1"},
+				"orig_block_2_2050-01-10": {"3:1"},
 			},
-			sessionData: map[string]int{},
-			want:        http.StatusSeeOther,
+			want:  http.StatusSeeOther,
+			flash: "Changes saved",
 		},
 		{
-			name: "Adding a block ",
+			name: "Nothing changed",
 			postData: url.Values{
-				"y":                         {"2050"},
-				"m":                         {"01"},
-				"add_block_1_2050-01-01":    {"1"},
-				"remove_block_1_2050-01-04": {"1"},
-				"remove_block_1_2050-01-06": {"2"},
+				"y":                      {"2050"},
+				"m":                      {"01"},
+				"orig_block_1_2050-01-4": {"1:1"},
+				"block_1_2050-01-4":      {"1"},
 			},
-			sessionData: map[string]int{
-				"2050-01-01": 1,
-			},
-			want: http.StatusSeeOther,
+			want:  http.StatusSeeOther,
+			flash: "Changes saved",
 		},
 		{
-			name: "Updating a block ",
+			name: "Stale block version",
 			postData: url.Values{
-				"y":                         {"2050"},
-				"m":                         {"01"},
-				"add_block_1_2050-01-01":    {"1"},
-				"remove_block_1_2050-01-04": {"1"},
-				"remove_block_1_2050-01-06": {"2"},
+				"y":                      {"2050"},
+				"m":                      {"01"},
+				"orig_block_1_2050-01-4": {"1:2"},
 			},
-			sessionData: map[string]int{
-				"2050-01-04": 1,
+			want:  http.StatusSeeOther,
+			error: "No changes saved, the calendar was changed meanwhile (room 1 on 2050-01-04: block was changed by someone else)",
+		},
+		{
+			name: "Malformed block",
+			postData: url.Values{
+				"y":                      {"2050"},
+				"m":                      {"01"},
+				"orig_block_1_2050-01-4": {"x"},
 			},
-			want: http.StatusSeeOther,
+			want:  http.StatusSeeOther,
+			error: "invalid block orig_block_1_2050-01-4",
 		},
 		{
 			name: "Not existing room",
 			postData: url.Values{
-				"y":                         {"2050"},
-				"m":                         {"01"},
-				"add_block_3_2050-01-01":    {"1"},
-				"remove_block_3_2050-01-04": {"1"},
-				"remove_block_3_2050-01-06": {"2"},
-			},
-			sessionData: map[string]int{
-				"2050-01-01": 3,
+				"y":                      {"2050"},
+				"m":                      {"01"},
+				"block_3_2050-01-1":      {"1"},
+				"orig_block_3_2050-01-4": {"1:1"},
 			},
 			want: http.StatusInternalServerError,
 		},
@@ -575,13 +585,54 @@ func TestRepository_AdminPostReservationsCalendar(t *testing.T) {
 		req = req.WithContext(ctx)
 		rr := httptest.NewRecorder()
 		handler := http.HandlerFunc(Repo.AdminPostReservationsCalendar)
-		session.Put(req.Context(), "block_map_1", testCase.sessionData)
-		session.Put(req.Context(), "block_map_2", testCase.sessionData)
 		handler.ServeHTTP(rr, req)
 
 		if rr.Code != testCase.want {
 			t.Errorf("AdminReservationsCalendar handler errored for (%s): got %d, want %d", testCase.name, rr.Code, testCase.want)
 		}
+		if flash := session.PopString(ctx, "flash"); flash != testCase.flash {
+			t.Errorf("AdminReservationsCalendar handler flashed wrong message for (%s): got %q, want %q", testCase.name, flash, testCase.flash)
+		}
+		if msg := session.PopString(ctx, "error"); msg != testCase.error {
+			t.Errorf("AdminReservationsCalendar handler reported wrong error for (%s): got %q, want %q", testCase.name, msg, testCase.error)
+		}
+	}
+}
+
+func TestCalendarBlockChanges(t *testing.T) {
+	form := url.Values{
+		"block_1_2050-01-1":      {"1"},
+		"orig_block_1_2050-01-4": {"7:3"},
+		"orig_block_2_2050-01-5": {"8:1"},
+		"block_2_2050-01-5":      {"1"},
+	}
+
+	changes, err := calendarBlockChanges(form)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(changes) != 2 {
+		t.Fatalf("expected 2 changes, got %d", len(changes))
+	}
+
+	for _, c := range changes {
+		switch c.Action {
+		case models.BlockActionAdd:
+			if c.RoomID != 1 || c.Date.Format("2006-01-02") != "2050-01-01" {
+				t.Errorf("wrong add change: %+v", c)
+			}
+		case models.BlockActionRemove:
+			if c.RoomID != 1 || c.BlockID != 7 || c.Version != 3 || c.Date.Format("2006-01-02") != "2050-01-04" {
+				t.Errorf("wrong remove change: %+v", c)
+			}
+		default:
+			t.Errorf("unexpected action %s", c.Action)
+		}
+	}
+
+	_, err = calendarBlockChanges(url.Values{"block_x_2050-01-1": {"1"}})
+	if err == nil {
+		t.Error("expected error for invalid room")
 	}
 }
 
@@ -627,6 +678,17 @@ func TestRepository_AdminPostShowReservation(t *testing.T) {
 			},
 			want: http.StatusSeeOther,
 		},
+		{
+			name: "Invalid phone",
+			url:  "/admin/reservations/all/1",
+			postData: url.Values{
+				"first_name": {"rajiv"},
+				"last_name":  {"singh"},
+				"email":      {"rajiv@gmail.org"},
+				"phone":      {"89898"},
+			},
+			want: http.StatusOK,
+		},
 		{
 			name: "Invalid id type",
 			url:  "/admin/reservations/cal/as",
@@ -685,8 +747,10 @@ func TestRepository_AdminPostShowReservation(t *testing.T) {
 		ctx := getCtx(req)
 		req = req.WithContext(ctx)
 		rr := httptest.NewRecorder()
-		handler := http.HandlerFunc(Repo.AdminPostShowReservation)
-		handler.ServeHTTP(rr, req)
+		// route the request so the handler gets its url params
+		mux := chi.NewRouter()
+		mux.Post("/admin/reservations/{src}/{id}", Repo.AdminPostShowReservation)
+		mux.ServeHTTP(rr, req)
 
 		if rr.Code != testCase.want {
 			t.Errorf("AdminPostShowReservation handler returned wrong status code for (%s): got %d, want %d", testCase.name, rr.Code, testCase.want)
@@ -694,88 +758,246 @@ func TestRepository_AdminPostShowReservation(t *testing.T) {
 	}
 }
 
-func TestRepository_AdminProcessReservation(t *testing.T) {
+func TestRepository_AdminPostReservationStatus(t *testing.T) {
 	var testCases = []struct {
-		name string
-		url  string
-		want int
+		name     string
+		src      string
+		id       string
+		postData url.Values
+		want     int
+		location string
+		flash    string
+		error    string
 	}{
 		{
-			name: "Valid Case for cal route",
-			url:  "/admin/process-reservation/cal/1/do?y=2050&m=01",
-			want: http.StatusSeeOther,
+			name:     "Valid Case for cal route",
+			src:      "cal",
+			id:       "1",
+			postData: url.Values{"status": {"confirmed"}, "year": {"2050"}, "month": {"01"}},
+			want:     http.StatusSeeOther,
+			location: "/admin/reservations-calendar?y=2050&m=01",
+			flash:    "Reservation marked as confirmed",
 		},
 		{
-			name: "Valid Case for route other than cal",
-			url:  "/admin/process-reservation/all/1/do",
-			want: http.StatusSeeOther,
+			name:     "Valid Case for route other than cal",
+			src:      "all",
+			id:       "1",
+			postData: url.Values{"status": {"cancelled"}},
+			want:     http.StatusSeeOther,
+			location: "/admin/reservations-all",
+			flash:    "Reservation marked as cancelled",
 		},
 		{
-			name: "Invalid reservation id",
-			url:  "/admin/process-reservation/all/3/do",
-			want: http.StatusInternalServerError,
+			name:     "Transition not allowed",
+			src:      "all",
+			id:       "2",
+			postData: url.Values{"status": {"confirmed"}},
+			want:     http.StatusSeeOther,
+			location: "/admin/reservations-all",
+			error:    "Status not changed: a cancelled reservation can't be changed to confirmed",
 		},
 		{
-			name: "Invalid id type",
-			url:  "/admin/process-reservation/all/as/do",
-			want: http.StatusInternalServerError,
+			name:     "Unknown status",
+			src:      "all",
+			id:       "1",
+			postData: url.Values{"status": {"processed"}},
+			want:     http.StatusBadRequest,
+		},
+		{
+			name:     "Invalid reservation id",
+			src:      "all",
+			id:       "3",
+			postData: url.Values{"status": {"confirmed"}},
+			want:     http.StatusInternalServerError,
+		},
+		{
+			name:     "Unknown reservation",
+			src:      "all",
+			id:       "4",
+			postData: url.Values{"status": {"confirmed"}},
+			want:     http.StatusNotFound,
+		},
+		{
+			name:     "Invalid id type",
+			src:      "all",
+			id:       "as",
+			postData: url.Values{"status": {"confirmed"}},
+			want:     http.StatusNotFound,
 		},
 	}
 
 	for _, testCase := range testCases {
-		var req *http.Request
-		req, _ = http.NewRequest("POST", testCase.url, nil)
+		req, _ := http.NewRequest("POST", "/admin/reservations/"+testCase.src+"/"+testCase.id+"/status", strings.NewReader(testCase.postData.Encode()))
 		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		rctx := chi.NewRouteContext()
+		rctx.URLParams.Add("src", testCase.src)
+		rctx.URLParams.Add("id", testCase.id)
 		ctx := getCtx(req)
-		req = req.WithContext(ctx)
+		req = req.WithContext(context.WithValue(ctx, chi.RouteCtxKey, rctx))
 		rr := httptest.NewRecorder()
-		handler := http.HandlerFunc(Repo.AdminProcessReservation)
+		handler := http.HandlerFunc(Repo.AdminPostReservationStatus)
 		handler.ServeHTTP(rr, req)
 		if rr.Code != testCase.want {
-			t.Errorf("AdminProcessReservation handler returned wrong status code for (%s): got %d, want %d", testCase.name, rr.Code, testCase.want)
+			t.Errorf("AdminPostReservationStatus handler returned wrong status code for (%s): got %d, want %d", testCase.name, rr.Code, testCase.want)
+		}
+		if testCase.location != "" && rr.Header().Get("Location") != testCase.location {
+			t.Errorf("AdminPostReservationStatus handler redirected (%s) to %q, want %q", testCase.name, rr.Header().Get("Location"), testCase.location)
+		}
+		if flash := session.PopString(req.Context(), "flash"); flash != testCase.flash {
+			t.Errorf("AdminPostReservationStatus handler flashed wrong message for (%s): got %q, want %q", testCase.name, flash, testCase.flash)
+		}
+		if msg := session.PopString(req.Context(), "error"); msg != testCase.error {
+			t.Errorf("AdminPostReservationStatus handler reported wrong error for (%s): got %q, want %q", testCase.name, msg, testCase.error)
 		}
 	}
 }
 
 func TestRepository_AdminDeleteReservation(t *testing.T) {
 	var testCases = []struct {
-		name string
-		url  string
-		want int
+		name     string
+		url      string
+		postData url.Values
+		want     int
+		location string
 	}{
 		{
-			name: "Valid Case for cal route",
-			url:  "/admin/delete-reservation/cal/1/do?y=2050&m=01",
-			want: http.StatusSeeOther,
+			name:     "Valid Case for cal route",
+			url:      "/admin/reservations/cal/1/delete",
+			postData: url.Values{"year": {"2050"}, "month": {"01"}},
+			want:     http.StatusSeeOther,
+			location: "/admin/reservations-calendar?y=2050&m=01",
 		},
 		{
-			name: "Valid Case for route other than cal",
-			url:  "/admin/delete-reservation/all/1/do",
-			want: http.StatusSeeOther,
+			name:     "Valid Case for route other than cal",
+			url:      "/admin/reservations/all/1/delete",
+			want:     http.StatusSeeOther,
+			location: "/admin/reservations-all",
 		},
 		{
 			name: "Invalid reservation id",
-			url:  "/admin/delete-reservation/all/3/do",
+			url:  "/admin/reservations/all/3/delete",
 			want: http.StatusInternalServerError,
 		},
 		{
 			name: "Invalid id type",
-			url:  "/admin/delete-reservation/all/as/do",
+			url:  "/admin/reservations/all/as/delete",
 			want: http.StatusInternalServerError,
 		},
 	}
 
 	for _, testCase := range testCases {
-		var req *http.Request
-		req, _ = http.NewRequest("POST", testCase.url, nil)
+		req, _ := http.NewRequest("POST", testCase.url, strings.NewReader(testCase.postData.Encode()))
 		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
 		ctx := getCtx(req)
 		req = req.WithContext(ctx)
 		rr := httptest.NewRecorder()
-		handler := http.HandlerFunc(Repo.AdminDeleteReservation)
-		handler.ServeHTTP(rr, req)
+		mux := chi.NewRouter()
+		mux.Post("/admin/reservations/{src}/{id}/delete", Repo.AdminDeleteReservation)
+		mux.ServeHTTP(rr, req)
 		if rr.Code != testCase.want {
 			t.Errorf("AdminDeleteReservation handler returned wrong status code for (%s): got %d, want %d", testCase.name, rr.Code, testCase.want)
 		}
+		if rr.Header().Get("Location") != testCase.location {
+			t.Errorf("AdminDeleteReservation handler redirected (%s) to %q, want %q", testCase.name, rr.Header().Get("Location"), testCase.location)
+		}
+		if flash := session.PopString(req.Context(), "flash"); rr.Code == http.StatusSeeOther && flash != "Reservation moved to trash" {
+			t.Errorf("AdminDeleteReservation handler flashed wrong message for (%s): got %q", testCase.name, flash)
+		}
+	}
+}
+
+func TestRepository_AdminAPIDeleteReservation(t *testing.T) {
+	var testCases = []struct {
+		name string
+		id   string
+		want int
+	}{
+		{"Valid case", "1", http.StatusNoContent},
+		{"Database error", "3", http.StatusInternalServerError},
+		{"Invalid id type", "as", http.StatusNotFound},
+	}
+
+	for _, testCase := range testCases {
+		req, _ := http.NewRequest("DELETE", "/admin/api/reservations/"+testCase.id, nil)
+		rctx := chi.NewRouteContext()
+		rctx.URLParams.Add("id", testCase.id)
+		ctx := getCtx(req)
+		req = req.WithContext(context.WithValue(ctx, chi.RouteCtxKey, rctx))
+		rr := httptest.NewRecorder()
+		handler := http.HandlerFunc(Repo.AdminAPIDeleteReservation)
+		handler.ServeHTTP(rr, req)
+		if rr.Code != testCase.want {
+			t.Errorf("AdminAPIDeleteReservation handler returned wrong status code for (%s): got %d, want %d", testCase.name, rr.Code, testCase.want)
+		}
+	}
+}
+
+// TestDestructiveRoutes_RejectUnsafeRequests checks that changes can't be made by following a link
+// or without the CSRF token of the session
+func TestDestructiveRoutes_RejectUnsafeRequests(t *testing.T) {
+	var testCases = []struct {
+		name   string
+		method string
+		url    string
+		want   int
+	}{
+		{"GET delete", "GET", "/admin/reservations/all/1/delete", http.StatusMethodNotAllowed},
+		{"GET status change", "GET", "/admin/reservations/all/1/status?status=cancelled", http.StatusMethodNotAllowed},
+		{"GET restore", "GET", "/admin/reservations-trash/1/restore", http.StatusMethodNotAllowed},
+		{"GET api delete", "GET", "/admin/api/reservations/1", http.StatusMethodNotAllowed},
+		{"Old delete link", "GET", "/admin/delete-reservation/all/1/do", http.StatusNotFound},
+		{"Old process link", "GET", "/admin/process-reservation/all/1/do", http.StatusNotFound},
+		{"POST delete without token", "POST", "/admin/reservations/all/1/delete", http.StatusBadRequest},
+		{"POST status change without token", "POST", "/admin/reservations/all/1/status", http.StatusBadRequest},
+		{"DELETE without token", "DELETE", "/admin/api/reservations/1", http.StatusBadRequest},
+	}
+
+	routes := getRoutes()
+	ts := httptest.NewServer(routes)
+	defer ts.Close()
+
+	client := &http.Client{
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+
+	for _, testCase := range testCases {
+		req, _ := http.NewRequest(testCase.method, ts.URL+testCase.url, nil)
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != testCase.want {
+			t.Errorf("%s: got %d, want %d", testCase.name, resp.StatusCode, testCase.want)
+		}
+	}
+}
+
+func TestRepository_AdminDashboard(t *testing.T) {
+	req, _ := http.NewRequest("GET", "/admin/dashboard", nil)
+	ctx := getCtx(req)
+	req = req.WithContext(ctx)
+	rr := httptest.NewRecorder()
+	handler := http.HandlerFunc(Repo.AdminDashboard)
+	handler.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Errorf("AdminDashboard handler returned wrong status: got %d, want %d", rr.Code, http.StatusOK)
+	}
+
+	var expected = []string{
+		`<h3 id="arrivals">3</h3>`,
+		`<h3 id="in-house">5</h3>`,
+		`<a href="/admin/reservations-new">7</a>`,
+		// 15 of 29 sellable nights, then of 89
+		"<td>51.7%</td><td>16.9%</td>",
+		`"leadCounts":[0,0,4,0,0]`,
+	}
+	for _, e := range expected {
+		if !strings.Contains(rr.Body.String(), e) {
+			t.Errorf("AdminDashboard handler should render %q", e)
+		}
 	}
 }
@@ -0,0 +1,103 @@
+package handlers
+
+import (
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-course/bookings/internal/helpers"
+	"github.com/go-course/bookings/internal/housekeeping"
+	"github.com/go-course/bookings/internal/models"
+	"github.com/go-course/bookings/internal/render"
+)
+
+// AdminHousekeeping shows the housekeeping tasks of a day, today by default, with the status of each room
+func (m *Repository) AdminHousekeeping(w http.ResponseWriter, r *http.Request) {
+	day, ok := dayParam(r.URL.Query().Get("date"))
+	if !ok {
+		helpers.ClientError(w, http.StatusBadRequest)
+		return
+	}
+
+	rooms, err := m.DB.AllRooms()
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	// the night before tells whether someone checks out
+	restrictions, err := m.DB.RestrictionsBetween(day.AddDate(0, 0, -1), day.AddDate(0, 0, 1))
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	statuses, err := m.DB.RoomStatuses()
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	data := make(map[string]interface{})
+	data["day"] = day
+	data["rooms"] = housekeeping.Tasks(day, rooms, restrictions, statuses)
+	data["statuses"] = models.RoomStatuses
+
+	stringMap := make(map[string]string)
+	stringMap["date"] = day.Format("2006-01-02")
+	stringMap["prev"] = "/admin/housekeeping?date=" + day.AddDate(0, 0, -1).Format("2006-01-02")
+	stringMap["next"] = "/admin/housekeeping?date=" + day.AddDate(0, 0, 1).Format("2006-01-02")
+
+	render.Template(w, r, "admin-housekeeping.page.tmpl", &models.TemplateData{
+		StringMap: stringMap,
+		Data:      data,
+	})
+}
+
+// AdminPostRoomStatus sets the housekeeping status of a room
+func (m *Repository) AdminPostRoomStatus(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		helpers.ClientError(w, http.StatusBadRequest)
+		return
+	}
+
+	err = r.ParseForm()
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	status := r.Form.Get("status")
+	if !models.ValidRoomStatus(status) {
+		helpers.ClientError(w, http.StatusBadRequest)
+		return
+	}
+
+	s, err := m.auditDB(r).UpdateRoomStatus(models.RoomStatus{
+		RoomID:    id,
+		Status:    status,
+		Note:      r.Form.Get("note"),
+		UpdatedBy: m.App.Session.GetInt(r.Context(), "user_id"),
+	})
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	switch {
+	case status != models.RoomOutOfOrder:
+		m.App.Session.Put(r.Context(), "flash", "Room status saved")
+	case s.BlockID > 0:
+		m.App.Session.Put(r.Context(), "flash", "Room is out of order and blocked until it is back in order")
+	default:
+		m.App.Session.Put(r.Context(), "flash", "Room is out of order, but it is already booked for the coming nights")
+	}
+
+	target := "/admin/housekeeping"
+	if date := r.Form.Get("date"); date != "" {
+		target += "?" + url.Values{"date": {date}}.Encode()
+	}
+	http.Redirect(w, r, target, http.StatusSeeOther)
+}
@@ -0,0 +1,99 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+)
+
+func TestRepository_AdminHousekeeping(t *testing.T) {
+	var testCases = []struct {
+		name  string
+		query string
+		want  int
+	}{
+		{"Today", "", http.StatusOK},
+		{"Chosen day", "?date=2050-01-10", http.StatusOK},
+		{"Invalid date", "?date=tomorrow", http.StatusBadRequest},
+	}
+
+	for _, testCase := range testCases {
+		req, _ := http.NewRequest("GET", "/admin/housekeeping"+testCase.query, nil)
+		ctx := getCtx(req)
+		req = req.WithContext(ctx)
+		rr := httptest.NewRecorder()
+		handler := http.HandlerFunc(Repo.AdminHousekeeping)
+		handler.ServeHTTP(rr, req)
+
+		if rr.Code != testCase.want {
+			t.Errorf("AdminHousekeeping handler returned wrong status for (%s): got %d, want %d", testCase.name, rr.Code, testCase.want)
+		}
+	}
+
+	req, _ := http.NewRequest("GET", "/admin/housekeeping?date=2050-01-10", nil)
+	req = req.WithContext(getCtx(req))
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(Repo.AdminHousekeeping).ServeHTTP(rr, req)
+
+	for _, s := range []string{
+		"Stay-over refresh",
+		"Broken window",
+		"/admin/housekeeping/rooms/1/status",
+		"/admin/housekeeping?date=2050-01-09",
+		"/admin/housekeeping?date=2050-01-11",
+	} {
+		if !strings.Contains(rr.Body.String(), s) {
+			t.Errorf("AdminHousekeeping handler should render %q", s)
+		}
+	}
+}
+
+func TestRepository_AdminPostRoomStatus(t *testing.T) {
+	var testCases = []struct {
+		name     string
+		id       string
+		status   string
+		want     int
+		location string
+		flash    string
+	}{
+		{"Clean", "1", "clean", http.StatusSeeOther, "/admin/housekeeping?date=2050-01-10", "Room status saved"},
+		{"Out of order", "1", "out-of-order", http.StatusSeeOther, "/admin/housekeeping?date=2050-01-10", "Room is out of order and blocked until it is back in order"},
+		{"Out of order but booked", "2", "out-of-order", http.StatusSeeOther, "/admin/housekeeping?date=2050-01-10", "Room is out of order, but it is already booked for the coming nights"},
+		{"Invalid status", "1", "sparkling", http.StatusBadRequest, "", ""},
+		{"Invalid room", "x", "clean", http.StatusBadRequest, "", ""},
+		{"Database error", "3", "clean", http.StatusInternalServerError, "", ""},
+	}
+
+	for _, testCase := range testCases {
+		postedData := url.Values{}
+		postedData.Add("status", testCase.status)
+		postedData.Add("note", "")
+		postedData.Add("date", "2050-01-10")
+
+		req, _ := http.NewRequest("POST", "/admin/housekeeping/rooms/"+testCase.id+"/status", strings.NewReader(postedData.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		rctx := chi.NewRouteContext()
+		rctx.URLParams.Add("id", testCase.id)
+		ctx := getCtx(req)
+		req = req.WithContext(context.WithValue(ctx, chi.RouteCtxKey, rctx))
+		rr := httptest.NewRecorder()
+		handler := http.HandlerFunc(Repo.AdminPostRoomStatus)
+		handler.ServeHTTP(rr, req)
+
+		if rr.Code != testCase.want {
+			t.Errorf("AdminPostRoomStatus handler returned wrong status for (%s): got %d, want %d", testCase.name, rr.Code, testCase.want)
+		}
+		if location := rr.Header().Get("Location"); location != testCase.location {
+			t.Errorf("AdminPostRoomStatus handler redirected to wrong location for (%s): got %q, want %q", testCase.name, location, testCase.location)
+		}
+		if flash := session.PopString(req.Context(), "flash"); flash != testCase.flash {
+			t.Errorf("AdminPostRoomStatus handler flashed wrong message for (%s): got %q, want %q", testCase.name, flash, testCase.flash)
+		}
+	}
+}
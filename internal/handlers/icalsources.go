@@ -0,0 +1,121 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-course/bookings/internal/forms"
+	"github.com/go-course/bookings/internal/helpers"
+	"github.com/go-course/bookings/internal/icalsync"
+	"github.com/go-course/bookings/internal/models"
+	"github.com/go-course/bookings/internal/render"
+)
+
+// AdminICalSources lists the external calendars imported into rooms
+func (m *Repository) AdminICalSources(w http.ResponseWriter, r *http.Request) {
+	m.renderICalSources(w, r, forms.New(nil))
+}
+
+// AdminPostICalSource adds an external calendar to import into a room
+func (m *Repository) AdminPostICalSource(w http.ResponseWriter, r *http.Request) {
+	err := r.ParseForm()
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	form := forms.New(r.PostForm)
+	form.Required("room_id", "name", "url")
+	form.IsURL("url")
+
+	roomID, err := strconv.Atoi(r.Form.Get("room_id"))
+	if err != nil {
+		form.Errors.Add("room_id", "Choose a room")
+	}
+
+	if !form.Valid() {
+		m.renderICalSources(w, r, form)
+		return
+	}
+
+	_, err = m.auditDB(r).InsertICalSource(models.ICalSource{
+		RoomID: roomID,
+		Name:   r.Form.Get("name"),
+		URL:    r.Form.Get("url"),
+	})
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	m.App.Session.Put(r.Context(), "flash", "Calendar added, it will be imported on the next sync")
+	http.Redirect(w, r, "/admin/ical-sources", http.StatusSeeOther)
+}
+
+// AdminDeleteICalSource removes an external calendar along with the bookings imported from it
+func (m *Repository) AdminDeleteICalSource(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		helpers.ClientError(w, http.StatusNotFound)
+		return
+	}
+
+	err = m.auditDB(r).DeleteICalSource(id)
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	m.App.Session.Put(r.Context(), "flash", "Calendar removed")
+	http.Redirect(w, r, "/admin/ical-sources", http.StatusSeeOther)
+}
+
+// AdminSyncICalSource imports an external calendar right away
+func (m *Repository) AdminSyncICalSource(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		helpers.ClientError(w, http.StatusNotFound)
+		return
+	}
+
+	src, err := m.DB.GetICalSourceByID(id)
+	if err != nil {
+		helpers.ClientError(w, http.StatusNotFound)
+		return
+	}
+
+	result, err := icalsync.New(m.auditDB(r), m.App).SyncSource(src)
+	if err != nil {
+		m.App.Session.Put(r.Context(), "error", "Sync failed: "+err.Error())
+	} else {
+		m.App.Session.Put(r.Context(), "flash", fmt.Sprintf("Synced: %d added, %d updated, %d removed", result.Added, result.Updated, result.Removed))
+	}
+
+	http.Redirect(w, r, "/admin/ical-sources", http.StatusSeeOther)
+}
+
+// renderICalSources renders the external calendars page with form
+func (m *Repository) renderICalSources(w http.ResponseWriter, r *http.Request, form *forms.Form) {
+	sources, err := m.DB.AllICalSources()
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	rooms, err := m.DB.AllRooms()
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	data := make(map[string]interface{})
+	data["sources"] = sources
+	data["rooms"] = rooms
+
+	render.Template(w, r, "admin-ical-sources.page.tmpl", &models.TemplateData{
+		Data: data,
+		Form: form,
+	})
+}
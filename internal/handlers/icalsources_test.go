@@ -0,0 +1,124 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+)
+
+func TestRepository_AdminPostICalSource(t *testing.T) {
+	var testCases = []struct {
+		name     string
+		postData url.Values
+		want     int
+		html     string
+	}{
+		{
+			name: "Valid case",
+			postData: url.Values{
+				"room_id": {"1"},
+				"name":    {"Booking site"},
+				"url":     {"https://example.com/room1.ics"},
+			},
+			want: http.StatusSeeOther,
+		},
+		{
+			name: "Invalid url",
+			postData: url.Values{
+				"room_id": {"1"},
+				"name":    {"Booking site"},
+				"url":     {"example"},
+			},
+			want: http.StatusOK,
+			html: "Invalid url",
+		},
+		{
+			name: "Missing room",
+			postData: url.Values{
+				"name": {"Booking site"},
+				"url":  {"https://example.com/room1.ics"},
+			},
+			want: http.StatusOK,
+			html: "This field cannot be blank",
+		},
+		{
+			name: "Invalid room",
+			postData: url.Values{
+				"room_id": {"as"},
+				"name":    {"Booking site"},
+				"url":     {"https://example.com/room1.ics"},
+			},
+			want: http.StatusOK,
+			html: "Choose a room",
+		},
+		{
+			name: "Non existent room",
+			postData: url.Values{
+				"room_id": {"3"},
+				"name":    {"Booking site"},
+				"url":     {"https://example.com/room1.ics"},
+			},
+			want: http.StatusInternalServerError,
+		},
+	}
+
+	for _, testCase := range testCases {
+		req, _ := http.NewRequest("POST", "/admin/ical-sources", strings.NewReader(testCase.postData.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		ctx := getCtx(req)
+		req = req.WithContext(ctx)
+		rr := httptest.NewRecorder()
+		handler := http.HandlerFunc(Repo.AdminPostICalSource)
+		handler.ServeHTTP(rr, req)
+
+		if rr.Code != testCase.want {
+			t.Errorf("AdminPostICalSource handler returned wrong status for (%s): got %d, want %d", testCase.name, rr.Code, testCase.want)
+		}
+		if testCase.html != "" && !strings.Contains(rr.Body.String(), testCase.html) {
+			t.Errorf("AdminPostICalSource handler for (%s) should render %q", testCase.name, testCase.html)
+		}
+	}
+}
+
+func TestRepository_AdminICalSourceActions(t *testing.T) {
+	var testCases = []struct {
+		name    string
+		id      string
+		handler http.HandlerFunc
+		want    int
+		flash   string
+		error   string
+	}{
+		{"Delete", "1", Repo.AdminDeleteICalSource, http.StatusSeeOther, "Calendar removed", ""},
+		{"Delete non existent", "3", Repo.AdminDeleteICalSource, http.StatusInternalServerError, "", ""},
+		{"Delete invalid id", "as", Repo.AdminDeleteICalSource, http.StatusNotFound, "", ""},
+		{"Sync unreachable feed", "1", Repo.AdminSyncICalSource, http.StatusSeeOther, "", "Sync failed: "},
+		{"Sync non existent", "3", Repo.AdminSyncICalSource, http.StatusNotFound, "", ""},
+		{"Sync invalid id", "as", Repo.AdminSyncICalSource, http.StatusNotFound, "", ""},
+	}
+
+	for _, testCase := range testCases {
+		req, _ := http.NewRequest("POST", "/admin/ical-sources/"+testCase.id+"/sync", nil)
+		rctx := chi.NewRouteContext()
+		rctx.URLParams.Add("id", testCase.id)
+		ctx := getCtx(req)
+		req = req.WithContext(context.WithValue(ctx, chi.RouteCtxKey, rctx))
+		rr := httptest.NewRecorder()
+		testCase.handler.ServeHTTP(rr, req)
+
+		if rr.Code != testCase.want {
+			t.Errorf("handler returned wrong status for (%s): got %d, want %d", testCase.name, rr.Code, testCase.want)
+		}
+		if flash := session.PopString(req.Context(), "flash"); flash != testCase.flash {
+			t.Errorf("handler flashed wrong message for (%s): got %q, want %q", testCase.name, flash, testCase.flash)
+		}
+		if msg := session.PopString(req.Context(), "error"); !strings.HasPrefix(msg, testCase.error) || (testCase.error == "" && msg != "") {
+			t.Errorf("handler reported wrong error for (%s): got %q", testCase.name, msg)
+		}
+	}
+}
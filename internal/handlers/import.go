@@ -0,0 +1,169 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+
+	"github.com/go-course/bookings/internal/csvimport"
+	"github.com/go-course/bookings/internal/forms"
+	"github.com/go-course/bookings/internal/helpers"
+	"github.com/go-course/bookings/internal/models"
+	"github.com/go-course/bookings/internal/render"
+)
+
+// maxImportSize bounds the size of uploaded import files
+const maxImportSize = 10 << 20
+
+// AdminImport shows the import upload form, and the column mapping of the uploaded file
+func (m *Repository) AdminImport(w http.ResponseWriter, r *http.Request) {
+	header, _ := m.importHeader(r)
+	m.renderImport(w, r, forms.New(nil), header, csvimport.GuessMapping(header), nil)
+}
+
+// AdminPostImport keeps an uploaded CSV file for the mapping step
+func (m *Repository) AdminPostImport(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxImportSize)
+	if err := r.ParseMultipartForm(maxImportSize); err != nil {
+		helpers.ClientError(w, http.StatusBadRequest)
+		return
+	}
+
+	form := forms.New(r.PostForm)
+	file, _, err := r.FormFile("file")
+	if err != nil {
+		form.Errors.Add("file", "Choose a CSV file")
+		m.renderImport(w, r, form, nil, nil, nil)
+		return
+	}
+	defer file.Close()
+
+	tmp, err := os.CreateTemp("", "bookings-import-*.csv")
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+	defer tmp.Close()
+
+	if _, err := io.Copy(tmp, file); err != nil {
+		os.Remove(tmp.Name())
+		helpers.ServerError(w, err)
+		return
+	}
+	if _, err := tmp.Seek(0, io.SeekStart); err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+	if _, err := csvimport.ReadHeader(tmp); err != nil {
+		os.Remove(tmp.Name())
+		form.Errors.Add("file", fmt.Sprintf("Not a CSV file: %s", err))
+		m.renderImport(w, r, form, nil, nil, nil)
+		return
+	}
+
+	m.forgetImportFile(r)
+	m.App.Session.Put(r.Context(), "import_file", tmp.Name())
+	http.Redirect(w, r, "/admin/import", http.StatusSeeOther)
+}
+
+// AdminPostImportRun checks the uploaded file with the posted column mapping, and saves its valid rows
+// unless it is a dry run
+func (m *Repository) AdminPostImportRun(w http.ResponseWriter, r *http.Request) {
+	err := r.ParseForm()
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	header, err := m.importHeader(r)
+	if err != nil {
+		m.App.Session.Put(r.Context(), "error", "Upload the file to import first")
+		http.Redirect(w, r, "/admin/import", http.StatusSeeOther)
+		return
+	}
+
+	form := forms.New(r.PostForm)
+	mapping := make(csvimport.Mapping)
+	for _, f := range csvimport.Fields {
+		v := form.Get("map_" + f.Name)
+		if v == "" {
+			continue
+		}
+		i, err := strconv.Atoi(v)
+		if err != nil || i < 0 || i >= len(header) {
+			helpers.ClientError(w, http.StatusBadRequest)
+			return
+		}
+		mapping[f.Name] = i
+	}
+
+	file, err := os.Open(m.App.Session.GetString(r.Context(), "import_file"))
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+	defer file.Close()
+
+	commit := form.Has("commit")
+	report, err := csvimport.Run(m.auditDB(r), file, mapping, commit)
+	switch {
+	case errors.Is(err, models.ErrRoomUnavailable):
+		form.Errors.Add("file", fmt.Sprintf("Nothing was imported, a room was booked meanwhile (%s). Run the check again", err))
+	case err != nil:
+		helpers.ServerError(w, err)
+		return
+	case report.Committed:
+		m.forgetImportFile(r)
+		header = nil
+	}
+
+	m.renderImport(w, r, form, header, mapping, &report)
+}
+
+// importHeader returns the columns of the uploaded file
+func (m *Repository) importHeader(r *http.Request) ([]string, error) {
+	name := m.App.Session.GetString(r.Context(), "import_file")
+	if name == "" {
+		return nil, errors.New("no file uploaded")
+	}
+
+	file, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	return csvimport.ReadHeader(file)
+}
+
+// forgetImportFile removes the uploaded file
+func (m *Repository) forgetImportFile(r *http.Request) {
+	if name := m.App.Session.PopString(r.Context(), "import_file"); name != "" {
+		os.Remove(name)
+	}
+}
+
+// renderImport renders the import page. The mapping step is shown when header is set
+func (m *Repository) renderImport(w http.ResponseWriter, r *http.Request, form *forms.Form, header []string, mapping csvimport.Mapping, report *csvimport.Report) {
+	// column indexes as option values
+	selected := make(map[string]string)
+	for field, i := range mapping {
+		selected[field] = strconv.Itoa(i)
+	}
+
+	data := make(map[string]interface{})
+	data["header"] = header
+	data["fields"] = csvimport.Fields
+	data["mapping"] = selected
+	if report != nil {
+		data["report"] = report
+	}
+
+	render.Template(w, r, "admin-import.page.tmpl", &models.TemplateData{
+		Data: data,
+		Form: form,
+	})
+}
@@ -0,0 +1,150 @@
+package handlers
+
+import (
+	"bytes"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const importCSV = `First Name,Last Name,Email,Arrival,Departure,Room
+John,Smith,john@here.com,2050-01-01,2050-01-03,Generals Quarters
+Al,Short,al@here.com,2050-01-01,2050-01-03,2
+`
+
+func TestRepository_AdminPostImport(t *testing.T) {
+	var testCases = []struct {
+		name     string
+		content  string
+		want     int
+		html     string
+		uploaded bool
+	}{
+		{"CSV file", importCSV, http.StatusSeeOther, "", true},
+		{"Empty file", "", http.StatusOK, "Not a CSV file", false},
+		{"No file", "-", http.StatusOK, "Choose a CSV file", false},
+	}
+
+	for _, testCase := range testCases {
+		var body bytes.Buffer
+		mw := multipart.NewWriter(&body)
+		if testCase.content != "-" {
+			fw, _ := mw.CreateFormFile("file", "bookings.csv")
+			fw.Write([]byte(testCase.content))
+		}
+		mw.Close()
+
+		req, _ := http.NewRequest("POST", "/admin/import", &body)
+		req.Header.Set("Content-Type", mw.FormDataContentType())
+		ctx := getCtx(req)
+		req = req.WithContext(ctx)
+		rr := httptest.NewRecorder()
+		handler := http.HandlerFunc(Repo.AdminPostImport)
+		handler.ServeHTTP(rr, req)
+
+		if rr.Code != testCase.want {
+			t.Errorf("AdminPostImport handler returned wrong status for (%s): got %d, want %d", testCase.name, rr.Code, testCase.want)
+		}
+		if !strings.Contains(rr.Body.String(), testCase.html) {
+			t.Errorf("AdminPostImport handler for (%s) should render %q", testCase.name, testCase.html)
+		}
+
+		name := session.GetString(req.Context(), "import_file")
+		if (name != "") != testCase.uploaded {
+			t.Errorf("AdminPostImport handler for (%s) kept file %q", testCase.name, name)
+		}
+		if name != "" {
+			os.Remove(name)
+		}
+	}
+}
+
+func TestRepository_AdminPostImportRun(t *testing.T) {
+	var testCases = []struct {
+		name     string
+		csv      string
+		postData url.Values
+		want     int
+		html     string
+		kept     bool
+	}{
+		{
+			name:     "Dry run",
+			csv:      importCSV,
+			postData: url.Values{"map_first_name": {"0"}, "map_last_name": {"1"}, "map_email": {"2"}, "map_start_date": {"3"}, "map_end_date": {"4"}, "map_room": {"5"}},
+			want:     http.StatusOK,
+			html:     "Dry run: 1 rows can be imported, 1 rows have errors",
+			kept:     true,
+		},
+		{
+			name:     "Commit",
+			csv:      importCSV,
+			postData: url.Values{"map_first_name": {"0"}, "map_last_name": {"1"}, "map_email": {"2"}, "map_start_date": {"3"}, "map_end_date": {"4"}, "map_room": {"5"}, "commit": {"1"}},
+			want:     http.StatusOK,
+			html:     "Imported 1 rows, skipped 1 rows with errors",
+			kept:     false,
+		},
+		{
+			name:     "Unmapped column",
+			csv:      importCSV,
+			postData: url.Values{"map_room": {"5"}},
+			want:     http.StatusOK,
+			html:     "First Name: This field cannot be blank",
+			kept:     true,
+		},
+		{
+			name:     "Room booked meanwhile",
+			csv:      strings.Replace(importCSV, "Smith", "Late", 1),
+			postData: url.Values{"map_first_name": {"0"}, "map_last_name": {"1"}, "map_email": {"2"}, "map_start_date": {"3"}, "map_end_date": {"4"}, "map_room": {"5"}, "commit": {"1"}},
+			want:     http.StatusOK,
+			html:     "Nothing was imported",
+			kept:     true,
+		},
+		{
+			name:     "Column out of range",
+			csv:      importCSV,
+			postData: url.Values{"map_room": {"9"}},
+			want:     http.StatusBadRequest,
+			kept:     true,
+		},
+	}
+
+	for _, testCase := range testCases {
+		name := filepath.Join(t.TempDir(), "import.csv")
+		os.WriteFile(name, []byte(testCase.csv), 0600)
+
+		req, _ := http.NewRequest("POST", "/admin/import/run", strings.NewReader(testCase.postData.Encode()))
+		ctx := getCtx(req)
+		req = req.WithContext(ctx)
+		session.Put(ctx, "import_file", name)
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		rr := httptest.NewRecorder()
+		handler := http.HandlerFunc(Repo.AdminPostImportRun)
+		handler.ServeHTTP(rr, req)
+
+		if rr.Code != testCase.want {
+			t.Errorf("AdminPostImportRun handler returned wrong status for (%s): got %d, want %d", testCase.name, rr.Code, testCase.want)
+		}
+		if !strings.Contains(rr.Body.String(), testCase.html) {
+			t.Errorf("AdminPostImportRun handler for (%s) should render %q", testCase.name, testCase.html)
+		}
+		if _, err := os.Stat(name); (err == nil) != testCase.kept {
+			t.Errorf("AdminPostImportRun handler for (%s): file kept should be %v", testCase.name, testCase.kept)
+		}
+	}
+
+	// without an uploaded file
+	req, _ := http.NewRequest("POST", "/admin/import/run", strings.NewReader(""))
+	ctx := getCtx(req)
+	req = req.WithContext(ctx)
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(Repo.AdminPostImportRun).ServeHTTP(rr, req)
+	if rr.Code != http.StatusSeeOther || rr.Header().Get("Location") != "/admin/import" {
+		t.Errorf("AdminPostImportRun without a file: got %d %q", rr.Code, rr.Header().Get("Location"))
+	}
+}
@@ -0,0 +1,144 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-course/bookings/internal/emails"
+	"github.com/go-course/bookings/internal/helpers"
+	"github.com/go-course/bookings/internal/models"
+	"github.com/go-course/bookings/internal/render"
+)
+
+// mailListLimit bounds the outbox messages listed at once
+const mailListLimit = 100
+
+// AdminMail lists the outbox messages with the status in the url query, failed ones by default
+func (m *Repository) AdminMail(w http.ResponseWriter, r *http.Request) {
+	status := r.URL.Query().Get("status")
+	if status == "" {
+		status = models.MailFailed
+	}
+
+	filter := status
+	if status == "all" {
+		filter = ""
+	} else if !validMailStatus(status) {
+		helpers.ClientError(w, http.StatusBadRequest)
+		return
+	}
+
+	messages, err := m.DB.MailMessages(filter, mailListLimit)
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	data := make(map[string]interface{})
+	data["messages"] = messages
+	data["statuses"] = []string{models.MailFailed, models.MailPending, models.MailSent, "all"}
+
+	stringMap := make(map[string]string)
+	stringMap["status"] = status
+
+	render.Template(w, r, "admin-mail.page.tmpl", &models.TemplateData{
+		StringMap: stringMap,
+		Data:      data,
+	})
+}
+
+// AdminShowMail shows an outbox message with its delivery attempts
+func (m *Repository) AdminShowMail(w http.ResponseWriter, r *http.Request) {
+	msg, ok := m.mailFromURL(w, r)
+	if !ok {
+		return
+	}
+
+	data := make(map[string]interface{})
+	data["message"] = msg
+
+	render.Template(w, r, "admin-mail-message.page.tmpl", &models.TemplateData{
+		Data: data,
+	})
+}
+
+// AdminResendMail queues a sent or failed message to be sent again
+func (m *Repository) AdminResendMail(w http.ResponseWriter, r *http.Request) {
+	msg, ok := m.mailFromURL(w, r)
+	if !ok {
+		return
+	}
+	target := fmt.Sprintf("/admin/mail/%d", msg.ID)
+
+	if msg.Status == models.MailPending {
+		m.App.Session.Put(r.Context(), "error", "This message is already waiting to be sent")
+		http.Redirect(w, r, target, http.StatusSeeOther)
+		return
+	}
+
+	err := m.auditDB(r).ResendMail(msg.ID)
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	m.App.Session.Put(r.Context(), "flash", "Message queued to be sent again")
+	http.Redirect(w, r, target, http.StatusSeeOther)
+}
+
+// AdminPreviewEmail shows the email template in the url rendered with sample data, with its HTML and text parts
+func (m *Repository) AdminPreviewEmail(w http.ResponseWriter, r *http.Request) {
+	name := chi.URLParam(r, "name")
+	email, err := m.App.EmailTemplates.Preview(name)
+	if errors.Is(err, emails.ErrUnknownTemplate) {
+		helpers.ClientError(w, http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	data := make(map[string]interface{})
+	data["email"] = email
+	data["templates"] = emails.Names
+
+	render.Template(w, r, "admin-email-preview.page.tmpl", &models.TemplateData{
+		Data: data,
+	})
+}
+
+// mailFromURL loads the outbox message in the url, writing a not found response if there is none
+func (m *Repository) mailFromURL(w http.ResponseWriter, r *http.Request) (models.MailMessage, bool) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		helpers.ClientError(w, http.StatusNotFound)
+		return models.MailMessage{}, false
+	}
+
+	msg, err := m.DB.GetMailMessage(id)
+	if errors.Is(err, sql.ErrNoRows) {
+		helpers.ClientError(w, http.StatusNotFound)
+		return msg, false
+	}
+	if err != nil {
+		helpers.ServerError(w, err)
+		return msg, false
+	}
+
+	return msg, true
+}
+
+// validMailStatus reports whether status is a status of outbox messages
+func validMailStatus(status string) bool {
+	for _, s := range models.MailStatuses {
+		if s == status {
+			return true
+		}
+	}
+	return false
+}
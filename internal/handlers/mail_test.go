@@ -0,0 +1,152 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-course/bookings/internal/emails"
+)
+
+func TestRepository_AdminMail(t *testing.T) {
+	var testCases = []struct {
+		name     string
+		url      string
+		want     int
+		expected string
+	}{
+		{"Failed by default", "/admin/mail", http.StatusOK, "dial tcp: connection refused"},
+		{"All", "/admin/mail?status=all", http.StatusOK, "Reservation Confirmation"},
+		{"Sent", "/admin/mail?status=sent", http.StatusOK, "No messages"},
+		{"Unknown status", "/admin/mail?status=lost", http.StatusBadRequest, ""},
+	}
+
+	for _, testCase := range testCases {
+		req, _ := http.NewRequest("GET", testCase.url, nil)
+		ctx := getCtx(req)
+		req = req.WithContext(ctx)
+		rr := httptest.NewRecorder()
+		handler := http.HandlerFunc(Repo.AdminMail)
+		handler.ServeHTTP(rr, req)
+
+		if rr.Code != testCase.want {
+			t.Errorf("AdminMail handler returned wrong status for (%s): got %d, want %d", testCase.name, rr.Code, testCase.want)
+		}
+		if !strings.Contains(rr.Body.String(), testCase.expected) {
+			t.Errorf("AdminMail handler for (%s) should render %q", testCase.name, testCase.expected)
+		}
+	}
+}
+
+func TestRepository_AdminShowMail(t *testing.T) {
+	var testCases = []struct {
+		name string
+		id   string
+		want int
+	}{
+		{"Failed message", "1", http.StatusOK},
+		{"Not found", "4", http.StatusNotFound},
+		{"Invalid id", "x", http.StatusNotFound},
+		{"Database error", "3", http.StatusInternalServerError},
+	}
+
+	for _, testCase := range testCases {
+		req, _ := http.NewRequest("GET", "/admin/mail/"+testCase.id, nil)
+		rctx := chi.NewRouteContext()
+		rctx.URLParams.Add("id", testCase.id)
+		ctx := getCtx(req)
+		req = req.WithContext(context.WithValue(ctx, chi.RouteCtxKey, rctx))
+		rr := httptest.NewRecorder()
+		handler := http.HandlerFunc(Repo.AdminShowMail)
+		handler.ServeHTTP(rr, req)
+
+		if rr.Code != testCase.want {
+			t.Errorf("AdminShowMail handler returned wrong status for (%s): got %d, want %d", testCase.name, rr.Code, testCase.want)
+		}
+	}
+
+	req, _ := http.NewRequest("GET", "/admin/mail/1", nil)
+	rctx := chi.NewRouteContext()
+	rctx.URLParams.Add("id", "1")
+	req = req.WithContext(context.WithValue(getCtx(req), chi.RouteCtxKey, rctx))
+	rr := httptest.NewRecorder()
+	Repo.AdminShowMail(rr, req)
+	for _, s := range []string{"john@smith.com", "reservation.ics", "&lt;h2&gt;Reservation Confirmation", "Dear John,", "/admin/mail/1/resend"} {
+		if !strings.Contains(rr.Body.String(), s) {
+			t.Errorf("AdminShowMail handler should render %q", s)
+		}
+	}
+}
+
+func TestRepository_AdminResendMail(t *testing.T) {
+	var testCases = []struct {
+		name  string
+		id    string
+		want  int
+		flash string
+		error string
+	}{
+		{"Failed message", "1", http.StatusSeeOther, "Message queued to be sent again", ""},
+		{"Pending message", "2", http.StatusSeeOther, "", "This message is already waiting to be sent"},
+		{"Database error", "3", http.StatusInternalServerError, "", ""},
+		{"Not found", "4", http.StatusNotFound, "", ""},
+	}
+
+	for _, testCase := range testCases {
+		req, _ := http.NewRequest("POST", "/admin/mail/"+testCase.id+"/resend", nil)
+		rctx := chi.NewRouteContext()
+		rctx.URLParams.Add("id", testCase.id)
+		ctx := getCtx(req)
+		req = req.WithContext(context.WithValue(ctx, chi.RouteCtxKey, rctx))
+		rr := httptest.NewRecorder()
+		handler := http.HandlerFunc(Repo.AdminResendMail)
+		handler.ServeHTTP(rr, req)
+
+		if rr.Code != testCase.want {
+			t.Errorf("AdminResendMail handler returned wrong status for (%s): got %d, want %d", testCase.name, rr.Code, testCase.want)
+		}
+		if rr.Code == http.StatusSeeOther && rr.Header().Get("Location") != "/admin/mail/"+testCase.id {
+			t.Errorf("AdminResendMail handler redirected (%s) to %q", testCase.name, rr.Header().Get("Location"))
+		}
+		if flash := session.PopString(req.Context(), "flash"); flash != testCase.flash {
+			t.Errorf("AdminResendMail handler flashed wrong message for (%s): got %q, want %q", testCase.name, flash, testCase.flash)
+		}
+		if msg := session.PopString(req.Context(), "error"); msg != testCase.error {
+			t.Errorf("AdminResendMail handler reported wrong error for (%s): got %q, want %q", testCase.name, msg, testCase.error)
+		}
+	}
+}
+
+func TestRepository_AdminPreviewEmail(t *testing.T) {
+	var testCases = []struct {
+		name     string
+		template string
+		want     int
+		expected string
+	}{
+		{"Confirmation", emails.Confirmation, http.StatusOK, "Dear John,"},
+		{"Password reset", emails.PasswordReset, http.StatusOK, "reset-password?token=sample"},
+		{"Unknown template", "welcome", http.StatusNotFound, ""},
+	}
+
+	for _, testCase := range testCases {
+		req, _ := http.NewRequest("GET", "/admin/mail/templates/"+testCase.template, nil)
+		rctx := chi.NewRouteContext()
+		rctx.URLParams.Add("name", testCase.template)
+		ctx := getCtx(req)
+		req = req.WithContext(context.WithValue(ctx, chi.RouteCtxKey, rctx))
+		rr := httptest.NewRecorder()
+		handler := http.HandlerFunc(Repo.AdminPreviewEmail)
+		handler.ServeHTTP(rr, req)
+
+		if rr.Code != testCase.want {
+			t.Errorf("AdminPreviewEmail handler returned wrong status for (%s): got %d, want %d", testCase.name, rr.Code, testCase.want)
+		}
+		if !strings.Contains(rr.Body.String(), testCase.expected) {
+			t.Errorf("AdminPreviewEmail handler for (%s) should render %q", testCase.name, testCase.expected)
+		}
+	}
+}
@@ -0,0 +1,254 @@
+package handlers
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/go-course/bookings/internal/emails"
+	"github.com/go-course/bookings/internal/forms"
+	"github.com/go-course/bookings/internal/helpers"
+	"github.com/go-course/bookings/internal/models"
+	"github.com/go-course/bookings/internal/render"
+)
+
+// notification is who the emails about an event come from and the staff they go to
+type notification struct {
+	settings   models.PropertySettings
+	recipients []string
+}
+
+// notification loads the sender identity and the staff recipients of event
+func (m *Repository) notification(event string) (notification, error) {
+	settings, err := m.DB.GetPropertySettings()
+	if err != nil {
+		return notification{}, err
+	}
+	recipients, err := m.DB.NotificationRecipients(event)
+	if err != nil {
+		return notification{}, err
+	}
+	return notification{settings: settings, recipients: recipients}, nil
+}
+
+// mail returns the message sending email to to, from the property
+func (n notification) mail(email emails.Email, to string) models.MailData {
+	msg := email.MailData(n.settings.From(), to)
+	msg.ReplyTo = n.settings.ReplyTo
+	return msg
+}
+
+// eventMail returns the emails about event for a reservation: to the guest, rendered from guestTemplate
+// unless it is empty, then to each staff recipient
+func (m *Repository) eventMail(event, guestTemplate string, res models.Reservation, n notification) ([]models.MailData, error) {
+	data := emails.Data{Reservation: res, Event: event}
+	var messages []models.MailData
+
+	if guestTemplate != "" && res.Email != "" {
+		email, err := m.App.EmailTemplates.Render(guestTemplate, data)
+		if err != nil {
+			return nil, err
+		}
+		messages = append(messages, n.mail(email, res.Email))
+	}
+
+	if len(n.recipients) > 0 {
+		email, err := m.App.EmailTemplates.Render(emails.StaffNotification, data)
+		if err != nil {
+			return nil, err
+		}
+		for _, to := range n.recipients {
+			messages = append(messages, n.mail(email, to))
+		}
+	}
+
+	return messages, nil
+}
+
+// reservationMail returns the notifications of a new reservation, first the confirmation to the guest with
+// the stay as a calendar file, then one to each staff recipient
+func (m *Repository) reservationMail(res models.Reservation, n notification) ([]models.MailData, error) {
+	messages, err := m.eventMail(models.EventNewBooking, emails.Confirmation, res, n)
+	if err != nil {
+		return nil, err
+	}
+	if len(messages) > 0 && res.Email != "" {
+		messages[0].Attachments = []models.MailAttachment{reservationICS(res)}
+	}
+	return messages, nil
+}
+
+// notify queues the emails and webhooks about event for the reservation with id. Failures are logged, the
+// change itself being saved
+func (m *Repository) notify(event, guestTemplate string, id int) {
+	res, err := m.DB.GetReservationById(id)
+	if err != nil {
+		m.App.ErrorLog.Println(err)
+		return
+	}
+	m.publishReservation(reservationWebhooks[event], res)
+
+	n, err := m.notification(event)
+	if err != nil {
+		m.App.ErrorLog.Println(err)
+		return
+	}
+
+	messages, err := m.eventMail(event, guestTemplate, res, n)
+	if err != nil {
+		m.App.ErrorLog.Println(err)
+		return
+	}
+	for _, msg := range messages {
+		if err := m.DB.QueueMail(msg); err != nil {
+			m.App.ErrorLog.Println(err)
+		}
+	}
+}
+
+// reservationText returns the confirmation text message of a new reservation, with no number unless the
+// guest opted in
+func (m *Repository) reservationText(res models.Reservation) (models.SMSData, error) {
+	if !res.SMSOptIn || res.Phone == "" {
+		return models.SMSData{}, nil
+	}
+	email, err := m.App.EmailTemplates.Render(emails.Confirmation, emails.Data{Reservation: res})
+	if err != nil || email.SMS == "" {
+		return models.SMSData{}, err
+	}
+	return email.SMSData(res.Phone), nil
+}
+
+// AdminSettings shows the property settings
+func (m *Repository) AdminSettings(w http.ResponseWriter, r *http.Request) {
+	settings, err := m.DB.GetPropertySettings()
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	form := forms.New(url.Values{})
+	form.Set("sender_name", settings.SenderName)
+	form.Set("sender_email", settings.SenderEmail)
+	form.Set("reply_to", settings.ReplyTo)
+	for _, event := range models.NotificationEvents {
+		form.Set("recipients_"+event, strings.Join(settings.Recipients[event], "\n"))
+	}
+
+	m.renderSettings(w, r, form)
+}
+
+// AdminPostSettings saves the property settings
+func (m *Repository) AdminPostSettings(w http.ResponseWriter, r *http.Request) {
+	err := r.ParseForm()
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	form := forms.New(r.PostForm)
+	form.Required("sender_email")
+	form.IsEmail("sender_email")
+	if form.Has("reply_to") {
+		form.IsEmail("reply_to")
+	}
+
+	settings := models.PropertySettings{
+		SenderName:  strings.TrimSpace(form.Get("sender_name")),
+		SenderEmail: form.Get("sender_email"),
+		ReplyTo:     form.Get("reply_to"),
+		Recipients:  make(map[string][]string),
+	}
+	for _, event := range models.NotificationEvents {
+		settings.Recipients[event] = form.EmailList("recipients_" + event)
+	}
+
+	if !form.Valid() {
+		m.renderSettings(w, r, form)
+		return
+	}
+
+	err = m.auditDB(r).UpdatePropertySettings(settings)
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	m.App.Session.Put(r.Context(), "flash", "Settings saved")
+	http.Redirect(w, r, "/admin/settings", http.StatusSeeOther)
+}
+
+// renderSettings renders the settings page with form
+func (m *Repository) renderSettings(w http.ResponseWriter, r *http.Request, form *forms.Form) {
+	data := make(map[string]interface{})
+	data["events"] = models.NotificationEvents
+	data["names"] = models.NotificationEventNames
+
+	render.Template(w, r, "admin-settings.page.tmpl", &models.TemplateData{
+		Form: form,
+		Data: data,
+	})
+}
+
+// AdminNotifications shows the events the logged in user is notified of
+func (m *Repository) AdminNotifications(w http.ResponseWriter, r *http.Request) {
+	userID, ok := m.App.Session.Get(r.Context(), "user_id").(int)
+	if !ok {
+		m.App.Session.Put(r.Context(), "error", "Log in first!")
+		http.Redirect(w, r, "/user/login", http.StatusSeeOther)
+		return
+	}
+	events, err := m.DB.NotificationPreferences(userID)
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	chosen := make(map[string]bool)
+	for _, event := range events {
+		chosen[event] = true
+	}
+
+	data := make(map[string]interface{})
+	data["events"] = models.NotificationEvents
+	data["names"] = models.NotificationEventNames
+	data["chosen"] = chosen
+
+	render.Template(w, r, "admin-notifications.page.tmpl", &models.TemplateData{
+		Data: data,
+	})
+}
+
+// AdminPostNotifications saves the events the logged in user is notified of
+func (m *Repository) AdminPostNotifications(w http.ResponseWriter, r *http.Request) {
+	userID, ok := m.App.Session.Get(r.Context(), "user_id").(int)
+	if !ok {
+		m.App.Session.Put(r.Context(), "error", "Log in first!")
+		http.Redirect(w, r, "/user/login", http.StatusSeeOther)
+		return
+	}
+
+	err := r.ParseForm()
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	var events []string
+	for _, event := range r.PostForm["events"] {
+		if !models.ValidNotificationEvent(event) {
+			helpers.ClientError(w, http.StatusBadRequest)
+			return
+		}
+		events = append(events, event)
+	}
+
+	err = m.auditDB(r).UpdateNotificationPreferences(userID, events)
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	m.App.Session.Put(r.Context(), "flash", "Notification preferences saved")
+	http.Redirect(w, r, "/admin/notifications", http.StatusSeeOther)
+}
@@ -0,0 +1,383 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-course/bookings/internal/emails"
+	"github.com/go-course/bookings/internal/mail"
+	"github.com/go-course/bookings/internal/models"
+	"github.com/go-course/bookings/internal/sms"
+)
+
+func TestRepository_Notify(t *testing.T) {
+	mailer := mail.NewMemory()
+	worker := mail.New(Repo.DB, mailer, &app)
+	worker.DeliverDue()
+	mailer.Reset()
+
+	Repo.notify(models.EventCancellation, emails.Cancellation, 1)
+	worker.DeliverDue()
+
+	sent := mailer.To("john@smith.com")
+	if len(sent) != 1 || sent[0].Subject != "Reservation Cancelled" || sent[0].Template != emails.Cancellation {
+		t.Fatalf("expected a cancellation to the guest, got %+v", mailer.Messages())
+	}
+	if !strings.Contains(sent[0].Text, "has been cancelled") {
+		t.Errorf("wrong cancellation:\n%s", sent[0].Text)
+	}
+	staff := mailer.To("frontdesk@fortsmythe.com")
+	if len(staff) != 1 || staff[0].Template != emails.StaffNotification || staff[0].Subject != "Reservation Cancelled" {
+		t.Errorf("expected a cancellation to the front desk, got %+v", mailer.Messages())
+	}
+	if len(mailer.Messages()) != 2 {
+		t.Errorf("expected 2 messages, got %d", len(mailer.Messages()))
+	}
+
+	// modifications only go to staff
+	mailer.Reset()
+	Repo.notify(models.EventModification, "", 1)
+	worker.DeliverDue()
+	if sent := mailer.Messages(); len(sent) != 1 || sent[0].To != "admin@admin.com" || sent[0].Subject != "Reservation Changed" {
+		t.Errorf("expected a modification to admin@admin.com, got %+v", sent)
+	}
+
+	// no email for a reservation that can't be loaded
+	Repo.notify(models.EventCancellation, emails.Cancellation, 3)
+	if n, _ := worker.DeliverDue(); n != 0 {
+		t.Errorf("expected no message, got %d", n)
+	}
+}
+
+func TestReservationMail(t *testing.T) {
+	res := models.Reservation{
+		ID:        7,
+		FirstName: "John",
+		LastName:  "Smith",
+		Email:     "john@smith.com",
+		StartDate: time.Date(2050, 1, 1, 0, 0, 0, 0, time.UTC),
+		EndDate:   time.Date(2050, 1, 3, 0, 0, 0, 0, time.UTC),
+		Room:      models.Room{RoomName: "Generals Quarters"},
+	}
+
+	n := notification{
+		settings: models.PropertySettings{
+			SenderName:  "Fort Smythe",
+			SenderEmail: "desk@fortsmythe.com",
+			ReplyTo:     "frontdesk@fortsmythe.com",
+		},
+		recipients: []string{"owner@fortsmythe.com", "manager@fortsmythe.com"},
+	}
+
+	messages, err := Repo.reservationMail(res, n)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(messages) != 3 {
+		t.Fatalf("expected 3 messages, got %d", len(messages))
+	}
+
+	guest := messages[0]
+	if guest.To != "john@smith.com" || guest.Template != emails.Confirmation || len(guest.Attachments) != 1 {
+		t.Errorf("wrong guest message %+v", guest)
+	}
+	if !strings.Contains(string(guest.Attachments[0].Data), "reservation-7") {
+		t.Errorf("the calendar attachment should be for reservation 7:\n%s", guest.Attachments[0].Data)
+	}
+	for i, to := range n.recipients {
+		staff := messages[i+1]
+		if staff.To != to || staff.Template != emails.StaffNotification || len(staff.Attachments) != 0 {
+			t.Errorf("wrong staff message %+v", staff)
+		}
+	}
+	for _, msg := range messages {
+		if msg.From != `"Fort Smythe" <desk@fortsmythe.com>` || msg.ReplyTo != "frontdesk@fortsmythe.com" {
+			t.Errorf("wrong sender %q, reply-to %q", msg.From, msg.ReplyTo)
+		}
+	}
+
+	// staff are still told of bookings without a guest address
+	res.Email = ""
+	messages, _ = Repo.reservationMail(res, n)
+	if len(messages) != 2 || messages[0].To != "owner@fortsmythe.com" || len(messages[0].Attachments) != 0 {
+		t.Errorf("expected only the staff messages, got %+v", messages)
+	}
+}
+
+func TestRepository_PostReservationMail(t *testing.T) {
+	mailer := mail.NewMemory()
+	worker := mail.New(Repo.DB, mailer, &app)
+
+	// deliver what other tests left in the outbox
+	if _, err := worker.DeliverDue(); err != nil {
+		t.Fatal(err)
+	}
+	mailer.Reset()
+
+	body := "start_date=2050-01-01&end_date=2050-01-02&first_name=John&last_name=Smith&email=john@smith.com&phone=123456789&room_id=1"
+	req, _ := http.NewRequest("POST", "/make-reservation", strings.NewReader(body))
+	req = req.WithContext(getCtx(req))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rr := httptest.NewRecorder()
+	Repo.PostReservation(rr, req)
+	if rr.Code != http.StatusSeeOther {
+		t.Fatalf("PostReservation handler returned wrong status: got %d, want %d", rr.Code, http.StatusSeeOther)
+	}
+
+	if n, err := worker.DeliverDue(); n != 2 || err != nil {
+		t.Fatalf("expected 2 messages sent, got %d, %v", n, err)
+	}
+
+	guest := mailer.To("john@smith.com")
+	if len(guest) != 1 {
+		t.Fatalf("expected a confirmation to the guest, got %+v", mailer.Messages())
+	}
+	if guest[0].Subject != "Reservation Confirmation" || len(guest[0].Attachments) != 1 {
+		t.Errorf("wrong confirmation %+v", guest[0])
+	}
+	for _, s := range []string{"<html", "Dear John", "from 2050-01-01 to 2050-01-02"} {
+		if !strings.Contains(guest[0].Content, s) {
+			t.Errorf("the confirmation should contain %q:\n%s", s, guest[0].Content)
+		}
+	}
+	if !strings.Contains(guest[0].Text, "Dear John,") || strings.Contains(guest[0].Text, "<") {
+		t.Errorf("wrong text part of the confirmation:\n%s", guest[0].Text)
+	}
+
+	owner := mailer.To("rajiv@mkcl.org")
+	if len(owner) != 1 || owner[0].Subject != "Reservation Notification" {
+		t.Fatalf("expected a notification to the owner, got %+v", mailer.Messages())
+	}
+	if owner[0].From != `"Fort Smythe Bed and Breakfast" <rajiv@mkcl.org>` || owner[0].ReplyTo != "frontdesk@fortsmythe.com" {
+		t.Errorf("the notification should be sent as the property, got %q, %q", owner[0].From, owner[0].ReplyTo)
+	}
+	if !strings.Contains(owner[0].Text, "has been made from 2050-01-01 to 2050-01-02 by John Smith") {
+		t.Errorf("wrong notification body:\n%s", owner[0].Text)
+	}
+}
+
+func TestRepository_PostReservationSMS(t *testing.T) {
+	sender := sms.NewMemory()
+	worker := sms.New(Repo.DB, sender, &app)
+
+	// send what other tests left in the outbox
+	if _, err := worker.DeliverDue(); err != nil {
+		t.Fatal(err)
+	}
+	sender.Reset()
+
+	var testCases = []struct {
+		name  string
+		phone string
+		optIn bool
+		want  int
+		error string
+		to    string
+	}{
+		{"Opted in", "(555) 010-2030", true, http.StatusSeeOther, "", "+15550102030"},
+		{"International number", "+44 20 7946 0958", true, http.StatusSeeOther, "", "+442079460958"},
+		{"Not opted in", "555 010 2030", false, http.StatusSeeOther, "", ""},
+		{"Opted in without a phone", "", true, http.StatusOK, "This field cannot be blank", ""},
+		{"Invalid phone", "555-CALL-NOW", true, http.StatusOK, "Invalid phone number", ""},
+	}
+
+	for _, testCase := range testCases {
+		postData := url.Values{
+			"start_date": {"2050-01-01"},
+			"end_date":   {"2050-01-02"},
+			"first_name": {"John"},
+			"last_name":  {"Smith"},
+			"email":      {"john@smith.com"},
+			"phone":      {testCase.phone},
+			"room_id":    {"1"},
+		}
+		if testCase.optIn {
+			postData.Set("sms_opt_in", "1")
+		}
+		req, _ := http.NewRequest("POST", "/make-reservation", strings.NewReader(postData.Encode()))
+		req = req.WithContext(getCtx(req))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		rr := httptest.NewRecorder()
+		Repo.PostReservation(rr, req)
+
+		if rr.Code != testCase.want {
+			t.Errorf("PostReservation handler returned wrong status for (%s): got %d, want %d", testCase.name, rr.Code, testCase.want)
+		}
+		if testCase.error != "" && !strings.Contains(rr.Body.String(), testCase.error) {
+			t.Errorf("expected %q for (%s)", testCase.error, testCase.name)
+		}
+
+		if _, err := worker.DeliverDue(); err != nil {
+			t.Fatal(err)
+		}
+		sent := sender.Messages()
+		sender.Reset()
+		if testCase.to == "" {
+			if len(sent) != 0 {
+				t.Errorf("expected no text for (%s), got %+v", testCase.name, sent)
+			}
+			continue
+		}
+		if len(sent) != 1 || sent[0].To != testCase.to || sent[0].Template != emails.Confirmation {
+			t.Errorf("expected a confirmation text to %s for (%s), got %+v", testCase.to, testCase.name, sent)
+			continue
+		}
+		if !strings.Contains(sent[0].Body, "is confirmed") || strings.Contains(sent[0].Body, "\n") {
+			t.Errorf("wrong confirmation text for (%s): %q", testCase.name, sent[0].Body)
+		}
+	}
+}
+
+func TestRepository_AdminSettings(t *testing.T) {
+	req, _ := http.NewRequest("GET", "/admin/settings", nil)
+	req = req.WithContext(getCtx(req))
+	rr := httptest.NewRecorder()
+	Repo.AdminSettings(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Errorf("AdminSettings handler returned wrong status: got %d, want %d", rr.Code, http.StatusOK)
+	}
+	for _, s := range []string{"Fort Smythe Bed and Breakfast", "frontdesk@fortsmythe.com", "recipients_modification"} {
+		if !strings.Contains(rr.Body.String(), s) {
+			t.Errorf("AdminSettings handler should render %q", s)
+		}
+	}
+}
+
+func TestRepository_AdminPostSettings(t *testing.T) {
+	var testCases = []struct {
+		name     string
+		postData url.Values
+		want     int
+		flash    string
+		expected string
+	}{
+		{
+			name: "Valid",
+			postData: url.Values{
+				"sender_name":             {"Fort Smythe"},
+				"sender_email":            {"desk@fortsmythe.com"},
+				"reply_to":                {""},
+				"recipients_new_booking":  {"owner@fortsmythe.com\nmanager@fortsmythe.com"},
+				"recipients_cancellation": {""},
+				"recipients_modification": {"owner@fortsmythe.com"},
+			},
+			want:  http.StatusSeeOther,
+			flash: "Settings saved",
+		},
+		{
+			name:     "No sender",
+			postData: url.Values{"sender_name": {"Fort Smythe"}},
+			want:     http.StatusOK,
+			expected: "This field cannot be blank",
+		},
+		{
+			name:     "Invalid reply-to",
+			postData: url.Values{"sender_email": {"desk@fortsmythe.com"}, "reply_to": {"desk@"}},
+			want:     http.StatusOK,
+			expected: "Invalid email address",
+		},
+		{
+			name:     "Invalid recipient",
+			postData: url.Values{"sender_email": {"desk@fortsmythe.com"}, "recipients_cancellation": {"owner@fortsmythe.com, nobody"}},
+			want:     http.StatusOK,
+			expected: "Invalid email address nobody",
+		},
+		{
+			name:     "Database error",
+			postData: url.Values{"sender_email": {"error@fortsmythe.com"}},
+			want:     http.StatusInternalServerError,
+		},
+	}
+
+	for _, testCase := range testCases {
+		req, _ := http.NewRequest("POST", "/admin/settings", strings.NewReader(testCase.postData.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req = req.WithContext(getCtx(req))
+		rr := httptest.NewRecorder()
+		handler := http.HandlerFunc(Repo.AdminPostSettings)
+		handler.ServeHTTP(rr, req)
+
+		if rr.Code != testCase.want {
+			t.Errorf("AdminPostSettings handler returned wrong status for (%s): got %d, want %d", testCase.name, rr.Code, testCase.want)
+		}
+		if !strings.Contains(rr.Body.String(), testCase.expected) {
+			t.Errorf("AdminPostSettings handler for (%s) should render %q", testCase.name, testCase.expected)
+		}
+		if flash := session.PopString(req.Context(), "flash"); flash != testCase.flash {
+			t.Errorf("AdminPostSettings handler flashed wrong message for (%s): got %q, want %q", testCase.name, flash, testCase.flash)
+		}
+	}
+}
+
+func TestRepository_AdminNotifications(t *testing.T) {
+	var testCases = []struct {
+		name   string
+		userID int
+		want   int
+	}{
+		{"Logged in user", 1, http.StatusOK},
+		{"Logged out", 0, http.StatusSeeOther},
+		{"Database error", 3, http.StatusInternalServerError},
+	}
+
+	for _, testCase := range testCases {
+		req, _ := http.NewRequest("GET", "/admin/notifications", nil)
+		ctx := getCtx(req)
+		req = req.WithContext(ctx)
+		if testCase.userID > 0 {
+			session.Put(ctx, "user_id", testCase.userID)
+		}
+		rr := httptest.NewRecorder()
+		handler := http.HandlerFunc(Repo.AdminNotifications)
+		handler.ServeHTTP(rr, req)
+
+		if rr.Code != testCase.want {
+			t.Errorf("AdminNotifications handler returned wrong status for (%s): got %d, want %d", testCase.name, rr.Code, testCase.want)
+		}
+		if rr.Code == http.StatusOK && !strings.Contains(rr.Body.String(), `value="modification" checked`) {
+			t.Errorf("AdminNotifications handler for (%s) should check the chosen events", testCase.name)
+		}
+	}
+}
+
+func TestRepository_AdminPostNotifications(t *testing.T) {
+	var testCases = []struct {
+		name   string
+		userID int
+		events []string
+		want   int
+		flash  string
+	}{
+		{"Valid", 1, []string{models.EventNewBooking, models.EventCancellation}, http.StatusSeeOther, "Notification preferences saved"},
+		{"None", 1, nil, http.StatusSeeOther, "Notification preferences saved"},
+		{"Unknown event", 1, []string{"birthday"}, http.StatusBadRequest, ""},
+		{"Logged out", 0, []string{models.EventNewBooking}, http.StatusSeeOther, ""},
+		{"Database error", 3, []string{models.EventNewBooking}, http.StatusInternalServerError, ""},
+	}
+
+	for _, testCase := range testCases {
+		postData := url.Values{"events": testCase.events}
+		req, _ := http.NewRequest("POST", "/admin/notifications", strings.NewReader(postData.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		ctx := getCtx(req)
+		req = req.WithContext(ctx)
+		if testCase.userID > 0 {
+			session.Put(ctx, "user_id", testCase.userID)
+		}
+		rr := httptest.NewRecorder()
+		handler := http.HandlerFunc(Repo.AdminPostNotifications)
+		handler.ServeHTTP(rr, req)
+
+		if rr.Code != testCase.want {
+			t.Errorf("AdminPostNotifications handler returned wrong status for (%s): got %d, want %d", testCase.name, rr.Code, testCase.want)
+		}
+		if flash := session.PopString(req.Context(), "flash"); flash != testCase.flash {
+			t.Errorf("AdminPostNotifications handler flashed wrong message for (%s): got %q, want %q", testCase.name, flash, testCase.flash)
+		}
+	}
+}
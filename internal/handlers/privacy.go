@@ -0,0 +1,110 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/go-course/bookings/internal/forms"
+	"github.com/go-course/bookings/internal/helpers"
+	"github.com/go-course/bookings/internal/models"
+	"github.com/go-course/bookings/internal/privacy"
+	"github.com/go-course/bookings/internal/render"
+)
+
+// AdminPrivacy shows what is held about the guest with the email address in the url query, with actions to
+// export or erase it
+func (m *Repository) AdminPrivacy(w http.ResponseWriter, r *http.Request) {
+	form := forms.New(r.URL.Query())
+
+	data := make(map[string]interface{})
+	if form.Has("email") {
+		form.IsEmail("email")
+		if form.Valid() {
+			held, err := m.DB.GuestDataByEmail(form.Get("email"))
+			if err != nil {
+				helpers.ServerError(w, err)
+				return
+			}
+			data["held"] = held
+		}
+	}
+
+	intMap := make(map[string]int)
+	intMap["anonymize_after"] = m.App.AnonymizeAfterYears
+
+	render.Template(w, r, "admin-privacy.page.tmpl", &models.TemplateData{
+		Data:   data,
+		IntMap: intMap,
+		Form:   form,
+	})
+}
+
+// AdminExportGuestData downloads everything held about a guest as JSON or a ZIP bundle
+func (m *Repository) AdminExportGuestData(w http.ResponseWriter, r *http.Request) {
+	err := r.ParseForm()
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	email := r.Form.Get("email")
+	format := r.Form.Get("format")
+	if !privacy.ValidFormat(format) {
+		helpers.ClientError(w, http.StatusBadRequest)
+		return
+	}
+
+	held, err := m.auditDB(r).GuestDataByEmail(email)
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+	if held.Empty() {
+		m.App.Session.Put(r.Context(), "error", "Nothing is held about this email address")
+		http.Redirect(w, r, privacyURL(email), http.StatusSeeOther)
+		return
+	}
+
+	w.Header().Set("Content-Type", privacy.ContentType(format))
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, privacy.FileName(format)))
+	if err := privacy.Write(format, w, held, time.Now()); err != nil {
+		// the headers are gone, all we can do is log
+		m.App.ErrorLog.Println("guest data export failed:", err)
+	}
+}
+
+// AdminEraseGuestData anonymizes the reservations of a guest and deletes their profile. The email address
+// has to be typed twice
+func (m *Repository) AdminEraseGuestData(w http.ResponseWriter, r *http.Request) {
+	err := r.ParseForm()
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	email := r.Form.Get("email")
+	if email == "" || models.NormalizeEmail(email) != models.NormalizeEmail(r.Form.Get("confirm_email")) {
+		m.App.Session.Put(r.Context(), "error", "Type the email address again to confirm the erasure")
+		http.Redirect(w, r, privacyURL(email), http.StatusSeeOther)
+		return
+	}
+
+	n, err := m.auditDB(r).EraseGuestData(email)
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	m.App.Session.Put(r.Context(), "flash", fmt.Sprintf("Guest data erased from %d reservations", n))
+	http.Redirect(w, r, "/admin/privacy", http.StatusSeeOther)
+}
+
+// privacyURL returns the privacy page for an email address
+func privacyURL(email string) string {
+	if email == "" {
+		return "/admin/privacy"
+	}
+	return "/admin/privacy?" + url.Values{"email": {email}}.Encode()
+}
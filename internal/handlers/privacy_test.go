@@ -0,0 +1,126 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestRepository_AdminPrivacy(t *testing.T) {
+	var testCases = []struct {
+		name   string
+		query  string
+		want   int
+		render string
+	}{
+		{"No email", "", http.StatusOK, "Look up"},
+		{"Guest", "?email=John@Smith.com", http.StatusOK, "1 reservations"},
+		{"Guest text messages", "?email=john@smith.com", http.StatusOK, "1 text messages"},
+		{"Unknown guest", "?email=nobody@example.com", http.StatusOK, "0 reservations"},
+		{"Invalid email", "?email=john", http.StatusOK, "Invalid email address"},
+		{"Database error", "?email=fail@error.com", http.StatusInternalServerError, ""},
+	}
+
+	for _, testCase := range testCases {
+		req, _ := http.NewRequest("GET", "/admin/privacy"+testCase.query, nil)
+		ctx := getCtx(req)
+		req = req.WithContext(ctx)
+		rr := httptest.NewRecorder()
+		handler := http.HandlerFunc(Repo.AdminPrivacy)
+		handler.ServeHTTP(rr, req)
+
+		if rr.Code != testCase.want {
+			t.Errorf("AdminPrivacy handler returned wrong status for (%s): got %d, want %d", testCase.name, rr.Code, testCase.want)
+		}
+		if !strings.Contains(rr.Body.String(), testCase.render) {
+			t.Errorf("AdminPrivacy handler should render %q for (%s)", testCase.render, testCase.name)
+		}
+	}
+}
+
+func TestRepository_AdminExportGuestData(t *testing.T) {
+	var testCases = []struct {
+		name        string
+		email       string
+		format      string
+		want        int
+		contentType string
+		error       string
+	}{
+		{"JSON", "john@smith.com", "json", http.StatusOK, "application/json", ""},
+		{"ZIP", "john@smith.com", "zip", http.StatusOK, "application/zip", ""},
+		{"Nothing held", "nobody@example.com", "json", http.StatusSeeOther, "", "Nothing is held about this email address"},
+		{"Invalid format", "john@smith.com", "xml", http.StatusBadRequest, "text/plain; charset=utf-8", ""},
+		{"Database error", "fail@error.com", "json", http.StatusInternalServerError, "text/plain; charset=utf-8", ""},
+	}
+
+	for _, testCase := range testCases {
+		postedData := url.Values{}
+		postedData.Add("email", testCase.email)
+		postedData.Add("format", testCase.format)
+
+		req, _ := http.NewRequest("POST", "/admin/privacy/export", strings.NewReader(postedData.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		ctx := getCtx(req)
+		req = req.WithContext(ctx)
+		rr := httptest.NewRecorder()
+		handler := http.HandlerFunc(Repo.AdminExportGuestData)
+		handler.ServeHTTP(rr, req)
+
+		if rr.Code != testCase.want {
+			t.Errorf("AdminExportGuestData handler returned wrong status for (%s): got %d, want %d", testCase.name, rr.Code, testCase.want)
+		}
+		if ct := rr.Header().Get("Content-Type"); testCase.contentType != "" && ct != testCase.contentType {
+			t.Errorf("AdminExportGuestData handler returned wrong content type for (%s): got %q, want %q", testCase.name, ct, testCase.contentType)
+		}
+		if msg := session.PopString(req.Context(), "error"); msg != testCase.error {
+			t.Errorf("AdminExportGuestData handler flashed wrong error for (%s): got %q, want %q", testCase.name, msg, testCase.error)
+		}
+	}
+}
+
+func TestRepository_AdminEraseGuestData(t *testing.T) {
+	var testCases = []struct {
+		name     string
+		email    string
+		confirm  string
+		want     int
+		location string
+		flash    string
+		error    string
+	}{
+		{"Erase", "john@smith.com", " John@Smith.com", http.StatusSeeOther, "/admin/privacy", "Guest data erased from 1 reservations", ""},
+		{"Not confirmed", "john@smith.com", "jon@smith.com", http.StatusSeeOther, "/admin/privacy?email=john%40smith.com", "", "Type the email address again to confirm the erasure"},
+		{"No email", "", "", http.StatusSeeOther, "/admin/privacy", "", "Type the email address again to confirm the erasure"},
+		{"Database error", "fail@error.com", "fail@error.com", http.StatusInternalServerError, "", "", ""},
+	}
+
+	for _, testCase := range testCases {
+		postedData := url.Values{}
+		postedData.Add("email", testCase.email)
+		postedData.Add("confirm_email", testCase.confirm)
+
+		req, _ := http.NewRequest("POST", "/admin/privacy/erase", strings.NewReader(postedData.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		ctx := getCtx(req)
+		req = req.WithContext(ctx)
+		rr := httptest.NewRecorder()
+		handler := http.HandlerFunc(Repo.AdminEraseGuestData)
+		handler.ServeHTTP(rr, req)
+
+		if rr.Code != testCase.want {
+			t.Errorf("AdminEraseGuestData handler returned wrong status for (%s): got %d, want %d", testCase.name, rr.Code, testCase.want)
+		}
+		if location := rr.Header().Get("Location"); location != testCase.location {
+			t.Errorf("AdminEraseGuestData handler redirected to wrong location for (%s): got %q, want %q", testCase.name, location, testCase.location)
+		}
+		if flash := session.PopString(req.Context(), "flash"); flash != testCase.flash {
+			t.Errorf("AdminEraseGuestData handler flashed wrong message for (%s): got %q, want %q", testCase.name, flash, testCase.flash)
+		}
+		if msg := session.PopString(req.Context(), "error"); msg != testCase.error {
+			t.Errorf("AdminEraseGuestData handler flashed wrong error for (%s): got %q, want %q", testCase.name, msg, testCase.error)
+		}
+	}
+}
@@ -0,0 +1,170 @@
+package handlers
+
+import (
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-course/bookings/internal/export"
+	"github.com/go-course/bookings/internal/forms"
+	"github.com/go-course/bookings/internal/helpers"
+	"github.com/go-course/bookings/internal/models"
+	"github.com/go-course/bookings/internal/render"
+	"github.com/go-course/bookings/internal/reports"
+)
+
+// maxReportDays bounds the period of a report
+const maxReportDays = 3 * 366
+
+// reportLink is a link to a report for the period shown
+type reportLink struct {
+	Title  string
+	URL    string
+	Active bool
+}
+
+// AdminReports shows a report for the period picked in the url query, the last twelve months by default
+func (m *Repository) AdminReports(w http.ResponseWriter, r *http.Request) {
+	name := chi.URLParam(r, "report")
+	if name == "" {
+		name = reports.Reports[0].Name
+	}
+	if _, ok := reports.Find(name); !ok {
+		helpers.ClientError(w, http.StatusNotFound)
+		return
+	}
+
+	form, from, to := reportPeriod(r.URL.Query())
+
+	data := make(map[string]interface{})
+	if form.Valid() {
+		table, err := m.buildReport(name, from, to)
+		if err != nil {
+			helpers.ServerError(w, err)
+			return
+		}
+		data["table"] = table
+	}
+
+	// the links keep the period
+	query := url.Values{"from": {form.Get("from")}, "to": {form.Get("to")}}.Encode()
+	var links []reportLink
+	for _, report := range reports.Reports {
+		links = append(links, reportLink{
+			Title:  report.Title,
+			URL:    "/admin/reports/" + report.Name + "?" + query,
+			Active: report.Name == name,
+		})
+	}
+	data["reports"] = links
+
+	stringMap := make(map[string]string)
+	stringMap["report"] = name
+	stringMap["export_csv"] = "/admin/reports/" + name + "/csv?" + query
+	stringMap["export_xlsx"] = "/admin/reports/" + name + "/xlsx?" + query
+
+	render.Template(w, r, "admin-reports.page.tmpl", &models.TemplateData{
+		StringMap: stringMap,
+		Data:      data,
+		Form:      form,
+	})
+}
+
+// AdminExportReport streams a report as CSV or XLSX
+func (m *Repository) AdminExportReport(w http.ResponseWriter, r *http.Request) {
+	name := chi.URLParam(r, "report")
+	format := chi.URLParam(r, "format")
+	if _, ok := reports.Find(name); !ok || !export.ValidFormat(format) {
+		helpers.ClientError(w, http.StatusNotFound)
+		return
+	}
+
+	form, from, to := reportPeriod(r.URL.Query())
+	if !form.Valid() {
+		helpers.ClientError(w, http.StatusBadRequest)
+		return
+	}
+
+	table, err := m.buildReport(name, from, to)
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	out, ok := m.startExport(w, format, "report-"+name, table.Title)
+	if !ok {
+		return
+	}
+
+	columns := make([]interface{}, len(table.Columns))
+	for i, c := range table.Columns {
+		columns[i] = c
+	}
+	err = out.WriteRow(columns...)
+	for _, row := range table.Rows {
+		if err != nil {
+			break
+		}
+		err = out.WriteRow(row...)
+	}
+	m.finishExport(out, err)
+}
+
+// reportPeriod reads the from and to dates of a report, both included, and returns the period as
+// from up to, but not including, the day after to. Missing dates default to the last twelve months
+func reportPeriod(query url.Values) (*forms.Form, time.Time, time.Time) {
+	now := time.Now()
+	firstOfMonth := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+	if query.Get("from") == "" {
+		query.Set("from", firstOfMonth.AddDate(-1, 0, 0).Format("2006-01-02"))
+	}
+	if query.Get("to") == "" {
+		query.Set("to", firstOfMonth.AddDate(0, 0, -1).Format("2006-01-02"))
+	}
+
+	form := forms.New(query)
+	from, err := time.Parse("2006-01-02", form.Get("from"))
+	if err != nil {
+		form.Errors.Add("from", "Invalid date")
+	}
+	to, err := time.Parse("2006-01-02", form.Get("to"))
+	if err != nil {
+		form.Errors.Add("to", "Invalid date")
+	}
+
+	if form.Valid() {
+		switch {
+		case to.Before(from):
+			form.Errors.Add("to", "The end must not be before the start")
+		case to.Sub(from).Hours()/24 >= maxReportDays:
+			form.Errors.Add("to", "Reports cover three years at most")
+		}
+	}
+
+	return form, from, to.AddDate(0, 0, 1)
+}
+
+// buildReport loads the data of the period and computes the report called name
+func (m *Repository) buildReport(name string, from, to time.Time) (reports.Table, error) {
+	d := reports.Data{From: from, To: to, AsOf: time.Now()}
+
+	var err error
+	if d.Rooms, err = m.DB.AllRooms(); err != nil {
+		return reports.Table{}, err
+	}
+	if d.Reservations, err = m.DB.ReservationsArriving(from, to); err != nil {
+		return reports.Table{}, err
+	}
+	if d.LastYear, err = m.DB.ReservationsArriving(from.AddDate(-1, 0, 0), to.AddDate(-1, 0, 0)); err != nil {
+		return reports.Table{}, err
+	}
+	if d.Restrictions, err = m.DB.RestrictionsBetween(from, to); err != nil {
+		return reports.Table{}, err
+	}
+	if d.Sources, err = m.DB.AllICalSources(); err != nil {
+		return reports.Table{}, err
+	}
+
+	return reports.Build(name, d)
+}
@@ -0,0 +1,103 @@
+package handlers
+
+import (
+	"context"
+	"encoding/csv"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// reportRequest serves url with the report and format route params set
+func reportRequest(handler http.HandlerFunc, url, report, format string) *httptest.ResponseRecorder {
+	req, _ := http.NewRequest("GET", url, nil)
+	rctx := chi.NewRouteContext()
+	if report != "" {
+		rctx.URLParams.Add("report", report)
+	}
+	if format != "" {
+		rctx.URLParams.Add("format", format)
+	}
+	ctx := getCtx(req)
+	req = req.WithContext(context.WithValue(ctx, chi.RouteCtxKey, rctx))
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	return rr
+}
+
+func TestRepository_AdminReports(t *testing.T) {
+	var testCases = []struct {
+		name   string
+		url    string
+		report string
+		want   int
+		html   string
+	}{
+		{"Default report", "/admin/reports", "", http.StatusOK, "Occupancy by Room and Month"},
+		{"Occupancy", "/admin/reports/occupancy?from=2050-01-01&to=2050-01-31", "occupancy", http.StatusOK, "<td>Generals Quarters</td><td>2050-01</td><td>31</td><td>3</td>"},
+		{"Stays", "/admin/reports/stays?from=2050-01-01&to=2050-01-31", "stays", http.StatusOK, "<td>All rooms</td><td>1</td><td>3</td><td>3</td>"},
+		{"Period kept in links", "/admin/reports/stays?from=2050-01-01&to=2050-01-31", "stays", http.StatusOK, `href="/admin/reports/pace?from=2050-01-01&amp;to=2050-01-31"`},
+		{"Export links", "/admin/reports/stays?from=2050-01-01&to=2050-01-31", "stays", http.StatusOK, `href='/admin/reports/stays/xlsx?from=2050-01-01&amp;to=2050-01-31'`},
+		{"Invalid date", "/admin/reports/stays?from=someday", "stays", http.StatusOK, "Invalid date"},
+		{"Reversed dates", "/admin/reports/stays?from=2050-02-01&to=2050-01-01", "stays", http.StatusOK, "The end must not be before the start"},
+		{"Too long", "/admin/reports/stays?from=2050-01-01&to=2060-01-01", "stays", http.StatusOK, "Reports cover three years at most"},
+		{"Unknown report", "/admin/reports/revenue", "revenue", http.StatusNotFound, ""},
+	}
+
+	for _, testCase := range testCases {
+		rr := reportRequest(Repo.AdminReports, testCase.url, testCase.report, "")
+
+		if rr.Code != testCase.want {
+			t.Errorf("AdminReports handler returned wrong status for (%s): got %d, want %d", testCase.name, rr.Code, testCase.want)
+		}
+		if !strings.Contains(rr.Body.String(), testCase.html) {
+			t.Errorf("AdminReports handler for (%s) should render %q", testCase.name, testCase.html)
+		}
+	}
+}
+
+func TestRepository_AdminExportReport(t *testing.T) {
+	var testCases = []struct {
+		name   string
+		url    string
+		report string
+		format string
+		want   int
+	}{
+		{"CSV", "/admin/reports/cancellations/csv?from=2050-01-01&to=2050-02-28", "cancellations", "csv", http.StatusOK},
+		{"XLSX", "/admin/reports/cancellations/xlsx?from=2050-01-01&to=2050-02-28", "cancellations", "xlsx", http.StatusOK},
+		{"Unknown report", "/admin/reports/revenue/csv", "revenue", "csv", http.StatusNotFound},
+		{"Unknown format", "/admin/reports/cancellations/pdf", "cancellations", "pdf", http.StatusNotFound},
+		{"Invalid date", "/admin/reports/cancellations/csv?from=someday", "cancellations", "csv", http.StatusBadRequest},
+	}
+
+	for _, testCase := range testCases {
+		rr := reportRequest(Repo.AdminExportReport, testCase.url, testCase.report, testCase.format)
+
+		if rr.Code != testCase.want {
+			t.Errorf("AdminExportReport handler returned wrong status for (%s): got %d, want %d", testCase.name, rr.Code, testCase.want)
+		}
+	}
+
+	rr := reportRequest(Repo.AdminExportReport, "/admin/reports/cancellations/csv?from=2050-01-01&to=2050-02-28", "cancellations", "csv")
+	records, err := csv.NewReader(rr.Body).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := [][]string{
+		{"Month", "Reservations", "Cancelled", "No-Shows", "Cancellation %"},
+		{"2050-01", "1", "0", "0", "0.00"},
+		{"2050-02", "0", "0", "0", "0.00"},
+	}
+	if len(records) != len(want) {
+		t.Fatalf("got %q, want %q", records, want)
+	}
+	for i := range want {
+		if strings.Join(records[i], ",") != strings.Join(want[i], ",") {
+			t.Errorf("row %d: got %q, want %q", i, records[i], want[i])
+		}
+	}
+}
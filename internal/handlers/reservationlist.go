@@ -0,0 +1,208 @@
+package handlers
+
+import (
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/go-course/bookings/internal/export"
+	"github.com/go-course/bookings/internal/forms"
+	"github.com/go-course/bookings/internal/helpers"
+	"github.com/go-course/bookings/internal/models"
+	"github.com/go-course/bookings/internal/render"
+)
+
+// reservationSortKeys are the columns reservation lists can be sorted by
+var reservationSortKeys = []string{"id", "last_name", "room", "start_date", "end_date", "status", "created_at"}
+
+// reservationPage is a page of a reservation list, with links that keep its filters
+type reservationPage struct {
+	Reservations []models.Reservation
+	Query        models.ReservationQuery
+	Total        int
+	Pages        int
+	PrevURL      string
+	NextURL      string
+	Links        []pageLink
+	SortURLs     map[string]string
+}
+
+// pageLink is a link to a page of a list. Gap links stand for skipped pages
+type pageLink struct {
+	Number int
+	URL    string
+	Active bool
+	Gap    bool
+}
+
+// renderReservationList renders tmpl with the page of reservations selected by the url query.
+// src is the list the reservation pages link back to, and a non empty status restricts the list to that status
+func (m *Repository) renderReservationList(w http.ResponseWriter, r *http.Request, tmpl, src, status string) {
+	form := forms.New(r.URL.Query())
+
+	if status == "" {
+		status = form.Get("status")
+		if status != "" && !models.ValidReservationStatus(status) {
+			helpers.ClientError(w, http.StatusBadRequest)
+			return
+		}
+	}
+
+	q := parseReservationQuery(form)
+	q.Status = status
+
+	page := reservationPage{Query: q}
+	if form.Valid() {
+		reservations, total, err := m.DB.SearchReservations(q)
+		if err != nil {
+			helpers.ServerError(w, err)
+			return
+		}
+		page.Reservations = reservations
+		page.Total = total
+	}
+	page.links(r.URL.Path, r.URL.Query())
+
+	rooms, err := m.DB.AllRooms()
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	data := make(map[string]interface{})
+	data["page"] = page
+	data["rooms"] = rooms
+	data["statuses"] = models.ReservationStatuses
+	data["src"] = src
+	data["path"] = r.URL.Path
+	for _, format := range []string{export.CSV, export.XLSX} {
+		data["export_"+format] = listURL("/admin/export/reservations/"+format, r.URL.Query(),
+			"page", "", "size", "", "status", status)
+	}
+
+	render.Template(w, r, tmpl, &models.TemplateData{
+		Data: data,
+		Form: form,
+	})
+}
+
+// parseReservationQuery reads the list query from form, adding errors for invalid values
+func parseReservationQuery(form *forms.Form) models.ReservationQuery {
+	q := models.ReservationQuery{
+		Page:     1,
+		PageSize: models.DefaultPageSize,
+		Sort:     "start_date",
+		Desc:     form.Get("dir") == "desc",
+		Search:   form.Get("q"),
+	}
+
+	if v := form.Get("page"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			form.Errors.Add("page", "Invalid page")
+		}
+		q.Page = n
+	}
+
+	if v := form.Get("size"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > models.MaxPageSize {
+			form.Errors.Add("size", "Invalid page size")
+		}
+		q.PageSize = n
+	}
+
+	// unknown sort keys fall back to the default order
+	for _, key := range reservationSortKeys {
+		if form.Get("sort") == key {
+			q.Sort = key
+		}
+	}
+
+	if v := form.Get("room_id"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			form.Errors.Add("room_id", "Choose a room")
+		}
+		q.RoomID = n
+	}
+
+	if v := form.Get("from"); v != "" {
+		from, err := time.Parse("2006-01-02", v)
+		if err != nil {
+			form.Errors.Add("from", "Invalid date")
+		}
+		q.From = from
+	}
+	if v := form.Get("to"); v != "" {
+		to, err := time.Parse("2006-01-02", v)
+		if err != nil {
+			form.Errors.Add("to", "Invalid date")
+		}
+		q.To = to
+	}
+
+	return q
+}
+
+// links fills in the paging and sorting links of p, keeping the other parameters of query
+func (p *reservationPage) links(path string, query url.Values) {
+	p.Pages = p.Query.Pages(p.Total)
+	current := p.Query.Page
+
+	if current > 1 {
+		p.PrevURL = listURL(path, query, "page", strconv.Itoa(current-1))
+	}
+	if current < p.Pages {
+		p.NextURL = listURL(path, query, "page", strconv.Itoa(current+1))
+	}
+
+	// the first and last pages, and two pages either side of the current one
+	gap := false
+	for n := 1; n <= p.Pages; n++ {
+		if n > 1 && n < p.Pages && (n < current-2 || n > current+2) {
+			if !gap {
+				p.Links = append(p.Links, pageLink{Gap: true})
+				gap = true
+			}
+			continue
+		}
+		gap = false
+		p.Links = append(p.Links, pageLink{
+			Number: n,
+			URL:    listURL(path, query, "page", strconv.Itoa(n)),
+			Active: n == current,
+		})
+	}
+
+	p.SortURLs = make(map[string]string)
+	for _, key := range reservationSortKeys {
+		dir := ""
+		if key == p.Query.Sort && !p.Query.Desc {
+			dir = "desc"
+		}
+		p.SortURLs[key] = listURL(path, query, "sort", key, "dir", dir, "page", "")
+	}
+}
+
+// listURL returns path with query, after setting the given key value pairs. Empty values are dropped
+func listURL(path string, query url.Values, pairs ...string) string {
+	v := url.Values{}
+	for key, values := range query {
+		v[key] = values
+	}
+	for i := 0; i+1 < len(pairs); i += 2 {
+		v.Set(pairs[i], pairs[i+1])
+	}
+	for key := range v {
+		if v.Get(key) == "" {
+			v.Del(key)
+		}
+	}
+
+	if len(v) == 0 {
+		return path
+	}
+	return path + "?" + v.Encode()
+}
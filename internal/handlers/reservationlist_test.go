@@ -0,0 +1,90 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestRepository_AdminAllReservations(t *testing.T) {
+	var testCases = []struct {
+		name    string
+		url     string
+		want    int
+		html    string
+		missing string
+	}{
+		{"First page", "/admin/reservations-all", http.StatusOK, `href="/admin/reservations-all?page=2"`, ""},
+		{"Filters kept when paging", "/admin/reservations-all?q=smith&status=confirmed&page=2", http.StatusOK, `href="/admin/reservations-all?page=3&amp;q=smith&amp;status=confirmed"`, ""},
+		{"Sorting resets the page", "/admin/reservations-all?q=smith&page=2", http.StatusOK, `href="/admin/reservations-all?dir=desc&amp;q=smith&amp;sort=start_date"`, ""},
+		{"Sorting toggles direction", "/admin/reservations-all?sort=last_name&dir=desc", http.StatusOK, `href="/admin/reservations-all?sort=last_name"`, ""},
+		{"No matches", "/admin/reservations-all?q=nobody", http.StatusOK, "No reservations found", "Smith"},
+		{"Invalid date", "/admin/reservations-all?from=yesterday", http.StatusOK, "Invalid date", "Smith"},
+		{"Invalid room", "/admin/reservations-all?room_id=generals", http.StatusOK, "Choose a room", "Smith"},
+		{"Invalid page size", "/admin/reservations-all?size=1000", http.StatusOK, "Invalid page size", "Smith"},
+		{"Invalid status", "/admin/reservations-all?status=processed", http.StatusBadRequest, "", ""},
+	}
+
+	for _, testCase := range testCases {
+		req, _ := http.NewRequest("GET", testCase.url, nil)
+		ctx := getCtx(req)
+		req = req.WithContext(ctx)
+		rr := httptest.NewRecorder()
+		handler := http.HandlerFunc(Repo.AdminAllReservations)
+		handler.ServeHTTP(rr, req)
+
+		if rr.Code != testCase.want {
+			t.Errorf("AdminAllReservations handler returned wrong status for (%s): got %d, want %d", testCase.name, rr.Code, testCase.want)
+		}
+		if !strings.Contains(rr.Body.String(), testCase.html) {
+			t.Errorf("AdminAllReservations handler for (%s) should render %q", testCase.name, testCase.html)
+		}
+		if testCase.missing != "" && strings.Contains(rr.Body.String(), testCase.missing) {
+			t.Errorf("AdminAllReservations handler for (%s) should not render %q", testCase.name, testCase.missing)
+		}
+	}
+}
+
+func TestRepository_AdminNewReservations(t *testing.T) {
+	// new reservations are always pending, whatever the query asks for
+	req, _ := http.NewRequest("GET", "/admin/reservations-new?status=confirmed", nil)
+	ctx := getCtx(req)
+	req = req.WithContext(ctx)
+	rr := httptest.NewRecorder()
+	handler := http.HandlerFunc(Repo.AdminNewReservations)
+	handler.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Errorf("AdminNewReservations handler returned wrong status: got %d, want %d", rr.Code, http.StatusOK)
+	}
+	if !strings.Contains(rr.Body.String(), "No reservations found") {
+		t.Error("AdminNewReservations handler should only list pending reservations")
+	}
+}
+
+func TestReservationPage_Links(t *testing.T) {
+	form := url.Values{"page": {"6"}}
+	p := reservationPage{Total: 250}
+	p.Query.Page = 6
+	p.Query.PageSize = 25
+	p.links("/admin/reservations-all", form)
+
+	var got []int
+	for _, l := range p.Links {
+		got = append(got, l.Number)
+	}
+	want := []int{1, 0, 4, 5, 6, 7, 8, 0, 10}
+	if len(got) != len(want) {
+		t.Fatalf("links: got %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("links: got %v, want %v", got, want)
+		}
+	}
+	if p.PrevURL != "/admin/reservations-all?page=5" || p.NextURL != "/admin/reservations-all?page=7" {
+		t.Errorf("wrong prev/next: %q %q", p.PrevURL, p.NextURL)
+	}
+}
@@ -0,0 +1,123 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-course/bookings/internal/forms"
+	"github.com/go-course/bookings/internal/helpers"
+	"github.com/go-course/bookings/internal/models"
+	"github.com/go-course/bookings/internal/render"
+)
+
+// scheduleOffsets describe what the offset of each scheduled job counts. Jobs without one have no offset
+var scheduleOffsets = map[string]string{
+	models.JobGuestReminder: "Days before arrival",
+	models.JobGuestFollowUp: "Days after departure",
+}
+
+// maxOffsetDays is the furthest from a stay guest emails can be scheduled
+const maxOffsetDays = 60
+
+// AdminSchedules shows the scheduled jobs with how their last run went
+func (m *Repository) AdminSchedules(w http.ResponseWriter, r *http.Request) {
+	jobs, err := m.DB.ScheduledJobs()
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	data := make(map[string]interface{})
+	data["jobs"] = jobs
+	data["names"] = models.JobNames
+	data["offsets"] = scheduleOffsets
+
+	render.Template(w, r, "admin-schedules.page.tmpl", &models.TemplateData{
+		Data: data,
+	})
+}
+
+// AdminShowSchedule shows the form to change when a scheduled job runs
+func (m *Repository) AdminShowSchedule(w http.ResponseWriter, r *http.Request) {
+	job, ok := m.scheduledJob(w, r)
+	if !ok {
+		return
+	}
+
+	form := forms.New(url.Values{})
+	if job.Enabled {
+		form.Set("enabled", "1")
+	}
+	form.Set("offset_days", strconv.Itoa(job.OffsetDays))
+	form.Set("hour", strconv.Itoa(job.Hour))
+
+	m.renderSchedule(w, r, job, form)
+}
+
+// AdminPostSchedule saves whether a scheduled job is enabled, its offset and the hour it runs at
+func (m *Repository) AdminPostSchedule(w http.ResponseWriter, r *http.Request) {
+	job, ok := m.scheduledJob(w, r)
+	if !ok {
+		return
+	}
+
+	err := r.ParseForm()
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	form := forms.New(r.PostForm)
+	job.Enabled = form.Has("enabled")
+	if _, ok := scheduleOffsets[job.Name]; ok {
+		job.OffsetDays = form.IntBetween("offset_days", 0, maxOffsetDays)
+	}
+	job.Hour = form.IntBetween("hour", 0, 23)
+
+	if !form.Valid() {
+		m.renderSchedule(w, r, job, form)
+		return
+	}
+
+	job.NextRunAt = job.NextRun(time.Now())
+	err = m.auditDB(r).UpdateScheduledJob(job)
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	m.App.Session.Put(r.Context(), "flash", "Schedule saved")
+	http.Redirect(w, r, "/admin/schedules", http.StatusSeeOther)
+}
+
+// scheduledJob loads the job named in the url, writing the error response if it can't
+func (m *Repository) scheduledJob(w http.ResponseWriter, r *http.Request) (models.ScheduledJob, bool) {
+	job, err := m.DB.GetScheduledJob(chi.URLParam(r, "name"))
+	if errors.Is(err, sql.ErrNoRows) {
+		helpers.ClientError(w, http.StatusNotFound)
+		return job, false
+	}
+	if err != nil {
+		helpers.ServerError(w, err)
+		return job, false
+	}
+	return job, true
+}
+
+// renderSchedule renders the schedule page of job with form
+func (m *Repository) renderSchedule(w http.ResponseWriter, r *http.Request, job models.ScheduledJob, form *forms.Form) {
+	data := make(map[string]interface{})
+	data["job"] = job
+	data["name"] = models.JobNames[job.Name]
+	data["offset"] = scheduleOffsets[job.Name]
+
+	render.Template(w, r, "admin-schedule.page.tmpl", &models.TemplateData{
+		Form: form,
+		Data: data,
+	})
+}
@@ -0,0 +1,131 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+)
+
+func TestRepository_AdminSchedules(t *testing.T) {
+	req, _ := http.NewRequest("GET", "/admin/schedules", nil)
+	req = req.WithContext(getCtx(req))
+	rr := httptest.NewRecorder()
+	Repo.AdminSchedules(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Errorf("AdminSchedules handler returned wrong status: got %d, want %d", rr.Code, http.StatusOK)
+	}
+	for _, s := range []string{"Pre-arrival reminder", "Days before arrival: 3", "Post-stay thank-you", "09:00", "can&#39;t render email"} {
+		if !strings.Contains(rr.Body.String(), s) {
+			t.Errorf("AdminSchedules handler should render %q", s)
+		}
+	}
+}
+
+func TestRepository_AdminShowSchedule(t *testing.T) {
+	var testCases = []struct {
+		name string
+		job  string
+		want int
+	}{
+		{"Reminder", "guest_reminder", http.StatusOK},
+		{"Unknown job", "newsletter", http.StatusNotFound},
+	}
+
+	for _, testCase := range testCases {
+		req, _ := http.NewRequest("GET", "/admin/schedules/"+testCase.job, nil)
+		rctx := chi.NewRouteContext()
+		rctx.URLParams.Add("name", testCase.job)
+		req = req.WithContext(context.WithValue(getCtx(req), chi.RouteCtxKey, rctx))
+		rr := httptest.NewRecorder()
+		Repo.AdminShowSchedule(rr, req)
+
+		if rr.Code != testCase.want {
+			t.Errorf("AdminShowSchedule handler returned wrong status for (%s): got %d, want %d", testCase.name, rr.Code, testCase.want)
+		}
+	}
+}
+
+func TestRepository_AdminPostSchedule(t *testing.T) {
+	var testCases = []struct {
+		name     string
+		job      string
+		postData url.Values
+		want     int
+		flash    string
+		expected string
+	}{
+		{
+			name:     "Valid",
+			job:      "guest_reminder",
+			postData: url.Values{"enabled": {"1"}, "offset_days": {"2"}, "hour": {"8"}},
+			want:     http.StatusSeeOther,
+			flash:    "Schedule saved",
+		},
+		{
+			name:     "Disabled",
+			job:      "guest_follow_up",
+			postData: url.Values{"offset_days": {"1"}, "hour": {"10"}},
+			want:     http.StatusSeeOther,
+			flash:    "Schedule saved",
+		},
+		{
+			name:     "Job without an offset",
+			job:      "out_of_order_blocks",
+			postData: url.Values{"enabled": {"1"}, "hour": {"0"}},
+			want:     http.StatusSeeOther,
+			flash:    "Schedule saved",
+		},
+		{
+			name:     "Offset out of range",
+			job:      "guest_reminder",
+			postData: url.Values{"enabled": {"1"}, "offset_days": {"-1"}, "hour": {"8"}},
+			want:     http.StatusOK,
+			expected: "Must be a whole number from 0 to 60",
+		},
+		{
+			name:     "Invalid hour",
+			job:      "guest_reminder",
+			postData: url.Values{"enabled": {"1"}, "offset_days": {"2"}, "hour": {"noon"}},
+			want:     http.StatusOK,
+			expected: "Must be a whole number from 0 to 23",
+		},
+		{
+			name:     "Unknown job",
+			job:      "newsletter",
+			postData: url.Values{"offset_days": {"2"}, "hour": {"8"}},
+			want:     http.StatusNotFound,
+		},
+		{
+			name:     "Database error",
+			job:      "guest_reminder",
+			postData: url.Values{"offset_days": {"2"}, "hour": {"23"}},
+			want:     http.StatusInternalServerError,
+		},
+	}
+
+	for _, testCase := range testCases {
+		req, _ := http.NewRequest("POST", "/admin/schedules/"+testCase.job, strings.NewReader(testCase.postData.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		rctx := chi.NewRouteContext()
+		rctx.URLParams.Add("name", testCase.job)
+		req = req.WithContext(context.WithValue(getCtx(req), chi.RouteCtxKey, rctx))
+		rr := httptest.NewRecorder()
+		Repo.AdminPostSchedule(rr, req)
+
+		if rr.Code != testCase.want {
+			t.Errorf("AdminPostSchedule handler returned wrong status for (%s): got %d, want %d", testCase.name, rr.Code, testCase.want)
+		}
+		if !strings.Contains(rr.Body.String(), testCase.expected) {
+			t.Errorf("AdminPostSchedule handler for (%s) should render %q", testCase.name, testCase.expected)
+		}
+		if flash := session.PopString(req.Context(), "flash"); flash != testCase.flash {
+			t.Errorf("AdminPostSchedule handler flashed wrong message for (%s): got %q, want %q", testCase.name, flash, testCase.flash)
+		}
+	}
+}
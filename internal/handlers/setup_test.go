@@ -17,6 +17,7 @@ import (
 	"github.com/justinas/nosurf"
 
 	"github.com/go-course/bookings/internal/config"
+	"github.com/go-course/bookings/internal/emails"
 	"github.com/go-course/bookings/internal/helpers"
 	"github.com/go-course/bookings/internal/models"
 	"github.com/go-course/bookings/internal/render"
@@ -57,7 +58,6 @@ func TestMain(m *testing.M) {
 	gob.Register(models.Restriction{})
 	gob.Register(models.Reservation{})
 	gob.Register(models.RoomRestriction{})
-	gob.Register(map[string]int{})
 
 	// change this to true when in production
 	app.InProduction = false
@@ -77,11 +77,6 @@ func TestMain(m *testing.M) {
 
 	app.Session = session
 
-	mailChan := make(chan models.MailData)
-	app.MailChan = mailChan
-	defer close(mailChan)
-	listenForMail()
-
 	tc, err := CreateTestTemplateCache()
 	if err != nil {
 		log.Fatal(err.Error())
@@ -90,6 +85,13 @@ func TestMain(m *testing.M) {
 	app.TemplateCache = tc
 	app.UseCache = true
 
+	app.EmailTemplates, err = emails.New("./../../email-templates", true)
+	if err != nil {
+		log.Fatal(err.Error())
+	}
+	app.SMSEnabled = true
+	app.SMSCountryCode = "1"
+
 	repo := NewTestRepo(&app)
 	NewHandlers(repo)
 
@@ -98,14 +100,6 @@ func TestMain(m *testing.M) {
 	os.Exit(m.Run())
 }
 
-func listenForMail() {
-	go func() {
-		for {
-			<-app.MailChan
-		}
-	}()
-}
-
 func getRoutes() http.Handler {
 
 	mux := chi.NewRouter()
@@ -134,18 +128,84 @@ func getRoutes() http.Handler {
 	mux.Post("/user/login", Repo.PostShowLogin)
 	mux.Get("/user/logout", Repo.Logout)
 
+	mux.Get("/ical/{token}/all.ics", Repo.CalendarFeedAll)
+	mux.Get("/ical/{token}/rooms/{id}.ics", Repo.CalendarFeedRoom)
+
 	mux.Route("/admin", func(mux chi.Router) {
 		// mux.Use(Auth)
 		mux.Get("/dashboard", Repo.AdminDashboard)
 		mux.Get("/reservations-new", Repo.AdminNewReservations)
 		mux.Get("/reservations-all", Repo.AdminAllReservations)
+		mux.Get("/reservations-trash", Repo.AdminTrash)
+		mux.Post("/reservations-trash/{id}/restore", Repo.AdminRestoreReservation)
 		mux.Get("/reservations-calendar", Repo.AdminReservationsCalendar)
 		mux.Post("/reservations-calendar", Repo.AdminPostReservationsCalendar)
 
 		mux.Get("/reservations/{src}/{id}/show", Repo.AdminShowReservation)
 		mux.Post("/reservations/{src}/{id}", Repo.AdminPostShowReservation)
-		mux.Get("/process-reservation/{src}/{id}/do", Repo.AdminProcessReservation)
-		mux.Get("/delete-reservation/{src}/{id}/do", Repo.AdminDeleteReservation)
+		mux.Post("/reservations/{src}/{id}/status", Repo.AdminPostReservationStatus)
+		mux.Post("/reservations/{src}/{id}/delete", Repo.AdminDeleteReservation)
+		mux.Delete("/api/reservations/{id}", Repo.AdminAPIDeleteReservation)
+
+		mux.Get("/guests", Repo.AdminGuests)
+		mux.Get("/guests/{id}", Repo.AdminShowGuest)
+		mux.Post("/guests/{id}", Repo.AdminPostGuest)
+		mux.Post("/guests/{id}/merge", Repo.AdminMergeGuest)
+
+		mux.Get("/privacy", Repo.AdminPrivacy)
+		mux.Post("/privacy/export", Repo.AdminExportGuestData)
+		mux.Post("/privacy/erase", Repo.AdminEraseGuestData)
+
+		mux.Get("/front-desk", Repo.AdminFrontDesk)
+		mux.Get("/front-desk/{id}/check-in", Repo.AdminCheckIn)
+		mux.Post("/front-desk/{id}/check-in", Repo.AdminPostCheckIn)
+		mux.Post("/front-desk/{id}/check-out", Repo.AdminPostCheckOut)
+		mux.Get("/front-desk/{id}/card", Repo.AdminRegistrationCard)
+
+		mux.Get("/housekeeping", Repo.AdminHousekeeping)
+		mux.Post("/housekeeping/rooms/{id}/status", Repo.AdminPostRoomStatus)
+
+		mux.Get("/calendar-feeds", Repo.AdminCalendarFeeds)
+		mux.Post("/calendar-feeds", Repo.AdminPostCalendarFeeds)
+
+		mux.Get("/ical-sources", Repo.AdminICalSources)
+		mux.Post("/ical-sources", Repo.AdminPostICalSource)
+		mux.Post("/ical-sources/{id}/sync", Repo.AdminSyncICalSource)
+		mux.Post("/ical-sources/{id}/delete", Repo.AdminDeleteICalSource)
+
+		mux.Get("/channels", Repo.AdminChannels)
+		mux.Post("/channels/{name}/retry", Repo.AdminRetryChannel)
+		mux.Get("/mail", Repo.AdminMail)
+		mux.Get("/mail/{id}", Repo.AdminShowMail)
+		mux.Post("/mail/{id}/resend", Repo.AdminResendMail)
+		mux.Get("/mail/templates/{name}", Repo.AdminPreviewEmail)
+		mux.Get("/settings", Repo.AdminSettings)
+		mux.Post("/settings", Repo.AdminPostSettings)
+		mux.Get("/notifications", Repo.AdminNotifications)
+		mux.Post("/notifications", Repo.AdminPostNotifications)
+		mux.Get("/schedules", Repo.AdminSchedules)
+		mux.Get("/schedules/{name}", Repo.AdminShowSchedule)
+		mux.Post("/schedules/{name}", Repo.AdminPostSchedule)
+		mux.Get("/webhooks", Repo.AdminWebhooks)
+		mux.Post("/webhooks", Repo.AdminPostWebhook)
+		mux.Get("/webhooks/{id}", Repo.AdminShowWebhook)
+		mux.Post("/webhooks/{id}", Repo.AdminPostWebhookUpdate)
+		mux.Post("/webhooks/{id}/delete", Repo.AdminDeleteWebhook)
+		mux.Get("/webhooks/deliveries/{id}", Repo.AdminShowWebhookDelivery)
+		mux.Post("/webhooks/deliveries/{id}/redeliver", Repo.AdminRedeliverWebhook)
+
+		mux.Get("/audit", Repo.AdminAudit)
+
+		mux.Get("/export/reservations/{format}", Repo.AdminExportReservations)
+		mux.Get("/export/occupancy/{format}", Repo.AdminExportOccupancy)
+
+		mux.Get("/reports", Repo.AdminReports)
+		mux.Get("/reports/{report}", Repo.AdminReports)
+		mux.Get("/reports/{report}/{format}", Repo.AdminExportReport)
+
+		mux.Get("/import", Repo.AdminImport)
+		mux.Post("/import", Repo.AdminPostImport)
+		mux.Post("/import/run", Repo.AdminPostImportRun)
 
 	})
 
@@ -0,0 +1,56 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-course/bookings/internal/helpers"
+	"github.com/go-course/bookings/internal/models"
+	"github.com/go-course/bookings/internal/render"
+)
+
+// AdminTrash lists the deleted reservations, which can be restored until they are purged
+func (m *Repository) AdminTrash(w http.ResponseWriter, r *http.Request) {
+	reservations, err := m.DB.DeletedReservations()
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	data := make(map[string]interface{})
+	data["reservations"] = reservations
+	data["retention_days"] = int(m.App.TrashRetention.Hours() / 24)
+
+	render.Template(w, r, "admin-trash.page.tmpl", &models.TemplateData{
+		Data: data,
+	})
+}
+
+// AdminRestoreReservation takes a reservation out of the trash if its room is still free
+func (m *Repository) AdminRestoreReservation(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		helpers.ClientError(w, http.StatusNotFound)
+		return
+	}
+
+	err = m.auditDB(r).RestoreReservation(id)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		helpers.ClientError(w, http.StatusNotFound)
+		return
+	case errors.Is(err, models.ErrRoomUnavailable):
+		m.App.Session.Put(r.Context(), "error", "Reservation not restored: "+err.Error())
+	case err != nil:
+		helpers.ServerError(w, err)
+		return
+	default:
+		m.App.Session.Put(r.Context(), "flash", "Reservation restored")
+		m.publishUpdate(id)
+	}
+
+	http.Redirect(w, r, "/admin/reservations-trash", http.StatusSeeOther)
+}
@@ -0,0 +1,65 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+)
+
+func TestRepository_AdminTrash(t *testing.T) {
+	req, _ := http.NewRequest("GET", "/admin/reservations-trash", nil)
+	ctx := getCtx(req)
+	req = req.WithContext(ctx)
+	rr := httptest.NewRecorder()
+	handler := http.HandlerFunc(Repo.AdminTrash)
+	handler.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Errorf("AdminTrash handler returned wrong status: got %d, want %d", rr.Code, http.StatusOK)
+	}
+	for _, s := range []string{"Smith", "/admin/reservations-trash/1/restore"} {
+		if !strings.Contains(rr.Body.String(), s) {
+			t.Errorf("AdminTrash handler should render %q", s)
+		}
+	}
+}
+
+func TestRepository_AdminRestoreReservation(t *testing.T) {
+	var testCases = []struct {
+		name  string
+		id    string
+		want  int
+		flash string
+		error string
+	}{
+		{"Restore", "1", http.StatusSeeOther, "Reservation restored", ""},
+		{"Room booked meanwhile", "2", http.StatusSeeOther, "", "Reservation not restored: the room is no longer available for these dates"},
+		{"Non existent reservation", "3", http.StatusInternalServerError, "", ""},
+		{"Invalid id", "as", http.StatusNotFound, "", ""},
+	}
+
+	for _, testCase := range testCases {
+		req, _ := http.NewRequest("POST", "/admin/reservations-trash/"+testCase.id+"/restore", nil)
+		rctx := chi.NewRouteContext()
+		rctx.URLParams.Add("id", testCase.id)
+		ctx := getCtx(req)
+		req = req.WithContext(context.WithValue(ctx, chi.RouteCtxKey, rctx))
+		rr := httptest.NewRecorder()
+		handler := http.HandlerFunc(Repo.AdminRestoreReservation)
+		handler.ServeHTTP(rr, req)
+
+		if rr.Code != testCase.want {
+			t.Errorf("AdminRestoreReservation handler returned wrong status for (%s): got %d, want %d", testCase.name, rr.Code, testCase.want)
+		}
+		if flash := session.PopString(req.Context(), "flash"); flash != testCase.flash {
+			t.Errorf("AdminRestoreReservation handler flashed wrong message for (%s): got %q, want %q", testCase.name, flash, testCase.flash)
+		}
+		if msg := session.PopString(req.Context(), "error"); msg != testCase.error {
+			t.Errorf("AdminRestoreReservation handler reported wrong error for (%s): got %q, want %q", testCase.name, msg, testCase.error)
+		}
+	}
+}
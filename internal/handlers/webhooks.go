@@ -0,0 +1,350 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-course/bookings/internal/forms"
+	"github.com/go-course/bookings/internal/helpers"
+	"github.com/go-course/bookings/internal/models"
+	"github.com/go-course/bookings/internal/render"
+	"github.com/go-course/bookings/internal/webhooks"
+)
+
+// webhookListLimit bounds the deliveries listed at once
+const webhookListLimit = 50
+
+// reservationWebhooks are the webhook events of the notification events about reservations
+var reservationWebhooks = map[string]string{
+	models.EventNewBooking:   models.WebhookReservationCreated,
+	models.EventModification: models.WebhookReservationUpdated,
+	models.EventCancellation: models.WebhookReservationCancelled,
+}
+
+// publishReservation queues event about a reservation for the webhook endpoints subscribed to it, logging
+// any error as the change itself is saved
+func (m *Repository) publishReservation(event string, res models.Reservation) {
+	payload, err := webhooks.ReservationPayload(event, res, time.Now())
+	if err == nil {
+		err = m.DB.QueueWebhook(event, payload)
+	}
+	if err != nil {
+		m.App.ErrorLog.Println(err)
+	}
+}
+
+// publishUpdate queues reservation.updated about the reservation with id as it is now, logging any error
+func (m *Repository) publishUpdate(id int) {
+	res, err := m.DB.GetReservationById(id)
+	if err != nil {
+		m.App.ErrorLog.Println(err)
+		return
+	}
+	m.publishReservation(models.WebhookReservationUpdated, res)
+}
+
+// deleteReservation moves the reservation with id to the trash and queues reservation.updated about it, as
+// the trash is out of reach of the endpoints
+func (m *Repository) deleteReservation(r *http.Request, id int) error {
+	res, err := m.DB.GetReservationById(id)
+	if err != nil {
+		return err
+	}
+	if err := m.auditDB(r).DeleteReservation(id); err != nil {
+		return err
+	}
+	res.DeletedAt = time.Now()
+	m.publishReservation(models.WebhookReservationUpdated, res)
+	return nil
+}
+
+// publishBlocks queues block.created for each day the calendar changes blocked, logging any error
+func (m *Repository) publishBlocks(changes []models.BlockChange) {
+	now := time.Now()
+	for _, c := range changes {
+		if c.Action != models.BlockActionAdd {
+			continue
+		}
+		payload, err := webhooks.BlockPayload(c.RoomID, c.Date, now)
+		if err == nil {
+			err = m.DB.QueueWebhook(models.WebhookBlockCreated, payload)
+		}
+		if err != nil {
+			m.App.ErrorLog.Println(err)
+		}
+	}
+}
+
+// AdminWebhooks lists the webhook endpoints and their latest deliveries
+func (m *Repository) AdminWebhooks(w http.ResponseWriter, r *http.Request) {
+	m.renderWebhooks(w, r, forms.New(nil))
+}
+
+// AdminPostWebhook adds a webhook endpoint with a new secret, and shows it so the secret can be copied
+func (m *Repository) AdminPostWebhook(w http.ResponseWriter, r *http.Request) {
+	err := r.ParseForm()
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	form := forms.New(r.PostForm)
+	endpoint, ok := webhookEndpointFromForm(form)
+	if !ok {
+		m.renderWebhooks(w, r, form)
+		return
+	}
+
+	endpoint.Active = true
+	endpoint.Secret, err = helpers.RandomToken(32)
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	id, err := m.auditDB(r).InsertWebhookEndpoint(endpoint)
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	m.App.Session.Put(r.Context(), "flash", "Webhook added, give its secret to the receiver to check signatures")
+	http.Redirect(w, r, fmt.Sprintf("/admin/webhooks/%d", id), http.StatusSeeOther)
+}
+
+// AdminShowWebhook shows a webhook endpoint with its secret, the form to change it and its deliveries
+func (m *Repository) AdminShowWebhook(w http.ResponseWriter, r *http.Request) {
+	endpoint, ok := m.webhookEndpoint(w, r)
+	if !ok {
+		return
+	}
+
+	form := forms.New(url.Values{})
+	form.Set("url", endpoint.URL)
+	form.Values["events"] = endpoint.Events
+	if endpoint.Active {
+		form.Set("active", "1")
+	}
+
+	m.renderWebhook(w, r, endpoint, form)
+}
+
+// AdminPostWebhookUpdate saves the url, events and state of a webhook endpoint, with a new secret if asked
+func (m *Repository) AdminPostWebhookUpdate(w http.ResponseWriter, r *http.Request) {
+	endpoint, ok := m.webhookEndpoint(w, r)
+	if !ok {
+		return
+	}
+
+	err := r.ParseForm()
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	form := forms.New(r.PostForm)
+	changed, ok := webhookEndpointFromForm(form)
+	if !ok {
+		m.renderWebhook(w, r, endpoint, form)
+		return
+	}
+
+	endpoint.URL = changed.URL
+	endpoint.Events = changed.Events
+	endpoint.Active = form.Has("active")
+	if form.Has("rotate_secret") {
+		endpoint.Secret, err = helpers.RandomToken(32)
+		if err != nil {
+			helpers.ServerError(w, err)
+			return
+		}
+	}
+
+	err = m.auditDB(r).UpdateWebhookEndpoint(endpoint)
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	m.App.Session.Put(r.Context(), "flash", "Webhook saved")
+	http.Redirect(w, r, fmt.Sprintf("/admin/webhooks/%d", endpoint.ID), http.StatusSeeOther)
+}
+
+// AdminDeleteWebhook removes a webhook endpoint along with its deliveries
+func (m *Repository) AdminDeleteWebhook(w http.ResponseWriter, r *http.Request) {
+	endpoint, ok := m.webhookEndpoint(w, r)
+	if !ok {
+		return
+	}
+
+	err := m.auditDB(r).DeleteWebhookEndpoint(endpoint.ID)
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	m.App.Session.Put(r.Context(), "flash", "Webhook removed")
+	http.Redirect(w, r, "/admin/webhooks", http.StatusSeeOther)
+}
+
+// AdminShowWebhookDelivery shows a delivery with its payload and the outcome of its last attempt
+func (m *Repository) AdminShowWebhookDelivery(w http.ResponseWriter, r *http.Request) {
+	delivery, ok := m.webhookDelivery(w, r)
+	if !ok {
+		return
+	}
+
+	data := make(map[string]interface{})
+	data["delivery"] = delivery
+
+	render.Template(w, r, "admin-webhook-delivery.page.tmpl", &models.TemplateData{
+		Data: data,
+	})
+}
+
+// AdminRedeliverWebhook queues the payload of a delivery to be posted again, as a new delivery
+func (m *Repository) AdminRedeliverWebhook(w http.ResponseWriter, r *http.Request) {
+	delivery, ok := m.webhookDelivery(w, r)
+	if !ok {
+		return
+	}
+
+	if delivery.Status == models.WebhookPending {
+		m.App.Session.Put(r.Context(), "error", "This delivery is already waiting to be posted")
+		http.Redirect(w, r, fmt.Sprintf("/admin/webhooks/deliveries/%d", delivery.ID), http.StatusSeeOther)
+		return
+	}
+
+	id, err := m.auditDB(r).RedeliverWebhook(delivery.ID)
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	m.App.Session.Put(r.Context(), "flash", "Event queued to be delivered again")
+	http.Redirect(w, r, fmt.Sprintf("/admin/webhooks/deliveries/%d", id), http.StatusSeeOther)
+}
+
+// webhookEndpointFromForm returns the url and events of a posted endpoint form, adding the errors to form
+func webhookEndpointFromForm(form *forms.Form) (models.WebhookEndpoint, bool) {
+	form.Required("url")
+	form.IsURL("url")
+
+	endpoint := models.WebhookEndpoint{URL: strings.TrimSpace(form.Get("url"))}
+	for _, event := range form.Values["events"] {
+		if !models.ValidWebhookEvent(event) {
+			form.Errors.Add("events", "Unknown event "+event)
+			continue
+		}
+		endpoint.Events = append(endpoint.Events, event)
+	}
+	if len(form.Values["events"]) == 0 {
+		form.Errors.Add("events", "Choose at least one event")
+	}
+
+	return endpoint, form.Valid()
+}
+
+// webhookEndpoint loads the endpoint in the url, writing the error response if it can't
+func (m *Repository) webhookEndpoint(w http.ResponseWriter, r *http.Request) (models.WebhookEndpoint, bool) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		helpers.ClientError(w, http.StatusNotFound)
+		return models.WebhookEndpoint{}, false
+	}
+
+	endpoint, err := m.DB.GetWebhookEndpoint(id)
+	if errors.Is(err, sql.ErrNoRows) {
+		helpers.ClientError(w, http.StatusNotFound)
+		return endpoint, false
+	}
+	if err != nil {
+		helpers.ServerError(w, err)
+		return endpoint, false
+	}
+
+	return endpoint, true
+}
+
+// webhookDelivery loads the delivery in the url, writing the error response if it can't
+func (m *Repository) webhookDelivery(w http.ResponseWriter, r *http.Request) (models.WebhookDelivery, bool) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		helpers.ClientError(w, http.StatusNotFound)
+		return models.WebhookDelivery{}, false
+	}
+
+	delivery, err := m.DB.GetWebhookDelivery(id)
+	if errors.Is(err, sql.ErrNoRows) {
+		helpers.ClientError(w, http.StatusNotFound)
+		return delivery, false
+	}
+	if err != nil {
+		helpers.ServerError(w, err)
+		return delivery, false
+	}
+
+	return delivery, true
+}
+
+// renderWebhooks renders the webhooks page with the form to add an endpoint
+func (m *Repository) renderWebhooks(w http.ResponseWriter, r *http.Request, form *forms.Form) {
+	endpoints, err := m.DB.WebhookEndpoints()
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	deliveries, err := m.DB.WebhookDeliveries(0, webhookListLimit)
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	data := make(map[string]interface{})
+	data["endpoints"] = endpoints
+	data["deliveries"] = deliveries
+	data["events"] = models.WebhookEvents
+	data["chosen"] = chosenEvents(form)
+
+	render.Template(w, r, "admin-webhooks.page.tmpl", &models.TemplateData{
+		Form: form,
+		Data: data,
+	})
+}
+
+// renderWebhook renders the page of endpoint with form
+func (m *Repository) renderWebhook(w http.ResponseWriter, r *http.Request, endpoint models.WebhookEndpoint, form *forms.Form) {
+	deliveries, err := m.DB.WebhookDeliveries(endpoint.ID, webhookListLimit)
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	data := make(map[string]interface{})
+	data["endpoint"] = endpoint
+	data["deliveries"] = deliveries
+	data["events"] = models.WebhookEvents
+	data["chosen"] = chosenEvents(form)
+
+	render.Template(w, r, "admin-webhook.page.tmpl", &models.TemplateData{
+		Form: form,
+		Data: data,
+	})
+}
+
+// chosenEvents returns the events checked on form
+func chosenEvents(form *forms.Form) map[string]bool {
+	chosen := make(map[string]bool)
+	for _, event := range form.Values["events"] {
+		chosen[event] = true
+	}
+	return chosen
+}
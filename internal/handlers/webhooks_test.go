@@ -0,0 +1,400 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-course/bookings/internal/emails"
+	"github.com/go-course/bookings/internal/models"
+)
+
+// claimWebhooks takes the deliveries queued so far out of the test outbox
+func claimWebhooks(t *testing.T) []models.WebhookDelivery {
+	deliveries, err := Repo.DB.ClaimWebhooks(100, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return deliveries
+}
+
+func TestRepository_Webhooks(t *testing.T) {
+	// forget what other tests queued
+	claimWebhooks(t)
+
+	body := "start_date=2050-01-01&end_date=2050-01-02&first_name=John&last_name=Smith&email=john@smith.com&phone=123456789&room_id=1"
+	req, _ := http.NewRequest("POST", "/make-reservation", strings.NewReader(body))
+	req = req.WithContext(getCtx(req))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rr := httptest.NewRecorder()
+	Repo.PostReservation(rr, req)
+	if rr.Code != http.StatusSeeOther {
+		t.Fatalf("PostReservation handler returned wrong status: got %d, want %d", rr.Code, http.StatusSeeOther)
+	}
+
+	deliveries := claimWebhooks(t)
+	if len(deliveries) != 1 || deliveries[0].Event != models.WebhookReservationCreated {
+		t.Fatalf("expected reservation.created, got %+v", deliveries)
+	}
+	for _, s := range []string{`"event":"reservation.created"`, `"status":"pending"`, `"start_date":"2050-01-01"`, `"email":"john@smith.com"`} {
+		if !strings.Contains(deliveries[0].Payload, s) {
+			t.Errorf("the payload should contain %s: %s", s, deliveries[0].Payload)
+		}
+	}
+
+	Repo.notify(models.EventCancellation, emails.Cancellation, 1)
+	Repo.notify(models.EventModification, "", 2)
+	deliveries = claimWebhooks(t)
+	if len(deliveries) != 2 || deliveries[0].Event != models.WebhookReservationCancelled || deliveries[1].Event != models.WebhookReservationUpdated {
+		t.Fatalf("expected reservation.cancelled and reservation.updated, got %+v", deliveries)
+	}
+	if !strings.Contains(deliveries[1].Payload, `"id":2`) {
+		t.Errorf("the update should be about reservation 2: %s", deliveries[1].Payload)
+	}
+
+	postData := url.Values{
+		"y":                      {"2050"},
+		"m":                      {"01"},
+		"block_1_2050-01-1":      {"1"},
+		"orig_block_1_2050-01-4": {"1:1"},
+	}
+	req, _ = http.NewRequest("POST", "/admin/reservations-calendar", strings.NewReader(postData.Encode()))
+	req = req.WithContext(getCtx(req))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rr = httptest.NewRecorder()
+	Repo.AdminPostReservationsCalendar(rr, req)
+
+	// removing a block is not an event
+	deliveries = claimWebhooks(t)
+	if len(deliveries) != 1 || deliveries[0].Event != models.WebhookBlockCreated {
+		t.Fatalf("expected block.created, got %+v", deliveries)
+	}
+	if !strings.Contains(deliveries[0].Payload, `"data":{"room_id":1,"date":"2050-01-01"}`) {
+		t.Errorf("wrong block payload: %s", deliveries[0].Payload)
+	}
+}
+
+func TestRepository_WebhookUpdates(t *testing.T) {
+	claimWebhooks(t)
+
+	var testCases = []struct {
+		name     string
+		handler  http.HandlerFunc
+		form     string
+		deleted  bool
+		expected bool
+	}{
+		{"Status change", Repo.AdminPostReservationStatus, "status=confirmed", false, true},
+		{"Cancellation", Repo.AdminPostReservationStatus, "status=cancelled", false, false},
+		{"Check out", Repo.AdminPostCheckOut, "", false, true},
+		{"Delete", Repo.AdminDeleteReservation, "", true, true},
+		{"Restore", Repo.AdminRestoreReservation, "", false, true},
+	}
+
+	for _, testCase := range testCases {
+		req, _ := http.NewRequest("POST", "/admin/reservations/1", strings.NewReader(testCase.form))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		rctx := chi.NewRouteContext()
+		rctx.URLParams.Add("id", "1")
+		rctx.URLParams.Add("src", "all")
+		ctx := getCtx(req)
+		req = req.WithContext(context.WithValue(ctx, chi.RouteCtxKey, rctx))
+		rr := httptest.NewRecorder()
+		testCase.handler.ServeHTTP(rr, req)
+
+		var updates []models.WebhookDelivery
+		for _, d := range claimWebhooks(t) {
+			if d.Event == models.WebhookReservationUpdated {
+				updates = append(updates, d)
+			}
+		}
+		if !testCase.expected {
+			if len(updates) != 0 {
+				t.Errorf("expected no reservation.updated for (%s), got %+v", testCase.name, updates)
+			}
+			continue
+		}
+		if len(updates) != 1 || !strings.Contains(updates[0].Payload, `"id":1`) {
+			t.Errorf("expected reservation.updated about reservation 1 for (%s), got %+v", testCase.name, updates)
+			continue
+		}
+		if deleted := strings.Contains(updates[0].Payload, `"deleted":true`); deleted != testCase.deleted {
+			t.Errorf("wrong deleted flag for (%s): %s", testCase.name, updates[0].Payload)
+		}
+	}
+}
+
+func TestRepository_AdminWebhooks(t *testing.T) {
+	req, _ := http.NewRequest("GET", "/admin/webhooks", nil)
+	req = req.WithContext(getCtx(req))
+	rr := httptest.NewRecorder()
+	Repo.AdminWebhooks(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Errorf("AdminWebhooks handler returned wrong status: got %d, want %d", rr.Code, http.StatusOK)
+	}
+	for _, s := range []string{"https://crm.example.com/hooks", "reservation.created, reservation.updated", "/admin/webhooks/deliveries/1"} {
+		if !strings.Contains(rr.Body.String(), s) {
+			t.Errorf("AdminWebhooks handler should render %q", s)
+		}
+	}
+	if strings.Contains(rr.Body.String(), "test-secret") {
+		t.Error("AdminWebhooks handler should not list the secrets")
+	}
+}
+
+func TestRepository_AdminPostWebhook(t *testing.T) {
+	var testCases = []struct {
+		name     string
+		postData url.Values
+		want     int
+		location string
+		expected string
+	}{
+		{
+			name:     "Valid",
+			postData: url.Values{"url": {"https://cleaning.example.com/hooks"}, "events": {"block.created", "reservation.created"}},
+			want:     http.StatusSeeOther,
+			location: "/admin/webhooks/1",
+		},
+		{
+			name:     "Missing url",
+			postData: url.Values{"events": {"block.created"}},
+			want:     http.StatusOK,
+			expected: "This field cannot be blank",
+		},
+		{
+			name:     "Invalid url",
+			postData: url.Values{"url": {"ftp://cleaning.example.com"}, "events": {"block.created"}},
+			want:     http.StatusOK,
+			expected: "Invalid url",
+		},
+		{
+			name:     "No events",
+			postData: url.Values{"url": {"https://cleaning.example.com/hooks"}},
+			want:     http.StatusOK,
+			expected: "Choose at least one event",
+		},
+		{
+			name:     "Unknown event",
+			postData: url.Values{"url": {"https://cleaning.example.com/hooks"}, "events": {"room.painted"}},
+			want:     http.StatusOK,
+			expected: "Unknown event room.painted",
+		},
+		{
+			name:     "Database error",
+			postData: url.Values{"url": {"https://error.example.com/hooks"}, "events": {"block.created"}},
+			want:     http.StatusInternalServerError,
+		},
+	}
+
+	for _, testCase := range testCases {
+		req, _ := http.NewRequest("POST", "/admin/webhooks", strings.NewReader(testCase.postData.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req = req.WithContext(getCtx(req))
+		rr := httptest.NewRecorder()
+		Repo.AdminPostWebhook(rr, req)
+
+		if rr.Code != testCase.want {
+			t.Errorf("AdminPostWebhook handler returned wrong status for (%s): got %d, want %d", testCase.name, rr.Code, testCase.want)
+		}
+		if testCase.location != "" && rr.Header().Get("Location") != testCase.location {
+			t.Errorf("AdminPostWebhook handler redirected (%s) to %q, want %q", testCase.name, rr.Header().Get("Location"), testCase.location)
+		}
+		if !strings.Contains(rr.Body.String(), testCase.expected) {
+			t.Errorf("AdminPostWebhook handler for (%s) should render %q", testCase.name, testCase.expected)
+		}
+	}
+}
+
+func TestRepository_AdminShowWebhook(t *testing.T) {
+	var testCases = []struct {
+		name string
+		id   string
+		want int
+	}{
+		{"Valid", "1", http.StatusOK},
+		{"Unknown endpoint", "2", http.StatusNotFound},
+		{"Database error", "3", http.StatusInternalServerError},
+		{"Invalid id", "crm", http.StatusNotFound},
+	}
+
+	for _, testCase := range testCases {
+		req, _ := http.NewRequest("GET", "/admin/webhooks/"+testCase.id, nil)
+		rctx := chi.NewRouteContext()
+		rctx.URLParams.Add("id", testCase.id)
+		req = req.WithContext(context.WithValue(getCtx(req), chi.RouteCtxKey, rctx))
+		rr := httptest.NewRecorder()
+		Repo.AdminShowWebhook(rr, req)
+
+		if rr.Code != testCase.want {
+			t.Errorf("AdminShowWebhook handler returned wrong status for (%s): got %d, want %d", testCase.name, rr.Code, testCase.want)
+		}
+		if testCase.want == http.StatusOK {
+			for _, s := range []string{"test-secret", `value="block.created" checked`, "endpoint returned 500 Internal Server Error"} {
+				if !strings.Contains(rr.Body.String(), s) {
+					t.Errorf("AdminShowWebhook handler should render %q", s)
+				}
+			}
+		}
+	}
+}
+
+func TestRepository_AdminPostWebhookUpdate(t *testing.T) {
+	var testCases = []struct {
+		name     string
+		id       string
+		postData url.Values
+		want     int
+		flash    string
+		expected string
+	}{
+		{
+			name:     "Valid",
+			id:       "1",
+			postData: url.Values{"url": {"https://crm.example.com/v2"}, "events": {"reservation.created"}, "active": {"1"}},
+			want:     http.StatusSeeOther,
+			flash:    "Webhook saved",
+		},
+		{
+			name:     "Deactivated with a new secret",
+			id:       "1",
+			postData: url.Values{"url": {"https://crm.example.com/v2"}, "events": {"reservation.created"}, "rotate_secret": {"1"}},
+			want:     http.StatusSeeOther,
+			flash:    "Webhook saved",
+		},
+		{
+			name:     "No events",
+			id:       "1",
+			postData: url.Values{"url": {"https://crm.example.com/v2"}, "active": {"1"}},
+			want:     http.StatusOK,
+			expected: "Choose at least one event",
+		},
+		{
+			name:     "Unknown endpoint",
+			id:       "2",
+			postData: url.Values{"url": {"https://crm.example.com/v2"}, "events": {"reservation.created"}},
+			want:     http.StatusNotFound,
+		},
+		{
+			name:     "Database error",
+			id:       "1",
+			postData: url.Values{"url": {"https://error.example.com/hooks"}, "events": {"reservation.created"}},
+			want:     http.StatusInternalServerError,
+		},
+	}
+
+	for _, testCase := range testCases {
+		req, _ := http.NewRequest("POST", "/admin/webhooks/"+testCase.id, strings.NewReader(testCase.postData.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		rctx := chi.NewRouteContext()
+		rctx.URLParams.Add("id", testCase.id)
+		req = req.WithContext(context.WithValue(getCtx(req), chi.RouteCtxKey, rctx))
+		rr := httptest.NewRecorder()
+		Repo.AdminPostWebhookUpdate(rr, req)
+
+		if rr.Code != testCase.want {
+			t.Errorf("AdminPostWebhookUpdate handler returned wrong status for (%s): got %d, want %d", testCase.name, rr.Code, testCase.want)
+		}
+		if !strings.Contains(rr.Body.String(), testCase.expected) {
+			t.Errorf("AdminPostWebhookUpdate handler for (%s) should render %q", testCase.name, testCase.expected)
+		}
+		if flash := session.PopString(req.Context(), "flash"); flash != testCase.flash {
+			t.Errorf("AdminPostWebhookUpdate handler for (%s) flashed %q, want %q", testCase.name, flash, testCase.flash)
+		}
+	}
+}
+
+func TestRepository_AdminDeleteWebhook(t *testing.T) {
+	var testCases = []struct {
+		name string
+		id   string
+		want int
+	}{
+		{"Valid", "1", http.StatusSeeOther},
+		{"Unknown endpoint", "2", http.StatusNotFound},
+	}
+
+	for _, testCase := range testCases {
+		req, _ := http.NewRequest("POST", "/admin/webhooks/"+testCase.id+"/delete", nil)
+		rctx := chi.NewRouteContext()
+		rctx.URLParams.Add("id", testCase.id)
+		req = req.WithContext(context.WithValue(getCtx(req), chi.RouteCtxKey, rctx))
+		rr := httptest.NewRecorder()
+		Repo.AdminDeleteWebhook(rr, req)
+
+		if rr.Code != testCase.want {
+			t.Errorf("AdminDeleteWebhook handler returned wrong status for (%s): got %d, want %d", testCase.name, rr.Code, testCase.want)
+		}
+	}
+}
+
+func TestRepository_AdminShowWebhookDelivery(t *testing.T) {
+	var testCases = []struct {
+		name string
+		id   string
+		want int
+	}{
+		{"Valid", "1", http.StatusOK},
+		{"Unknown delivery", "2", http.StatusNotFound},
+		{"Database error", "3", http.StatusInternalServerError},
+	}
+
+	for _, testCase := range testCases {
+		req, _ := http.NewRequest("GET", "/admin/webhooks/deliveries/"+testCase.id, nil)
+		rctx := chi.NewRouteContext()
+		rctx.URLParams.Add("id", testCase.id)
+		req = req.WithContext(context.WithValue(getCtx(req), chi.RouteCtxKey, rctx))
+		rr := httptest.NewRecorder()
+		Repo.AdminShowWebhookDelivery(rr, req)
+
+		if rr.Code != testCase.want {
+			t.Errorf("AdminShowWebhookDelivery handler returned wrong status for (%s): got %d, want %d", testCase.name, rr.Code, testCase.want)
+		}
+		if testCase.want == http.StatusOK {
+			for _, s := range []string{"reservation.created", "Redeliver", "&#34;event&#34;:&#34;reservation.created&#34;"} {
+				if !strings.Contains(rr.Body.String(), s) {
+					t.Errorf("AdminShowWebhookDelivery handler should render %q", s)
+				}
+			}
+		}
+	}
+}
+
+func TestRepository_AdminRedeliverWebhook(t *testing.T) {
+	var testCases = []struct {
+		name     string
+		id       string
+		want     int
+		location string
+		flash    string
+	}{
+		{"Valid", "1", http.StatusSeeOther, "/admin/webhooks/deliveries/2", "Event queued to be delivered again"},
+		{"Unknown delivery", "2", http.StatusNotFound, "", ""},
+		{"Database error", "3", http.StatusInternalServerError, "", ""},
+	}
+
+	for _, testCase := range testCases {
+		req, _ := http.NewRequest("POST", "/admin/webhooks/deliveries/"+testCase.id+"/redeliver", nil)
+		rctx := chi.NewRouteContext()
+		rctx.URLParams.Add("id", testCase.id)
+		req = req.WithContext(context.WithValue(getCtx(req), chi.RouteCtxKey, rctx))
+		rr := httptest.NewRecorder()
+		Repo.AdminRedeliverWebhook(rr, req)
+
+		if rr.Code != testCase.want {
+			t.Errorf("AdminRedeliverWebhook handler returned wrong status for (%s): got %d, want %d", testCase.name, rr.Code, testCase.want)
+		}
+		if rr.Header().Get("Location") != testCase.location {
+			t.Errorf("AdminRedeliverWebhook handler redirected (%s) to %q, want %q", testCase.name, rr.Header().Get("Location"), testCase.location)
+		}
+		if flash := session.PopString(req.Context(), "flash"); flash != testCase.flash {
+			t.Errorf("AdminRedeliverWebhook handler for (%s) flashed %q, want %q", testCase.name, flash, testCase.flash)
+		}
+	}
+}
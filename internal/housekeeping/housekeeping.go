@@ -0,0 +1,113 @@
+// Package housekeeping derives the cleaning tasks of a day from the bookings of each room
+package housekeeping
+
+import (
+	"time"
+
+	"github.com/go-course/bookings/internal/models"
+)
+
+// Kinds of task
+const (
+	TaskCheckout   = "checkout"
+	TaskStayOver   = "stay-over"
+	TaskInspection = "inspection"
+)
+
+// taskLabels are the names tasks are shown with
+var taskLabels = map[string]string{
+	TaskCheckout:   "Checkout clean",
+	TaskStayOver:   "Stay-over refresh",
+	TaskInspection: "Inspection",
+}
+
+// Task is a job to do in a room
+type Task struct {
+	Kind string
+	Note string
+}
+
+// Label returns the name the task is shown with
+func (t Task) Label() string {
+	return taskLabels[t.Kind]
+}
+
+// RoomTasks is a room with its housekeeping status and the tasks of the day
+type RoomTasks struct {
+	Room   models.Room
+	Status models.RoomStatus
+	Tasks  []Task
+}
+
+// Tasks returns the tasks of day for each room, from the room restrictions overlapping the day and
+// the night before, and the current room statuses.
+//
+// A room needs a checkout clean when a booking or block ends on day, unless an owner block simply
+// carries on. Guests staying over get a refresh. A room is inspected before a guest arrives, and
+// once it is clean. Out of order rooms get no tasks
+func Tasks(day time.Time, rooms []models.Room, restrictions []models.RoomRestriction, statuses []models.RoomStatus) []RoomTasks {
+	byRoom := make(map[int]models.RoomStatus)
+	for _, s := range statuses {
+		byRoom[s.RoomID] = s
+	}
+
+	var result []RoomTasks
+	for _, room := range rooms {
+		status, ok := byRoom[room.ID]
+		if !ok {
+			status = models.RoomStatus{RoomID: room.ID, Status: models.RoomClean}
+		}
+		rt := RoomTasks{Room: room, Status: status}
+
+		if status.Status != models.RoomOutOfOrder {
+			rt.Tasks = roomTasks(day, room.ID, restrictions, status.Status)
+		}
+		result = append(result, rt)
+	}
+	return result
+}
+
+func roomTasks(day time.Time, roomID int, restrictions []models.RoomRestriction, status string) []Task {
+	var ending, arriving, staying, blockEnding, blockStarting bool
+	for _, rr := range restrictions {
+		if rr.RoomID != roomID {
+			continue
+		}
+		block := rr.RestrictionID == models.RestrictionOwnerBlock
+		switch {
+		case sameDay(rr.EndDate, day):
+			if block {
+				blockEnding = true
+			} else {
+				ending = true
+			}
+		case sameDay(rr.StartDate, day):
+			if block {
+				blockStarting = true
+			} else {
+				arriving = true
+			}
+		case rr.StartDate.Before(day) && rr.EndDate.After(day) && !block:
+			staying = true
+		}
+	}
+
+	var tasks []Task
+	if ending || (blockEnding && !blockStarting) {
+		tasks = append(tasks, Task{Kind: TaskCheckout})
+	}
+	if staying {
+		tasks = append(tasks, Task{Kind: TaskStayOver})
+	}
+	switch {
+	case arriving && status != models.RoomInspected:
+		tasks = append(tasks, Task{Kind: TaskInspection, Note: "Arrival today"})
+	case status == models.RoomClean && !ending && !staying:
+		tasks = append(tasks, Task{Kind: TaskInspection})
+	}
+	return tasks
+}
+
+func sameDay(a, b time.Time) bool {
+	return a.Year() == b.Year() && a.YearDay() == b.YearDay()
+}
@@ -0,0 +1,79 @@
+package housekeeping
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-course/bookings/internal/models"
+)
+
+func date(s string) time.Time {
+	t, _ := time.Parse("2006-01-02", s)
+	return t
+}
+
+func TestTasks(t *testing.T) {
+	day := date("2050-01-10")
+	var rooms []models.Room
+	for id := 1; id <= 7; id++ {
+		rooms = append(rooms, models.Room{ID: id})
+	}
+
+	restrictions := []models.RoomRestriction{
+		// room 1 turns over: a guest leaves and another arrives
+		{RoomID: 1, RestrictionID: models.RestrictionReservation, StartDate: date("2050-01-07"), EndDate: day},
+		{RoomID: 1, RestrictionID: models.RestrictionExternalBooking, StartDate: day, EndDate: date("2050-01-12")},
+		// room 2 has a guest staying over
+		{RoomID: 2, RestrictionID: models.RestrictionReservation, StartDate: date("2050-01-08"), EndDate: date("2050-01-11")},
+		// room 3 is blocked by the owner on consecutive nights
+		{RoomID: 3, RestrictionID: models.RestrictionOwnerBlock, StartDate: date("2050-01-09"), EndDate: day},
+		{RoomID: 3, RestrictionID: models.RestrictionOwnerBlock, StartDate: day, EndDate: date("2050-01-11")},
+		// room 4 was used by the owner last night
+		{RoomID: 4, RestrictionID: models.RestrictionOwnerBlock, StartDate: date("2050-01-09"), EndDate: day},
+		// room 6 is out of order with a guest leaving
+		{RoomID: 6, RestrictionID: models.RestrictionReservation, StartDate: date("2050-01-07"), EndDate: day},
+		// room 7 has an arrival and is already inspected
+		{RoomID: 7, RestrictionID: models.RestrictionReservation, StartDate: day, EndDate: date("2050-01-12")},
+	}
+	statuses := []models.RoomStatus{
+		{RoomID: 1, Status: models.RoomDirty},
+		{RoomID: 2, Status: models.RoomDirty},
+		{RoomID: 3, Status: models.RoomInspected},
+		{RoomID: 4, Status: models.RoomInspected},
+		// room 5 has no status yet, so it is clean
+		{RoomID: 6, Status: models.RoomOutOfOrder},
+		{RoomID: 7, Status: models.RoomInspected},
+	}
+
+	want := map[int]string{
+		1: "checkout,inspection",
+		2: "stay-over",
+		3: "",
+		4: "checkout",
+		5: "inspection",
+		6: "",
+		7: "",
+	}
+
+	got := Tasks(day, rooms, restrictions, statuses)
+	if len(got) != len(rooms) {
+		t.Fatalf("got %d rooms, want %d", len(got), len(rooms))
+	}
+	for _, rt := range got {
+		var kinds []string
+		for _, task := range rt.Tasks {
+			kinds = append(kinds, task.Kind)
+		}
+		if strings.Join(kinds, ",") != want[rt.Room.ID] {
+			t.Errorf("room %d: got tasks %q, want %q", rt.Room.ID, kinds, want[rt.Room.ID])
+		}
+	}
+
+	if got[4].Status.Status != models.RoomClean {
+		t.Errorf("a room without status should be clean, got %q", got[4].Status.Status)
+	}
+	if got[0].Tasks[1].Note != "Arrival today" || got[0].Tasks[0].Label() != "Checkout clean" {
+		t.Errorf("wrong task details: %+v", got[0].Tasks)
+	}
+}
@@ -0,0 +1,155 @@
+package icalsync
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/go-course/bookings/internal/config"
+	"github.com/go-course/bookings/internal/ics"
+	"github.com/go-course/bookings/internal/models"
+)
+
+// maxFeedSize limits how much of a feed is read
+const maxFeedSize = 5 << 20
+
+// Store is the part of the repository the importer needs
+type Store interface {
+	AllICalSources() ([]models.ICalSource, error)
+	UpdateICalSourceStatus(id int, syncedAt time.Time, status string) error
+	SyncExternalBookings(source models.ICalSource, bookings []models.RoomRestriction) (models.ICalSyncResult, error)
+}
+
+// Importer imports bookings from external iCalendar feeds
+type Importer struct {
+	Store    Store
+	Client   *http.Client
+	InfoLog  *log.Logger
+	ErrorLog *log.Logger
+}
+
+// New creates an importer using the loggers of the app config
+func New(store Store, a *config.AppConfig) *Importer {
+	return &Importer{
+		Store:    store,
+		Client:   &http.Client{Timeout: 30 * time.Second},
+		InfoLog:  a.InfoLog,
+		ErrorLog: a.ErrorLog,
+	}
+}
+
+// Start syncs all sources now and then every interval, until stop is closed
+func (im *Importer) Start(interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		im.SyncAll()
+		select {
+		case <-ticker.C:
+		case <-stop:
+			return
+		}
+	}
+}
+
+// SyncAll syncs every configured source, logging the results
+func (im *Importer) SyncAll() {
+	sources, err := im.Store.AllICalSources()
+	if err != nil {
+		im.ErrorLog.Println(err)
+		return
+	}
+
+	for _, src := range sources {
+		_, _ = im.SyncSource(src)
+	}
+}
+
+// SyncSource fetches one source, imports its bookings and records the outcome on the source
+func (im *Importer) SyncSource(src models.ICalSource) (models.ICalSyncResult, error) {
+	result, err := im.sync(src)
+
+	var status string
+	if err != nil {
+		status = "error: " + err.Error()
+		im.ErrorLog.Printf("ical sync of %q for room %d failed: %s", src.Name, src.RoomID, err)
+	} else {
+		status = fmt.Sprintf("ok: %d added, %d updated, %d removed", result.Added, result.Updated, result.Removed)
+		if result.Overlaps > 0 {
+			status += fmt.Sprintf(", %d overlapping other bookings", result.Overlaps)
+		}
+		im.InfoLog.Printf("ical sync of %q for room %d %s", src.Name, src.RoomID, status)
+	}
+
+	if serr := im.Store.UpdateICalSourceStatus(src.ID, time.Now(), status); serr != nil {
+		im.ErrorLog.Println(serr)
+	}
+
+	return result, err
+}
+
+// sync fetches and imports one source
+func (im *Importer) sync(src models.ICalSource) (models.ICalSyncResult, error) {
+	var result models.ICalSyncResult
+
+	events, err := im.fetch(src.URL)
+	if err != nil {
+		return result, err
+	}
+
+	// bookings that already ended don't affect availability
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+
+	var bookings []models.RoomRestriction
+	index := make(map[string]int)
+	for _, e := range events {
+		if e.End.Before(today) {
+			continue
+		}
+		b := models.RoomRestriction{
+			StartDate:     e.Start,
+			EndDate:       e.End,
+			RoomID:        src.RoomID,
+			RestrictionID: models.RestrictionExternalBooking,
+			SourceID:      src.ID,
+			SourceUID:     e.UID,
+		}
+		// the last event with a uid wins
+		if i, ok := index[e.UID]; ok {
+			bookings[i] = b
+			continue
+		}
+		index[e.UID] = len(bookings)
+		bookings = append(bookings, b)
+	}
+
+	return im.Store.SyncExternalBookings(src, bookings)
+}
+
+// fetch downloads and parses a feed
+func (im *Importer) fetch(url string) ([]ics.Event, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "text/calendar")
+
+	resp, err := im.Client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("feed returned %s", resp.Status)
+	}
+
+	return ics.Parse(io.LimitReader(resp.Body, maxFeedSize))
+}
@@ -0,0 +1,177 @@
+package icalsync
+
+import (
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-course/bookings/internal/models"
+)
+
+// memoryStore keeps imported bookings in memory, matching them on source uid like the database does
+type memoryStore struct {
+	sources  []models.ICalSource
+	bookings map[string]models.RoomRestriction
+	statuses map[int]string
+}
+
+func newMemoryStore(sources ...models.ICalSource) *memoryStore {
+	return &memoryStore{
+		sources:  sources,
+		bookings: make(map[string]models.RoomRestriction),
+		statuses: make(map[int]string),
+	}
+}
+
+func (s *memoryStore) AllICalSources() ([]models.ICalSource, error) {
+	return s.sources, nil
+}
+
+func (s *memoryStore) UpdateICalSourceStatus(id int, syncedAt time.Time, status string) error {
+	s.statuses[id] = status
+	return nil
+}
+
+func (s *memoryStore) SyncExternalBookings(source models.ICalSource, bookings []models.RoomRestriction) (models.ICalSyncResult, error) {
+	var result models.ICalSyncResult
+	seen := make(map[string]bool)
+	for _, b := range bookings {
+		seen[b.SourceUID] = true
+		ex, ok := s.bookings[b.SourceUID]
+		switch {
+		case !ok:
+			result.Added++
+		case !ex.StartDate.Equal(b.StartDate) || !ex.EndDate.Equal(b.EndDate):
+			result.Updated++
+		}
+		s.bookings[b.SourceUID] = b
+	}
+	for uid := range s.bookings {
+		if !seen[uid] {
+			delete(s.bookings, uid)
+			result.Removed++
+		}
+	}
+	return result, nil
+}
+
+func newTestImporter(store Store) *Importer {
+	return &Importer{
+		Store:    store,
+		Client:   &http.Client{Timeout: 5 * time.Second},
+		InfoLog:  log.New(io.Discard, "", 0),
+		ErrorLog: log.New(io.Discard, "", 0),
+	}
+}
+
+func TestImporter_SyncSource(t *testing.T) {
+	ts := httptest.NewServer(http.FileServer(http.Dir("testdata")))
+	defer ts.Close()
+
+	src := models.ICalSource{ID: 1, RoomID: 1, Name: "Channel", URL: ts.URL + "/channel.ics"}
+	store := newMemoryStore(src)
+	im := newTestImporter(store)
+
+	result, err := im.SyncSource(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Added != 2 || result.Updated != 0 || result.Removed != 0 {
+		t.Errorf("first sync: got %+v, want 2 added", result)
+	}
+	if _, ok := store.bookings["past@channel.example"]; ok {
+		t.Error("past booking should not be imported")
+	}
+	if _, ok := store.bookings["stay-3@channel.example"]; ok {
+		t.Error("cancelled booking should not be imported")
+	}
+	b := store.bookings["stay-1@channel.example"]
+	if b.RoomID != 1 || b.SourceID != 1 || b.RestrictionID != models.RestrictionExternalBooking {
+		t.Errorf("wrong imported booking: %+v", b)
+	}
+
+	// syncing the same feed again changes nothing
+	result, err = im.SyncSource(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result != (models.ICalSyncResult{}) {
+		t.Errorf("second sync: got %+v, want no changes", result)
+	}
+
+	src.URL = ts.URL + "/channel-updated.ics"
+	result, err = im.SyncSource(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Added != 1 || result.Updated != 1 || result.Removed != 1 {
+		t.Errorf("updated sync: got %+v, want 1 added, 1 updated, 1 removed", result)
+	}
+	if b := store.bookings["stay-4@channel.example"]; b.EndDate.Format("2006-01-02") != "2050-03-03" {
+		t.Errorf("last event with a uid should win, got end %s", b.EndDate)
+	}
+	if store.statuses[1] != "ok: 1 added, 1 updated, 1 removed" {
+		t.Errorf("wrong status recorded: %q", store.statuses[1])
+	}
+}
+
+func TestImporter_SyncSourceErrors(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/broken.ics" {
+			w.Write([]byte("<html>not a calendar</html>"))
+			return
+		}
+		http.NotFound(w, r)
+	}))
+	defer ts.Close()
+
+	var testCases = []struct {
+		name   string
+		url    string
+		status string
+	}{
+		{"Missing feed", ts.URL + "/missing.ics", "error: feed returned 404 Not Found"},
+		{"Invalid feed", ts.URL + "/broken.ics", "error: "},
+		{"Unreachable feed", "http://127.0.0.1:1/feed.ics", "error: "},
+	}
+
+	for _, testCase := range testCases {
+		src := models.ICalSource{ID: 2, RoomID: 1, URL: testCase.url}
+		store := newMemoryStore(src)
+		store.bookings["stay-1@channel.example"] = models.RoomRestriction{SourceUID: "stay-1@channel.example"}
+
+		_, err := newTestImporter(store).SyncSource(src)
+		if err == nil {
+			t.Errorf("expected error for (%s)", testCase.name)
+		}
+		if !strings.HasPrefix(store.statuses[2], testCase.status) {
+			t.Errorf("wrong status for (%s): %q", testCase.name, store.statuses[2])
+		}
+		if len(store.bookings) != 1 {
+			t.Errorf("failed sync should keep imported bookings for (%s)", testCase.name)
+		}
+	}
+}
+
+func TestImporter_SyncAll(t *testing.T) {
+	ts := httptest.NewServer(http.FileServer(http.Dir("testdata")))
+	defer ts.Close()
+
+	store := newMemoryStore(
+		models.ICalSource{ID: 1, RoomID: 1, URL: ts.URL + "/channel.ics"},
+		models.ICalSource{ID: 2, RoomID: 2, URL: ts.URL + "/missing.ics"},
+	)
+
+	newTestImporter(store).SyncAll()
+
+	if !strings.HasPrefix(store.statuses[1], "ok") {
+		t.Errorf("source 1 should have synced, got %q", store.statuses[1])
+	}
+	if !strings.HasPrefix(store.statuses[2], "error") {
+		t.Errorf("source 2 should have failed, got %q", store.statuses[2])
+	}
+}
@@ -0,0 +1,171 @@
+package ics
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/go-course/bookings/internal/models"
+)
+
+const (
+	uidDomain   = "bookings.go-course"
+	dateLayout  = "20060102"
+	stampLayout = "20060102T150405Z"
+	maxLineLen  = 75
+)
+
+// Event is a single all-day VEVENT. End is exclusive, as in the room_restrictions table
+type Event struct {
+	UID         string
+	Stamp       time.Time
+	Start       time.Time
+	End         time.Time
+	Summary     string
+	Description string
+	Categories  string
+}
+
+// Calendar is a VCALENDAR holding events
+type Calendar struct {
+	Name   string
+	Method string
+	Events []Event
+}
+
+// ReservationUID returns the stable UID for a reservation
+func ReservationUID(id int) string {
+	return fmt.Sprintf("reservation-%d@%s", id, uidDomain)
+}
+
+// BlockUID returns the stable UID for an owner block
+func BlockUID(id int) string {
+	return fmt.Sprintf("block-%d@%s", id, uidDomain)
+}
+
+// ReservationEvent builds the event for a reservation
+func ReservationEvent(res models.Reservation) Event {
+	return Event{
+		UID:         ReservationUID(res.ID),
+		Stamp:       stamp(res.UpdatedAt),
+		Start:       res.StartDate,
+		End:         res.EndDate,
+		Summary:     fmt.Sprintf("Reservation: %s %s (%s)", res.FirstName, res.LastName, res.Room.RoomName),
+		Description: fmt.Sprintf("Email: %s\nPhone: %s", res.Email, res.Phone),
+		Categories:  "RESERVATION",
+	}
+}
+
+// RestrictionEvent builds the event for a room restriction, which is either a reservation or a block
+func RestrictionEvent(rr models.RoomRestriction) Event {
+	if rr.ReservationID > 0 {
+		res := rr.Reservation
+		res.ID = rr.ReservationID
+		res.StartDate = rr.StartDate
+		res.EndDate = rr.EndDate
+		res.Room = rr.Room
+		if res.UpdatedAt.IsZero() {
+			res.UpdatedAt = rr.UpdatedAt
+		}
+		return ReservationEvent(res)
+	}
+
+	name := rr.Restriction.RestrictionName
+	if name == "" {
+		name = "Owner Block"
+	}
+	return Event{
+		UID:        BlockUID(rr.ID),
+		Stamp:      stamp(rr.UpdatedAt),
+		Start:      rr.StartDate,
+		End:        rr.EndDate,
+		Summary:    fmt.Sprintf("%s (%s)", name, rr.Room.RoomName),
+		Categories: "BLOCK",
+	}
+}
+
+// Write writes the calendar to w in iCalendar format
+func (c *Calendar) Write(w io.Writer) error {
+	var buf bytes.Buffer
+
+	writeLine(&buf, "BEGIN:VCALENDAR")
+	writeLine(&buf, "VERSION:2.0")
+	writeLine(&buf, "PRODID:-//go-course//bookings//EN")
+	writeLine(&buf, "CALSCALE:GREGORIAN")
+	if c.Method != "" {
+		writeLine(&buf, "METHOD:"+c.Method)
+	}
+	if c.Name != "" {
+		writeLine(&buf, "X-WR-CALNAME:"+escape(c.Name))
+	}
+
+	for _, e := range c.Events {
+		writeLine(&buf, "BEGIN:VEVENT")
+		writeLine(&buf, "UID:"+e.UID)
+		writeLine(&buf, "DTSTAMP:"+stamp(e.Stamp).Format(stampLayout))
+		writeLine(&buf, "DTSTART;VALUE=DATE:"+e.Start.Format(dateLayout))
+		writeLine(&buf, "DTEND;VALUE=DATE:"+e.End.Format(dateLayout))
+		writeLine(&buf, "SUMMARY:"+escape(e.Summary))
+		if e.Description != "" {
+			writeLine(&buf, "DESCRIPTION:"+escape(e.Description))
+		}
+		if e.Categories != "" {
+			writeLine(&buf, "CATEGORIES:"+escape(e.Categories))
+		}
+		writeLine(&buf, "TRANSP:OPAQUE")
+		writeLine(&buf, "END:VEVENT")
+	}
+
+	writeLine(&buf, "END:VCALENDAR")
+
+	_, err := buf.WriteTo(w)
+	return err
+}
+
+// Bytes returns the calendar in iCalendar format
+func (c *Calendar) Bytes() []byte {
+	var buf bytes.Buffer
+	_ = c.Write(&buf)
+	return buf.Bytes()
+}
+
+// stamp returns t in UTC, falling back to now for zero times
+func stamp(t time.Time) time.Time {
+	if t.IsZero() {
+		return time.Now().UTC()
+	}
+	return t.UTC()
+}
+
+// escape escapes text values as required by RFC 5545
+func escape(s string) string {
+	r := strings.NewReplacer(
+		`\`, `\\`,
+		";", `\;`,
+		",", `\,`,
+		"\r\n", `\n`,
+		"\n", `\n`,
+	)
+	return r.Replace(s)
+}
+
+// writeLine writes a content line, folding it at 75 octets and terminating it with CRLF
+func writeLine(buf *bytes.Buffer, line string) {
+	limit := maxLineLen
+	for len(line) > limit {
+		cut := limit
+		// don't split a multi-byte character
+		for cut > 0 && line[cut]&0xC0 == 0x80 {
+			cut--
+		}
+		buf.WriteString(line[:cut])
+		buf.WriteString("\r\n ")
+		line = line[cut:]
+		// continuation lines start with a space, which counts towards the limit
+		limit = maxLineLen - 1
+	}
+	buf.WriteString(line)
+	buf.WriteString("\r\n")
+}
@@ -0,0 +1,85 @@
+package ics
+
+import (
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/go-course/bookings/internal/models"
+)
+
+func TestCalendar_Write(t *testing.T) {
+	start, _ := time.Parse("2006-01-02", "2050-01-01")
+	end, _ := time.Parse("2006-01-02", "2050-01-03")
+
+	cal := Calendar{
+		Name: "All rooms",
+		Events: []Event{
+			RestrictionEvent(models.RoomRestriction{
+				ID:            10,
+				StartDate:     start,
+				EndDate:       end,
+				ReservationID: 4,
+				Room:          models.Room{RoomName: "Generals Quarters"},
+				Reservation:   models.Reservation{FirstName: "John", LastName: "Smith, Jr"},
+			}),
+			RestrictionEvent(models.RoomRestriction{
+				ID:        11,
+				StartDate: end,
+				EndDate:   end.AddDate(0, 0, 1),
+				Room:      models.Room{RoomName: "Majors Suite"},
+			}),
+		},
+	}
+
+	out := string(cal.Bytes())
+
+	var expected = []string{
+		"BEGIN:VCALENDAR\r\n",
+		"X-WR-CALNAME:All rooms\r\n",
+		"UID:reservation-4@bookings.go-course\r\n",
+		"DTSTART;VALUE=DATE:20500101\r\n",
+		"DTEND;VALUE=DATE:20500103\r\n",
+		"SUMMARY:Reservation: John Smith\\, Jr (Generals Quarters)\r\n",
+		"CATEGORIES:RESERVATION\r\n",
+		"UID:block-11@bookings.go-course\r\n",
+		"SUMMARY:Owner Block (Majors Suite)\r\n",
+		"CATEGORIES:BLOCK\r\n",
+		"END:VCALENDAR\r\n",
+	}
+	for _, e := range expected {
+		if !strings.Contains(out, e) {
+			t.Errorf("calendar does not contain %q", e)
+		}
+	}
+
+	if strings.Count(out, "BEGIN:VEVENT") != 2 {
+		t.Errorf("expected 2 events, got %d", strings.Count(out, "BEGIN:VEVENT"))
+	}
+}
+
+func TestWriteLine_Folds(t *testing.T) {
+	cal := Calendar{
+		Events: []Event{
+			{UID: "x", Summary: strings.Repeat("é", 100)},
+		},
+	}
+
+	for _, line := range strings.Split(string(cal.Bytes()), "\r\n") {
+		if len(line) > maxLineLen {
+			t.Errorf("line longer than %d octets: %q", maxLineLen, line)
+		}
+		if !utf8.ValidString(line) {
+			t.Errorf("line split inside a character: %q", line)
+		}
+	}
+}
+
+func TestEscape(t *testing.T) {
+	got := escape("a;b,c\\d\ne")
+	want := `a\;b\,c\\d\ne`
+	if got != want {
+		t.Errorf("escape: got %q, want %q", got, want)
+	}
+}
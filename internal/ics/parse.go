@@ -0,0 +1,166 @@
+package ics
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// Parse reads the VEVENTs of an iCalendar stream. Cancelled events are skipped, events without
+// an end last one day, and times are truncated to dates since bookings are per night
+func Parse(r io.Reader) ([]Event, error) {
+	lines, err := unfold(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var events []Event
+	var cur *Event
+	var cancelled, sawCalendar bool
+
+	for n, line := range lines {
+		name, params, value, ok := splitLine(line)
+		if !ok {
+			return nil, fmt.Errorf("line %d: malformed content line", n+1)
+		}
+
+		switch name {
+		case "BEGIN":
+			if value == "VCALENDAR" {
+				sawCalendar = true
+			}
+			if value == "VEVENT" {
+				cur = &Event{}
+				cancelled = false
+			}
+		case "END":
+			if value == "VEVENT" && cur != nil {
+				if cur.UID == "" || cur.Start.IsZero() {
+					return nil, fmt.Errorf("line %d: event without uid or start", n+1)
+				}
+				if cur.End.IsZero() || !cur.End.After(cur.Start) {
+					cur.End = cur.Start.AddDate(0, 0, 1)
+				}
+				if !cancelled {
+					events = append(events, *cur)
+				}
+				cur = nil
+			}
+		}
+
+		if cur == nil {
+			continue
+		}
+
+		switch name {
+		case "UID":
+			cur.UID = value
+		case "SUMMARY":
+			cur.Summary = unescape(value)
+		case "DESCRIPTION":
+			cur.Description = unescape(value)
+		case "CATEGORIES":
+			cur.Categories = unescape(value)
+		case "STATUS":
+			cancelled = strings.EqualFold(value, "CANCELLED")
+		case "DTSTAMP":
+			cur.Stamp, _ = parseDate(params, value)
+		case "DTSTART":
+			cur.Start, err = parseDate(params, value)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", n+1, err)
+			}
+		case "DTEND":
+			cur.End, err = parseDate(params, value)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", n+1, err)
+			}
+		}
+	}
+
+	if !sawCalendar {
+		return nil, errors.New("not an iCalendar stream")
+	}
+
+	return events, nil
+}
+
+// unfold reads content lines, joining folded continuation lines
+func unfold(r io.Reader) ([]string, error) {
+	var lines []string
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if line == "" {
+			continue
+		}
+		if (line[0] == ' ' || line[0] == '\t') && len(lines) > 0 {
+			lines[len(lines)-1] += line[1:]
+			continue
+		}
+		lines = append(lines, line)
+	}
+	return lines, scanner.Err()
+}
+
+// splitLine splits a content line into its upper cased name, parameters and value
+func splitLine(line string) (string, map[string]string, string, bool) {
+	colon := strings.IndexByte(line, ':')
+	if colon < 0 {
+		return "", nil, "", false
+	}
+	head, value := line[:colon], line[colon+1:]
+
+	parts := strings.Split(head, ";")
+	params := make(map[string]string)
+	for _, p := range parts[1:] {
+		kv := strings.SplitN(p, "=", 2)
+		if len(kv) == 2 {
+			params[strings.ToUpper(kv[0])] = strings.Trim(kv[1], `"`)
+		}
+	}
+	return strings.ToUpper(parts[0]), params, value, true
+}
+
+// parseDate parses DATE and DATE-TIME values, returning midnight UTC of the date
+func parseDate(params map[string]string, value string) (time.Time, error) {
+	if len(value) >= 8 {
+		loc := time.UTC
+		if tz, ok := params["TZID"]; ok {
+			if l, err := time.LoadLocation(tz); err == nil {
+				loc = l
+			}
+		}
+		var t time.Time
+		var err error
+		switch {
+		case len(value) == 8:
+			t, err = time.ParseInLocation(dateLayout, value, loc)
+		case strings.HasSuffix(value, "Z"):
+			t, err = time.Parse(stampLayout, value)
+		default:
+			t, err = time.ParseInLocation("20060102T150405", value, loc)
+		}
+		if err == nil {
+			y, m, d := t.Date()
+			return time.Date(y, m, d, 0, 0, 0, 0, time.UTC), nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid date %q", value)
+}
+
+// unescape reverses escape
+func unescape(s string) string {
+	r := strings.NewReplacer(
+		`\\`, `\`,
+		`\;`, ";",
+		`\,`, ",",
+		`\n`, "\n",
+		`\N`, "\n",
+	)
+	return r.Replace(s)
+}
@@ -0,0 +1,93 @@
+package ics
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+const fixture = "BEGIN:VCALENDAR\r\n" +
+	"VERSION:2.0\r\n" +
+	"PRODID:-//Example//Channel//EN\r\n" +
+	"BEGIN:VEVENT\r\n" +
+	"UID:abc-1@example.com\r\n" +
+	"DTSTART;VALUE=DATE:20500101\r\n" +
+	"DTEND;VALUE=DATE:20500104\r\n" +
+	"SUMMARY:Reserved\\, paid\r\n" +
+	"  in full\r\n" +
+	"END:VEVENT\r\n" +
+	"BEGIN:VEVENT\r\n" +
+	"UID:abc-2@example.com\r\n" +
+	"DTSTART;TZID=Europe/Berlin:20500110T150000\r\n" +
+	"END:VEVENT\r\n" +
+	"BEGIN:VEVENT\r\n" +
+	"UID:abc-3@example.com\r\n" +
+	"STATUS:CANCELLED\r\n" +
+	"DTSTART;VALUE=DATE:20500201\r\n" +
+	"DTEND;VALUE=DATE:20500202\r\n" +
+	"END:VEVENT\r\n" +
+	"END:VCALENDAR\r\n"
+
+func TestParse(t *testing.T) {
+	events, err := Parse(strings.NewReader(fixture))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 2 {
+		t.Fatalf("expected 2 events, got %d", len(events))
+	}
+
+	e := events[0]
+	if e.UID != "abc-1@example.com" {
+		t.Errorf("wrong uid %s", e.UID)
+	}
+	if e.Summary != "Reserved, paid in full" {
+		t.Errorf("wrong summary %q", e.Summary)
+	}
+	if e.Start.Format("2006-01-02") != "2050-01-01" || e.End.Format("2006-01-02") != "2050-01-04" {
+		t.Errorf("wrong dates %s - %s", e.Start, e.End)
+	}
+
+	e = events[1]
+	if e.Start.Format("2006-01-02") != "2050-01-10" || e.End.Format("2006-01-02") != "2050-01-11" {
+		t.Errorf("event without end should last one day, got %s - %s", e.Start, e.End)
+	}
+	if e.Start.Location() != time.UTC {
+		t.Errorf("dates should be normalised to UTC, got %s", e.Start.Location())
+	}
+}
+
+func TestParse_RoundTrip(t *testing.T) {
+	start, _ := time.Parse("2006-01-02", "2050-03-01")
+	cal := Calendar{
+		Events: []Event{
+			{UID: "x@y", Start: start, End: start.AddDate(0, 0, 2), Summary: strings.Repeat("long; summary, ", 10)},
+		},
+	}
+
+	events, err := Parse(bytes.NewReader(cal.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 1 || events[0].Summary != cal.Events[0].Summary || !events[0].End.Equal(cal.Events[0].End) {
+		t.Errorf("round trip failed: %+v", events)
+	}
+}
+
+func TestParse_Invalid(t *testing.T) {
+	var testCases = []struct {
+		name  string
+		input string
+	}{
+		{"Not a calendar", "<html></html>"},
+		{"Event without start", "BEGIN:VCALENDAR\r\nBEGIN:VEVENT\r\nUID:1\r\nEND:VEVENT\r\nEND:VCALENDAR\r\n"},
+		{"Invalid date", "BEGIN:VCALENDAR\r\nBEGIN:VEVENT\r\nUID:1\r\nDTSTART:tomorrow\r\nEND:VEVENT\r\nEND:VCALENDAR\r\n"},
+	}
+
+	for _, testCase := range testCases {
+		if _, err := Parse(strings.NewReader(testCase.input)); err == nil {
+			t.Errorf("expected error for (%s)", testCase.name)
+		}
+	}
+}
@@ -0,0 +1,41 @@
+package mail
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-course/bookings/internal/models"
+)
+
+// FileDrop writes each message as an .eml file to Dir instead of sending it, for local development
+type FileDrop struct {
+	Dir string
+	seq uint64
+	now func() time.Time
+}
+
+// NewFileDrop returns a mailer writing to dir, creating it if needed
+func NewFileDrop(dir string) (*FileDrop, error) {
+	if dir == "" {
+		return nil, errors.New("no directory to write mail to")
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	return &FileDrop{Dir: dir, now: time.Now}, nil
+}
+
+// Send writes the message to a new file named after the time it was sent
+func (f *FileDrop) Send(msg models.MailData) error {
+	email, err := message(msg)
+	if err != nil {
+		return err
+	}
+
+	name := fmt.Sprintf("%s-%d.eml", f.now().UTC().Format("20060102T150405.000000000"), atomic.AddUint64(&f.seq, 1))
+	return os.WriteFile(filepath.Join(f.Dir, name), []byte(email.GetMessage()), 0o644)
+}
@@ -0,0 +1,58 @@
+// Package mail sends the messages of the mail outbox through a pluggable transport, retrying failures with
+// a growing delay and giving up on messages that keep failing
+package mail
+
+import (
+	"fmt"
+
+	"github.com/go-course/bookings/internal/models"
+	simplemail "github.com/xhit/go-simple-mail/v2"
+)
+
+// Names of the transports a Mailer can be created for
+const (
+	TransportSMTP   = "smtp"
+	TransportFile   = "file"
+	TransportMemory = "memory"
+)
+
+// Mailer sends a single message
+type Mailer interface {
+	Send(msg models.MailData) error
+}
+
+// message builds the email of msg, with its text part as an alternative to the HTML one
+func message(msg models.MailData) (*simplemail.Email, error) {
+	email := simplemail.NewMSG()
+	email.SetFrom(msg.From).AddTo(msg.To).SetSubject(msg.Subject)
+	if msg.ReplyTo != "" {
+		email.SetReplyTo(msg.ReplyTo)
+	}
+	email.SetBody(simplemail.TextHTML, msg.Content)
+	if msg.Text != "" {
+		email.AddAlternative(simplemail.TextPlain, msg.Text)
+	}
+	for _, a := range msg.Attachments {
+		email.Attach(&simplemail.File{
+			Name:     a.Name,
+			MimeType: a.MimeType,
+			Data:     a.Data,
+		})
+	}
+
+	return email, email.GetError()
+}
+
+// NewMailer returns the mailer for the named transport: the SMTP server c describes, .eml files written to
+// dir, or an in-memory capture
+func NewMailer(transport string, c SMTPConfig, dir string) (Mailer, error) {
+	switch transport {
+	case TransportSMTP, "":
+		return NewSMTP(c)
+	case TransportFile:
+		return NewFileDrop(dir)
+	case TransportMemory:
+		return NewMemory(), nil
+	}
+	return nil, fmt.Errorf("unknown mail transport %q", transport)
+}
@@ -0,0 +1,115 @@
+package mail
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/go-course/bookings/internal/models"
+)
+
+// confirmation is a message like the one sent to guests
+var confirmation = models.MailData{
+	To:          "john@smith.com",
+	From:        `"Fort Smythe" <rajiv@mkcl.org>`,
+	ReplyTo:     "frontdesk@fortsmythe.com",
+	Subject:     "Reservation Confirmation",
+	Content:     "<h2>Reservation Confirmation</h2>",
+	Text:        "Reservation Confirmation",
+	Template:    "confirmation",
+	Attachments: []models.MailAttachment{{Name: "reservation.ics", MimeType: "text/calendar", Data: []byte("BEGIN:VCALENDAR")}},
+}
+
+func TestMessage(t *testing.T) {
+	email, err := message(confirmation)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	msg := email.GetMessage()
+	for _, s := range []string{"To: <john@smith.com>", `From: "Fort Smythe" <rajiv@mkcl.org>`,
+		"Reply-To: <frontdesk@fortsmythe.com>", "Subject: Reservation Confirmation", "multipart/alternative",
+		"text/plain", "text/html", "reservation.ics"} {
+		if !strings.Contains(msg, s) {
+			t.Errorf("message should contain %q:\n%s", s, msg)
+		}
+	}
+}
+
+func TestNewMailer(t *testing.T) {
+	var testCases = []struct {
+		name      string
+		transport string
+		expectErr bool
+	}{
+		{"SMTP", TransportSMTP, false},
+		{"Default", "", false},
+		{"File", TransportFile, false},
+		{"Memory", TransportMemory, false},
+		{"Unknown", "pigeon", true},
+	}
+
+	for _, testCase := range testCases {
+		_, err := NewMailer(testCase.transport, SMTPConfig{Host: "localhost", Port: 1025}, t.TempDir())
+		if (err != nil) != testCase.expectErr {
+			t.Errorf("NewMailer for (%s): got error %v", testCase.name, err)
+		}
+	}
+}
+
+func TestFileDrop_Send(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "mail")
+	f, err := NewFileDrop(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 2; i++ {
+		if err := f.Send(confirmation); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	files, _ := filepath.Glob(filepath.Join(dir, "*.eml"))
+	if len(files) != 2 {
+		t.Fatalf("expected 2 .eml files, got %v", files)
+	}
+	data, _ := os.ReadFile(files[0])
+	for _, s := range []string{"To: <john@smith.com>", "Subject: Reservation Confirmation", "reservation.ics"} {
+		if !strings.Contains(string(data), s) {
+			t.Errorf("%s should contain %q", files[0], s)
+		}
+	}
+
+	if _, err := NewFileDrop(""); err == nil {
+		t.Error("a file drop needs a directory")
+	}
+}
+
+func TestMemory_Send(t *testing.T) {
+	m := NewMemory()
+
+	if err := m.Send(confirmation); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Send(models.MailData{To: "rajiv@mkcl.org", Subject: "Reservation Notification", Content: "booked"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if n := len(m.Messages()); n != 2 {
+		t.Fatalf("expected 2 messages, got %d", n)
+	}
+	sent := m.To("john@smith.com")
+	if len(sent) != 1 || sent[0].Subject != "Reservation Confirmation" {
+		t.Fatalf("wrong messages to the guest: %+v", sent)
+	}
+	if sent[0].Content != confirmation.Content || sent[0].Text != confirmation.Text {
+		t.Errorf("the message should be kept as sent: %+v", sent[0])
+	}
+
+	m.Reset()
+	if len(m.Messages()) != 0 {
+		t.Error("Reset should forget the messages")
+	}
+}
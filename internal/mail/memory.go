@@ -0,0 +1,51 @@
+package mail
+
+import (
+	"sync"
+
+	"github.com/go-course/bookings/internal/models"
+)
+
+// Memory keeps the messages sent in memory, so tests can inspect them
+type Memory struct {
+	mu   sync.Mutex
+	sent []models.MailData
+}
+
+// NewMemory returns an empty in-memory mailer
+func NewMemory() *Memory {
+	return &Memory{}
+}
+
+// Send keeps the message
+func (m *Memory) Send(msg models.MailData) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.sent = append(m.sent, msg)
+	return nil
+}
+
+// Messages returns the messages sent so far, oldest first
+func (m *Memory) Messages() []models.MailData {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]models.MailData(nil), m.sent...)
+}
+
+// To returns the messages sent to address
+func (m *Memory) To(address string) []models.MailData {
+	var sent []models.MailData
+	for _, msg := range m.Messages() {
+		if msg.To == address {
+			sent = append(sent, msg)
+		}
+	}
+	return sent
+}
+
+// Reset forgets the messages sent so far
+func (m *Memory) Reset() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.sent = nil
+}
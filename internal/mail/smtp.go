@@ -0,0 +1,92 @@
+package mail
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/go-course/bookings/internal/models"
+	simplemail "github.com/xhit/go-simple-mail/v2"
+)
+
+// Encryptions of the connection to the SMTP server
+const (
+	EncryptionNone     = "none"
+	EncryptionSTARTTLS = "starttls"
+	EncryptionTLS      = "tls"
+)
+
+// SMTPConfig holds the settings of the SMTP server. Username is empty for servers without authentication
+type SMTPConfig struct {
+	Host       string
+	Port       int
+	Username   string
+	Password   string
+	Encryption string
+	Timeout    time.Duration
+}
+
+// SMTP sends messages through an SMTP server, opening a connection for each
+type SMTP struct {
+	Config SMTPConfig
+}
+
+// NewSMTP returns a mailer for the server c describes
+func NewSMTP(c SMTPConfig) (*SMTP, error) {
+	if c.Host == "" || c.Port <= 0 {
+		return nil, fmt.Errorf("invalid SMTP server %s:%d", c.Host, c.Port)
+	}
+	if _, err := encryption(c.Encryption); err != nil {
+		return nil, err
+	}
+	if c.Timeout <= 0 {
+		c.Timeout = 10 * time.Second
+	}
+	return &SMTP{Config: c}, nil
+}
+
+// encryption returns the encryption of the mail library named by s
+func encryption(s string) (simplemail.Encryption, error) {
+	switch s {
+	case EncryptionNone, "":
+		return simplemail.EncryptionNone, nil
+	case EncryptionSTARTTLS:
+		return simplemail.EncryptionSTARTTLS, nil
+	case EncryptionTLS:
+		return simplemail.EncryptionSSLTLS, nil
+	}
+	return simplemail.EncryptionNone, fmt.Errorf("unknown SMTP encryption %q", s)
+}
+
+// Send sends a message through the server
+func (s *SMTP) Send(msg models.MailData) error {
+	email, err := message(msg)
+	if err != nil {
+		return err
+	}
+
+	enc, err := encryption(s.Config.Encryption)
+	if err != nil {
+		return err
+	}
+
+	server := simplemail.NewSMTPClient()
+	server.Host = s.Config.Host
+	server.Port = s.Config.Port
+	server.Encryption = enc
+	server.Authentication = simplemail.AuthNone
+	if s.Config.Username != "" {
+		server.Authentication = simplemail.AuthPlain
+		server.Username = s.Config.Username
+		server.Password = s.Config.Password
+	}
+	server.KeepAlive = false
+	server.ConnectTimeout = s.Config.Timeout
+	server.SendTimeout = s.Config.Timeout
+
+	client, err := server.Connect()
+	if err != nil {
+		return err
+	}
+
+	return email.Send(client)
+}
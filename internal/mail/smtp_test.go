@@ -0,0 +1,30 @@
+package mail
+
+import (
+	"testing"
+)
+
+func TestNewSMTP(t *testing.T) {
+	var testCases = []struct {
+		name      string
+		config    SMTPConfig
+		expectErr bool
+	}{
+		{"Plain", SMTPConfig{Host: "localhost", Port: 1025}, false},
+		{"STARTTLS", SMTPConfig{Host: "smtp.example.com", Port: 587, Username: "u", Password: "p", Encryption: EncryptionSTARTTLS}, false},
+		{"TLS", SMTPConfig{Host: "smtp.example.com", Port: 465, Encryption: EncryptionTLS}, false},
+		{"No host", SMTPConfig{Port: 25}, true},
+		{"No port", SMTPConfig{Host: "localhost"}, true},
+		{"Unknown encryption", SMTPConfig{Host: "localhost", Port: 25, Encryption: "ssl3"}, true},
+	}
+
+	for _, testCase := range testCases {
+		s, err := NewSMTP(testCase.config)
+		if (err != nil) != testCase.expectErr {
+			t.Errorf("NewSMTP for (%s): got error %v", testCase.name, err)
+		}
+		if err == nil && s.Config.Timeout == 0 {
+			t.Errorf("NewSMTP for (%s) should default the timeout", testCase.name)
+		}
+	}
+}
@@ -0,0 +1,97 @@
+package mail
+
+import (
+	"log"
+	"time"
+
+	"github.com/go-course/bookings/internal/config"
+	"github.com/go-course/bookings/internal/models"
+	"github.com/go-course/bookings/internal/outbox"
+)
+
+// Store is the part of the repository the worker needs
+type Store interface {
+	ClaimMail(limit int, lease time.Duration) ([]models.MailMessage, error)
+	UpdateMailDelivery(msg models.MailMessage) error
+}
+
+// Worker sends the messages due in the outbox with a pool of goroutines. A message that fails is retried
+// with a growing delay, and marked failed after MaxAttempts
+type Worker struct {
+	Store       Store
+	Mailer      Mailer
+	Workers     int
+	BatchSize   int
+	MaxAttempts int
+	MinBackoff  time.Duration
+	MaxBackoff  time.Duration
+	// Lease is how long a claimed message is kept from other workers while it is being sent
+	Lease    time.Duration
+	InfoLog  *log.Logger
+	ErrorLog *log.Logger
+	now      func() time.Time
+}
+
+// New creates a worker using the loggers of the app config
+func New(store Store, mailer Mailer, a *config.AppConfig) *Worker {
+	return &Worker{
+		Store:       store,
+		Mailer:      mailer,
+		Workers:     4,
+		BatchSize:   20,
+		MaxAttempts: 8,
+		MinBackoff:  time.Minute,
+		MaxBackoff:  6 * time.Hour,
+		Lease:       5 * time.Minute,
+		InfoLog:     a.InfoLog,
+		ErrorLog:    a.ErrorLog,
+		now:         time.Now,
+	}
+}
+
+// Start sends the messages due now and then every interval, until stop is closed
+func (w *Worker) Start(interval time.Duration, stop <-chan struct{}) {
+	outbox.Start(interval, stop, w.DeliverDue)
+}
+
+// DeliverDue sends the messages due in the outbox and returns how many were sent
+func (w *Worker) DeliverDue() (int, error) {
+	sent, err := outbox.DeliverDue(w.BatchSize, w.Workers, func(limit int) ([]models.MailMessage, error) {
+		return w.Store.ClaimMail(limit, w.Lease)
+	}, w.deliver)
+	if err != nil {
+		w.ErrorLog.Println(err)
+	}
+
+	if sent > 0 {
+		w.InfoLog.Printf("mail: sent %d messages", sent)
+	}
+
+	return sent, err
+}
+
+// deliver sends a claimed message and records the outcome, reporting whether it was sent
+func (w *Worker) deliver(msg models.MailMessage) bool {
+	err := w.Mailer.Send(msg.MailData)
+	switch {
+	case err == nil:
+		msg.Status = models.MailSent
+		msg.SentAt = w.now()
+		msg.LastError = ""
+	case msg.Attempts >= w.MaxAttempts:
+		msg.Status = models.MailFailed
+		msg.LastError = err.Error()
+		w.ErrorLog.Printf("mail %d: giving up after %d attempts: %s", msg.ID, msg.Attempts, err)
+	default:
+		msg.LastError = err.Error()
+		msg.NextAttemptAt = w.now().Add(outbox.Backoff(msg.Attempts, w.MinBackoff, w.MaxBackoff))
+		w.ErrorLog.Printf("mail %d: attempt %d failed, retrying at %s: %s",
+			msg.ID, msg.Attempts, msg.NextAttemptAt.Format(time.RFC3339), err)
+	}
+
+	if err := w.Store.UpdateMailDelivery(msg); err != nil {
+		w.ErrorLog.Println(err)
+	}
+
+	return msg.Status == models.MailSent
+}
@@ -0,0 +1,192 @@
+package mail
+
+import (
+	"errors"
+	"io"
+	"log"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-course/bookings/internal/models"
+)
+
+// clock is a settable time source
+type clock struct {
+	t time.Time
+}
+
+func (c *clock) now() time.Time {
+	return c.t
+}
+
+// memoryOutbox keeps the outbox in memory, claiming messages like the database does
+type memoryOutbox struct {
+	mu       sync.Mutex
+	clock    *clock
+	messages map[int]models.MailMessage
+	locked   map[int]bool
+}
+
+func newMemoryOutbox(c *clock, n int) *memoryOutbox {
+	o := &memoryOutbox{
+		clock:    c,
+		messages: make(map[int]models.MailMessage),
+		locked:   make(map[int]bool),
+	}
+	for id := 1; id <= n; id++ {
+		o.messages[id] = models.MailMessage{
+			ID:            id,
+			MailData:      models.MailData{To: "john@smith.com", Subject: "Reservation Confirmation"},
+			Status:        models.MailPending,
+			NextAttemptAt: c.t,
+		}
+	}
+	return o
+}
+
+func (o *memoryOutbox) ClaimMail(limit int, lease time.Duration) ([]models.MailMessage, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	var claimed []models.MailMessage
+	for id := 1; id <= len(o.messages) && len(claimed) < limit; id++ {
+		msg := o.messages[id]
+		if msg.Status != models.MailPending || msg.NextAttemptAt.After(o.clock.t) || o.locked[id] {
+			continue
+		}
+		msg.Attempts++
+		o.messages[id] = msg
+		o.locked[id] = true
+		claimed = append(claimed, msg)
+	}
+	return claimed, nil
+}
+
+func (o *memoryOutbox) UpdateMailDelivery(msg models.MailMessage) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	o.messages[msg.ID] = msg
+	delete(o.locked, msg.ID)
+	return nil
+}
+
+// fakeMailer records the messages sent, failing with err when it is set
+type fakeMailer struct {
+	mu   sync.Mutex
+	err  error
+	sent int
+}
+
+func (s *fakeMailer) Send(msg models.MailData) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.err != nil {
+		return s.err
+	}
+	s.sent++
+	return nil
+}
+
+func newTestWorker(store Store, mailer Mailer, c *clock) *Worker {
+	return &Worker{
+		Store:       store,
+		Mailer:      mailer,
+		Workers:     3,
+		BatchSize:   2,
+		MaxAttempts: 4,
+		MinBackoff:  time.Minute,
+		MaxBackoff:  3 * time.Minute,
+		Lease:       time.Minute,
+		InfoLog:     log.New(io.Discard, "", 0),
+		ErrorLog:    log.New(io.Discard, "", 0),
+		now:         c.now,
+	}
+}
+
+func TestWorker_DeliverDue(t *testing.T) {
+	c := &clock{t: time.Date(2050, 1, 1, 12, 0, 0, 0, time.UTC)}
+	outbox := newMemoryOutbox(c, 5)
+	mailer := &fakeMailer{}
+	w := newTestWorker(outbox, mailer, c)
+
+	n, err := w.DeliverDue()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 5 || mailer.sent != 5 {
+		t.Errorf("expected 5 messages sent, got %d (mailer saw %d)", n, mailer.sent)
+	}
+	for id, msg := range outbox.messages {
+		if msg.Status != models.MailSent || !msg.SentAt.Equal(c.t) || msg.Attempts != 1 {
+			t.Errorf("message %d not recorded as sent: %+v", id, msg)
+		}
+	}
+
+	// nothing due, nothing sent
+	if n, _ := w.DeliverDue(); n != 0 || mailer.sent != 5 {
+		t.Errorf("expected nothing sent twice, got %d", n)
+	}
+}
+
+func TestWorker_DeliverBacksOff(t *testing.T) {
+	c := &clock{t: time.Date(2050, 1, 1, 12, 0, 0, 0, time.UTC)}
+	outbox := newMemoryOutbox(c, 1)
+	mailer := &fakeMailer{err: errors.New("connection refused")}
+	w := newTestWorker(outbox, mailer, c)
+
+	var expected = []time.Duration{time.Minute, 2 * time.Minute, 3 * time.Minute}
+	for i, wait := range expected {
+		if n, err := w.DeliverDue(); n != 0 || err != nil {
+			t.Fatalf("attempt %d: got %d, %v", i+1, n, err)
+		}
+		msg := outbox.messages[1]
+		if msg.Status != models.MailPending || msg.Attempts != i+1 || msg.LastError != "connection refused" {
+			t.Errorf("attempt %d: wrong message %+v", i+1, msg)
+		}
+		if got := msg.NextAttemptAt.Sub(c.t); got != wait {
+			t.Errorf("attempt %d: got backoff %s, want %s", i+1, got, wait)
+		}
+
+		// not retried before the backoff is over
+		c.t = c.t.Add(wait - time.Second)
+		w.DeliverDue()
+		if outbox.messages[1].Attempts != i+1 {
+			t.Errorf("attempt %d: retried during backoff", i+1)
+		}
+		c.t = c.t.Add(time.Second)
+	}
+
+	// the last attempt gives up
+	w.DeliverDue()
+	msg := outbox.messages[1]
+	if msg.Status != models.MailFailed || msg.Attempts != 4 {
+		t.Errorf("expected message to fail after 4 attempts, got %+v", msg)
+	}
+
+	c.t = c.t.Add(time.Hour)
+	mailer.err = nil
+	if n, _ := w.DeliverDue(); n != 0 {
+		t.Error("failed messages should not be retried")
+	}
+}
+
+func TestWorker_Recovers(t *testing.T) {
+	c := &clock{t: time.Date(2050, 1, 1, 12, 0, 0, 0, time.UTC)}
+	outbox := newMemoryOutbox(c, 1)
+	mailer := &fakeMailer{err: errors.New("connection refused")}
+	w := newTestWorker(outbox, mailer, c)
+
+	w.DeliverDue()
+	c.t = c.t.Add(time.Minute)
+	mailer.err = nil
+
+	if n, err := w.DeliverDue(); n != 1 || err != nil {
+		t.Fatalf("expected recovery, got %d, %v", n, err)
+	}
+	if msg := outbox.messages[1]; msg.Status != models.MailSent || msg.LastError != "" || msg.Attempts != 2 {
+		t.Errorf("wrong message after recovery: %+v", msg)
+	}
+}
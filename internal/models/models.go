@@ -1,6 +1,10 @@
 package models
 
 import (
+	"errors"
+	"fmt"
+	"net/mail"
+	"strings"
 	"time"
 )
 
@@ -12,6 +16,7 @@ type User struct {
 	Email       string
 	Password    string
 	AccessLevel int
+	FeedToken   string
 	CreatedAt   time.Time
 	UpdatedAt   time.Time
 }
@@ -32,22 +37,370 @@ type Restriction struct {
 	UpdatedAt       time.Time
 }
 
+// Restriction types seeded in the restrictions table
+const (
+	RestrictionReservation     = 1
+	RestrictionOwnerBlock      = 2
+	RestrictionExternalBooking = 3
+)
+
 // Reservations is the reservation model
 type Reservation struct {
+	ID           int
+	FirstName    string
+	LastName     string
+	Email        string
+	Phone        string
+	StartDate    time.Time
+	EndDate      time.Time
+	RoomID       int
+	Status       string
+	ConfirmedAt  time.Time
+	CheckedInAt  time.Time
+	CheckedOutAt time.Time
+	CancelledAt  time.Time
+	NoShowAt     time.Time
+	CheckedInBy  int
+	CheckedOutBy int
+	GuestID      int
+	// SMSOptIn is whether the guest asked to get text messages about the reservation at Phone
+	SMSOptIn  bool
+	DeletedAt time.Time
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	Room      Room
+}
+
+// Guest is a person who stays with us, linked to all of their reservations
+type Guest struct {
 	ID        int
 	FirstName string
 	LastName  string
 	Email     string
 	Phone     string
-	StartDate time.Time
-	EndDate   time.Time
-	RoomID    int
-	Procesed  int
+	Notes     string
+	Stays     int
+	LastStay  time.Time
 	CreatedAt time.Time
 	UpdatedAt time.Time
+}
+
+// GuestData is everything held about the guest with an email address
+type GuestData struct {
+	Email             string
+	Guests            []Guest
+	Reservations      []Reservation
+	RegistrationCards []RegistrationCard
+	TextMessages      []SMSMessage
+}
+
+// Empty reports whether nothing is held about the guest
+func (d GuestData) Empty() bool {
+	return len(d.Guests) == 0 && len(d.Reservations) == 0 && len(d.RegistrationCards) == 0 && len(d.TextMessages) == 0
+}
+
+// Names erased reservations are left with
+const (
+	ErasedFirstName = "Erased"
+	ErasedLastName  = "Guest"
+)
+
+// NormalizeEmail returns the form of an email address guests are matched on
+func NormalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+// NormalizePhone returns the form of a phone number guests are matched on: its digits, after a plus
+// sign if the number had one
+func NormalizePhone(phone string) string {
+	var digits strings.Builder
+	for _, r := range phone {
+		if r >= '0' && r <= '9' {
+			digits.WriteRune(r)
+		}
+	}
+	if digits.Len() == 0 {
+		return ""
+	}
+	if strings.HasPrefix(strings.TrimSpace(phone), "+") {
+		return "+" + digits.String()
+	}
+	return digits.String()
+}
+
+// Kinds of identity document accepted at check-in
+const (
+	IDPassport       = "passport"
+	IDNationalCard   = "national-id"
+	IDDrivingLicence = "driving-licence"
+)
+
+// IDTypes lists the identity documents accepted at check-in, with the names they are shown with
+var IDTypes = []struct {
+	Value string
+	Label string
+}{
+	{IDPassport, "Passport"},
+	{IDNationalCard, "National ID card"},
+	{IDDrivingLicence, "Driving licence"},
+}
+
+// ValidIDType reports whether t is an accepted identity document
+func ValidIDType(t string) bool {
+	for _, id := range IDTypes {
+		if id.Value == t {
+			return true
+		}
+	}
+	return false
+}
+
+// RegistrationCard holds the details a guest fills in at check-in
+type RegistrationCard struct {
+	ID            int
+	ReservationID int
+	Address       string
+	IDType        string
+	IDNumber      string
+	VehiclePlate  string
+	CreatedAt     time.Time
+	UpdatedAt     time.Time
+}
+
+// ErrRoomUnavailable is returned when a reservation's room is no longer free for its dates
+var ErrRoomUnavailable = errors.New("the room is no longer available for these dates")
+
+// Reservation statuses
+const (
+	ReservationPending    = "pending"
+	ReservationConfirmed  = "confirmed"
+	ReservationCheckedIn  = "checked-in"
+	ReservationCheckedOut = "checked-out"
+	ReservationCancelled  = "cancelled"
+	ReservationNoShow     = "no-show"
+)
+
+// ReservationStatuses lists the statuses in lifecycle order
+var ReservationStatuses = []string{
+	ReservationPending,
+	ReservationConfirmed,
+	ReservationCheckedIn,
+	ReservationCheckedOut,
+	ReservationCancelled,
+	ReservationNoShow,
+}
+
+// ReservationTransition is a status change allowed from some status, with the label of its button
+type ReservationTransition struct {
+	To    string
+	Label string
+}
+
+// reservationTransitions holds the allowed status changes. Checked-out, cancelled and no-show are final
+var reservationTransitions = map[string][]ReservationTransition{
+	ReservationPending: {
+		{ReservationConfirmed, "Confirm"},
+		{ReservationCancelled, "Cancel"},
+	},
+	ReservationConfirmed: {
+		{ReservationCheckedIn, "Check In"},
+		{ReservationNoShow, "No-Show"},
+		{ReservationCancelled, "Cancel"},
+	},
+	ReservationCheckedIn: {
+		{ReservationCheckedOut, "Check Out"},
+	},
+}
+
+// Transitions returns the status changes allowed from the reservation's current status
+func (r Reservation) Transitions() []ReservationTransition {
+	return reservationTransitions[r.Status]
+}
+
+// ValidReservationStatus reports whether status is a known reservation status
+func ValidReservationStatus(status string) bool {
+	for _, s := range ReservationStatuses {
+		if s == status {
+			return true
+		}
+	}
+	return false
+}
+
+// ReservationStatusesFrom returns the statuses a reservation can be moved to status from
+func ReservationStatusesFrom(status string) []string {
+	var from []string
+	for _, s := range ReservationStatuses {
+		for _, t := range reservationTransitions[s] {
+			if t.To == status {
+				from = append(from, s)
+			}
+		}
+	}
+	return from
+}
+
+// TransitionError is returned when a reservation can't be moved to a status from its current one
+type TransitionError struct {
+	From string
+	To   string
+}
+
+func (e *TransitionError) Error() string {
+	return fmt.Sprintf("a %s reservation can't be changed to %s", e.From, e.To)
+}
+
+// Page sizes of reservation lists
+const (
+	DefaultPageSize = 25
+	MaxPageSize     = 100
+)
+
+// ReservationQuery selects a page of reservations. Zero filter fields don't filter
+type ReservationQuery struct {
+	Page     int
+	PageSize int
+	Sort     string
+	Desc     bool
+	Search   string
+	RoomID   int
+	From     time.Time
+	To       time.Time
+	Status   string
+}
+
+// Limit returns the page size, bounded to MaxPageSize
+func (q ReservationQuery) Limit() int {
+	switch {
+	case q.PageSize <= 0:
+		return DefaultPageSize
+	case q.PageSize > MaxPageSize:
+		return MaxPageSize
+	}
+	return q.PageSize
+}
+
+// Offset returns how many reservations come before the page
+func (q ReservationQuery) Offset() int {
+	if q.Page < 1 {
+		return 0
+	}
+	return (q.Page - 1) * q.Limit()
+}
+
+// Pages returns how many pages total reservations fill, at least one
+func (q ReservationQuery) Pages(total int) int {
+	if total <= 0 {
+		return 1
+	}
+	return (total + q.Limit() - 1) / q.Limit()
+}
+
+// Housekeeping statuses of a room
+const (
+	RoomDirty      = "dirty"
+	RoomClean      = "clean"
+	RoomInspected  = "inspected"
+	RoomOutOfOrder = "out-of-order"
+)
+
+// RoomStatuses lists the housekeeping statuses in the order rooms go through them
+var RoomStatuses = []string{RoomDirty, RoomClean, RoomInspected, RoomOutOfOrder}
+
+// ValidRoomStatus reports whether status is a known housekeeping status
+func ValidRoomStatus(status string) bool {
+	for _, s := range RoomStatuses {
+		if s == status {
+			return true
+		}
+	}
+	return false
+}
+
+// RoomStatus is the housekeeping status of a room. BlockID is the owner block created when the room
+// was put out of order, if any
+type RoomStatus struct {
+	RoomID    int
+	Status    string
+	Note      string
+	BlockID   int
+	UpdatedBy int
+	UpdatedAt time.Time
 	Room      Room
 }
 
+// RoomOccupancy counts how a room's nights in a period were used
+type RoomOccupancy struct {
+	Room           Room
+	Nights         int
+	ReservedNights int
+	ExternalNights int
+	BlockedNights  int
+	Reservations   int
+}
+
+// Rate returns the share of the nights the room could be sold, that is not blocked, which were booked
+func (o RoomOccupancy) Rate() float64 {
+	available := o.Nights - o.BlockedNights
+	if available <= 0 {
+		return 0
+	}
+	return float64(o.ReservedNights+o.ExternalNights) / float64(available)
+}
+
+// DashboardCounts are the figures of a day shown on the admin dashboard
+type DashboardCounts struct {
+	Arrivals   int
+	Departures int
+	InHouse    int
+	Pending    int
+}
+
+// LeadTimeBucket counts the reservations made from MinDays up to MaxDays days before arrival
+type LeadTimeBucket struct {
+	Label   string
+	MinDays int
+	MaxDays int
+	Count   int
+}
+
+// LeadTimeBuckets returns the empty buckets of the booking lead time distribution. The last one is open ended
+func LeadTimeBuckets() []LeadTimeBucket {
+	return []LeadTimeBucket{
+		{Label: "Same day", MinDays: 0, MaxDays: 0},
+		{Label: "1-7 days", MinDays: 1, MaxDays: 7},
+		{Label: "8-30 days", MinDays: 8, MaxDays: 30},
+		{Label: "31-90 days", MinDays: 31, MaxDays: 90},
+		{Label: "Over 90 days", MinDays: 91},
+	}
+}
+
+// AddLeadTime counts n reservations made days before arrival in the bucket holding days
+func AddLeadTime(buckets []LeadTimeBucket, days, n int) {
+	for i := range buckets {
+		b := &buckets[i]
+		last := i == len(buckets)-1
+		if days >= b.MinDays && (days <= b.MaxDays || last) {
+			b.Count += n
+			return
+		}
+	}
+}
+
+// ImportRow is a row of a bookings import: a reservation, or an owner block over the reservation's dates,
+// with what is wrong with it
+type ImportRow struct {
+	Line        int
+	Block       bool
+	Reservation Reservation
+	Errors      []string
+}
+
+// Valid reports whether the row can be imported
+func (r ImportRow) Valid() bool {
+	return len(r.Errors) == 0
+}
+
 // RoomRestrictions is the room restriction model
 type RoomRestriction struct {
 	ID            int
@@ -56,6 +409,9 @@ type RoomRestriction struct {
 	RoomID        int
 	ReservationID int
 	RestrictionID int
+	Version       int
+	SourceID      int
+	SourceUID     string
 	CreatedAt     time.Time
 	UpdatedAt     time.Time
 	Room          Room
@@ -63,11 +419,323 @@ type RoomRestriction struct {
 	Restriction   Restriction
 }
 
-// MailData holds an email message
+// ICalSource is an external iCalendar feed whose bookings are imported into a room
+type ICalSource struct {
+	ID           int
+	RoomID       int
+	Name         string
+	URL          string
+	LastSyncedAt time.Time
+	LastStatus   string
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+	Room         Room
+}
+
+// ICalSyncResult counts what an iCalendar import changed
+type ICalSyncResult struct {
+	Added    int
+	Updated  int
+	Removed  int
+	Overlaps int
+}
+
+// ChannelEvent is a change of room_restrictions recorded in the channel outbox
+type ChannelEvent struct {
+	ID                int
+	Position          int
+	Action            string
+	RoomID            int
+	StartDate         time.Time
+	EndDate           time.Time
+	RoomRestrictionID int
+	CreatedAt         time.Time
+}
+
+// ChannelCursor tracks how far a channel has been sent the outbox
+type ChannelCursor struct {
+	ID            int
+	Channel       string
+	LastPosition  int
+	Attempts      int
+	NextAttemptAt time.Time
+	LastError     string
+	LastSyncedAt  time.Time
+	Pending       int
+	CreatedAt     time.Time
+	UpdatedAt     time.Time
+}
+
+// RoomAvailability is whether a room can be booked on a day
+type RoomAvailability struct {
+	RoomID    int
+	Date      time.Time
+	Available bool
+}
+
+// Actions understood by BlockChange
+const (
+	BlockActionAdd    = "add"
+	BlockActionRemove = "remove"
+)
+
+// BlockChange is an add or remove intent for an owner block posted from the reservations calendar
+type BlockChange struct {
+	Action  string
+	RoomID  int
+	Date    time.Time
+	BlockID int
+	Version int
+}
+
+// BlockConflict describes a block change that could not be applied
+type BlockConflict struct {
+	Change BlockChange
+	Reason string
+}
+
+// AuditEvent records a change made by a user. Before and After hold the entity as JSON, and are empty
+// when the entity didn't exist before or after the change
+type AuditEvent struct {
+	ID        int
+	UserID    int
+	Action    string
+	Entity    string
+	EntityID  string
+	Before    string
+	After     string
+	CreatedAt time.Time
+	User      User
+}
+
+// AuditFilter selects audit events. Zero fields don't filter
+type AuditFilter struct {
+	UserID int
+	Entity string
+	From   time.Time
+	To     time.Time
+	Limit  int
+}
+
+// MailData holds an email message. Content is its HTML part and Text its plain text alternative; Template
+// names the email template they were rendered from
 type MailData struct {
+	To          string
+	From        string
+	ReplyTo     string
+	Subject     string
+	Content     string
+	Text        string
+	Template    string
+	Attachments []MailAttachment
+}
+
+// MailAttachment is a file attached to an email message
+type MailAttachment struct {
+	Name     string
+	MimeType string
+	Data     []byte
+}
+
+// Statuses of a message in the mail outbox. Failed messages gave up after too many attempts and wait to
+// be resent
+const (
+	MailPending = "pending"
+	MailSent    = "sent"
+	MailFailed  = "failed"
+)
+
+// MailStatuses lists the statuses of outbox messages
+var MailStatuses = []string{MailPending, MailSent, MailFailed}
+
+// MailMessage is a message in the mail outbox
+type MailMessage struct {
+	ID int
+	MailData
+	Status        string
+	Attempts      int
+	NextAttemptAt time.Time
+	LastError     string
+	SentAt        time.Time
+	CreatedAt     time.Time
+	UpdatedAt     time.Time
+}
+
+// SMSData holds a text message. Template names the email template its body was rendered from
+type SMSData struct {
 	To       string
-	From     string
-	Subject  string
-	Content  string
+	Body     string
 	Template string
 }
+
+// SMSMessage is a text message of the SMS outbox, which goes through the statuses of the mail outbox
+type SMSMessage struct {
+	ID int
+	SMSData
+	Status        string
+	Attempts      int
+	NextAttemptAt time.Time
+	LastError     string
+	SentAt        time.Time
+	CreatedAt     time.Time
+	UpdatedAt     time.Time
+}
+
+// Events staff can be notified of
+const (
+	EventNewBooking   = "new_booking"
+	EventCancellation = "cancellation"
+	EventModification = "modification"
+)
+
+// NotificationEvents lists the events staff can be notified of
+var NotificationEvents = []string{EventNewBooking, EventCancellation, EventModification}
+
+// NotificationEventNames are the names of the events shown to staff
+var NotificationEventNames = map[string]string{
+	EventNewBooking:   "New booking",
+	EventCancellation: "Cancellation",
+	EventModification: "Modification",
+}
+
+// ValidNotificationEvent reports whether event is one staff can be notified of
+func ValidNotificationEvent(event string) bool {
+	for _, e := range NotificationEvents {
+		if e == event {
+			return true
+		}
+	}
+	return false
+}
+
+// PropertySettings holds who the property's emails come from, and the staff addresses notified of each
+// event on top of the users who chose to be
+type PropertySettings struct {
+	SenderName  string
+	SenderEmail string
+	ReplyTo     string
+	Recipients  map[string][]string
+	UpdatedAt   time.Time
+}
+
+// From returns the sender of the property's emails, with its name if it has one
+func (s PropertySettings) From() string {
+	if s.SenderName == "" {
+		return s.SenderEmail
+	}
+	return (&mail.Address{Name: s.SenderName, Address: s.SenderEmail}).String()
+}
+
+// Jobs run by the scheduler
+const (
+	JobGuestReminder = "guest_reminder"
+	JobGuestFollowUp = "guest_follow_up"
+	JobOutOfOrder    = "out_of_order_blocks"
+)
+
+// JobNames are the names of the scheduled jobs shown to staff
+var JobNames = map[string]string{
+	JobGuestReminder: "Pre-arrival reminder",
+	JobGuestFollowUp: "Post-stay thank-you",
+	JobOutOfOrder:    "Out of order room blocks",
+}
+
+// ScheduledJob is a job the scheduler runs once a day at Hour. OffsetDays is how many days before arrival,
+// or after departure, the guest emails of the job are sent
+type ScheduledJob struct {
+	ID         int
+	Name       string
+	Enabled    bool
+	OffsetDays int
+	Hour       int
+	NextRunAt  time.Time
+	LastRunAt  time.Time
+	LastResult string
+	LastError  string
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+}
+
+// NextRun returns the first time after t that the job is due, at Hour o'clock in the location of t
+func (j ScheduledJob) NextRun(t time.Time) time.Time {
+	next := time.Date(t.Year(), t.Month(), t.Day(), j.Hour, 0, 0, 0, t.Location())
+	if !next.After(t) {
+		next = next.AddDate(0, 0, 1)
+	}
+	return next
+}
+
+// Events posted to webhook endpoints
+const (
+	WebhookReservationCreated   = "reservation.created"
+	WebhookReservationUpdated   = "reservation.updated"
+	WebhookReservationCancelled = "reservation.cancelled"
+	WebhookBlockCreated         = "block.created"
+)
+
+// WebhookEvents lists the events a webhook endpoint can subscribe to
+var WebhookEvents = []string{
+	WebhookReservationCreated,
+	WebhookReservationUpdated,
+	WebhookReservationCancelled,
+	WebhookBlockCreated,
+}
+
+// ValidWebhookEvent reports whether event is one a webhook endpoint can subscribe to
+func ValidWebhookEvent(event string) bool {
+	for _, e := range WebhookEvents {
+		if e == event {
+			return true
+		}
+	}
+	return false
+}
+
+// WebhookEndpoint is a URL the events it subscribes to are posted to, signed with Secret. An inactive
+// endpoint gets no new events
+type WebhookEndpoint struct {
+	ID        int
+	URL       string
+	Secret    string
+	Events    []string
+	Active    bool
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+// Subscribed reports whether the endpoint gets event
+func (e WebhookEndpoint) Subscribed(event string) bool {
+	for _, s := range e.Events {
+		if s == event {
+			return true
+		}
+	}
+	return false
+}
+
+// Statuses of a webhook delivery. Failed deliveries gave up after too many attempts and wait to be
+// redelivered
+const (
+	WebhookPending   = "pending"
+	WebhookDelivered = "delivered"
+	WebhookFailed    = "failed"
+)
+
+// WebhookDelivery is an event queued for an endpoint, which goes through the webhook statuses. URL and Secret are those of the endpoint when the delivery was loaded, and ResponseStatus is the HTTP
+// status of the last attempt, 0 if there was no response
+type WebhookDelivery struct {
+	ID             int
+	EndpointID     int
+	URL            string
+	Secret         string
+	Event          string
+	Payload        string
+	Status         string
+	Attempts       int
+	NextAttemptAt  time.Time
+	ResponseStatus int
+	LastError      string
+	DeliveredAt    time.Time
+	CreatedAt      time.Time
+	UpdatedAt      time.Time
+}
@@ -0,0 +1,169 @@
+package models
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestReservationStatusesFrom(t *testing.T) {
+	var testCases = []struct {
+		status string
+		from   []string
+	}{
+		{ReservationPending, nil},
+		{ReservationConfirmed, []string{ReservationPending}},
+		{ReservationCheckedIn, []string{ReservationConfirmed}},
+		{ReservationCheckedOut, []string{ReservationCheckedIn}},
+		{ReservationCancelled, []string{ReservationPending, ReservationConfirmed}},
+		{ReservationNoShow, []string{ReservationConfirmed}},
+	}
+
+	for _, testCase := range testCases {
+		if got := ReservationStatusesFrom(testCase.status); !reflect.DeepEqual(got, testCase.from) {
+			t.Errorf("statuses leading to %s: got %v, want %v", testCase.status, got, testCase.from)
+		}
+	}
+}
+
+func TestReservation_Transitions(t *testing.T) {
+	for _, status := range []string{ReservationCheckedOut, ReservationCancelled, ReservationNoShow} {
+		if got := (Reservation{Status: status}).Transitions(); len(got) != 0 {
+			t.Errorf("%s should be final, got %v", status, got)
+		}
+	}
+
+	for _, status := range ReservationStatuses {
+		for _, tr := range (Reservation{Status: status}).Transitions() {
+			if !ValidReservationStatus(tr.To) {
+				t.Errorf("transition from %s to unknown status %s", status, tr.To)
+			}
+		}
+	}
+}
+
+func TestReservationQuery_Paging(t *testing.T) {
+	var testCases = []struct {
+		query  ReservationQuery
+		total  int
+		limit  int
+		offset int
+		pages  int
+	}{
+		{ReservationQuery{}, 0, DefaultPageSize, 0, 1},
+		{ReservationQuery{Page: 3, PageSize: 10}, 21, 10, 20, 3},
+		{ReservationQuery{Page: 2, PageSize: 1000}, 250, MaxPageSize, MaxPageSize, 3},
+		{ReservationQuery{Page: -1, PageSize: 10}, 10, 10, 0, 1},
+	}
+
+	for _, testCase := range testCases {
+		q := testCase.query
+		if q.Limit() != testCase.limit || q.Offset() != testCase.offset || q.Pages(testCase.total) != testCase.pages {
+			t.Errorf("%+v with %d total: got limit %d, offset %d, pages %d", q, testCase.total, q.Limit(), q.Offset(), q.Pages(testCase.total))
+		}
+	}
+}
+
+func TestRoomOccupancy_Rate(t *testing.T) {
+	var testCases = []struct {
+		name string
+		o    RoomOccupancy
+		want float64
+	}{
+		{"Empty", RoomOccupancy{Nights: 10}, 0},
+		{"Half booked", RoomOccupancy{Nights: 10, ReservedNights: 3, ExternalNights: 2}, 0.5},
+		{"Blocks are not sellable", RoomOccupancy{Nights: 10, ReservedNights: 4, BlockedNights: 2}, 0.5},
+		{"All blocked", RoomOccupancy{Nights: 10, BlockedNights: 10}, 0},
+	}
+
+	for _, testCase := range testCases {
+		if got := testCase.o.Rate(); got != testCase.want {
+			t.Errorf("Rate for (%s): got %v, want %v", testCase.name, got, testCase.want)
+		}
+	}
+}
+
+func TestAddLeadTime(t *testing.T) {
+	buckets := LeadTimeBuckets()
+	for _, days := range []int{-2, 0, 1, 7, 8, 30, 31, 90, 91, 400} {
+		AddLeadTime(buckets, days, 1)
+	}
+
+	want := []int{1, 2, 2, 2, 2}
+	for i, b := range buckets {
+		if b.Count != want[i] {
+			t.Errorf("bucket %s: got %d, want %d", b.Label, b.Count, want[i])
+		}
+	}
+}
+
+func TestNormalizeGuestContact(t *testing.T) {
+	if got := NormalizeEmail("  John.Smith@Example.COM "); got != "john.smith@example.com" {
+		t.Errorf("NormalizeEmail: got %q", got)
+	}
+
+	var testCases = []struct {
+		phone string
+		want  string
+	}{
+		{"+44 (0)20 7946-0018", "+4402079460018"},
+		{"555.123.4567", "5551234567"},
+		{" +1 555 123 4567", "+15551234567"},
+		{"n/a", ""},
+		{"+", ""},
+	}
+
+	for _, testCase := range testCases {
+		if got := NormalizePhone(testCase.phone); got != testCase.want {
+			t.Errorf("NormalizePhone(%q): got %q, want %q", testCase.phone, got, testCase.want)
+		}
+	}
+}
+
+func TestPropertySettings_From(t *testing.T) {
+	var testCases = []struct {
+		name     string
+		settings PropertySettings
+		expected string
+	}{
+		{"Address only", PropertySettings{SenderEmail: "desk@fortsmythe.com"}, "desk@fortsmythe.com"},
+		{"With name", PropertySettings{SenderName: "Fort Smythe", SenderEmail: "desk@fortsmythe.com"}, `"Fort Smythe" <desk@fortsmythe.com>`},
+	}
+
+	for _, testCase := range testCases {
+		if got := testCase.settings.From(); got != testCase.expected {
+			t.Errorf("From for (%s): got %q, want %q", testCase.name, got, testCase.expected)
+		}
+	}
+}
+
+func TestScheduledJob_NextRun(t *testing.T) {
+	job := ScheduledJob{Hour: 9}
+
+	var testCases = []struct {
+		name     string
+		now      time.Time
+		expected time.Time
+	}{
+		{"Before the hour", time.Date(2050, 1, 1, 8, 30, 0, 0, time.UTC), time.Date(2050, 1, 1, 9, 0, 0, 0, time.UTC)},
+		{"At the hour", time.Date(2050, 1, 1, 9, 0, 0, 0, time.UTC), time.Date(2050, 1, 2, 9, 0, 0, 0, time.UTC)},
+		{"After the hour", time.Date(2050, 1, 31, 17, 0, 0, 0, time.UTC), time.Date(2050, 2, 1, 9, 0, 0, 0, time.UTC)},
+	}
+
+	for _, testCase := range testCases {
+		if got := job.NextRun(testCase.now); !got.Equal(testCase.expected) {
+			t.Errorf("NextRun for (%s): got %s, want %s", testCase.name, got, testCase.expected)
+		}
+	}
+}
+
+func TestWebhookEndpoint_Subscribed(t *testing.T) {
+	endpoint := WebhookEndpoint{Events: []string{WebhookReservationCreated, WebhookBlockCreated}}
+
+	if !endpoint.Subscribed(WebhookReservationCreated) || !endpoint.Subscribed(WebhookBlockCreated) {
+		t.Error("the endpoint should get the events it subscribes to")
+	}
+	if endpoint.Subscribed(WebhookReservationCancelled) {
+		t.Error("the endpoint should not get other events")
+	}
+}
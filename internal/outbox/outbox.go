@@ -0,0 +1,97 @@
+// Package outbox holds the loop shared by the workers draining the mail, SMS and webhook outboxes: claim
+// what is due a batch at a time, deliver it, and retry failures with a growing delay
+package outbox
+
+import (
+	"sync"
+	"time"
+)
+
+// Start runs deliverDue now and then every interval, until stop is closed
+func Start(interval time.Duration, stop <-chan struct{}, deliverDue func() (int, error)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		_, _ = deliverDue()
+		select {
+		case <-ticker.C:
+		case <-stop:
+			return
+		}
+	}
+}
+
+// DeliverDue claims the items due batchSize at a time and hands them to deliver over workers goroutines,
+// until a claim comes back short. It returns how many deliver reported delivered
+func DeliverDue[T any](batchSize, workers int, claim func(limit int) ([]T, error), deliver func(T) bool) (int, error) {
+	delivered := 0
+	for {
+		items, err := claim(batchSize)
+		if err != nil {
+			return delivered, err
+		}
+
+		delivered += deliverAll(items, workers, deliver)
+
+		if len(items) < batchSize {
+			return delivered, nil
+		}
+	}
+}
+
+// deliverAll delivers items over a pool of workers goroutines, one after the other if there is a single
+// worker, and returns how many were delivered
+func deliverAll[T any](items []T, workers int, deliver func(T) bool) int {
+	if workers <= 1 {
+		delivered := 0
+		for _, item := range items {
+			if deliver(item) {
+				delivered++
+			}
+		}
+		return delivered
+	}
+
+	jobs := make(chan T)
+	results := make(chan bool, len(items))
+
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for item := range jobs {
+				results <- deliver(item)
+			}
+		}()
+	}
+
+	for _, item := range items {
+		jobs <- item
+	}
+	close(jobs)
+	wg.Wait()
+	close(results)
+
+	delivered := 0
+	for ok := range results {
+		if ok {
+			delivered++
+		}
+	}
+	return delivered
+}
+
+// Backoff returns how long to wait after the given number of failed attempts: min after the first,
+// doubling with each one after it, up to max
+func Backoff(attempts int, min, max time.Duration) time.Duration {
+	d := min
+	for i := 1; i < attempts && d < max; i++ {
+		d *= 2
+	}
+	if d > max {
+		d = max
+	}
+	return d
+}
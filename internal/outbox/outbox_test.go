@@ -0,0 +1,65 @@
+package outbox
+
+import (
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestDeliverDue(t *testing.T) {
+	for _, workers := range []int{1, 3} {
+		queue := []int{1, 2, 3, 4, 5}
+		claims := 0
+		claim := func(limit int) ([]int, error) {
+			claims++
+			n := limit
+			if n > len(queue) {
+				n = len(queue)
+			}
+			items := queue[:n]
+			queue = queue[n:]
+			return items, nil
+		}
+		var seen int32
+		deliver := func(item int) bool {
+			atomic.AddInt32(&seen, 1)
+			return item%2 == 1
+		}
+
+		delivered, err := DeliverDue(2, workers, claim, deliver)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if delivered != 3 || seen != 5 || claims != 3 {
+			t.Errorf("%d workers: delivered %d of %d in %d claims, want 3 of 5 in 3", workers, delivered, seen, claims)
+		}
+	}
+}
+
+func TestDeliverDue_ClaimError(t *testing.T) {
+	claim := func(limit int) ([]int, error) { return nil, errors.New("database down") }
+	deliver := func(item int) bool { return true }
+
+	if _, err := DeliverDue(2, 1, claim, deliver); err == nil {
+		t.Error("expected the claim error")
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	var testCases = []struct {
+		attempts int
+		want     time.Duration
+	}{
+		{1, time.Minute},
+		{2, 2 * time.Minute},
+		{3, 4 * time.Minute},
+		{10, time.Hour},
+	}
+
+	for _, testCase := range testCases {
+		if got := Backoff(testCase.attempts, time.Minute, time.Hour); got != testCase.want {
+			t.Errorf("Backoff after %d attempts: got %s, want %s", testCase.attempts, got, testCase.want)
+		}
+	}
+}
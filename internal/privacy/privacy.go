@@ -0,0 +1,100 @@
+// Package privacy writes everything held about a guest, for answering data access requests
+package privacy
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/go-course/bookings/internal/export"
+	"github.com/go-course/bookings/internal/models"
+)
+
+// Formats that guest data can be exported in
+const (
+	JSON = "json"
+	ZIP  = "zip"
+)
+
+// Export is the document handed to a guest
+type Export struct {
+	ExportedAt time.Time
+	models.GuestData
+}
+
+// ValidFormat reports whether guest data can be exported in format
+func ValidFormat(format string) bool {
+	return format == JSON || format == ZIP
+}
+
+// ContentType returns the media type of format
+func ContentType(format string) string {
+	if format == ZIP {
+		return "application/zip"
+	}
+	return "application/json"
+}
+
+// FileName returns the name guest data is downloaded as
+func FileName(format string) string {
+	return "guest-data." + format
+}
+
+// Write writes data in format to w. A ZIP bundle holds the JSON document and the reservations as CSV
+func Write(format string, w io.Writer, data models.GuestData, exportedAt time.Time) error {
+	doc := Export{ExportedAt: exportedAt, GuestData: data}
+
+	switch format {
+	case JSON:
+		return writeJSON(w, doc)
+
+	case ZIP:
+		z := zip.NewWriter(w)
+
+		f, err := z.Create(FileName(JSON))
+		if err != nil {
+			return err
+		}
+		if err := writeJSON(f, doc); err != nil {
+			return err
+		}
+
+		f, err = z.Create("reservations.csv")
+		if err != nil {
+			return err
+		}
+		if err := writeReservations(f, data.Reservations); err != nil {
+			return err
+		}
+
+		return z.Close()
+	}
+
+	return fmt.Errorf("unknown guest data format %q", format)
+}
+
+// writeJSON writes doc as indented JSON
+func writeJSON(w io.Writer, doc Export) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(doc)
+}
+
+// writeReservations writes reservations as a CSV table
+func writeReservations(w io.Writer, reservations []models.Reservation) error {
+	out := export.NewCSV(w)
+	err := out.WriteRow("ID", "First Name", "Last Name", "Email", "Phone", "Room", "Arrival", "Departure", "Status", "Booked")
+	if err != nil {
+		return err
+	}
+	for _, r := range reservations {
+		err := out.WriteRow(r.ID, r.FirstName, r.LastName, r.Email, r.Phone, r.Room.RoomName, r.StartDate, r.EndDate,
+			r.Status, r.CreatedAt)
+		if err != nil {
+			return err
+		}
+	}
+	return out.Close()
+}
@@ -0,0 +1,89 @@
+package privacy
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-course/bookings/internal/models"
+)
+
+func guestData() models.GuestData {
+	return models.GuestData{
+		Email:  "john@smith.com",
+		Guests: []models.Guest{{ID: 1, FirstName: "John", LastName: "Smith", Email: "john@smith.com"}},
+		Reservations: []models.Reservation{{
+			ID:        7,
+			FirstName: "John",
+			LastName:  "Smith",
+			Email:     "john@smith.com",
+			StartDate: time.Date(2050, 1, 10, 0, 0, 0, 0, time.UTC),
+			EndDate:   time.Date(2050, 1, 12, 0, 0, 0, 0, time.UTC),
+			Status:    models.ReservationConfirmed,
+			Room:      models.Room{RoomName: "Generals Quarters"},
+		}},
+		RegistrationCards: []models.RegistrationCard{{ReservationID: 7, IDNumber: "X1234567"}},
+		TextMessages:      []models.SMSMessage{{ID: 1, SMSData: models.SMSData{To: "+15550102030", Body: "See you"}}},
+	}
+}
+
+func TestWrite_JSON(t *testing.T) {
+	var buf bytes.Buffer
+	exportedAt := time.Date(2050, 2, 1, 12, 0, 0, 0, time.UTC)
+	if err := Write(JSON, &buf, guestData(), exportedAt); err != nil {
+		t.Fatal(err)
+	}
+
+	var doc Export
+	if err := json.Unmarshal(buf.Bytes(), &doc); err != nil {
+		t.Fatal(err)
+	}
+	if !doc.ExportedAt.Equal(exportedAt) || doc.Email != "john@smith.com" || len(doc.Reservations) != 1 ||
+		doc.RegistrationCards[0].IDNumber != "X1234567" || doc.TextMessages[0].To != "+15550102030" {
+		t.Errorf("wrong export: %+v", doc)
+	}
+}
+
+func TestWrite_ZIP(t *testing.T) {
+	var buf bytes.Buffer
+	if err := Write(ZIP, &buf, guestData(), time.Now()); err != nil {
+		t.Fatal(err)
+	}
+
+	z, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	files := make(map[string]string)
+	for _, f := range z.File {
+		r, err := f.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, _ := io.ReadAll(r)
+		r.Close()
+		files[f.Name] = string(b)
+	}
+
+	if !strings.Contains(files["guest-data.json"], `"IDNumber": "X1234567"`) {
+		t.Errorf("bundle should hold the JSON document, got %q", files["guest-data.json"])
+	}
+	want := "7,John,Smith,john@smith.com,,Generals Quarters,2050-01-10,2050-01-12,confirmed,\n"
+	if !strings.HasSuffix(files["reservations.csv"], want) {
+		t.Errorf("wrong reservations.csv: got %q, want suffix %q", files["reservations.csv"], want)
+	}
+}
+
+func TestWrite_UnknownFormat(t *testing.T) {
+	if err := Write("xml", io.Discard, guestData(), time.Now()); err == nil {
+		t.Error("expected an error for an unknown format")
+	}
+	if ValidFormat("xml") || !ValidFormat(ZIP) {
+		t.Error("ValidFormat is wrong")
+	}
+}
@@ -0,0 +1,399 @@
+// Package reports aggregates reservations and room restrictions into management reports. The
+// aggregations are pure functions over data loaded beforehand, and every report comes out as a Table
+// that can be rendered, printed or exported
+package reports
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/go-course/bookings/internal/models"
+)
+
+// Report describes a report
+type Report struct {
+	Name  string
+	Title string
+}
+
+// Reports lists the reports in the order they are shown
+var Reports = []Report{
+	{"occupancy", "Occupancy by Room and Month"},
+	{"stays", "Length of Stay"},
+	{"cancellations", "Cancellations"},
+	{"pace", "Booking Pace"},
+	{"sources", "Booking Sources"},
+}
+
+// Find returns the report called name
+func Find(name string) (Report, bool) {
+	for _, r := range Reports {
+		if r.Name == name {
+			return r, true
+		}
+	}
+	return Report{}, false
+}
+
+// Data is what reports are computed from, for the nights from From up to, but not including, To
+type Data struct {
+	From time.Time
+	To   time.Time
+	// AsOf is when the books are looked at, for the booking pace
+	AsOf  time.Time
+	Rooms []models.Room
+	// Reservations arriving in the period, and in the same period a year before
+	Reservations []models.Reservation
+	LastYear     []models.Reservation
+	// Restrictions overlapping the period
+	Restrictions []models.RoomRestriction
+	Sources      []models.ICalSource
+}
+
+// Table is a computed report. Cells are strings, ints or float64s rounded for display
+type Table struct {
+	Title   string
+	Columns []string
+	Rows    [][]interface{}
+}
+
+// Build computes the report called name
+func Build(name string, d Data) (Table, error) {
+	report, ok := Find(name)
+	if !ok {
+		return Table{}, fmt.Errorf("unknown report %q", name)
+	}
+
+	var t Table
+	switch name {
+	case "occupancy":
+		t = occupancyTable(OccupancyByMonth(d.Rooms, d.Restrictions, d.From, d.To))
+	case "stays":
+		t = staysTable(LengthOfStay(d.Reservations))
+	case "cancellations":
+		t = cancellationsTable(Cancellations(d.Reservations, d.From, d.To))
+	case "pace":
+		t = paceTable(BookingPace(d.Reservations, d.LastYear, d.From, d.To, d.AsOf))
+	case "sources":
+		t = sourcesTable(BookingSources(d.Restrictions, d.Sources, d.From, d.To))
+	}
+	t.Title = report.Title
+	return t, nil
+}
+
+// RoomMonth is the occupancy of a room in a month
+type RoomMonth struct {
+	Month     time.Time
+	Occupancy models.RoomOccupancy
+}
+
+// OccupancyByMonth returns the occupancy of each room in each month of the period, by room then month.
+// Months at the edges only count their nights inside the period
+func OccupancyByMonth(rooms []models.Room, restrictions []models.RoomRestriction, from, to time.Time) []RoomMonth {
+	var result []RoomMonth
+	for _, room := range rooms {
+		for _, m := range months(from, to) {
+			start, end := clamp(m, m.AddDate(0, 1, 0), from, to)
+			o := models.RoomOccupancy{Room: room, Nights: nights(start, end)}
+			reservations := make(map[int]bool)
+
+			for _, rr := range restrictions {
+				if rr.RoomID != room.ID {
+					continue
+				}
+				n := overlap(rr.StartDate, rr.EndDate, start, end)
+				if n == 0 {
+					continue
+				}
+				switch rr.RestrictionID {
+				case models.RestrictionReservation:
+					o.ReservedNights += n
+					reservations[rr.ReservationID] = true
+				case models.RestrictionExternalBooking:
+					o.ExternalNights += n
+				case models.RestrictionOwnerBlock:
+					o.BlockedNights += n
+				}
+			}
+			o.Reservations = len(reservations)
+
+			result = append(result, RoomMonth{Month: m, Occupancy: o})
+		}
+	}
+	return result
+}
+
+// Stay is the length of stay of the reservations of a room
+type Stay struct {
+	Room         string
+	Reservations int
+	Nights       int
+}
+
+// Average returns the average number of nights per reservation
+func (s Stay) Average() float64 {
+	if s.Reservations == 0 {
+		return 0
+	}
+	return float64(s.Nights) / float64(s.Reservations)
+}
+
+// LengthOfStay returns the stays of each room, in the order rooms are first seen, followed by all
+// rooms together. Cancelled and no-show reservations are left out
+func LengthOfStay(reservations []models.Reservation) []Stay {
+	var stays []Stay
+	index := make(map[int]int)
+	total := Stay{Room: "All rooms"}
+
+	for _, res := range reservations {
+		if !stayed(res) {
+			continue
+		}
+		i, ok := index[res.RoomID]
+		if !ok {
+			i = len(stays)
+			index[res.RoomID] = i
+			stays = append(stays, Stay{Room: res.Room.RoomName})
+		}
+		n := nights(res.StartDate, res.EndDate)
+		stays[i].Reservations++
+		stays[i].Nights += n
+		total.Reservations++
+		total.Nights += n
+	}
+
+	return append(stays, total)
+}
+
+// MonthCancellations counts the reservations arriving in a month by outcome
+type MonthCancellations struct {
+	Month        time.Time
+	Reservations int
+	Cancelled    int
+	NoShows      int
+}
+
+// Rate returns the share of the reservations which were cancelled or didn't show up
+func (c MonthCancellations) Rate() float64 {
+	if c.Reservations == 0 {
+		return 0
+	}
+	return float64(c.Cancelled+c.NoShows) / float64(c.Reservations)
+}
+
+// Cancellations counts the reservations of each month of the period by the month they arrive in
+func Cancellations(reservations []models.Reservation, from, to time.Time) []MonthCancellations {
+	var result []MonthCancellations
+	for _, m := range months(from, to) {
+		c := MonthCancellations{Month: m}
+		for _, res := range reservations {
+			if !sameMonth(res.StartDate, m) {
+				continue
+			}
+			c.Reservations++
+			switch res.Status {
+			case models.ReservationCancelled:
+				c.Cancelled++
+			case models.ReservationNoShow:
+				c.NoShows++
+			}
+		}
+		result = append(result, c)
+	}
+	return result
+}
+
+// MonthPace compares the reservations on the books for a month with the same month a year before,
+// looked at the same time before
+type MonthPace struct {
+	Month    time.Time
+	OnBooks  int
+	LastYear int
+}
+
+// Change returns how much the reservations on the books grew since last year, or 0 without last year's
+func (p MonthPace) Change() float64 {
+	if p.LastYear == 0 {
+		return 0
+	}
+	return float64(p.OnBooks-p.LastYear) / float64(p.LastYear)
+}
+
+// BookingPace counts, for each month of the period, the reservations arriving in it that were on the
+// books at asOf, against the reservations arriving a year before that were on the books a year before asOf
+func BookingPace(reservations, lastYear []models.Reservation, from, to, asOf time.Time) []MonthPace {
+	var result []MonthPace
+	for _, m := range months(from, to) {
+		p := MonthPace{Month: m}
+		for _, res := range reservations {
+			if sameMonth(res.StartDate, m) && onBooks(res, asOf) {
+				p.OnBooks++
+			}
+		}
+		for _, res := range lastYear {
+			if sameMonth(res.StartDate, m.AddDate(-1, 0, 0)) && onBooks(res, asOf.AddDate(-1, 0, 0)) {
+				p.LastYear++
+			}
+		}
+		result = append(result, p)
+	}
+	return result
+}
+
+// Source counts the bookings and nights of a booking source
+type Source struct {
+	Name     string
+	Bookings int
+	Nights   int
+}
+
+// BookingSources counts the nights of the period sold directly and through each external calendar
+func BookingSources(restrictions []models.RoomRestriction, sources []models.ICalSource, from, to time.Time) []Source {
+	names := make(map[int]string)
+	for _, s := range sources {
+		names[s.ID] = s.Name
+	}
+
+	result := []Source{{Name: "Direct"}}
+	index := make(map[string]int)
+	for _, rr := range restrictions {
+		n := overlap(rr.StartDate, rr.EndDate, from, to)
+		if n == 0 {
+			continue
+		}
+
+		var name string
+		switch rr.RestrictionID {
+		case models.RestrictionReservation:
+			name = "Direct"
+		case models.RestrictionExternalBooking:
+			name = names[rr.SourceID]
+			if name == "" {
+				name = "Other channels"
+			}
+		default:
+			continue
+		}
+
+		i, ok := index[name]
+		if !ok && name != "Direct" {
+			i = len(result)
+			index[name] = i
+			result = append(result, Source{Name: name})
+		}
+		result[i].Bookings++
+		result[i].Nights += n
+	}
+	return result
+}
+
+func occupancyTable(rows []RoomMonth) Table {
+	t := Table{Columns: []string{"Room", "Month", "Nights", "Reserved", "External", "Blocked", "Reservations", "Occupancy %"}}
+	for _, r := range rows {
+		o := r.Occupancy
+		t.Rows = append(t.Rows, []interface{}{o.Room.RoomName, r.Month.Format("2006-01"), o.Nights, o.ReservedNights,
+			o.ExternalNights, o.BlockedNights, o.Reservations, percent(o.Rate())})
+	}
+	return t
+}
+
+func staysTable(rows []Stay) Table {
+	t := Table{Columns: []string{"Room", "Reservations", "Nights", "Average Nights"}}
+	for _, s := range rows {
+		t.Rows = append(t.Rows, []interface{}{s.Room, s.Reservations, s.Nights, round(s.Average())})
+	}
+	return t
+}
+
+func cancellationsTable(rows []MonthCancellations) Table {
+	t := Table{Columns: []string{"Month", "Reservations", "Cancelled", "No-Shows", "Cancellation %"}}
+	for _, c := range rows {
+		t.Rows = append(t.Rows, []interface{}{c.Month.Format("2006-01"), c.Reservations, c.Cancelled, c.NoShows, percent(c.Rate())})
+	}
+	return t
+}
+
+func paceTable(rows []MonthPace) Table {
+	t := Table{Columns: []string{"Month", "On the Books", "Last Year", "Change %"}}
+	for _, p := range rows {
+		t.Rows = append(t.Rows, []interface{}{p.Month.Format("2006-01"), p.OnBooks, p.LastYear, percent(p.Change())})
+	}
+	return t
+}
+
+func sourcesTable(rows []Source) Table {
+	total := 0
+	for _, s := range rows {
+		total += s.Nights
+	}
+
+	t := Table{Columns: []string{"Source", "Bookings", "Nights", "Share of Nights %"}}
+	for _, s := range rows {
+		share := 0.0
+		if total > 0 {
+			share = float64(s.Nights) / float64(total)
+		}
+		t.Rows = append(t.Rows, []interface{}{s.Name, s.Bookings, s.Nights, percent(share)})
+	}
+	return t
+}
+
+// stayed reports whether the guest of res came
+func stayed(res models.Reservation) bool {
+	return res.Status != models.ReservationCancelled && res.Status != models.ReservationNoShow
+}
+
+// onBooks reports whether res had been made, and not yet cancelled, at t
+func onBooks(res models.Reservation, t time.Time) bool {
+	if res.CreatedAt.After(t) {
+		return false
+	}
+	return res.Status != models.ReservationCancelled || res.CancelledAt.IsZero() || res.CancelledAt.After(t)
+}
+
+// months returns the first day of each month with nights from from up to, but not including, to
+func months(from, to time.Time) []time.Time {
+	var result []time.Time
+	for m := time.Date(from.Year(), from.Month(), 1, 0, 0, 0, 0, time.UTC); m.Before(to); m = m.AddDate(0, 1, 0) {
+		result = append(result, m)
+	}
+	return result
+}
+
+func sameMonth(t, month time.Time) bool {
+	return t.Year() == month.Year() && t.Month() == month.Month()
+}
+
+// clamp returns the part of start to end inside from to to
+func clamp(start, end, from, to time.Time) (time.Time, time.Time) {
+	if start.Before(from) {
+		start = from
+	}
+	if end.After(to) {
+		end = to
+	}
+	return start, end
+}
+
+// nights returns the nights from start to end, 0 if end isn't after start
+func nights(start, end time.Time) int {
+	if !end.After(start) {
+		return 0
+	}
+	return int(math.Round(end.Sub(start).Hours() / 24))
+}
+
+// overlap returns the nights the stay from start to end spends from from to to
+func overlap(start, end, from, to time.Time) int {
+	start, end = clamp(start, end, from, to)
+	return nights(start, end)
+}
+
+func percent(f float64) float64 {
+	return math.Round(f*1000) / 10
+}
+
+func round(f float64) float64 {
+	return math.Round(f*10) / 10
+}
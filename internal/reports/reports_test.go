@@ -0,0 +1,165 @@
+package reports
+
+import (
+	"testing"
+	"time"
+
+	"github.com/go-course/bookings/internal/models"
+)
+
+func date(s string) time.Time {
+	t, _ := time.Parse("2006-01-02", s)
+	return t
+}
+
+var rooms = []models.Room{{ID: 1, RoomName: "Generals Quarters"}, {ID: 2, RoomName: "Majors Suite"}}
+
+func TestOccupancyByMonth(t *testing.T) {
+	restrictions := []models.RoomRestriction{
+		// 3 nights in January, 2 in February
+		{RoomID: 1, ReservationID: 1, RestrictionID: models.RestrictionReservation, StartDate: date("2050-01-29"), EndDate: date("2050-02-03")},
+		{RoomID: 1, RestrictionID: models.RestrictionExternalBooking, StartDate: date("2050-01-10"), EndDate: date("2050-01-12")},
+		{RoomID: 1, RestrictionID: models.RestrictionOwnerBlock, StartDate: date("2050-01-20"), EndDate: date("2050-01-21")},
+		{RoomID: 2, ReservationID: 2, RestrictionID: models.RestrictionReservation, StartDate: date("2050-02-10"), EndDate: date("2050-02-11")},
+	}
+
+	// the period starts mid January
+	got := OccupancyByMonth(rooms, restrictions, date("2050-01-16"), date("2050-03-01"))
+	if len(got) != 4 {
+		t.Fatalf("got %d room months, want 4", len(got))
+	}
+
+	jan := got[0].Occupancy
+	if jan.Nights != 16 || jan.ReservedNights != 3 || jan.ExternalNights != 0 || jan.BlockedNights != 1 || jan.Reservations != 1 {
+		t.Errorf("wrong January occupancy: %+v", jan)
+	}
+	feb := got[1].Occupancy
+	if feb.Nights != 28 || feb.ReservedNights != 2 || feb.Reservations != 1 {
+		t.Errorf("wrong February occupancy: %+v", feb)
+	}
+	if got[3].Occupancy.Room.ID != 2 || got[3].Occupancy.ReservedNights != 1 {
+		t.Errorf("wrong room 2 occupancy: %+v", got[3])
+	}
+}
+
+func TestLengthOfStay(t *testing.T) {
+	reservations := []models.Reservation{
+		{RoomID: 1, Room: rooms[0], Status: models.ReservationCheckedOut, StartDate: date("2050-01-01"), EndDate: date("2050-01-03")},
+		{RoomID: 1, Room: rooms[0], Status: models.ReservationConfirmed, StartDate: date("2050-01-05"), EndDate: date("2050-01-10")},
+		{RoomID: 2, Room: rooms[1], Status: models.ReservationConfirmed, StartDate: date("2050-01-05"), EndDate: date("2050-01-06")},
+		{RoomID: 2, Room: rooms[1], Status: models.ReservationCancelled, StartDate: date("2050-01-05"), EndDate: date("2050-01-25")},
+	}
+
+	got := LengthOfStay(reservations)
+	want := []Stay{
+		{"Generals Quarters", 2, 7},
+		{"Majors Suite", 1, 1},
+		{"All rooms", 3, 8},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got %+v, want %+v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("got %+v, want %+v", got[i], want[i])
+		}
+	}
+	if got[0].Average() != 3.5 {
+		t.Errorf("wrong average: %v", got[0].Average())
+	}
+}
+
+func TestCancellations(t *testing.T) {
+	reservations := []models.Reservation{
+		{Status: models.ReservationConfirmed, StartDate: date("2050-01-01")},
+		{Status: models.ReservationCancelled, StartDate: date("2050-01-15")},
+		{Status: models.ReservationNoShow, StartDate: date("2050-01-31")},
+		{Status: models.ReservationCancelled, StartDate: date("2050-02-01")},
+	}
+
+	got := Cancellations(reservations, date("2050-01-01"), date("2050-03-01"))
+	if len(got) != 2 {
+		t.Fatalf("got %d months, want 2", len(got))
+	}
+	if got[0].Reservations != 3 || got[0].Cancelled != 1 || got[0].NoShows != 1 {
+		t.Errorf("wrong January: %+v", got[0])
+	}
+	if got[1].Rate() != 1 {
+		t.Errorf("wrong February rate: %v", got[1].Rate())
+	}
+}
+
+func TestBookingPace(t *testing.T) {
+	asOf := date("2050-01-10")
+	reservations := []models.Reservation{
+		{Status: models.ReservationConfirmed, StartDate: date("2050-02-01"), CreatedAt: date("2050-01-01")},
+		{Status: models.ReservationConfirmed, StartDate: date("2050-02-02"), CreatedAt: date("2050-01-05")},
+		// made after asOf
+		{Status: models.ReservationConfirmed, StartDate: date("2050-02-03"), CreatedAt: date("2050-01-20")},
+		// cancelled before asOf
+		{Status: models.ReservationCancelled, StartDate: date("2050-02-04"), CreatedAt: date("2050-01-01"), CancelledAt: date("2050-01-02")},
+		// cancelled after asOf, still on the books back then
+		{Status: models.ReservationCancelled, StartDate: date("2050-02-05"), CreatedAt: date("2050-01-01"), CancelledAt: date("2050-01-20")},
+	}
+	lastYear := []models.Reservation{
+		{Status: models.ReservationCheckedOut, StartDate: date("2049-02-01"), CreatedAt: date("2049-01-01")},
+		{Status: models.ReservationCheckedOut, StartDate: date("2049-02-10"), CreatedAt: date("2049-01-30")},
+	}
+
+	got := BookingPace(reservations, lastYear, date("2050-02-01"), date("2050-03-01"), asOf)
+	if len(got) != 1 {
+		t.Fatalf("got %d months, want 1", len(got))
+	}
+	if got[0].OnBooks != 3 || got[0].LastYear != 1 || got[0].Change() != 2 {
+		t.Errorf("wrong pace: %+v, change %v", got[0], got[0].Change())
+	}
+}
+
+func TestBookingSources(t *testing.T) {
+	restrictions := []models.RoomRestriction{
+		{RestrictionID: models.RestrictionReservation, StartDate: date("2050-01-01"), EndDate: date("2050-01-03")},
+		{RestrictionID: models.RestrictionExternalBooking, SourceID: 4, StartDate: date("2050-01-05"), EndDate: date("2050-01-06")},
+		{RestrictionID: models.RestrictionExternalBooking, SourceID: 4, StartDate: date("2050-01-30"), EndDate: date("2050-02-05")},
+		{RestrictionID: models.RestrictionExternalBooking, SourceID: 9, StartDate: date("2050-01-07"), EndDate: date("2050-01-08")},
+		{RestrictionID: models.RestrictionOwnerBlock, StartDate: date("2050-01-10"), EndDate: date("2050-01-11")},
+	}
+	sources := []models.ICalSource{{ID: 4, Name: "Channel"}}
+
+	got := BookingSources(restrictions, sources, date("2050-01-01"), date("2050-02-01"))
+	want := []Source{
+		{"Direct", 1, 2},
+		{"Channel", 2, 3},
+		{"Other channels", 1, 1},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got %+v, want %+v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("got %+v, want %+v", got[i], want[i])
+		}
+	}
+}
+
+func TestBuild(t *testing.T) {
+	d := Data{From: date("2050-01-01"), To: date("2050-02-01"), Rooms: rooms}
+
+	for _, r := range Reports {
+		table, err := Build(r.Name, d)
+		if err != nil {
+			t.Errorf("%s: %s", r.Name, err)
+		}
+		if table.Title != r.Title || len(table.Columns) == 0 {
+			t.Errorf("%s: wrong table %+v", r.Name, table)
+		}
+		for _, row := range table.Rows {
+			if len(row) != len(table.Columns) {
+				t.Errorf("%s: row %v doesn't match columns %v", r.Name, row, table.Columns)
+			}
+		}
+	}
+
+	if _, err := Build("revenue", d); err == nil {
+		t.Error("expected error for an unknown report")
+	}
+}
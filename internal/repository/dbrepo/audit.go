@@ -0,0 +1,504 @@
+package dbrepo
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/go-course/bookings/internal/models"
+	"github.com/go-course/bookings/internal/repository"
+)
+
+// auditDBRepo records the changes made through it in the audit log. Each change and its audit events
+// run in one transaction, so a change is never saved without its record
+type auditDBRepo struct {
+	repository.DatabaseRepo
+	userID int
+}
+
+// NewAuditRepo wraps repo so that the changes made through it are recorded as made by userID
+func NewAuditRepo(repo repository.DatabaseRepo, userID int) repository.DatabaseRepo {
+	return &auditDBRepo{
+		DatabaseRepo: repo,
+		userID:       userID,
+	}
+}
+
+// record writes an audit event with repo, with before and after encoded as JSON unless they are nil
+func (m *auditDBRepo) record(repo repository.DatabaseRepo, action, entity string, entityID interface{}, before, after interface{}) error {
+	e := models.AuditEvent{
+		UserID:   m.userID,
+		Action:   action,
+		Entity:   entity,
+		EntityID: fmt.Sprint(entityID),
+	}
+
+	if before != nil {
+		b, err := json.Marshal(before)
+		if err != nil {
+			return err
+		}
+		e.Before = string(b)
+	}
+	if after != nil {
+		b, err := json.Marshal(after)
+		if err != nil {
+			return err
+		}
+		e.After = string(b)
+	}
+
+	return repo.InsertAuditEvent(e)
+}
+
+// withoutContact returns the reservation for the audit log, which only records whether the guest's email
+// address and phone number are set, as the log outlives the erasure of guest data
+func withoutContact(res models.Reservation) models.Reservation {
+	res.Email, res.Phone = redacted(res.Email), redacted(res.Phone)
+	return res
+}
+
+// guestWithoutContact returns the guest for the audit log, like withoutContact
+func guestWithoutContact(g models.Guest) models.Guest {
+	g.Email, g.Phone = redacted(g.Email), redacted(g.Phone)
+	return g
+}
+
+// redacted returns "(set)" in place of a value that is set
+func redacted(s string) string {
+	if s == "" {
+		return ""
+	}
+	return "(set)"
+}
+
+func (m *auditDBRepo) UpdateReservation(u models.Reservation) error {
+	return m.DatabaseRepo.Transaction(func(repo repository.DatabaseRepo) error {
+		before, err := repo.GetReservationById(u.ID)
+		if err != nil {
+			return err
+		}
+		if err := repo.UpdateReservation(u); err != nil {
+			return err
+		}
+		after, err := repo.GetReservationById(u.ID)
+		if err != nil {
+			return err
+		}
+		return m.record(repo, "reservation.update", "reservation", u.ID, withoutContact(before), withoutContact(after))
+	})
+}
+
+func (m *auditDBRepo) UpdateReservationStatus(id int, status string) error {
+	return m.DatabaseRepo.Transaction(func(repo repository.DatabaseRepo) error {
+		before, err := repo.GetReservationById(id)
+		if err != nil {
+			return err
+		}
+		if err := repo.UpdateReservationStatus(id, status); err != nil {
+			return err
+		}
+		after, err := repo.GetReservationById(id)
+		if err != nil {
+			return err
+		}
+		return m.record(repo, "reservation.status", "reservation", id, withoutContact(before), withoutContact(after))
+	})
+}
+
+func (m *auditDBRepo) CheckIn(id, userID int, card models.RegistrationCard) error {
+	return m.DatabaseRepo.Transaction(func(repo repository.DatabaseRepo) error {
+		before, err := repo.GetReservationById(id)
+		if err != nil {
+			return err
+		}
+		if err := repo.CheckIn(id, userID, card); err != nil {
+			return err
+		}
+		after, err := repo.GetReservationById(id)
+		if err != nil {
+			return err
+		}
+		// the registration card holds identity documents, so it is left out
+		return m.record(repo, "reservation.check_in", "reservation", id, withoutContact(before), withoutContact(after))
+	})
+}
+
+func (m *auditDBRepo) CheckOut(id, userID int) error {
+	return m.DatabaseRepo.Transaction(func(repo repository.DatabaseRepo) error {
+		before, err := repo.GetReservationById(id)
+		if err != nil {
+			return err
+		}
+		if err := repo.CheckOut(id, userID); err != nil {
+			return err
+		}
+		after, err := repo.GetReservationById(id)
+		if err != nil {
+			return err
+		}
+		return m.record(repo, "reservation.check_out", "reservation", id, withoutContact(before), withoutContact(after))
+	})
+}
+
+func (m *auditDBRepo) SaveRegistrationCard(card models.RegistrationCard) error {
+	return m.DatabaseRepo.Transaction(func(repo repository.DatabaseRepo) error {
+		if err := repo.SaveRegistrationCard(card); err != nil {
+			return err
+		}
+		// only record that the card changed, not the identity documents on it
+		return m.record(repo, "registration_card.update", "reservation", card.ReservationID, nil, nil)
+	})
+}
+
+func (m *auditDBRepo) UpdateGuest(g models.Guest) error {
+	return m.DatabaseRepo.Transaction(func(repo repository.DatabaseRepo) error {
+		before, err := repo.GetGuestByID(g.ID)
+		if err != nil {
+			return err
+		}
+		if err := repo.UpdateGuest(g); err != nil {
+			return err
+		}
+		after, err := repo.GetGuestByID(g.ID)
+		if err != nil {
+			return err
+		}
+		return m.record(repo, "guest.update", "guest", g.ID, guestWithoutContact(before), guestWithoutContact(after))
+	})
+}
+
+func (m *auditDBRepo) MergeGuests(keepID, duplicateID int) error {
+	return m.DatabaseRepo.Transaction(func(repo repository.DatabaseRepo) error {
+		duplicate, err := repo.GetGuestByID(duplicateID)
+		if err != nil {
+			return err
+		}
+		before, err := repo.GetGuestByID(keepID)
+		if err != nil {
+			return err
+		}
+		if err := repo.MergeGuests(keepID, duplicateID); err != nil {
+			return err
+		}
+		after, err := repo.GetGuestByID(keepID)
+		if err != nil {
+			return err
+		}
+		if err := m.record(repo, "guest.delete", "guest", duplicateID, guestWithoutContact(duplicate), nil); err != nil {
+			return err
+		}
+		return m.record(repo, "guest.merge", "guest", keepID, guestWithoutContact(before), guestWithoutContact(after))
+	})
+}
+
+func (m *auditDBRepo) DeleteReservation(id int) error {
+	return m.DatabaseRepo.Transaction(func(repo repository.DatabaseRepo) error {
+		before, err := repo.GetReservationById(id)
+		if err != nil {
+			return err
+		}
+		if err := repo.DeleteReservation(id); err != nil {
+			return err
+		}
+		return m.record(repo, "reservation.delete", "reservation", id, withoutContact(before), nil)
+	})
+}
+
+func (m *auditDBRepo) RestoreReservation(id int) error {
+	return m.DatabaseRepo.Transaction(func(repo repository.DatabaseRepo) error {
+		if err := repo.RestoreReservation(id); err != nil {
+			return err
+		}
+		after, err := repo.GetReservationById(id)
+		if err != nil {
+			return err
+		}
+		return m.record(repo, "reservation.restore", "reservation", id, nil, withoutContact(after))
+	})
+}
+
+func (m *auditDBRepo) ApplyBlockChanges(changes []models.BlockChange) ([]models.BlockConflict, error) {
+	var conflicts []models.BlockConflict
+	err := m.DatabaseRepo.Transaction(func(repo repository.DatabaseRepo) error {
+		var err error
+		conflicts, err = repo.ApplyBlockChanges(changes)
+		if err != nil || len(conflicts) > 0 {
+			// nothing was saved
+			return err
+		}
+
+		for _, c := range changes {
+			var before, after interface{}
+			if c.Action == models.BlockActionRemove {
+				before = c
+			} else {
+				after = c
+			}
+			if err := m.record(repo, "block."+c.Action, "room", c.RoomID, before, after); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	return conflicts, err
+}
+
+func (m *auditDBRepo) UpdateRoomStatus(s models.RoomStatus) (models.RoomStatus, error) {
+	after := s
+	err := m.DatabaseRepo.Transaction(func(repo repository.DatabaseRepo) error {
+		before, err := repo.GetRoomStatus(s.RoomID)
+		if err != nil {
+			return err
+		}
+		after, err = repo.UpdateRoomStatus(s)
+		if err != nil {
+			return err
+		}
+		return m.record(repo, "room.status", "room", s.RoomID, before, after)
+	})
+	return after, err
+}
+
+func (m *auditDBRepo) InsertICalSource(s models.ICalSource) (int, error) {
+	var id int
+	err := m.DatabaseRepo.Transaction(func(repo repository.DatabaseRepo) error {
+		var err error
+		id, err = repo.InsertICalSource(s)
+		if err != nil {
+			return err
+		}
+		s.ID = id
+		return m.record(repo, "ical_source.create", "ical_source", id, nil, s)
+	})
+	return id, err
+}
+
+func (m *auditDBRepo) DeleteICalSource(id int) error {
+	return m.DatabaseRepo.Transaction(func(repo repository.DatabaseRepo) error {
+		before, err := repo.GetICalSourceByID(id)
+		if err != nil {
+			return err
+		}
+		if err := repo.DeleteICalSource(id); err != nil {
+			return err
+		}
+		return m.record(repo, "ical_source.delete", "ical_source", id, before, nil)
+	})
+}
+
+func (m *auditDBRepo) SyncExternalBookings(source models.ICalSource, bookings []models.RoomRestriction) (models.ICalSyncResult, error) {
+	var result models.ICalSyncResult
+	err := m.DatabaseRepo.Transaction(func(repo repository.DatabaseRepo) error {
+		var err error
+		result, err = repo.SyncExternalBookings(source, bookings)
+		if err != nil {
+			return err
+		}
+		return m.record(repo, "ical_source.sync", "ical_source", source.ID, nil, result)
+	})
+	return result, err
+}
+
+func (m *auditDBRepo) RetryChannelNow(channel string) error {
+	return m.DatabaseRepo.Transaction(func(repo repository.DatabaseRepo) error {
+		if err := repo.RetryChannelNow(channel); err != nil {
+			return err
+		}
+		return m.record(repo, "channel.retry", "channel", channel, nil, nil)
+	})
+}
+
+func (m *auditDBRepo) ResendMail(id int) error {
+	return m.DatabaseRepo.Transaction(func(repo repository.DatabaseRepo) error {
+		if err := repo.ResendMail(id); err != nil {
+			return err
+		}
+		return m.record(repo, "mail.resend", "mail", id, nil, nil)
+	})
+}
+
+func (m *auditDBRepo) UpdateFeedTokenForUser(id int, token string) error {
+	return m.DatabaseRepo.Transaction(func(repo repository.DatabaseRepo) error {
+		if err := repo.UpdateFeedTokenForUser(id, token); err != nil {
+			return err
+		}
+		// the token is a secret, only record that it changed
+		return m.record(repo, "user.feed_token", "user", id, nil, nil)
+	})
+}
+
+func (m *auditDBRepo) UpdateNotificationPreferences(userID int, events []string) error {
+	return m.DatabaseRepo.Transaction(func(repo repository.DatabaseRepo) error {
+		before, err := repo.NotificationPreferences(userID)
+		if err != nil {
+			return err
+		}
+		if err := repo.UpdateNotificationPreferences(userID, events); err != nil {
+			return err
+		}
+		return m.record(repo, "user.notifications", "user", userID, before, events)
+	})
+}
+
+func (m *auditDBRepo) PurgeDeletedReservations(before time.Time) (int, error) {
+	var n int
+	err := m.DatabaseRepo.Transaction(func(repo repository.DatabaseRepo) error {
+		var err error
+		n, err = repo.PurgeDeletedReservations(before)
+		if err != nil || n == 0 {
+			return err
+		}
+		return m.record(repo, "reservation.purge", "reservation", "", nil, map[string]interface{}{"Purged": n, "DeletedBefore": before})
+	})
+	return n, err
+}
+
+// GuestDataByEmail is recorded as exports of personal data are accountable. The email address itself is
+// personal data, so it is left out
+func (m *auditDBRepo) GuestDataByEmail(email string) (models.GuestData, error) {
+	var data models.GuestData
+	err := m.DatabaseRepo.Transaction(func(repo repository.DatabaseRepo) error {
+		var err error
+		data, err = repo.GuestDataByEmail(email)
+		if err != nil || data.Empty() {
+			return err
+		}
+		return m.record(repo, "guest.export", "guest", "", nil, map[string]interface{}{
+			"Guests":       len(data.Guests),
+			"Reservations": len(data.Reservations),
+		})
+	})
+	return data, err
+}
+
+func (m *auditDBRepo) EraseGuestData(email string) (int, error) {
+	var n int
+	err := m.DatabaseRepo.Transaction(func(repo repository.DatabaseRepo) error {
+		var err error
+		n, err = repo.EraseGuestData(email)
+		if err != nil {
+			return err
+		}
+		return m.record(repo, "guest.erase", "guest", "", nil, map[string]interface{}{"Reservations": n})
+	})
+	return n, err
+}
+
+func (m *auditDBRepo) AnonymizeReservationsBefore(before time.Time) (int, error) {
+	var n int
+	err := m.DatabaseRepo.Transaction(func(repo repository.DatabaseRepo) error {
+		var err error
+		n, err = repo.AnonymizeReservationsBefore(before)
+		if err != nil || n == 0 {
+			return err
+		}
+		return m.record(repo, "reservation.anonymize", "reservation", "", nil, map[string]interface{}{"Anonymized": n, "EndedBefore": before})
+	})
+	return n, err
+}
+
+func (m *auditDBRepo) ImportBookings(rows []models.ImportRow) error {
+	return m.DatabaseRepo.Transaction(func(repo repository.DatabaseRepo) error {
+		if err := repo.ImportBookings(rows); err != nil {
+			return err
+		}
+
+		var reservations, blocks int
+		for _, row := range rows {
+			switch {
+			case !row.Valid():
+			case row.Block:
+				blocks++
+			default:
+				reservations++
+			}
+		}
+		return m.record(repo, "reservation.import", "reservation", "", nil, map[string]interface{}{"Reservations": reservations, "Blocks": blocks})
+	})
+}
+
+func (m *auditDBRepo) UpdatePropertySettings(settings models.PropertySettings) error {
+	return m.DatabaseRepo.Transaction(func(repo repository.DatabaseRepo) error {
+		before, err := repo.GetPropertySettings()
+		if err != nil {
+			return err
+		}
+		if err := repo.UpdatePropertySettings(settings); err != nil {
+			return err
+		}
+		return m.record(repo, "settings.update", "property_settings", 1, before, settings)
+	})
+}
+
+func (m *auditDBRepo) UpdateScheduledJob(job models.ScheduledJob) error {
+	return m.DatabaseRepo.Transaction(func(repo repository.DatabaseRepo) error {
+		before, err := repo.GetScheduledJob(job.Name)
+		if err != nil {
+			return err
+		}
+		if err := repo.UpdateScheduledJob(job); err != nil {
+			return err
+		}
+		return m.record(repo, "job.update", "scheduled_job", before.ID, before, job)
+	})
+}
+
+// withoutSecret returns the endpoint for the audit log, which only records that the secret is set
+func withoutSecret(e models.WebhookEndpoint) models.WebhookEndpoint {
+	e.Secret = redacted(e.Secret)
+	return e
+}
+
+func (m *auditDBRepo) InsertWebhookEndpoint(e models.WebhookEndpoint) (int, error) {
+	var id int
+	err := m.DatabaseRepo.Transaction(func(repo repository.DatabaseRepo) error {
+		var err error
+		id, err = repo.InsertWebhookEndpoint(e)
+		if err != nil {
+			return err
+		}
+		e.ID = id
+		return m.record(repo, "webhook.create", "webhook_endpoint", id, nil, withoutSecret(e))
+	})
+	return id, err
+}
+
+func (m *auditDBRepo) UpdateWebhookEndpoint(e models.WebhookEndpoint) error {
+	return m.DatabaseRepo.Transaction(func(repo repository.DatabaseRepo) error {
+		before, err := repo.GetWebhookEndpoint(e.ID)
+		if err != nil {
+			return err
+		}
+		if err := repo.UpdateWebhookEndpoint(e); err != nil {
+			return err
+		}
+		return m.record(repo, "webhook.update", "webhook_endpoint", e.ID, withoutSecret(before), withoutSecret(e))
+	})
+}
+
+func (m *auditDBRepo) DeleteWebhookEndpoint(id int) error {
+	return m.DatabaseRepo.Transaction(func(repo repository.DatabaseRepo) error {
+		before, err := repo.GetWebhookEndpoint(id)
+		if err != nil {
+			return err
+		}
+		if err := repo.DeleteWebhookEndpoint(id); err != nil {
+			return err
+		}
+		return m.record(repo, "webhook.delete", "webhook_endpoint", id, withoutSecret(before), nil)
+	})
+}
+
+func (m *auditDBRepo) RedeliverWebhook(id int) (int, error) {
+	var newID int
+	err := m.DatabaseRepo.Transaction(func(repo repository.DatabaseRepo) error {
+		var err error
+		newID, err = repo.RedeliverWebhook(id)
+		if err != nil {
+			return err
+		}
+		return m.record(repo, "webhook.redeliver", "webhook_delivery", id, nil, nil)
+	})
+	return newID, err
+}
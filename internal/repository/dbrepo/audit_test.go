@@ -0,0 +1,271 @@
+package dbrepo
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/go-course/bookings/internal/config"
+	"github.com/go-course/bookings/internal/models"
+	"github.com/go-course/bookings/internal/repository"
+)
+
+// recordingRepo is the testing repo keeping the audit events written to it, and counting those written
+// outside a transaction
+type recordingRepo struct {
+	repository.DatabaseRepo
+	events        []models.AuditEvent
+	inTransaction bool
+	outside       int
+}
+
+func (m *recordingRepo) Transaction(fn func(repo repository.DatabaseRepo) error) error {
+	m.inTransaction = true
+	defer func() { m.inTransaction = false }()
+	return fn(m)
+}
+
+func (m *recordingRepo) InsertAuditEvent(e models.AuditEvent) error {
+	if !m.inTransaction {
+		m.outside++
+	}
+	m.events = append(m.events, e)
+	return nil
+}
+
+func newRecordingRepo() *recordingRepo {
+	return &recordingRepo{DatabaseRepo: NewTestingRepo(&config.AppConfig{})}
+}
+
+func TestAuditRepo_Records(t *testing.T) {
+	var testCases = []struct {
+		name     string
+		change   func(repo repository.DatabaseRepo) error
+		action   string
+		entity   string
+		entityID string
+		before   bool
+		after    bool
+	}{
+		{
+			name:     "Update reservation",
+			change:   func(repo repository.DatabaseRepo) error { return repo.UpdateReservation(models.Reservation{ID: 1}) },
+			action:   "reservation.update",
+			entity:   "reservation",
+			entityID: "1",
+			before:   true,
+			after:    true,
+		},
+		{
+			name: "Change status",
+			change: func(repo repository.DatabaseRepo) error {
+				return repo.UpdateReservationStatus(1, models.ReservationConfirmed)
+			},
+			action:   "reservation.status",
+			entity:   "reservation",
+			entityID: "1",
+			before:   true,
+			after:    true,
+		},
+		{
+			name:     "Delete reservation",
+			change:   func(repo repository.DatabaseRepo) error { return repo.DeleteReservation(1) },
+			action:   "reservation.delete",
+			entity:   "reservation",
+			entityID: "1",
+			before:   true,
+		},
+		{
+			name:     "Restore reservation",
+			change:   func(repo repository.DatabaseRepo) error { return repo.RestoreReservation(1) },
+			action:   "reservation.restore",
+			entity:   "reservation",
+			entityID: "1",
+			after:    true,
+		},
+		{
+			name: "Update guest",
+			change: func(repo repository.DatabaseRepo) error {
+				return repo.UpdateGuest(models.Guest{ID: 1, FirstName: "John", Email: "john@smith.com"})
+			},
+			action:   "guest.update",
+			entity:   "guest",
+			entityID: "1",
+			before:   true,
+			after:    true,
+		},
+		{
+			name:     "Delete calendar",
+			change:   func(repo repository.DatabaseRepo) error { return repo.DeleteICalSource(1) },
+			action:   "ical_source.delete",
+			entity:   "ical_source",
+			entityID: "1",
+			before:   true,
+		},
+		{
+			name:     "Retry channel",
+			change:   func(repo repository.DatabaseRepo) error { return repo.RetryChannelNow("mock") },
+			action:   "channel.retry",
+			entity:   "channel",
+			entityID: "mock",
+		},
+		{
+			name:     "Resend mail",
+			change:   func(repo repository.DatabaseRepo) error { return repo.ResendMail(1) },
+			action:   "mail.resend",
+			entity:   "mail",
+			entityID: "1",
+		},
+		{
+			name:     "Regenerate feed token",
+			change:   func(repo repository.DatabaseRepo) error { return repo.UpdateFeedTokenForUser(1, "secret") },
+			action:   "user.feed_token",
+			entity:   "user",
+			entityID: "1",
+		},
+		{
+			name: "Update notifications",
+			change: func(repo repository.DatabaseRepo) error {
+				return repo.UpdateNotificationPreferences(1, []string{models.EventNewBooking})
+			},
+			action:   "user.notifications",
+			entity:   "user",
+			entityID: "1",
+			before:   true,
+			after:    true,
+		},
+		{
+			name: "Update settings",
+			change: func(repo repository.DatabaseRepo) error {
+				return repo.UpdatePropertySettings(models.PropertySettings{SenderEmail: "desk@fortsmythe.com"})
+			},
+			action:   "settings.update",
+			entity:   "property_settings",
+			entityID: "1",
+			before:   true,
+			after:    true,
+		},
+		{
+			name: "Update job",
+			change: func(repo repository.DatabaseRepo) error {
+				return repo.UpdateScheduledJob(models.ScheduledJob{Name: models.JobGuestReminder, OffsetDays: 2})
+			},
+			action:   "job.update",
+			entity:   "scheduled_job",
+			entityID: "1",
+			before:   true,
+			after:    true,
+		},
+		{
+			name: "Add webhook",
+			change: func(repo repository.DatabaseRepo) error {
+				_, err := repo.InsertWebhookEndpoint(models.WebhookEndpoint{URL: "https://crm.example.com/hooks", Secret: "secret"})
+				return err
+			},
+			action:   "webhook.create",
+			entity:   "webhook_endpoint",
+			entityID: "1",
+			after:    true,
+		},
+		{
+			name: "Update webhook",
+			change: func(repo repository.DatabaseRepo) error {
+				return repo.UpdateWebhookEndpoint(models.WebhookEndpoint{ID: 1, URL: "https://crm.example.com/v2", Secret: "secret"})
+			},
+			action:   "webhook.update",
+			entity:   "webhook_endpoint",
+			entityID: "1",
+			before:   true,
+			after:    true,
+		},
+		{
+			name:     "Delete webhook",
+			change:   func(repo repository.DatabaseRepo) error { return repo.DeleteWebhookEndpoint(1) },
+			action:   "webhook.delete",
+			entity:   "webhook_endpoint",
+			entityID: "1",
+			before:   true,
+		},
+		{
+			name: "Redeliver webhook",
+			change: func(repo repository.DatabaseRepo) error {
+				_, err := repo.RedeliverWebhook(1)
+				return err
+			},
+			action:   "webhook.redeliver",
+			entity:   "webhook_delivery",
+			entityID: "1",
+		},
+	}
+
+	for _, testCase := range testCases {
+		inner := newRecordingRepo()
+		if err := testCase.change(NewAuditRepo(inner, 7)); err != nil {
+			t.Fatalf("%s: %v", testCase.name, err)
+		}
+		if len(inner.events) != 1 {
+			t.Fatalf("%s: expected 1 event, got %d", testCase.name, len(inner.events))
+		}
+		e := inner.events[0]
+		if e.UserID != 7 || e.Action != testCase.action || e.Entity != testCase.entity || e.EntityID != testCase.entityID {
+			t.Errorf("%s: wrong event %+v", testCase.name, e)
+		}
+		if (e.Before != "") != testCase.before || (e.After != "") != testCase.after {
+			t.Errorf("%s: wrong before/after: %q / %q", testCase.name, e.Before, e.After)
+		}
+		if strings.Contains(e.Before+e.After, "secret") {
+			t.Errorf("%s: secret recorded in audit log", testCase.name)
+		}
+		if strings.Contains(e.Before+e.After, "john@smith.com") {
+			t.Errorf("%s: contact details recorded in audit log", testCase.name)
+		}
+		if inner.outside > 0 {
+			t.Errorf("%s: event recorded outside the transaction of the change", testCase.name)
+		}
+	}
+}
+
+func TestAuditRepo_SkipsFailedChanges(t *testing.T) {
+	inner := newRecordingRepo()
+	repo := NewAuditRepo(inner, 7)
+
+	if err := repo.UpdateReservationStatus(2, models.ReservationConfirmed); err == nil {
+		t.Error("expected transition error")
+	}
+	if err := repo.DeleteReservation(3); err == nil {
+		t.Error("expected error deleting a missing reservation")
+	}
+
+	conflicts, err := repo.ApplyBlockChanges([]models.BlockChange{
+		{Action: models.BlockActionRemove, RoomID: 1, BlockID: 1, Version: 2},
+	})
+	if err != nil || len(conflicts) != 1 {
+		t.Fatalf("expected a conflict, got %v, %v", conflicts, err)
+	}
+
+	if len(inner.events) != 0 {
+		t.Errorf("failed changes should not be recorded, got %+v", inner.events)
+	}
+}
+
+func TestAuditRepo_ApplyBlockChanges(t *testing.T) {
+	inner := newRecordingRepo()
+	repo := NewAuditRepo(inner, 7)
+
+	_, err := repo.ApplyBlockChanges([]models.BlockChange{
+		{Action: models.BlockActionAdd, RoomID: 1},
+		{Action: models.BlockActionRemove, RoomID: 2, BlockID: 4, Version: 1},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(inner.events) != 2 {
+		t.Fatalf("expected an event per change, got %d", len(inner.events))
+	}
+	if e := inner.events[0]; e.Action != "block.add" || e.EntityID != "1" || e.Before != "" || e.After == "" {
+		t.Errorf("wrong add event %+v", e)
+	}
+	if e := inner.events[1]; e.Action != "block.remove" || e.EntityID != "2" || e.Before == "" || e.After != "" {
+		t.Errorf("wrong remove event %+v", e)
+	}
+}
@@ -2,19 +2,32 @@ package dbrepo
 
 import (
 	"database/sql"
+	"sync"
 
 	"github.com/go-course/bookings/internal/config"
+	"github.com/go-course/bookings/internal/models"
 	"github.com/go-course/bookings/internal/repository"
 )
 
 type postgresDBRepo struct {
 	App *config.AppConfig
 	DB  *sql.DB
+	// tx is the transaction every statement runs in when the repo was handed out by Transaction
+	tx *sql.Tx
 }
 
 type testDBRepo struct {
 	App *config.AppConfig
 	DB  *sql.DB
+	// outbox holds the mail, text messages and webhook deliveries queued until they are claimed
+	outbox *testOutbox
+}
+
+type testOutbox struct {
+	mu       sync.Mutex
+	messages []models.MailData
+	texts    []models.SMSData
+	webhooks []models.WebhookDelivery
 }
 
 func NewPostgresRepo(conn *sql.DB, a *config.AppConfig) repository.DatabaseRepo {
@@ -26,6 +39,7 @@ func NewPostgresRepo(conn *sql.DB, a *config.AppConfig) repository.DatabaseRepo
 
 func NewTestingRepo(a *config.AppConfig) repository.DatabaseRepo {
 	return &testDBRepo{
-		App: a,
+		App:    a,
+		outbox: &testOutbox{},
 	}
 }
@@ -2,9 +2,15 @@ package dbrepo
 
 import (
 	"context"
+	"database/sql"
+	"encoding/json"
 	"errors"
+	"fmt"
+	"sort"
+	"strings"
 	"time"
 
+	"github.com/go-course/bookings/internal/fieldcrypt"
 	"github.com/go-course/bookings/internal/models"
 	"golang.org/x/crypto/bcrypt"
 )
@@ -13,26 +19,176 @@ func (m *postgresDBRepo) AllUsers() bool {
 	return true
 }
 
+// cipher returns the cipher of the contact details of reservations and guests, nil when they are kept in plain text
+func (m *postgresDBRepo) cipher() *fieldcrypt.Cipher {
+	if m.App == nil {
+		return nil
+	}
+	return m.App.FieldCipher
+}
+
+// sealContact returns an email address and phone number as they are stored in reservations and guests, and
+// the blind index of the email address
+func (m *postgresDBRepo) sealContact(email, phone string) (string, string, string, error) {
+	c := m.cipher()
+	sealedEmail, err := c.Encrypt(email)
+	if err != nil {
+		return "", "", "", err
+	}
+	sealedPhone, err := c.Encrypt(phone)
+	if err != nil {
+		return "", "", "", err
+	}
+	return sealedEmail, sealedPhone, c.BlindIndex(email), nil
+}
+
+// openContact decrypts the email address and phone number of a reservation read from the database
+func (m *postgresDBRepo) openContact(res *models.Reservation) error {
+	c := m.cipher()
+	email, err := c.Decrypt(res.Email)
+	if err != nil {
+		return err
+	}
+	phone, err := c.Decrypt(res.Phone)
+	if err != nil {
+		return err
+	}
+	res.Email, res.Phone = email, phone
+	return nil
+}
+
+// guestIndexes returns the email_index and phone_index of a guest with an email address and phone number:
+// their blind indexes when contact details are encrypted, else the normalized values
+func (m *postgresDBRepo) guestIndexes(email, phone string) (string, string) {
+	c := m.cipher()
+	if c == nil {
+		return models.NormalizeEmail(email), models.NormalizePhone(phone)
+	}
+	return c.BlindIndex(email), c.PhoneIndex(phone)
+}
+
+// openGuest decrypts the email address and phone number of a guest read from the database
+func (m *postgresDBRepo) openGuest(g *models.Guest) error {
+	c := m.cipher()
+	email, err := c.Decrypt(g.Email)
+	if err != nil {
+		return err
+	}
+	phone, err := c.Decrypt(g.Phone)
+	if err != nil {
+		return err
+	}
+	g.Email, g.Phone = email, phone
+	return nil
+}
+
 // InsertReservation inserts reservation into the database
 func (m *postgresDBRepo) InsertReservation(res models.Reservation) (int, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
+
+	tx, err := m.begin(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	newId, err := m.insertReservation(ctx, tx, res)
+	if err != nil {
+		return 0, err
+	}
+
+	return newId, tx.Commit()
+}
+
+// BookReservation inserts a reservation with its room restriction, matching it to its guest's profile or
+// creating one, and queues the mail and the text message, unless it has no number, notify returns for the
+// saved reservation, in one transaction so that no booking goes unannounced and a failed one leaves no
+// guest behind
+func (m *postgresDBRepo) BookReservation(res models.Reservation, notify func(models.Reservation) ([]models.MailData, models.SMSData, error)) (int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	tx, err := m.begin(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	if res.GuestID == 0 {
+		res.GuestID, err = m.findOrCreateGuest(ctx, tx, models.Guest{
+			FirstName: res.FirstName,
+			LastName:  res.LastName,
+			Email:     res.Email,
+			Phone:     res.Phone,
+		})
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	res.ID, err = m.insertReservation(ctx, tx, res)
+	if err != nil {
+		return 0, err
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		insert into room_restrictions (start_date, end_date, room_id, reservation_id, restriction_id, created_at, updated_at)
+		values ($1, $2, $3, $4, $5, $6, $6)
+	`, res.StartDate, res.EndDate, res.RoomID, res.ID, models.RestrictionReservation, time.Now())
+	if err != nil {
+		return 0, err
+	}
+
+	messages, text, err := notify(res)
+	if err != nil {
+		return 0, err
+	}
+	for _, msg := range messages {
+		if err := queueMail(ctx, tx, msg); err != nil {
+			return 0, err
+		}
+	}
+	if text.To != "" {
+		if err := queueSMS(ctx, tx, res.ID, text); err != nil {
+			return 0, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+
+	return res.ID, nil
+}
+
+// insertReservation inserts a reservation within tx and returns its id
+func (m *postgresDBRepo) insertReservation(ctx context.Context, tx *txn, res models.Reservation) (int, error) {
+	email, phone, emailIndex, err := m.sealContact(res.Email, res.Phone)
+	if err != nil {
+		return 0, err
+	}
+
 	var newId int
 	stmt := `
 		insert into 
-			reservations (first_name, last_name, email, phone, start_date, end_date, room_id, created_at, updated_at)
-		values ($1, $2, $3, $4, $5, $6, $7, $8, $9) returning id
+			reservations (first_name, last_name, email, phone, start_date, end_date, room_id, created_at, updated_at,
+				guest_id, email_index, sms_opt_in)
+		values ($1, $2, $3, $4, $5, $6, $7, $8, $9, nullif($10, 0), $11, $12) returning id
 	`
-	err := m.DB.QueryRowContext(ctx, stmt,
+	err = tx.QueryRowContext(ctx, stmt,
 		res.FirstName,
 		res.LastName,
-		res.Email,
-		res.Phone,
+		email,
+		phone,
 		res.StartDate,
 		res.EndDate,
 		res.RoomID,
 		time.Now(),
 		time.Now(),
+		res.GuestID,
+		emailIndex,
+		res.SMSOptIn,
 	).Scan(&newId)
 
 	if err != nil {
@@ -50,7 +206,7 @@ func (m *postgresDBRepo) InsertRoomRestriction(res models.RoomRestriction) error
 			room_restrictions (start_date, end_date, room_id, reservation_id, restriction_id, created_at, updated_at)
 		values ($1, $2, $3, $4, $5, $6, $7) returning id
 	`
-	_, err := m.DB.ExecContext(ctx, stmt,
+	_, err := m.conn().ExecContext(ctx, stmt,
 		res.StartDate,
 		res.EndDate,
 		res.RoomID,
@@ -84,7 +240,7 @@ func (m *postgresDBRepo) SearchAvailabilityByDatesByRoomID(start, end time.Time,
 		and $2 < end_date
 		AND $3 > start_date
 	`
-	err := m.DB.QueryRowContext(ctx, stmt, roomID, start, end).Scan(&count)
+	err := m.conn().QueryRowContext(ctx, stmt, roomID, start, end).Scan(&count)
 
 	if err != nil {
 		return false, err
@@ -118,7 +274,7 @@ func (m *postgresDBRepo) SearchAvailabilityForAllRooms(start, end time.Time) ([]
 			$1 < rr.end_date
 			AND $2 > rr.start_date)
 	`
-	rows, err := m.DB.QueryContext(ctx, stmt, start, end)
+	rows, err := m.conn().QueryContext(ctx, stmt, start, end)
 	if err != nil {
 		return rooms, err
 	}
@@ -145,7 +301,7 @@ func (m *postgresDBRepo) GetRoomByID(id int) (models.Room, error) {
 		select id, room_name, created_at, updated_at from rooms where id = $1
 	`
 
-	err := m.DB.QueryRowContext(ctx, query, id).Scan(
+	err := m.conn().QueryRowContext(ctx, query, id).Scan(
 		&rooms.ID,
 		&rooms.RoomName,
 		&rooms.CreatedAt,
@@ -166,18 +322,19 @@ func (m *postgresDBRepo) GetUserById(id int) (models.User, error) {
 	var user models.User
 
 	query := `
-		select id, first_name, last_name, email, password, access_level, created_at, update_at 
+		select id, first_name, last_name, email, password, access_level, feed_token, created_at, updated_at
 		from users
 		where id = $1
 	`
 
-	err := m.DB.QueryRowContext(ctx, query, id).Scan(
+	err := m.conn().QueryRowContext(ctx, query, id).Scan(
 		&user.ID,
 		&user.FirstName,
 		&user.LastName,
 		&user.Email,
 		&user.Password,
 		&user.AccessLevel,
+		&user.FeedToken,
 		&user.CreatedAt,
 		&user.UpdatedAt,
 	)
@@ -203,7 +360,7 @@ func (m *postgresDBRepo) UpdateUser(u models.User) error {
 	updated_at = $5
 	`
 
-	_, err := m.DB.ExecContext(ctx, query,
+	_, err := m.conn().ExecContext(ctx, query,
 		u.FirstName,
 		u.LastName,
 		u.Email,
@@ -232,7 +389,7 @@ func (m *postgresDBRepo) Authenticate(email, testPassword string) (int, string,
 		where email = $1
 	`
 
-	err := m.DB.QueryRowContext(ctx, query, email).Scan(
+	err := m.conn().QueryRowContext(ctx, query, email).Scan(
 		&id,
 		&hashedPassword,
 	)
@@ -252,112 +409,298 @@ func (m *postgresDBRepo) Authenticate(email, testPassword string) (int, string,
 	return id, hashedPassword, nil
 }
 
-// AllReservations returns a slice of all reservations
-func (m *postgresDBRepo) AllReservations() ([]models.Reservation, error) {
+// GetUserByFeedToken returns the user owning a calendar feed token
+func (m *postgresDBRepo) GetUserByFeedToken(token string) (models.User, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
-	var reservations []models.Reservation
+	var user models.User
+
+	if token == "" {
+		return user, errors.New("empty feed token")
+	}
 
 	query := `
-	select 
-		r.id, r.first_name, r.last_name, r.email, 
-		r.start_date, r.end_date, r.room_id, 
-		r.created_at, r.updated_at, rm.id, 
-		rm.room_name
-	from reservations r
-	left join rooms rm
-	on (r.room_id = rm.id)
-	order by r.start_date asc
+		select id, first_name, last_name, email, access_level, feed_token, created_at, updated_at
+		from users
+		where feed_token = $1
 	`
 
-	rows, err := m.DB.QueryContext(ctx, query)
+	err := m.conn().QueryRowContext(ctx, query, token).Scan(
+		&user.ID,
+		&user.FirstName,
+		&user.LastName,
+		&user.Email,
+		&user.AccessLevel,
+		&user.FeedToken,
+		&user.CreatedAt,
+		&user.UpdatedAt,
+	)
 
 	if err != nil {
-		return reservations, err
+		return user, err
+	}
+
+	return user, nil
+}
+
+// UpdateFeedTokenForUser sets the calendar feed token of a user
+func (m *postgresDBRepo) UpdateFeedTokenForUser(id int, token string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	query := `
+	update users
+	set feed_token = $1,
+	updated_at = $2
+	where id = $3
+	`
+
+	_, err := m.conn().ExecContext(ctx, query, token, time.Now(), id)
+
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// exportTimeout bounds the queries streaming exports, which run for as long as the client reads
+const exportTimeout = 5 * time.Minute
+
+// reservationSortColumns maps the sort keys of a models.ReservationQuery to columns
+var reservationSortColumns = map[string]string{
+	"id":         "r.id",
+	"last_name":  "r.last_name",
+	"room":       "rm.room_name",
+	"start_date": "r.start_date",
+	"end_date":   "r.end_date",
+	"status":     "r.status",
+	"created_at": "r.created_at",
+}
+
+// reservationQuerySQL returns the where conditions and order of q, appending their values to args. Encrypted
+// email addresses only match a search for the whole address, through its blind index emailIndex. Phone
+// numbers have no index on reservations, so only those still stored in plain text match; search the guests
+// to find an encrypted number
+func reservationQuerySQL(q models.ReservationQuery, emailIndex string, args *[]interface{}) (string, string) {
+	arg := func(v interface{}) string {
+		*args = append(*args, v)
+		return fmt.Sprintf("$%d", len(*args))
+	}
+
+	where := []string{"r.deleted_at is null"}
+	if q.Status != "" {
+		where = append(where, "r.status = "+arg(q.Status))
+	}
+	if q.RoomID > 0 {
+		where = append(where, "r.room_id = "+arg(q.RoomID))
+	}
+	if q.Search != "" {
+		p := arg("%" + escapeLike(q.Search) + "%")
+		search := fmt.Sprintf("r.first_name || ' ' || r.last_name ilike %[1]s"+
+			" or (r.email not like 'enc:%%' and r.email ilike %[1]s) or (r.phone not like 'enc:%%' and r.phone ilike %[1]s)", p)
+		if emailIndex != "" {
+			search += " or r.email_index = " + arg(emailIndex)
+		}
+		where = append(where, "("+search+")")
+	}
+	// stays overlapping the range
+	if !q.From.IsZero() {
+		where = append(where, "r.end_date > "+arg(q.From))
+	}
+	if !q.To.IsZero() {
+		where = append(where, "r.start_date <= "+arg(q.To))
+	}
+
+	column, ok := reservationSortColumns[q.Sort]
+	if !ok {
+		column = "r.start_date"
+	}
+	direction := "asc"
+	if q.Desc {
+		direction = "desc"
+	}
+
+	return strings.Join(where, " and "), fmt.Sprintf("%s %s, r.id %s", column, direction, direction)
+}
+
+// reservationListColumns are the columns scanned by scanReservationListRow
+const reservationListColumns = `
+		r.id, r.first_name, r.last_name, r.email, r.phone,
+		r.start_date, r.end_date, r.room_id,
+		r.created_at, r.updated_at, r.status,
+		rm.id, rm.room_name`
+
+// scanReservationListRow scans a row of reservationListColumns
+func scanReservationListRow(rows *sql.Rows) (models.Reservation, error) {
+	var i models.Reservation
+	err := rows.Scan(
+		&i.ID,
+		&i.FirstName,
+		&i.LastName,
+		&i.Email,
+		&i.Phone,
+		&i.StartDate,
+		&i.EndDate,
+		&i.RoomID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Status,
+		&i.Room.ID,
+		&i.Room.RoomName,
+	)
+	return i, err
+}
+
+// SearchReservations returns the page of reservations selected by q, and how many reservations match in total
+func (m *postgresDBRepo) SearchReservations(q models.ReservationQuery) ([]models.Reservation, int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var reservations []models.Reservation
+
+	var args []interface{}
+	conditions, order := reservationQuerySQL(q, m.cipher().BlindIndex(q.Search), &args)
+
+	var total int
+	err := m.conn().QueryRowContext(ctx, `
+	select count(r.id)
+	from reservations r
+	left join rooms rm on (r.room_id = rm.id)
+	where `+conditions, args...).Scan(&total)
+	if err != nil {
+		return reservations, 0, err
+	}
+
+	args = append(args, q.Limit(), q.Offset())
+	query := fmt.Sprintf(`
+	select %s
+	from reservations r
+	left join rooms rm on (r.room_id = rm.id)
+	where %s
+	order by %s
+	limit $%d offset $%d
+	`, reservationListColumns, conditions, order, len(args)-1, len(args))
+
+	rows, err := m.conn().QueryContext(ctx, query, args...)
+	if err != nil {
+		return reservations, 0, err
 	}
 	defer rows.Close()
 
 	for rows.Next() {
-		var i models.Reservation
-		err := rows.Scan(
-			&i.ID,
-			&i.FirstName,
-			&i.LastName,
-			&i.Email,
-			&i.StartDate,
-			&i.EndDate,
-			&i.RoomID,
-			&i.CreatedAt,
-			&i.UpdatedAt,
-			&i.Room.ID,
-			&i.Room.RoomName,
-		)
+		i, err := scanReservationListRow(rows)
 		if err != nil {
-			return reservations, err
+			return reservations, 0, err
+		}
+		if err := m.openContact(&i); err != nil {
+			return reservations, 0, err
 		}
 		reservations = append(reservations, i)
 	}
 
 	if err := rows.Err(); err != nil {
-		return reservations, err
+		return reservations, 0, err
 	}
 
-	return reservations, nil
+	return reservations, total, nil
 }
 
-// AllNewReservations returns a slice of all new reservations
-func (m *postgresDBRepo) AllNewReservations() ([]models.Reservation, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+// EachReservation calls fn with every reservation selected by q, ignoring its paging, as the rows are read.
+// It stops at the first error fn returns
+func (m *postgresDBRepo) EachReservation(q models.ReservationQuery, fn func(models.Reservation) error) error {
+	ctx, cancel := context.WithTimeout(context.Background(), exportTimeout)
 	defer cancel()
-	var reservations []models.Reservation
 
-	query := `
-	select 
-		r.id, r.first_name, r.last_name, r.email, 
-		r.start_date, r.end_date, r.room_id, 
-		r.created_at, r.updated_at, r.processed,
-		rm.id, rm.room_name
+	var args []interface{}
+	conditions, order := reservationQuerySQL(q, m.cipher().BlindIndex(q.Search), &args)
+
+	query := fmt.Sprintf(`
+	select %s
 	from reservations r
-	left join rooms rm
-	on (r.room_id = rm.id)
-	where r.processed = 0
-	order by r.start_date asc
-	`
+	left join rooms rm on (r.room_id = rm.id)
+	where %s
+	order by %s
+	`, reservationListColumns, conditions, order)
+
+	rows, err := m.conn().QueryContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		i, err := scanReservationListRow(rows)
+		if err != nil {
+			return err
+		}
+		if err := m.openContact(&i); err != nil {
+			return err
+		}
+		if err := fn(i); err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
+
+// EachRoomOccupancy calls fn with the occupancy of every room over the nights from start up to, but not
+// including, end. It stops at the first error fn returns
+func (m *postgresDBRepo) EachRoomOccupancy(start, end time.Time, fn func(models.RoomOccupancy) error) error {
+	ctx, cancel := context.WithTimeout(context.Background(), exportTimeout)
+	defer cancel()
 
-	rows, err := m.DB.QueryContext(ctx, query)
+	// nights of each restriction inside the range
+	query := `
+	select
+		rm.id, rm.room_name,
+		coalesce(sum(least(rr.end_date, $2::date) - greatest(rr.start_date, $1::date))
+			filter (where rr.restriction_id = $3), 0),
+		coalesce(sum(least(rr.end_date, $2::date) - greatest(rr.start_date, $1::date))
+			filter (where rr.restriction_id = $4), 0),
+		coalesce(sum(least(rr.end_date, $2::date) - greatest(rr.start_date, $1::date))
+			filter (where rr.restriction_id = $5), 0),
+		count(distinct rr.reservation_id) filter (where rr.restriction_id = $3)
+	from rooms rm
+	left join room_restrictions rr
+		on (rr.room_id = rm.id and rr.start_date < $2::date and rr.end_date > $1::date)
+	group by rm.id, rm.room_name
+	order by rm.room_name
+	`
 
+	rows, err := m.conn().QueryContext(ctx, query, start, end,
+		models.RestrictionReservation, models.RestrictionExternalBooking, models.RestrictionOwnerBlock)
 	if err != nil {
-		return reservations, err
+		return err
 	}
 	defer rows.Close()
 
+	nights := int(end.Sub(start).Hours() / 24)
 	for rows.Next() {
-		var i models.Reservation
+		o := models.RoomOccupancy{Nights: nights}
 		err := rows.Scan(
-			&i.ID,
-			&i.FirstName,
-			&i.LastName,
-			&i.Email,
-			&i.StartDate,
-			&i.EndDate,
-			&i.RoomID,
-			&i.CreatedAt,
-			&i.UpdatedAt,
-			&i.Procesed,
-			&i.Room.ID,
-			&i.Room.RoomName,
+			&o.Room.ID,
+			&o.Room.RoomName,
+			&o.ReservedNights,
+			&o.ExternalNights,
+			&o.BlockedNights,
+			&o.Reservations,
 		)
 		if err != nil {
-			return reservations, err
+			return err
+		}
+		if err := fn(o); err != nil {
+			return err
 		}
-		reservations = append(reservations, i)
 	}
 
-	if err := rows.Err(); err != nil {
-		return reservations, err
-	}
+	return rows.Err()
+}
 
-	return reservations, nil
+// escapeLike escapes the wildcards of a like pattern
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(s)
 }
 
 // GetReservatioById returns a reservation based on id
@@ -370,15 +713,19 @@ func (m *postgresDBRepo) GetReservationById(id int) (models.Reservation, error)
 	select 
 		r.id, r.first_name, r.last_name, r.email, r.phone,
 		r.start_date, r.end_date, r.room_id, 
-		r.created_at, r.updated_at, r.processed,
+		r.created_at, r.updated_at, r.status,
+		coalesce(r.confirmed_at, '0001-01-01'::timestamp), coalesce(r.checked_in_at, '0001-01-01'::timestamp),
+		coalesce(r.checked_out_at, '0001-01-01'::timestamp), coalesce(r.cancelled_at, '0001-01-01'::timestamp),
+		coalesce(r.no_show_at, '0001-01-01'::timestamp),
+		coalesce(r.checked_in_by, 0), coalesce(r.checked_out_by, 0), coalesce(r.guest_id, 0), r.sms_opt_in,
 		rm.id, rm.room_name
 	from reservations r
 	left join rooms rm
 	on (r.room_id = rm.id)
-	where r.id = $1
+	where r.id = $1 and r.deleted_at is null
 	`
 
-	err := m.DB.QueryRowContext(ctx, query, id).Scan(
+	err := m.conn().QueryRowContext(ctx, query, id).Scan(
 		&reservation.ID,
 		&reservation.FirstName,
 		&reservation.LastName,
@@ -389,7 +736,16 @@ func (m *postgresDBRepo) GetReservationById(id int) (models.Reservation, error)
 		&reservation.RoomID,
 		&reservation.CreatedAt,
 		&reservation.UpdatedAt,
-		&reservation.Procesed,
+		&reservation.Status,
+		&reservation.ConfirmedAt,
+		&reservation.CheckedInAt,
+		&reservation.CheckedOutAt,
+		&reservation.CancelledAt,
+		&reservation.NoShowAt,
+		&reservation.CheckedInBy,
+		&reservation.CheckedOutBy,
+		&reservation.GuestID,
+		&reservation.SMSOptIn,
 		&reservation.Room.ID,
 		&reservation.Room.RoomName,
 	)
@@ -398,7 +754,8 @@ func (m *postgresDBRepo) GetReservationById(id int) (models.Reservation, error)
 		return reservation, err
 	}
 
-	return reservation, nil
+	err = m.openContact(&reservation)
+	return reservation, err
 }
 
 // UpdateReservation updates a reservation in database
@@ -406,21 +763,28 @@ func (m *postgresDBRepo) UpdateReservation(u models.Reservation) error {
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
 
+	email, phone, emailIndex, err := m.sealContact(u.Email, u.Phone)
+	if err != nil {
+		return err
+	}
+
 	query := `
 	update reservations	 
 	set first_name = $1,
 	last_name = $2,
 	email = $3, 
 	phone = $4,
-	updated_at = $5
-	where id = $6
+	email_index = $5,
+	updated_at = $6
+	where id = $7 and deleted_at is null
 	`
 
-	_, err := m.DB.ExecContext(ctx, query,
+	_, err = m.conn().ExecContext(ctx, query,
 		u.FirstName,
 		u.LastName,
-		u.Email,
-		u.Phone,
+		email,
+		phone,
+		emailIndex,
 		time.Now(),
 		u.ID,
 	)
@@ -432,167 +796,3813 @@ func (m *postgresDBRepo) UpdateReservation(u models.Reservation) error {
 	return nil
 }
 
-// DeletReservation deletes reservation from databsase
+// DeleteReservation moves a reservation to the trash, releasing its room restrictions
 func (m *postgresDBRepo) DeleteReservation(id int) error {
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
 
-	query := `
-	delete 
-	from reservations 
-	where id =$1
-	`
-
-	_, err := m.DB.ExecContext(ctx, query, id)
-
+	tx, err := m.begin(ctx)
 	if err != nil {
 		return err
 	}
-
-	return nil
-}
-
-// UpdateProcessedForReservation updates processed flag in database
-func (m *postgresDBRepo) UpdateProcessedForReservation(id, processed int) error {
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
-	defer cancel()
+	defer tx.Rollback()
 
 	query := `
-	update reservations 
-	set processed = $1
-	where id =$2
+	update reservations
+	set deleted_at = $1
+	where id = $2 and deleted_at is null
 	`
 
-	_, err := m.DB.ExecContext(ctx, query, processed, id)
+	result, err := tx.ExecContext(ctx, query, time.Now(), id)
+	if err != nil {
+		return err
+	}
 
+	n, err := result.RowsAffected()
 	if err != nil {
 		return err
 	}
+	if n == 0 {
+		return sql.ErrNoRows
+	}
 
-	return nil
+	_, err = tx.ExecContext(ctx, `delete from room_restrictions where reservation_id = $1`, id)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
 }
 
-// AllRooms return a slice of models.Room
-func (m *postgresDBRepo) AllRooms() ([]models.Room, error) {
+// DeletedReservations returns the reservations in the trash, most recently deleted first
+func (m *postgresDBRepo) DeletedReservations() ([]models.Reservation, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
-
-	var rooms []models.Room
+	var reservations []models.Reservation
 
 	query := `
-	select id, room_name, created_at, updated_at
-	from rooms
+	select
+		r.id, r.first_name, r.last_name, r.email,
+		r.start_date, r.end_date, r.room_id,
+		r.created_at, r.updated_at, r.status, r.deleted_at,
+		rm.id, rm.room_name
+	from reservations r
+	left join rooms rm
+	on (r.room_id = rm.id)
+	where r.deleted_at is not null
+	order by r.deleted_at desc
 	`
 
-	rows, err := m.DB.QueryContext(ctx, query)
-
+	rows, err := m.conn().QueryContext(ctx, query)
 	if err != nil {
-		return rooms, err
+		return reservations, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var i models.Reservation
+		err := rows.Scan(
+			&i.ID,
+			&i.FirstName,
+			&i.LastName,
+			&i.Email,
+			&i.StartDate,
+			&i.EndDate,
+			&i.RoomID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Status,
+			&i.DeletedAt,
+			&i.Room.ID,
+			&i.Room.RoomName,
+		)
+		if err != nil {
+			return reservations, err
+		}
+		if err := m.openContact(&i); err != nil {
+			return reservations, err
+		}
+		reservations = append(reservations, i)
+	}
+
+	if err := rows.Err(); err != nil {
+		return reservations, err
+	}
+
+	return reservations, nil
+}
+
+// RestoreReservation takes a reservation out of the trash. Unless it was cancelled, its room restriction
+// is created again, which fails with models.ErrRoomUnavailable if the room was booked meanwhile
+func (m *postgresDBRepo) RestoreReservation(id int) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	tx, err := m.begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var res models.Reservation
+	err = tx.QueryRowContext(ctx, `
+		select id, room_id, start_date, end_date, status
+		from reservations
+		where id = $1 and deleted_at is not null
+		for update
+	`, id).Scan(&res.ID, &res.RoomID, &res.StartDate, &res.EndDate, &res.Status)
+	if err != nil {
+		return err
+	}
+
+	if res.Status != models.ReservationCancelled {
+		// serialize with bookings of the same room
+		_, err = tx.ExecContext(ctx, `select id from rooms where id = $1 for update`, res.RoomID)
+		if err != nil {
+			return err
+		}
+
+		var count int
+		err = tx.QueryRowContext(ctx, `
+			select count(id) from room_restrictions
+			where room_id = $1 and $2 < end_date and $3 > start_date
+		`, res.RoomID, res.StartDate, res.EndDate).Scan(&count)
+		if err != nil {
+			return err
+		}
+		if count > 0 {
+			return models.ErrRoomUnavailable
+		}
+
+		_, err = tx.ExecContext(ctx, `
+			insert into room_restrictions (start_date, end_date, room_id, reservation_id, restriction_id, created_at, updated_at)
+			values ($1, $2, $3, $4, $5, $6, $6)
+		`, res.StartDate, res.EndDate, res.RoomID, res.ID, models.RestrictionReservation, time.Now())
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err = tx.ExecContext(ctx, `update reservations set deleted_at = null, updated_at = $1 where id = $2`, time.Now(), id)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// PurgeDeletedReservations permanently deletes the reservations moved to the trash before the given time
+func (m *postgresDBRepo) PurgeDeletedReservations(before time.Time) (int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	result, err := m.conn().ExecContext(ctx, `delete from reservations where deleted_at < $1`, before)
+	if err != nil {
+		return 0, err
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+
+	return int(n), nil
+}
+
+// guestEmailCondition selects the reservations of the guest with the normalized email address $1, whose
+// blind index is $2 when email addresses are encrypted
+const guestEmailCondition = `(lower(trim(email)) = $1 or ($2 <> '' and email_index = $2)
+	or guest_id in (select id from guests where ` + guestIndexCondition + `))`
+
+// guestIndexCondition selects the guests with the normalized email address $1 or its blind index $2. Guests
+// saved before encryption was enabled keep the normalized address until they are re-encrypted
+const guestIndexCondition = `email_index <> '' and email_index in ($1, $2)`
+
+// GuestDataByEmail returns everything held about the guest with an email address, including reservations
+// in the trash
+func (m *postgresDBRepo) GuestDataByEmail(email string) (models.GuestData, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	email = models.NormalizeEmail(email)
+	data := models.GuestData{Email: email}
+	if email == "" {
+		return data, nil
+	}
+
+	guests, err := m.queryGuests(`select `+guestColumns+` from guests g where `+guestIndexCondition+` order by g.id`,
+		email, m.cipher().BlindIndex(email))
+	if err != nil {
+		return data, err
+	}
+	data.Guests = guests
+
+	query := `
+	select
+		r.id, r.first_name, r.last_name, r.email, r.phone, r.start_date, r.end_date, r.room_id, r.status,
+		coalesce(r.confirmed_at, '0001-01-01'::timestamp), coalesce(r.checked_in_at, '0001-01-01'::timestamp),
+		coalesce(r.checked_out_at, '0001-01-01'::timestamp), coalesce(r.cancelled_at, '0001-01-01'::timestamp),
+		coalesce(r.no_show_at, '0001-01-01'::timestamp), coalesce(r.deleted_at, '0001-01-01'::timestamp),
+		coalesce(r.guest_id, 0), r.created_at, r.updated_at, rm.id, rm.room_name
+	from reservations r
+	left join rooms rm on (r.room_id = rm.id)
+	where ` + guestEmailCondition + `
+	order by r.start_date, r.id
+	`
+
+	emailIndex := m.cipher().BlindIndex(email)
+	rows, err := m.conn().QueryContext(ctx, query, email, emailIndex)
+	if err != nil {
+		return data, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var i models.Reservation
+		err := rows.Scan(
+			&i.ID,
+			&i.FirstName,
+			&i.LastName,
+			&i.Email,
+			&i.Phone,
+			&i.StartDate,
+			&i.EndDate,
+			&i.RoomID,
+			&i.Status,
+			&i.ConfirmedAt,
+			&i.CheckedInAt,
+			&i.CheckedOutAt,
+			&i.CancelledAt,
+			&i.NoShowAt,
+			&i.DeletedAt,
+			&i.GuestID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Room.ID,
+			&i.Room.RoomName,
+		)
+		if err != nil {
+			return data, err
+		}
+		if err := m.openContact(&i); err != nil {
+			return data, err
+		}
+		data.Reservations = append(data.Reservations, i)
+	}
+	if err := rows.Err(); err != nil {
+		return data, err
+	}
+
+	cardRows, err := m.conn().QueryContext(ctx, `
+		select id, reservation_id, address, id_type, id_number, vehicle_plate, created_at, updated_at
+		from registration_cards
+		where reservation_id in (select id from reservations where `+guestEmailCondition+`)
+		order by reservation_id
+	`, email, emailIndex)
+	if err != nil {
+		return data, err
+	}
+	defer cardRows.Close()
+
+	for cardRows.Next() {
+		var c models.RegistrationCard
+		err := cardRows.Scan(
+			&c.ID,
+			&c.ReservationID,
+			&c.Address,
+			&c.IDType,
+			&c.IDNumber,
+			&c.VehiclePlate,
+			&c.CreatedAt,
+			&c.UpdatedAt,
+		)
+		if err != nil {
+			return data, err
+		}
+		data.RegistrationCards = append(data.RegistrationCards, c)
+	}
+	if err := cardRows.Err(); err != nil {
+		return data, err
+	}
+
+	textRows, err := m.conn().QueryContext(ctx, `
+		select `+smsColumns+`
+		from sms_outbox
+		where reservation_id in (select id from reservations where `+guestEmailCondition+`)
+		order by id
+	`, email, emailIndex)
+	if err != nil {
+		return data, err
+	}
+	defer textRows.Close()
+
+	for textRows.Next() {
+		msg, err := scanSMSMessage(textRows)
+		if err != nil {
+			return data, err
+		}
+		data.TextMessages = append(data.TextMessages, msg)
+	}
+	if err := textRows.Err(); err != nil {
+		return data, err
+	}
+
+	return data, nil
+}
+
+// EraseGuestData anonymizes the reservations of the guest with an email address and deletes their guest
+// profiles, registration cards and messages. Dates, rooms and statuses are kept so occupancy statistics don't change.
+// It returns the number of reservations anonymized
+func (m *postgresDBRepo) EraseGuestData(email string) (int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	email = models.NormalizeEmail(email)
+	if email == "" {
+		return 0, nil
+	}
+
+	tx, err := m.begin(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	n, err := anonymizeReservations(ctx, tx, guestEmailCondition, email, m.cipher().BlindIndex(email))
+	if err != nil {
+		return 0, err
+	}
+
+	err = deleteGuests(ctx, tx, guestIndexCondition, email, m.cipher().BlindIndex(email))
+	if err != nil {
+		return 0, err
+	}
+
+	// messages to the guest hold their name and stay
+	_, err = tx.ExecContext(ctx, `delete from mail_outbox where lower(trim(to_address)) = $1`, email)
+	if err != nil {
+		return 0, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+
+	return n, nil
+}
+
+// AnonymizeReservationsBefore anonymizes the reservations that ended before the given time, and deletes
+// the guest profiles left without reservations. It returns the number of reservations anonymized
+func (m *postgresDBRepo) AnonymizeReservationsBefore(before time.Time) (int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+
+	tx, err := m.begin(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	n, err := anonymizeReservations(ctx, tx, `end_date < $1 and anonymized_at is null`, before)
+	if err != nil {
+		return 0, err
+	}
+
+	err = deleteGuests(ctx, tx, `id not in (select guest_id from reservations where guest_id is not null)`)
+	if err != nil {
+		return 0, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+
+	return n, nil
+}
+
+// ReencryptReservations encrypts the contact details of all reservations, including those in the trash, under
+// the current key and refreshes their blind index. Reservations are updated batchSize at a time, and those
+// already up to date are left alone. It returns the number of reservations updated
+func (m *postgresDBRepo) ReencryptReservations(batchSize int) (int, error) {
+	if m.cipher() == nil {
+		return 0, errors.New("no encryption keys configured")
+	}
+
+	updated, lastID := 0, 0
+	for {
+		n, last, err := m.reencryptBatch(lastID, batchSize)
+		updated += n
+		if err != nil || last == lastID {
+			return updated, err
+		}
+		lastID = last
+	}
+}
+
+// reencryptBatch re-encrypts up to limit reservations after afterID, returning how many were updated and the
+// id of the last one read
+func (m *postgresDBRepo) reencryptBatch(afterID, limit int) (int, int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	tx, err := m.begin(ctx)
+	if err != nil {
+		return 0, afterID, err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.QueryContext(ctx, `
+		select id, email, phone, email_index from reservations
+		where id > $1
+		order by id
+		limit $2
+		for update
+	`, afterID, limit)
+	if err != nil {
+		return 0, afterID, err
+	}
+	var batch []models.Reservation
+	var indexes []string
+	for rows.Next() {
+		var i models.Reservation
+		var index string
+		if err := rows.Scan(&i.ID, &i.Email, &i.Phone, &index); err != nil {
+			rows.Close()
+			return 0, afterID, err
+		}
+		batch = append(batch, i)
+		indexes = append(indexes, index)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, afterID, err
+	}
+
+	c := m.cipher()
+	n, lastID := 0, afterID
+	for k, res := range batch {
+		lastID = res.ID
+		current := c.Current(res.Email) && c.Current(res.Phone)
+
+		if err := m.openContact(&res); err != nil {
+			return 0, afterID, fmt.Errorf("reservation %d: %w", res.ID, err)
+		}
+		if current && c.BlindIndex(res.Email) == indexes[k] {
+			continue
+		}
+
+		email, phone, emailIndex, err := m.sealContact(res.Email, res.Phone)
+		if err != nil {
+			return 0, afterID, err
+		}
+		_, err = tx.ExecContext(ctx, `update reservations set email = $1, phone = $2, email_index = $3 where id = $4`,
+			email, phone, emailIndex, res.ID)
+		if err != nil {
+			return 0, afterID, err
+		}
+		n++
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, afterID, err
+	}
+
+	return n, lastID, nil
+}
+
+// ReencryptGuests encrypts the contact details of all guests under the current key and refreshes their blind
+// indexes, batchSize guests at a time. Guests already up to date are left alone. It returns the number of
+// guests updated
+func (m *postgresDBRepo) ReencryptGuests(batchSize int) (int, error) {
+	if m.cipher() == nil {
+		return 0, errors.New("no encryption keys configured")
+	}
+
+	updated, lastID := 0, 0
+	for {
+		n, last, err := m.reencryptGuestBatch(lastID, batchSize)
+		updated += n
+		if err != nil || last == lastID {
+			return updated, err
+		}
+		lastID = last
+	}
+}
+
+// reencryptGuestBatch re-encrypts up to limit guests after afterID, returning how many were updated and the
+// id of the last one read
+func (m *postgresDBRepo) reencryptGuestBatch(afterID, limit int) (int, int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	tx, err := m.begin(ctx)
+	if err != nil {
+		return 0, afterID, err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.QueryContext(ctx, `
+		select id, email, phone, email_index, phone_index from guests
+		where id > $1
+		order by id
+		limit $2
+		for update
+	`, afterID, limit)
+	if err != nil {
+		return 0, afterID, err
+	}
+	var batch []models.Guest
+	var indexes []guestContact
+	for rows.Next() {
+		var g models.Guest
+		var index guestContact
+		if err := rows.Scan(&g.ID, &g.Email, &g.Phone, &index.Email, &index.Phone); err != nil {
+			rows.Close()
+			return 0, afterID, err
+		}
+		batch = append(batch, g)
+		indexes = append(indexes, index)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, afterID, err
+	}
+
+	c := m.cipher()
+	n, lastID := 0, afterID
+	for k, g := range batch {
+		lastID = g.ID
+		current := c.Current(g.Email) && c.Current(g.Phone)
+
+		if err := m.openGuest(&g); err != nil {
+			return 0, afterID, fmt.Errorf("guest %d: %w", g.ID, err)
+		}
+		emailIndex, phoneIndex := m.guestIndexes(g.Email, g.Phone)
+		if current && emailIndex == indexes[k].Email && phoneIndex == indexes[k].Phone {
+			continue
+		}
+
+		email, phone, _, err := m.sealContact(g.Email, g.Phone)
+		if err != nil {
+			return 0, afterID, err
+		}
+		_, err = tx.ExecContext(ctx, `
+			update guests set email = $1, phone = $2, email_index = $3, phone_index = $4 where id = $5
+		`, email, phone, emailIndex, phoneIndex, g.ID)
+		if err != nil {
+			return 0, afterID, err
+		}
+		n++
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, afterID, err
+	}
+
+	return n, lastID, nil
+}
+
+// anonymizeReservations removes the personal details of the reservations matching condition within tx,
+// along with their registration cards, their text messages and the details recorded in their audit events
+// and webhook payloads
+func anonymizeReservations(ctx context.Context, tx *txn, condition string, args ...interface{}) (int, error) {
+	selected := `select id from reservations where ` + condition
+
+	_, err := tx.ExecContext(ctx, `
+		update audit_events set before = null, after = null
+		where entity = 'reservation' and entity_id in (select id::text from (`+selected+`) r)
+	`, args...)
+	if err != nil {
+		return 0, err
+	}
+
+	_, err = tx.ExecContext(ctx, `delete from registration_cards where reservation_id in (`+selected+`)`, args...)
+	if err != nil {
+		return 0, err
+	}
+
+	_, err = tx.ExecContext(ctx, `delete from sms_outbox where reservation_id in (`+selected+`)`, args...)
+	if err != nil {
+		return 0, err
+	}
+
+	// deliveries still pending post the anonymized reservation
+	_, err = tx.ExecContext(ctx, `
+		update webhook_deliveries
+		set payload = (payload::jsonb || jsonb_build_object('data', payload::jsonb->'data' || jsonb_build_object(
+			'first_name', '`+models.ErasedFirstName+`', 'last_name', '`+models.ErasedLastName+`', 'email', '', 'phone', ''
+		)))::text
+		where event like 'reservation.%' and payload::jsonb->'data'->>'id' in (select id::text from (`+selected+`) r)
+	`, args...)
+	if err != nil {
+		return 0, err
+	}
+
+	// the parameters of condition come first
+	now := fmt.Sprintf("$%d", len(args)+1)
+	result, err := tx.ExecContext(ctx, `
+		update reservations
+		set first_name = '`+models.ErasedFirstName+`', last_name = '`+models.ErasedLastName+`', email = '', phone = '',
+			email_index = '', guest_id = null, anonymized_at = `+now+`, updated_at = `+now+`
+		where `+condition, append(args, time.Now())...)
+	if err != nil {
+		return 0, err
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+
+	return int(n), nil
+}
+
+// deleteGuests deletes the guests matching condition within tx, with the details recorded in their audit events
+func deleteGuests(ctx context.Context, tx *txn, condition string, args ...interface{}) error {
+	_, err := tx.ExecContext(ctx, `
+		update audit_events set before = null, after = null
+		where entity = 'guest' and entity_id in (select id::text from guests where `+condition+`)
+	`, args...)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, `delete from guests where `+condition, args...)
+	return err
+}
+
+// DashboardCounts returns the arrivals and departures of day, the guests checked in and the reservations
+// waiting for confirmation. Cancelled and no-show reservations neither arrive nor depart
+func (m *postgresDBRepo) DashboardCounts(day time.Time) (models.DashboardCounts, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var c models.DashboardCounts
+	err := m.conn().QueryRowContext(ctx, `
+	select
+		count(id) filter (where start_date = $1::date and status not in ($2, $3)),
+		count(id) filter (where end_date = $1::date and status not in ($2, $3)),
+		count(id) filter (where status = $4),
+		count(id) filter (where status = $5)
+	from reservations
+	where deleted_at is null
+	`, day, models.ReservationCancelled, models.ReservationNoShow, models.ReservationCheckedIn, models.ReservationPending).Scan(
+		&c.Arrivals,
+		&c.Departures,
+		&c.InHouse,
+		&c.Pending,
+	)
+	if err != nil {
+		return c, err
+	}
+
+	return c, nil
+}
+
+// LeadTimes returns how many days before arrival the reservations created since the given time were made
+func (m *postgresDBRepo) LeadTimes(since time.Time) ([]models.LeadTimeBucket, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	buckets := models.LeadTimeBuckets()
+
+	rows, err := m.conn().QueryContext(ctx, `
+	select greatest(start_date - created_at::date, 0) as days, count(id)
+	from reservations
+	where deleted_at is null and created_at >= $1
+	group by days
+	`, since)
+	if err != nil {
+		return buckets, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var days, n int
+		if err := rows.Scan(&days, &n); err != nil {
+			return buckets, err
+		}
+		models.AddLeadTime(buckets, days, n)
+	}
+
+	if err := rows.Err(); err != nil {
+		return buckets, err
+	}
+
+	return buckets, nil
+}
+
+// ImportBookings saves the valid rows in a single transaction. Reservations get a room restriction
+// unless they are cancelled, and blocks get one owner block per night. If a row's room has been booked
+// since the rows were checked nothing is saved, and the error wraps models.ErrRoomUnavailable
+func (m *postgresDBRepo) ImportBookings(rows []models.ImportRow) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+
+	tx, err := m.begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	now := time.Now()
+	for _, row := range rows {
+		if !row.Valid() {
+			continue
+		}
+		res := row.Reservation
+
+		if row.Block || res.Status != models.ReservationCancelled {
+			// serialize with bookings of the same room
+			_, err = tx.ExecContext(ctx, `select id from rooms where id = $1 for update`, res.RoomID)
+			if err != nil {
+				return err
+			}
+
+			var count int
+			err = tx.QueryRowContext(ctx, `
+				select count(id) from room_restrictions
+				where room_id = $1 and $2 < end_date and $3 > start_date
+			`, res.RoomID, res.StartDate, res.EndDate).Scan(&count)
+			if err != nil {
+				return err
+			}
+			if count > 0 {
+				return fmt.Errorf("line %d: %w", row.Line, models.ErrRoomUnavailable)
+			}
+		}
+
+		if row.Block {
+			for d := res.StartDate; d.Before(res.EndDate); d = d.AddDate(0, 0, 1) {
+				_, err = tx.ExecContext(ctx, `
+					insert into room_restrictions (start_date, end_date, room_id, restriction_id, created_at, updated_at)
+					values ($1, $2, $3, $4, $5, $5)
+				`, d, d.AddDate(0, 0, 1), res.RoomID, models.RestrictionOwnerBlock, now)
+				if err != nil {
+					return err
+				}
+			}
+			continue
+		}
+
+		email, phone, emailIndex, err := m.sealContact(res.Email, res.Phone)
+		if err != nil {
+			return err
+		}
+
+		var id int
+		err = tx.QueryRowContext(ctx, importReservationQuery(res.Status), res.FirstName, res.LastName, email, phone, emailIndex, res.StartDate, res.EndDate, res.RoomID, res.Status,
+			now).Scan(&id)
+		if err != nil {
+			return err
+		}
+
+		if res.Status != models.ReservationCancelled {
+			_, err = tx.ExecContext(ctx, `
+				insert into room_restrictions (start_date, end_date, room_id, reservation_id, restriction_id, created_at, updated_at)
+				values ($1, $2, $3, $4, $5, $6, $6)
+			`, res.StartDate, res.EndDate, res.RoomID, id, models.RestrictionReservation, now)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return tx.Commit()
+}
+
+// importReservationQuery returns the insert of an imported reservation in status. The import time is
+// recorded as when the reservation entered its status, if the status has a column for it
+func importReservationQuery(status string) string {
+	columns := "first_name, last_name, email, phone, email_index, start_date, end_date, room_id, status, created_at, updated_at"
+	values := "$1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $10"
+	if column, ok := reservationStatusColumns[status]; ok {
+		columns += ", " + column
+		values += ", $10"
+	}
+	return "insert into reservations (" + columns + ") values (" + values + ") returning id"
+}
+
+// reservationStatusColumns holds the column recording when a reservation entered each status
+var reservationStatusColumns = map[string]string{
+	models.ReservationConfirmed:  "confirmed_at",
+	models.ReservationCheckedIn:  "checked_in_at",
+	models.ReservationCheckedOut: "checked_out_at",
+	models.ReservationCancelled:  "cancelled_at",
+	models.ReservationNoShow:     "no_show_at",
+}
+
+// UpdateReservationStatus moves a reservation to status if its current status allows it, recording when.
+// Cancelling a reservation releases its room restrictions
+func (m *postgresDBRepo) UpdateReservationStatus(id int, status string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := m.begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := updateReservationStatus(ctx, tx, id, status); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// updateReservationStatus moves a reservation to status within tx. See UpdateReservationStatus
+func updateReservationStatus(ctx context.Context, tx *txn, id int, status string) error {
+	var current string
+	var roomID int
+	err := tx.QueryRowContext(ctx, `select status, room_id from reservations where id = $1 and deleted_at is null for update`, id).Scan(&current, &roomID)
+	if err != nil {
+		return err
+	}
+
+	allowed := false
+	for _, from := range models.ReservationStatusesFrom(status) {
+		if from == current {
+			allowed = true
+		}
+	}
+	if !allowed {
+		return &models.TransitionError{From: current, To: status}
+	}
+
+	query := fmt.Sprintf(`
+	update reservations
+	set status = $1, %s = $2, updated_at = $2
+	where id = $3
+	`, reservationStatusColumns[status])
+
+	_, err = tx.ExecContext(ctx, query, status, time.Now(), id)
+	if err != nil {
+		return err
+	}
+
+	if status == models.ReservationCancelled {
+		_, err = tx.ExecContext(ctx, `delete from room_restrictions where reservation_id = $1`, id)
+		if err != nil {
+			return err
+		}
+	}
+
+	if status == models.ReservationCheckedOut {
+		// the room needs cleaning, unless it is out of order anyway
+		_, err = tx.ExecContext(ctx, `
+			insert into room_statuses (room_id, status, created_at, updated_at)
+			values ($1, $2, $3, $3)
+			on conflict (room_id) do update
+			set status = excluded.status, updated_at = excluded.updated_at
+			where room_statuses.status <> $4
+		`, roomID, models.RoomDirty, time.Now(), models.RoomOutOfOrder)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// FrontDeskReservations returns the reservations arriving or leaving on day, and the guests still in house
+// after their departure date
+func (m *postgresDBRepo) FrontDeskReservations(day time.Time) ([]models.Reservation, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var reservations []models.Reservation
+
+	query := `
+	select
+		r.id, r.first_name, r.last_name, r.email, r.phone, r.start_date, r.end_date, r.room_id, r.status,
+		coalesce(r.checked_in_at, '0001-01-01'::timestamp), coalesce(r.checked_out_at, '0001-01-01'::timestamp),
+		coalesce(r.checked_in_by, 0), coalesce(r.checked_out_by, 0),
+		rm.id, rm.room_name
+	from reservations r
+	left join rooms rm on (r.room_id = rm.id)
+	where r.deleted_at is null and r.status not in ($2, $3)
+		and (r.start_date = $1 or r.end_date = $1 or (r.status = $4 and r.end_date < $1))
+	order by rm.room_name, r.start_date, r.id
+	`
+
+	rows, err := m.conn().QueryContext(ctx, query, day, models.ReservationCancelled, models.ReservationNoShow,
+		models.ReservationCheckedIn)
+	if err != nil {
+		return reservations, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var i models.Reservation
+		err := rows.Scan(
+			&i.ID,
+			&i.FirstName,
+			&i.LastName,
+			&i.Email,
+			&i.Phone,
+			&i.StartDate,
+			&i.EndDate,
+			&i.RoomID,
+			&i.Status,
+			&i.CheckedInAt,
+			&i.CheckedOutAt,
+			&i.CheckedInBy,
+			&i.CheckedOutBy,
+			&i.Room.ID,
+			&i.Room.RoomName,
+		)
+		if err != nil {
+			return reservations, err
+		}
+		if err := m.openContact(&i); err != nil {
+			return reservations, err
+		}
+		reservations = append(reservations, i)
+	}
+
+	if err := rows.Err(); err != nil {
+		return reservations, err
+	}
+
+	return reservations, nil
+}
+
+// CheckIn checks a guest in, recording the staff member and the registration card
+func (m *postgresDBRepo) CheckIn(id, userID int, card models.RegistrationCard) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := m.begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := updateReservationStatus(ctx, tx, id, models.ReservationCheckedIn); err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, `update reservations set checked_in_by = nullif($1, 0) where id = $2`, userID, id)
+	if err != nil {
+		return err
+	}
+
+	card.ReservationID = id
+	if err := saveRegistrationCard(ctx, tx, card); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// CheckOut checks a guest out, recording the staff member
+func (m *postgresDBRepo) CheckOut(id, userID int) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := m.begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := updateReservationStatus(ctx, tx, id, models.ReservationCheckedOut); err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, `update reservations set checked_out_by = nullif($1, 0) where id = $2`, userID, id)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// GetRegistrationCard returns the registration card of a reservation, or sql.ErrNoRows if none was filled in
+func (m *postgresDBRepo) GetRegistrationCard(reservationID int) (models.RegistrationCard, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var c models.RegistrationCard
+
+	query := `
+	select id, reservation_id, address, id_type, id_number, vehicle_plate, created_at, updated_at
+	from registration_cards
+	where reservation_id = $1
+	`
+
+	err := m.conn().QueryRowContext(ctx, query, reservationID).Scan(
+		&c.ID,
+		&c.ReservationID,
+		&c.Address,
+		&c.IDType,
+		&c.IDNumber,
+		&c.VehiclePlate,
+		&c.CreatedAt,
+		&c.UpdatedAt,
+	)
+	if err != nil {
+		return c, err
+	}
+
+	return c, nil
+}
+
+// SaveRegistrationCard creates or replaces the registration card of a reservation
+func (m *postgresDBRepo) SaveRegistrationCard(card models.RegistrationCard) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := m.begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var id int
+	err = tx.QueryRowContext(ctx, `select id from reservations where id = $1 and deleted_at is null`, card.ReservationID).Scan(&id)
+	if err != nil {
+		return err
+	}
+
+	if err := saveRegistrationCard(ctx, tx, card); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// saveRegistrationCard upserts a registration card within tx
+func saveRegistrationCard(ctx context.Context, tx *txn, card models.RegistrationCard) error {
+	_, err := tx.ExecContext(ctx, `
+		insert into registration_cards
+			(reservation_id, address, id_type, id_number, vehicle_plate, created_at, updated_at)
+		values ($1, $2, $3, $4, $5, $6, $6)
+		on conflict (reservation_id) do update
+		set address = excluded.address, id_type = excluded.id_type, id_number = excluded.id_number,
+			vehicle_plate = excluded.vehicle_plate, updated_at = excluded.updated_at
+	`, card.ReservationID, card.Address, card.IDType, card.IDNumber, card.VehiclePlate, time.Now())
+	return err
+}
+
+// AllRooms return a slice of models.Room
+func (m *postgresDBRepo) AllRooms() ([]models.Room, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var rooms []models.Room
+
+	query := `
+	select id, room_name, created_at, updated_at
+	from rooms
+	`
+
+	rows, err := m.conn().QueryContext(ctx, query)
+
+	if err != nil {
+		return rooms, err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var r models.Room
+
+		err := rows.Scan(
+			&r.ID,
+			&r.RoomName,
+			&r.CreatedAt,
+			&r.UpdatedAt,
+		)
+		if err != nil {
+			return rooms, err
+		}
+		rooms = append(rooms, r)
+	}
+
+	return rooms, nil
+}
+
+// GetRestrictionsForRoomByDate gets restrcition from room_restrictions table for start, end date
+func (m *postgresDBRepo) GetRestrictionsForRoomByDate(roomID int, start, end time.Time) ([]models.RoomRestriction, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var restrictions []models.RoomRestriction
+
+	query := `
+	select id, coalesce(reservation_id, 0), restriction_id, room_id, start_date, end_date, version,
+		coalesce(source_id, 0), source_uid
+	from room_restrictions 
+	where room_id = $1 and $2 < end_date and $3 >= start_date
+	`
+
+	rows, err := m.conn().QueryContext(ctx, query, roomID, start, end)
+
+	if err != nil {
+		return restrictions, err
+	}
+
+	for rows.Next() {
+		var r models.RoomRestriction
+		err := rows.Scan(
+			&r.ID,
+			&r.ReservationID,
+			&r.RestrictionID,
+			&r.RoomID,
+			&r.StartDate,
+			&r.EndDate,
+			&r.Version,
+			&r.SourceID,
+			&r.SourceUID,
+		)
+		if err != nil {
+			return restrictions, err
+		}
+		restrictions = append(restrictions, r)
+	}
+
+	if rows.Err() != nil {
+		return restrictions, rows.Err()
+	}
+
+	return restrictions, nil
+}
+
+// InsertBlockForRoom inserts block for a particular room
+func (m *postgresDBRepo) InsertBlockForRoom(id int, startDate time.Time) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	query := `
+		insert into 
+		room_restrictions(start_date,end_date, room_id, restriction_id, created_at, updated_at)
+		values($1, $2, $3, $4, $5, $6)
+	`
+
+	_, err := m.conn().ExecContext(ctx, query,
+		startDate,
+		startDate.AddDate(0, 0, 1),
+		id,
+		2,
+		time.Now(),
+		time.Now(),
+	)
+
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// DeleteBlockByID deletes block for a particular room
+func (m *postgresDBRepo) DeleteBlockByID(id int) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	query := `
+		delete from room_restrictions where id = $1
+	`
+
+	_, err := m.conn().ExecContext(ctx, query, id)
+
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// ApplyBlockChanges applies calendar block changes in a single transaction. Removals only succeed
+// against the version the admin saw, and additions only succeed on free days. If any change
+// conflicts, nothing is applied and the conflicts are returned. Replaying the same changes is a no-op.
+func (m *postgresDBRepo) ApplyBlockChanges(changes []models.BlockChange) ([]models.BlockConflict, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var conflicts []models.BlockConflict
+
+	tx, err := m.begin(ctx)
+	if err != nil {
+		return conflicts, err
+	}
+	defer tx.Rollback()
+
+	// lock rooms in a stable order so concurrent saves serialize instead of deadlocking
+	sorted := make([]models.BlockChange, len(changes))
+	copy(sorted, changes)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].RoomID < sorted[j].RoomID
+	})
+
+	locked := make(map[int]bool)
+	for _, c := range sorted {
+		if locked[c.RoomID] {
+			continue
+		}
+		var id int
+		err := tx.QueryRowContext(ctx, `select id from rooms where id = $1 for update`, c.RoomID).Scan(&id)
+		if err != nil {
+			return conflicts, err
+		}
+		locked[c.RoomID] = true
+	}
+
+	for _, c := range sorted {
+		switch c.Action {
+		case models.BlockActionRemove:
+			res, err := tx.ExecContext(ctx, `
+				delete from room_restrictions
+				where id = $1 and room_id = $2 and restriction_id = $3 and version = $4
+			`, c.BlockID, c.RoomID, models.RestrictionOwnerBlock, c.Version)
+			if err != nil {
+				return conflicts, err
+			}
+			n, err := res.RowsAffected()
+			if err != nil {
+				return conflicts, err
+			}
+			if n > 0 {
+				continue
+			}
+
+			// the block is gone or has a newer version; a block added on the day since the calendar
+			// was loaded is a change too, so only a day left free counts as already removed
+			var changed int
+			err = tx.QueryRowContext(ctx, `
+				select count(id) from room_restrictions
+				where id = $1 or (room_id = $2 and restriction_id = $3 and start_date = $4)
+			`, c.BlockID, c.RoomID, models.RestrictionOwnerBlock, c.Date).Scan(&changed)
+			if err != nil {
+				return conflicts, err
+			}
+			if changed == 0 {
+				// already removed by an earlier save
+				continue
+			}
+			conflicts = append(conflicts, models.BlockConflict{
+				Change: c,
+				Reason: "block was changed by someone else",
+			})
+
+		case models.BlockActionAdd:
+			var bookedCount, blockCount int
+			err := tx.QueryRowContext(ctx, `
+				select
+					count(id) filter (where restriction_id <> $4),
+					count(id) filter (where restriction_id = $4 and start_date = $2)
+				from room_restrictions
+				where room_id = $1 and $2 < end_date and $3 > start_date
+			`, c.RoomID, c.Date, c.Date.AddDate(0, 0, 1), models.RestrictionOwnerBlock).Scan(&bookedCount, &blockCount)
+			if err != nil {
+				return conflicts, err
+			}
+			if bookedCount > 0 {
+				conflicts = append(conflicts, models.BlockConflict{
+					Change: c,
+					Reason: "room is booked on this day",
+				})
+				continue
+			}
+			if blockCount > 0 {
+				// already blocked by an earlier save
+				continue
+			}
+
+			_, err = tx.ExecContext(ctx, `
+				insert into
+				room_restrictions(start_date, end_date, room_id, restriction_id, created_at, updated_at)
+				values($1, $2, $3, $4, $5, $6)
+			`, c.Date, c.Date.AddDate(0, 0, 1), c.RoomID, models.RestrictionOwnerBlock, time.Now(), time.Now())
+			if err != nil {
+				return conflicts, err
+			}
+
+		default:
+			return conflicts, fmt.Errorf("unknown block action %q", c.Action)
+		}
+	}
+
+	if len(conflicts) > 0 {
+		return conflicts, nil
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return nil, nil
+}
+
+// GetRestrictionsForFeed returns restrictions ending after since, with room, restriction and
+// reservation details filled in. A roomID of 0 returns restrictions for all rooms
+func (m *postgresDBRepo) GetRestrictionsForFeed(roomID int, since time.Time) ([]models.RoomRestriction, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var restrictions []models.RoomRestriction
+
+	query := `
+	select
+		rr.id, coalesce(rr.reservation_id, 0), rr.restriction_id, rr.room_id,
+		rr.start_date, rr.end_date, rr.updated_at,
+		rm.room_name, rs.restriction_name,
+		coalesce(r.first_name, ''), coalesce(r.last_name, ''), coalesce(r.email, ''),
+		coalesce(r.phone, ''), coalesce(r.updated_at, rr.updated_at)
+	from room_restrictions rr
+	left join rooms rm on (rr.room_id = rm.id)
+	left join restrictions rs on (rr.restriction_id = rs.id)
+	left join reservations r on (rr.reservation_id = r.id)
+	where rr.end_date >= $1 and ($2 = 0 or rr.room_id = $2)
+	order by rr.start_date asc
+	`
+
+	rows, err := m.conn().QueryContext(ctx, query, since, roomID)
+	if err != nil {
+		return restrictions, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var r models.RoomRestriction
+		err := rows.Scan(
+			&r.ID,
+			&r.ReservationID,
+			&r.RestrictionID,
+			&r.RoomID,
+			&r.StartDate,
+			&r.EndDate,
+			&r.UpdatedAt,
+			&r.Room.RoomName,
+			&r.Restriction.RestrictionName,
+			&r.Reservation.FirstName,
+			&r.Reservation.LastName,
+			&r.Reservation.Email,
+			&r.Reservation.Phone,
+			&r.Reservation.UpdatedAt,
+		)
+		if err != nil {
+			return restrictions, err
+		}
+		if err := m.openContact(&r.Reservation); err != nil {
+			return restrictions, err
+		}
+		r.Room.ID = r.RoomID
+		r.Restriction.ID = r.RestrictionID
+		restrictions = append(restrictions, r)
+	}
+
+	if err := rows.Err(); err != nil {
+		return restrictions, err
+	}
+
+	return restrictions, nil
+}
+
+// ReservationsArriving returns the reservations arriving from start up to, but not including, end,
+// whatever their status
+func (m *postgresDBRepo) ReservationsArriving(start, end time.Time) ([]models.Reservation, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var reservations []models.Reservation
+
+	query := `
+	select
+		r.id, r.first_name, r.last_name, r.start_date, r.end_date, r.room_id, r.status,
+		coalesce(r.cancelled_at, '0001-01-01'::timestamp), r.created_at,
+		rm.id, rm.room_name
+	from reservations r
+	left join rooms rm on (r.room_id = rm.id)
+	where r.deleted_at is null and r.start_date >= $1 and r.start_date < $2
+	order by r.start_date, r.id
+	`
+
+	rows, err := m.conn().QueryContext(ctx, query, start, end)
+	if err != nil {
+		return reservations, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var i models.Reservation
+		err := rows.Scan(
+			&i.ID,
+			&i.FirstName,
+			&i.LastName,
+			&i.StartDate,
+			&i.EndDate,
+			&i.RoomID,
+			&i.Status,
+			&i.CancelledAt,
+			&i.CreatedAt,
+			&i.Room.ID,
+			&i.Room.RoomName,
+		)
+		if err != nil {
+			return reservations, err
+		}
+		reservations = append(reservations, i)
+	}
+
+	if err := rows.Err(); err != nil {
+		return reservations, err
+	}
+
+	return reservations, nil
+}
+
+// RestrictionsBetween returns the room restrictions of every room overlapping the nights from start up to,
+// but not including, end
+func (m *postgresDBRepo) RestrictionsBetween(start, end time.Time) ([]models.RoomRestriction, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var restrictions []models.RoomRestriction
+
+	query := `
+	select
+		rr.id, rr.start_date, rr.end_date, rr.room_id, coalesce(rr.reservation_id, 0),
+		rr.restriction_id, coalesce(rr.source_id, 0), rm.room_name
+	from room_restrictions rr
+	left join rooms rm on (rr.room_id = rm.id)
+	where rr.start_date < $2 and rr.end_date > $1
+	order by rr.start_date, rr.id
+	`
+
+	rows, err := m.conn().QueryContext(ctx, query, start, end)
+	if err != nil {
+		return restrictions, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var r models.RoomRestriction
+		err := rows.Scan(
+			&r.ID,
+			&r.StartDate,
+			&r.EndDate,
+			&r.RoomID,
+			&r.ReservationID,
+			&r.RestrictionID,
+			&r.SourceID,
+			&r.Room.RoomName,
+		)
+		if err != nil {
+			return restrictions, err
+		}
+		r.Room.ID = r.RoomID
+		restrictions = append(restrictions, r)
+	}
+
+	if err := rows.Err(); err != nil {
+		return restrictions, err
+	}
+
+	return restrictions, nil
+}
+
+// RoomStatuses returns the housekeeping status of every room. Rooms never marked are clean
+func (m *postgresDBRepo) RoomStatuses() ([]models.RoomStatus, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var statuses []models.RoomStatus
+
+	query := `
+	select
+		rm.id, rm.room_name, coalesce(s.status, $1), coalesce(s.note, ''), coalesce(s.block_id, 0),
+		coalesce(s.updated_by, 0), coalesce(s.updated_at, '0001-01-01'::timestamp)
+	from rooms rm
+	left join room_statuses s on (s.room_id = rm.id)
+	order by rm.id
+	`
+
+	rows, err := m.conn().QueryContext(ctx, query, models.RoomClean)
+	if err != nil {
+		return statuses, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var s models.RoomStatus
+		err := rows.Scan(
+			&s.RoomID,
+			&s.Room.RoomName,
+			&s.Status,
+			&s.Note,
+			&s.BlockID,
+			&s.UpdatedBy,
+			&s.UpdatedAt,
+		)
+		if err != nil {
+			return statuses, err
+		}
+		s.Room.ID = s.RoomID
+		statuses = append(statuses, s)
+	}
+
+	if err := rows.Err(); err != nil {
+		return statuses, err
+	}
+
+	return statuses, nil
+}
+
+// GetRoomStatus returns the housekeeping status of a room
+func (m *postgresDBRepo) GetRoomStatus(roomID int) (models.RoomStatus, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var s models.RoomStatus
+
+	query := `
+	select
+		rm.id, rm.room_name, coalesce(s.status, $2), coalesce(s.note, ''), coalesce(s.block_id, 0),
+		coalesce(s.updated_by, 0), coalesce(s.updated_at, '0001-01-01'::timestamp)
+	from rooms rm
+	left join room_statuses s on (s.room_id = rm.id)
+	where rm.id = $1
+	`
+
+	err := m.conn().QueryRowContext(ctx, query, roomID, models.RoomClean).Scan(
+		&s.RoomID,
+		&s.Room.RoomName,
+		&s.Status,
+		&s.Note,
+		&s.BlockID,
+		&s.UpdatedBy,
+		&s.UpdatedAt,
+	)
+	if err != nil {
+		return s, err
+	}
+	s.Room.ID = s.RoomID
+
+	return s, nil
+}
+
+// UpdateRoomStatus sets the housekeeping status of a room. Putting a room out of order blocks the free
+// nights among the next outOfOrderNights, and the out of order job keeps extending that block until the
+// room is back in order, which ends the block tonight
+func (m *postgresDBRepo) UpdateRoomStatus(s models.RoomStatus) (models.RoomStatus, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := m.begin(ctx)
+	if err != nil {
+		return s, err
+	}
+	defer tx.Rollback()
+
+	var roomID int
+	err = tx.QueryRowContext(ctx, `select id from rooms where id = $1 for update`, s.RoomID).Scan(&roomID)
+	if err != nil {
+		return s, err
+	}
+
+	var current string
+	var blockID int
+	err = tx.QueryRowContext(ctx, `
+		select status, coalesce(block_id, 0) from room_statuses where room_id = $1
+	`, s.RoomID).Scan(&current, &blockID)
+	if err == sql.ErrNoRows {
+		current = models.RoomClean
+	} else if err != nil {
+		return s, err
+	}
+
+	now := time.Now()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+
+	switch {
+	case s.Status == models.RoomOutOfOrder && current != models.RoomOutOfOrder:
+		blockID, err = blockOutOfOrderRoom(ctx, tx, s.RoomID, 0, today)
+		if err != nil {
+			return s, err
+		}
+
+	case s.Status != models.RoomOutOfOrder && current == models.RoomOutOfOrder:
+		if blockID > 0 {
+			// free the room from tonight, keeping the nights it was out of order
+			_, err := tx.ExecContext(ctx, `
+				delete from room_restrictions where id = $1 and restriction_id = $2 and start_date >= $3
+			`, blockID, models.RestrictionOwnerBlock, today)
+			if err != nil {
+				return s, err
+			}
+			_, err = tx.ExecContext(ctx, `
+				update room_restrictions set end_date = $3, version = version + 1, updated_at = $4
+				where id = $1 and restriction_id = $2 and end_date > $3
+			`, blockID, models.RestrictionOwnerBlock, today, now)
+			if err != nil {
+				return s, err
+			}
+		}
+		blockID = 0
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		insert into room_statuses (room_id, status, note, block_id, updated_by, created_at, updated_at)
+		values ($1, $2, $3, nullif($4, 0), nullif($5, 0), $6, $6)
+		on conflict (room_id) do update
+		set status = excluded.status, note = excluded.note, block_id = excluded.block_id,
+			updated_by = excluded.updated_by, updated_at = excluded.updated_at
+	`, s.RoomID, s.Status, s.Note, blockID, s.UpdatedBy, now)
+	if err != nil {
+		return s, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return s, err
+	}
+
+	s.BlockID = blockID
+	s.UpdatedAt = now
+	return s, nil
+}
+
+// outOfOrderNights is how many nights from today a room that is out of order is kept blocked. It is
+// more than one so that the room stays blocked if the daily job runs late
+const outOfOrderNights = 2
+
+// blockOutOfOrderRoom blocks the free nights of a room among the next outOfOrderNights from today within
+// tx, extending its out of order block blockID where it can, and returns the id of the block covering
+// the latest night. A night already booked or blocked is left as it is. The room must be locked by tx
+func blockOutOfOrderRoom(ctx context.Context, tx *txn, roomID, blockID int, today time.Time) (int, error) {
+	var start, end time.Time
+	if blockID > 0 {
+		err := tx.QueryRowContext(ctx, `
+			select start_date, end_date from room_restrictions where id = $1 and restriction_id = $2
+		`, blockID, models.RestrictionOwnerBlock).Scan(&start, &end)
+		if err == sql.ErrNoRows {
+			blockID = 0
+		} else if err != nil {
+			return blockID, err
+		}
+	}
+
+	now := time.Now()
+	for d := today; d.Before(today.AddDate(0, 0, outOfOrderNights)); d = d.AddDate(0, 0, 1) {
+		if blockID > 0 && !d.Before(start) && d.Before(end) {
+			continue
+		}
+
+		var booked int
+		err := tx.QueryRowContext(ctx, `
+			select count(id) from room_restrictions
+			where room_id = $1 and $2 < end_date and $3 > start_date
+		`, roomID, d, d.AddDate(0, 0, 1)).Scan(&booked)
+		if err != nil {
+			return blockID, err
+		}
+		if booked > 0 {
+			continue
+		}
+
+		if blockID > 0 && end.Equal(d) {
+			_, err = tx.ExecContext(ctx, `
+				update room_restrictions set end_date = $2, version = version + 1, updated_at = $3 where id = $1
+			`, blockID, d.AddDate(0, 0, 1), now)
+			if err != nil {
+				return blockID, err
+			}
+			end = d.AddDate(0, 0, 1)
+			continue
+		}
+
+		err = tx.QueryRowContext(ctx, `
+			insert into
+			room_restrictions(start_date, end_date, room_id, restriction_id, created_at, updated_at)
+			values($1, $2, $3, $4, $5, $5)
+			returning id
+		`, d, d.AddDate(0, 0, 1), roomID, models.RestrictionOwnerBlock, now).Scan(&blockID)
+		if err != nil {
+			return blockID, err
+		}
+		start, end = d, d.AddDate(0, 0, 1)
+	}
+
+	return blockID, nil
+}
+
+// ExtendOutOfOrderBlocks keeps the rooms that are out of order blocked for the next outOfOrderNights from
+// today, and returns how many rooms are out of order
+func (m *postgresDBRepo) ExtendOutOfOrderBlocks(today time.Time) (int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := m.begin(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	// lock the rooms first, in the order ApplyBlockChanges locks them
+	rows, err := tx.QueryContext(ctx, `
+		select rm.id, coalesce(s.block_id, 0)
+		from rooms rm
+		join room_statuses s on (s.room_id = rm.id)
+		where s.status = $1
+		order by rm.id
+		for update of rm
+	`, models.RoomOutOfOrder)
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+
+	blocks := make(map[int]int)
+	var roomIDs []int
+	for rows.Next() {
+		var roomID, blockID int
+		if err := rows.Scan(&roomID, &blockID); err != nil {
+			return 0, err
+		}
+		roomIDs = append(roomIDs, roomID)
+		blocks[roomID] = blockID
+	}
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	for _, roomID := range roomIDs {
+		blockID, err := blockOutOfOrderRoom(ctx, tx, roomID, blocks[roomID], today)
+		if err != nil {
+			return 0, err
+		}
+		if blockID == blocks[roomID] {
+			continue
+		}
+		_, err = tx.ExecContext(ctx, `
+			update room_statuses set block_id = nullif($2, 0) where room_id = $1
+		`, roomID, blockID)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+
+	return len(roomIDs), nil
+}
+
+// guestColumns are the columns scanned by scanGuest, with the number of stays and the last arrival
+const guestColumns = `
+	g.id, g.first_name, g.last_name, g.email, g.phone, g.notes, g.created_at, g.updated_at,
+	(select count(*) from reservations r where r.guest_id = g.id and r.deleted_at is null),
+	coalesce((select max(r.start_date) from reservations r where r.guest_id = g.id and r.deleted_at is null),
+		'0001-01-01'::date) as last_stay
+`
+
+// scanGuest scans a row selected with guestColumns
+func scanGuest(row interface{ Scan(...interface{}) error }) (models.Guest, error) {
+	var g models.Guest
+	err := row.Scan(
+		&g.ID,
+		&g.FirstName,
+		&g.LastName,
+		&g.Email,
+		&g.Phone,
+		&g.Notes,
+		&g.CreatedAt,
+		&g.UpdatedAt,
+		&g.Stays,
+		&g.LastStay,
+	)
+	return g, err
+}
+
+// queryGuests returns the guests selected by query, which must select guestColumns
+func (m *postgresDBRepo) queryGuests(query string, args ...interface{}) ([]models.Guest, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var guests []models.Guest
+
+	rows, err := m.conn().QueryContext(ctx, query, args...)
+	if err != nil {
+		return guests, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		g, err := scanGuest(rows)
+		if err != nil {
+			return guests, err
+		}
+		if err := m.openGuest(&g); err != nil {
+			return guests, err
+		}
+		guests = append(guests, g)
+	}
+
+	if err := rows.Err(); err != nil {
+		return guests, err
+	}
+
+	return guests, nil
+}
+
+// guestContact is a guest with the indexes of the contact details guests are matched on
+type guestContact struct {
+	ID    int
+	Email string
+	Phone string
+}
+
+// matchGuest returns the id of the guest among candidates who is the person with the normalized email and
+// phone, or 0 if there is none. The email address decides; the phone number only matches when one of
+// the two has no email address, as people sharing a phone each have their own
+func matchGuest(candidates []guestContact, email, phone string) int {
+	for _, c := range candidates {
+		if email != "" && c.Email == email {
+			return c.ID
+		}
+	}
+	for _, c := range candidates {
+		if phone != "" && c.Phone == phone && (email == "" || c.Email == "") {
+			return c.ID
+		}
+	}
+	return 0
+}
+
+// findOrCreateGuest returns the id of the guest g is, see matchGuest, creating one within tx if there is
+// none. Contact details the matched guest is missing are filled in from g. The contact details are
+// locked until tx ends, so concurrent bookings of a new guest create one profile
+func (m *postgresDBRepo) findOrCreateGuest(ctx context.Context, tx *txn, g models.Guest) (int, error) {
+	email := models.NormalizeEmail(g.Email)
+	phone := models.NormalizePhone(g.Phone)
+	emailIndex, phoneIndex := m.guestIndexes(g.Email, g.Phone)
+
+	// the email is always locked before the phone, so two bookings can't wait on each other
+	var keys []string
+	if email != "" {
+		keys = append(keys, "guest email "+email)
+	}
+	if phone != "" {
+		keys = append(keys, "guest phone "+phone)
+	}
+	for _, key := range keys {
+		if _, err := tx.ExecContext(ctx, `select pg_advisory_xact_lock(hashtext($1))`, key); err != nil {
+			return 0, err
+		}
+	}
+
+	// guests saved before encryption was enabled are indexed by the normalized values
+	rows, err := tx.QueryContext(ctx, `
+		select id, email_index, phone_index from guests
+		where ($1 <> '' and email_index in ($1, $2)) or ($3 <> '' and phone_index in ($3, $4))
+		order by id
+	`, email, emailIndex, phone, phoneIndex)
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+
+	var candidates []guestContact
+	for rows.Next() {
+		var c guestContact
+		if err := rows.Scan(&c.ID, &c.Email, &c.Phone); err != nil {
+			return 0, err
+		}
+		if c.Email == emailIndex {
+			c.Email = email
+		}
+		if c.Phone == phoneIndex {
+			c.Phone = phone
+		}
+		candidates = append(candidates, c)
+	}
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	sealedEmail, sealedPhone, _, err := m.sealContact(strings.TrimSpace(g.Email), strings.TrimSpace(g.Phone))
+	if err != nil {
+		return 0, err
+	}
+
+	id := matchGuest(candidates, email, phone)
+	if id == 0 {
+		err = tx.QueryRowContext(ctx, `
+			insert into guests
+				(first_name, last_name, email, phone, email_index, phone_index, created_at, updated_at)
+			values ($1, $2, $3, $4, $5, $6, $7, $7)
+			returning id
+		`, g.FirstName, g.LastName, sealedEmail, sealedPhone, emailIndex, phoneIndex, time.Now()).Scan(&id)
+		return id, err
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		update guests
+		set email = case when email = '' then $2 else email end,
+			email_index = case when email_index = '' then $3 else email_index end,
+			phone = case when phone = '' then $4 else phone end,
+			phone_index = case when phone_index = '' then $5 else phone_index end,
+			updated_at = $6
+		where id = $1
+	`, id, sealedEmail, emailIndex, sealedPhone, phoneIndex, time.Now())
+	if err != nil {
+		return 0, err
+	}
+
+	return id, nil
+}
+
+// SearchGuests returns up to limit guests whose name, email or phone contains q, most recent stays first.
+// Encrypted contact details only match a search for the whole email address or phone number
+func (m *postgresDBRepo) SearchGuests(q string, limit int) ([]models.Guest, error) {
+	query := `
+	select ` + guestColumns + `
+	from guests g
+	where $1 = '' or g.first_name || ' ' || g.last_name ilike $2
+		or (g.email not like 'enc:%' and g.email ilike $2) or (g.phone not like 'enc:%' and g.phone ilike $2)
+		or ($4 <> '' and g.email_index = $4) or ($5 <> '' and g.phone_index = $5)
+	order by last_stay desc, g.last_name, g.first_name, g.id
+	limit $3
+	`
+	emailIndex, phoneIndex := m.guestIndexes(q, q)
+	return m.queryGuests(query, q, "%"+escapeLike(q)+"%", limit, emailIndex, phoneIndex)
+}
+
+// GetGuestByID returns a guest
+func (m *postgresDBRepo) GetGuestByID(id int) (models.Guest, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	query := `select ` + guestColumns + ` from guests g where g.id = $1`
+	g, err := scanGuest(m.conn().QueryRowContext(ctx, query, id))
+	if err != nil {
+		return g, err
+	}
+	err = m.openGuest(&g)
+	return g, err
+}
+
+// PossibleDuplicateGuests returns the other guests with the same name, email address or phone number as g
+func (m *postgresDBRepo) PossibleDuplicateGuests(g models.Guest) ([]models.Guest, error) {
+	query := `
+	select ` + guestColumns + `
+	from guests g
+	where g.id <> $1 and (
+		(lower(g.first_name) = lower($2) and lower(g.last_name) = lower($3))
+		or ($4 <> '' and g.email_index in ($4, $6))
+		or ($5 <> '' and g.phone_index in ($5, $7))
+	)
+	order by g.id
+	`
+	emailIndex, phoneIndex := m.guestIndexes(g.Email, g.Phone)
+	return m.queryGuests(query, g.ID, strings.TrimSpace(g.FirstName), strings.TrimSpace(g.LastName),
+		models.NormalizeEmail(g.Email), models.NormalizePhone(g.Phone), emailIndex, phoneIndex)
+}
+
+// GuestReservations returns the reservations of a guest, latest first
+func (m *postgresDBRepo) GuestReservations(guestID int) ([]models.Reservation, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var reservations []models.Reservation
+
+	query := `
+	select r.id, r.first_name, r.last_name, r.email, r.phone, r.start_date, r.end_date, r.room_id, r.status,
+		r.created_at, rm.id, rm.room_name
+	from reservations r
+	left join rooms rm on (r.room_id = rm.id)
+	where r.guest_id = $1 and r.deleted_at is null
+	order by r.start_date desc, r.id desc
+	`
+
+	rows, err := m.conn().QueryContext(ctx, query, guestID)
+	if err != nil {
+		return reservations, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var i models.Reservation
+		err := rows.Scan(
+			&i.ID,
+			&i.FirstName,
+			&i.LastName,
+			&i.Email,
+			&i.Phone,
+			&i.StartDate,
+			&i.EndDate,
+			&i.RoomID,
+			&i.Status,
+			&i.CreatedAt,
+			&i.Room.ID,
+			&i.Room.RoomName,
+		)
+		if err != nil {
+			return reservations, err
+		}
+		if err := m.openContact(&i); err != nil {
+			return reservations, err
+		}
+		i.GuestID = guestID
+		reservations = append(reservations, i)
+	}
+
+	if err := rows.Err(); err != nil {
+		return reservations, err
+	}
+
+	return reservations, nil
+}
+
+// UpdateGuest updates the details and notes of a guest
+func (m *postgresDBRepo) UpdateGuest(g models.Guest) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	query := `
+	update guests
+	set first_name = $1, last_name = $2, email = $3, phone = $4, email_index = $5, phone_index = $6,
+		notes = $7, updated_at = $8
+	where id = $9
+	`
+
+	email, phone, _, err := m.sealContact(strings.TrimSpace(g.Email), strings.TrimSpace(g.Phone))
+	if err != nil {
+		return err
+	}
+	emailIndex, phoneIndex := m.guestIndexes(g.Email, g.Phone)
+
+	result, err := m.conn().ExecContext(ctx, query,
+		g.FirstName,
+		g.LastName,
+		email,
+		phone,
+		emailIndex,
+		phoneIndex,
+		g.Notes,
+		time.Now(),
+		g.ID,
+	)
+	if err != nil {
+		return err
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return sql.ErrNoRows
+	}
+
+	return nil
+}
+
+// MergeGuests moves the reservations of the duplicate guest to the kept one and deletes the duplicate.
+// The kept guest takes over the notes and any contact details it is missing
+func (m *postgresDBRepo) MergeGuests(keepID, duplicateID int) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	if keepID == duplicateID {
+		return errors.New("cannot merge a guest into itself")
+	}
+
+	tx, err := m.begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// lock both guests in a stable order
+	var locked int
+	err = tx.QueryRowContext(ctx, `
+		select count(*) from (select id from guests where id in ($1, $2) order by id for update) g
+	`, keepID, duplicateID).Scan(&locked)
+	if err != nil {
+		return err
+	}
+	if locked != 2 {
+		return sql.ErrNoRows
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		update guests k
+		set email = case when k.email = '' then d.email else k.email end,
+			email_index = case when k.email_index = '' then d.email_index else k.email_index end,
+			phone = case when k.phone = '' then d.phone else k.phone end,
+			phone_index = case when k.phone_index = '' then d.phone_index else k.phone_index end,
+			notes = case
+				when d.notes = '' then k.notes
+				when k.notes = '' then d.notes
+				else k.notes || E'\n\n' || d.notes
+			end,
+			updated_at = $3
+		from guests d
+		where k.id = $1 and d.id = $2
+	`, keepID, duplicateID, time.Now())
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, `update reservations set guest_id = $1 where guest_id = $2`, keepID, duplicateID)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, `delete from guests where id = $1`, duplicateID)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// AllICalSources returns all iCalendar import sources with their rooms
+func (m *postgresDBRepo) AllICalSources() ([]models.ICalSource, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var sources []models.ICalSource
+
+	query := `
+	select
+		s.id, s.room_id, s.name, s.url, coalesce(s.last_synced_at, '0001-01-01'::timestamp), s.last_status,
+		s.created_at, s.updated_at, rm.id, rm.room_name
+	from ical_sources s
+	left join rooms rm on (s.room_id = rm.id)
+	order by rm.room_name asc, s.name asc
+	`
+
+	rows, err := m.conn().QueryContext(ctx, query)
+	if err != nil {
+		return sources, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var i models.ICalSource
+		err := rows.Scan(
+			&i.ID,
+			&i.RoomID,
+			&i.Name,
+			&i.URL,
+			&i.LastSyncedAt,
+			&i.LastStatus,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Room.ID,
+			&i.Room.RoomName,
+		)
+		if err != nil {
+			return sources, err
+		}
+		sources = append(sources, i)
+	}
+
+	if err := rows.Err(); err != nil {
+		return sources, err
+	}
+
+	return sources, nil
+}
+
+// GetICalSourceByID returns an iCalendar import source
+func (m *postgresDBRepo) GetICalSourceByID(id int) (models.ICalSource, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var source models.ICalSource
+
+	query := `
+	select
+		s.id, s.room_id, s.name, s.url, coalesce(s.last_synced_at, '0001-01-01'::timestamp), s.last_status,
+		s.created_at, s.updated_at, rm.id, rm.room_name
+	from ical_sources s
+	left join rooms rm on (s.room_id = rm.id)
+	where s.id = $1
+	`
+
+	err := m.conn().QueryRowContext(ctx, query, id).Scan(
+		&source.ID,
+		&source.RoomID,
+		&source.Name,
+		&source.URL,
+		&source.LastSyncedAt,
+		&source.LastStatus,
+		&source.CreatedAt,
+		&source.UpdatedAt,
+		&source.Room.ID,
+		&source.Room.RoomName,
+	)
+
+	if err != nil {
+		return source, err
+	}
+
+	return source, nil
+}
+
+// InsertICalSource inserts an iCalendar import source
+func (m *postgresDBRepo) InsertICalSource(s models.ICalSource) (int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var newID int
+
+	stmt := `
+		insert into ical_sources (room_id, name, url, created_at, updated_at)
+		values ($1, $2, $3, $4, $5) returning id
+	`
+
+	err := m.conn().QueryRowContext(ctx, stmt,
+		s.RoomID,
+		s.Name,
+		s.URL,
+		time.Now(),
+		time.Now(),
+	).Scan(&newID)
+
+	if err != nil {
+		return 0, err
+	}
+
+	return newID, nil
+}
+
+// DeleteICalSource deletes an iCalendar import source, along with the bookings imported from it
+func (m *postgresDBRepo) DeleteICalSource(id int) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	_, err := m.conn().ExecContext(ctx, `delete from ical_sources where id = $1`, id)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// UpdateICalSourceStatus records the outcome of the last sync of a source
+func (m *postgresDBRepo) UpdateICalSourceStatus(id int, syncedAt time.Time, status string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	query := `
+	update ical_sources
+	set last_synced_at = $1,
+	last_status = $2,
+	updated_at = $3
+	where id = $4
+	`
+
+	_, err := m.conn().ExecContext(ctx, query, syncedAt, status, time.Now(), id)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// SyncExternalBookings makes the bookings imported from source match bookings, matched on source uid.
+// Imported bookings that are no longer in the feed are removed, unless they already ended
+func (m *postgresDBRepo) SyncExternalBookings(source models.ICalSource, bookings []models.RoomRestriction) (models.ICalSyncResult, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var result models.ICalSyncResult
+
+	tx, err := m.begin(ctx)
+	if err != nil {
+		return result, err
+	}
+	defer tx.Rollback()
+
+	// serialize with calendar saves on the same room
+	var roomID int
+	err = tx.QueryRowContext(ctx, `select id from rooms where id = $1 for update`, source.RoomID).Scan(&roomID)
+	if err != nil {
+		return result, err
+	}
+
+	rows, err := tx.QueryContext(ctx, `
+		select id, source_uid, start_date, end_date
+		from room_restrictions
+		where source_id = $1
+	`, source.ID)
+	if err != nil {
+		return result, err
+	}
+
+	existing := make(map[string]models.RoomRestriction)
+	for rows.Next() {
+		var r models.RoomRestriction
+		if err := rows.Scan(&r.ID, &r.SourceUID, &r.StartDate, &r.EndDate); err != nil {
+			rows.Close()
+			return result, err
+		}
+		existing[r.SourceUID] = r
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return result, err
+	}
+
+	seen := make(map[string]bool)
+	for _, b := range bookings {
+		seen[b.SourceUID] = true
+
+		ex, ok := existing[b.SourceUID]
+		if !ok {
+			_, err := tx.ExecContext(ctx, `
+				insert into room_restrictions
+					(start_date, end_date, room_id, restriction_id, source_id, source_uid, created_at, updated_at)
+				values ($1, $2, $3, $4, $5, $6, $7, $8)
+			`, b.StartDate, b.EndDate, source.RoomID, models.RestrictionExternalBooking, source.ID, b.SourceUID, time.Now(), time.Now())
+			if err != nil {
+				return result, err
+			}
+			result.Added++
+			continue
+		}
+
+		if ex.StartDate.Equal(b.StartDate) && ex.EndDate.Equal(b.EndDate) {
+			continue
+		}
+		_, err := tx.ExecContext(ctx, `
+			update room_restrictions
+			set start_date = $1, end_date = $2, room_id = $3, version = version + 1, updated_at = $4
+			where id = $5
+		`, b.StartDate, b.EndDate, source.RoomID, time.Now(), ex.ID)
+		if err != nil {
+			return result, err
+		}
+		result.Updated++
+	}
+
+	today := time.Now().Truncate(24 * time.Hour)
+	for uid, ex := range existing {
+		if seen[uid] || ex.EndDate.Before(today) {
+			continue
+		}
+		_, err := tx.ExecContext(ctx, `delete from room_restrictions where id = $1`, ex.ID)
+		if err != nil {
+			return result, err
+		}
+		result.Removed++
+	}
+
+	err = tx.QueryRowContext(ctx, `
+		select count(distinct ext.id)
+		from room_restrictions ext
+		join room_restrictions other on (
+			other.room_id = ext.room_id
+			and other.id <> ext.id
+			and other.source_id is distinct from ext.source_id
+			and other.start_date < ext.end_date
+			and other.end_date > ext.start_date
+		)
+		where ext.source_id = $1 and ext.end_date >= $2
+	`, source.ID, today).Scan(&result.Overlaps)
+	if err != nil {
+		return result, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return result, err
+	}
+
+	return result, nil
+}
+
+// ChannelEventsAfter returns up to limit outbox events with a position greater than afterPosition, in
+// the order their transactions ended. Events only get a position once they are committed, so a channel's
+// cursor never passes an event whose transaction is still running
+func (m *postgresDBRepo) ChannelEventsAfter(afterPosition, limit int) ([]models.ChannelEvent, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var events []models.ChannelEvent
+
+	if err := m.positionChannelEvents(ctx); err != nil {
+		return events, err
+	}
+
+	query := `
+	select id, position, action, room_id, start_date, end_date, room_restriction_id, created_at
+	from channel_outbox
+	where position > $1
+	order by position asc
+	limit $2
+	`
+
+	rows, err := m.conn().QueryContext(ctx, query, afterPosition, limit)
+	if err != nil {
+		return events, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var e models.ChannelEvent
+		err := rows.Scan(
+			&e.ID,
+			&e.Position,
+			&e.Action,
+			&e.RoomID,
+			&e.StartDate,
+			&e.EndDate,
+			&e.RoomRestrictionID,
+			&e.CreatedAt,
+		)
+		if err != nil {
+			return events, err
+		}
+		events = append(events, e)
+	}
+
+	if err := rows.Err(); err != nil {
+		return events, err
+	}
+
+	return events, nil
+}
+
+// positionChannelEvents numbers the outbox events of the transactions older than the oldest one still
+// running, ordered by transaction. Ids are taken when a transaction writes, not when it commits, so they
+// can become visible out of order; a transaction that has yet to end keeps its events, and any written
+// after them, without a position until it does. The lock makes callers number one at a time, so
+// positions are committed in the order they are taken
+func (m *postgresDBRepo) positionChannelEvents(ctx context.Context) error {
+	tx, err := m.begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `select pg_advisory_xact_lock(hashtext('channel_outbox.position'))`); err != nil {
+		return err
+	}
+
+	query := `
+	update channel_outbox o
+	set position = p.position
+	from (
+		select id, nextval('channel_outbox_position_seq') as position
+		from (
+			select id from channel_outbox
+			where position is null and xid < pg_snapshot_xmin(pg_current_snapshot())
+			order by xid asc, id asc
+		) committed
+	) p
+	where o.id = p.id
+	`
+
+	if _, err := tx.ExecContext(ctx, query); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// RoomAvailabilityByDate returns whether a room is free on each day from start up to, but not including, end
+func (m *postgresDBRepo) RoomAvailabilityByDate(roomID int, start, end time.Time) ([]models.RoomAvailability, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var days []models.RoomAvailability
+
+	query := `
+	select d::date, not exists (
+		select 1 from room_restrictions rr
+		where rr.room_id = $1 and rr.start_date <= d::date and rr.end_date > d::date
+	)
+	from generate_series($2::date, $3::date - 1, interval '1 day') d
+	order by d asc
+	`
+
+	rows, err := m.conn().QueryContext(ctx, query, roomID, start, end)
+	if err != nil {
+		return days, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		a := models.RoomAvailability{RoomID: roomID}
+		if err := rows.Scan(&a.Date, &a.Available); err != nil {
+			return days, err
+		}
+		days = append(days, a)
+	}
+
+	if err := rows.Err(); err != nil {
+		return days, err
+	}
+
+	return days, nil
+}
+
+// GetChannelCursor returns the cursor of a channel, creating it at the start of the outbox if needed
+func (m *postgresDBRepo) GetChannelCursor(channel string) (models.ChannelCursor, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var c models.ChannelCursor
+
+	_, err := m.conn().ExecContext(ctx, `
+		insert into channel_cursors (channel, created_at, updated_at)
+		values ($1, $2, $2)
+		on conflict (channel) do nothing
+	`, channel, time.Now())
+	if err != nil {
+		return c, err
+	}
+
+	query := `
+	select
+		id, channel, last_position, attempts, coalesce(next_attempt_at, '0001-01-01'::timestamp),
+		last_error, coalesce(last_synced_at, '0001-01-01'::timestamp), created_at, updated_at
+	from channel_cursors
+	where channel = $1
+	`
+
+	err = m.conn().QueryRowContext(ctx, query, channel).Scan(
+		&c.ID,
+		&c.Channel,
+		&c.LastPosition,
+		&c.Attempts,
+		&c.NextAttemptAt,
+		&c.LastError,
+		&c.LastSyncedAt,
+		&c.CreatedAt,
+		&c.UpdatedAt,
+	)
+	if err != nil {
+		return c, err
+	}
+
+	return c, nil
+}
+
+// UpdateChannelCursor saves the delivery state of a channel
+func (m *postgresDBRepo) UpdateChannelCursor(c models.ChannelCursor) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var nextAttemptAt, lastSyncedAt sql.NullTime
+	if !c.NextAttemptAt.IsZero() {
+		nextAttemptAt = sql.NullTime{Time: c.NextAttemptAt, Valid: true}
+	}
+	if !c.LastSyncedAt.IsZero() {
+		lastSyncedAt = sql.NullTime{Time: c.LastSyncedAt, Valid: true}
+	}
+
+	query := `
+	update channel_cursors
+	set last_position = $1, attempts = $2, next_attempt_at = $3, last_error = $4, last_synced_at = $5, updated_at = $6
+	where channel = $7
+	`
+
+	_, err := m.conn().ExecContext(ctx, query,
+		c.LastPosition,
+		c.Attempts,
+		nextAttemptAt,
+		c.LastError,
+		lastSyncedAt,
+		time.Now(),
+		c.Channel,
+	)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// AllChannelCursors returns the delivery state of every channel, with the number of events it has yet to be sent
+func (m *postgresDBRepo) AllChannelCursors() ([]models.ChannelCursor, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var cursors []models.ChannelCursor
+
+	query := `
+	select
+		c.id, c.channel, c.last_position, c.attempts, coalesce(c.next_attempt_at, '0001-01-01'::timestamp),
+		c.last_error, coalesce(c.last_synced_at, '0001-01-01'::timestamp), c.created_at, c.updated_at,
+		(select count(o.id) from channel_outbox o where o.position is null or o.position > c.last_position)
+	from channel_cursors c
+	order by c.channel asc
+	`
+
+	rows, err := m.conn().QueryContext(ctx, query)
+	if err != nil {
+		return cursors, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var c models.ChannelCursor
+		err := rows.Scan(
+			&c.ID,
+			&c.Channel,
+			&c.LastPosition,
+			&c.Attempts,
+			&c.NextAttemptAt,
+			&c.LastError,
+			&c.LastSyncedAt,
+			&c.CreatedAt,
+			&c.UpdatedAt,
+			&c.Pending,
+		)
+		if err != nil {
+			return cursors, err
+		}
+		cursors = append(cursors, c)
+	}
+
+	if err := rows.Err(); err != nil {
+		return cursors, err
+	}
+
+	return cursors, nil
+}
+
+// RetryChannelNow clears the backoff of a channel so the worker retries it on its next run
+func (m *postgresDBRepo) RetryChannelNow(channel string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	query := `update channel_cursors set next_attempt_at = null, updated_at = $1 where channel = $2`
+
+	result, err := m.conn().ExecContext(ctx, query, time.Now(), channel)
+	if err != nil {
+		return err
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return sql.ErrNoRows
+	}
+
+	return nil
+}
+
+// ListUsers returns all users ordered by name
+func (m *postgresDBRepo) ListUsers() ([]models.User, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var users []models.User
+
+	query := `
+	select id, first_name, last_name, email, access_level, created_at, updated_at
+	from users
+	order by last_name asc, first_name asc
+	`
+
+	rows, err := m.conn().QueryContext(ctx, query)
+	if err != nil {
+		return users, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var u models.User
+		err := rows.Scan(
+			&u.ID,
+			&u.FirstName,
+			&u.LastName,
+			&u.Email,
+			&u.AccessLevel,
+			&u.CreatedAt,
+			&u.UpdatedAt,
+		)
+		if err != nil {
+			return users, err
+		}
+		users = append(users, u)
+	}
+
+	if err := rows.Err(); err != nil {
+		return users, err
+	}
+
+	return users, nil
+}
+
+// InsertAuditEvent records a change in the audit log
+func (m *postgresDBRepo) InsertAuditEvent(e models.AuditEvent) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var userID sql.NullInt64
+	if e.UserID > 0 {
+		userID = sql.NullInt64{Int64: int64(e.UserID), Valid: true}
+	}
+
+	query := `
+	insert into audit_events (user_id, action, entity, entity_id, before, after, created_at, updated_at)
+	values ($1, $2, $3, $4, nullif($5, '')::jsonb, nullif($6, '')::jsonb, $7, $7)
+	`
+
+	_, err := m.conn().ExecContext(ctx, query,
+		userID,
+		e.Action,
+		e.Entity,
+		e.EntityID,
+		e.Before,
+		e.After,
+		time.Now(),
+	)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// AuditEvents returns the audit events matching filter, newest first
+func (m *postgresDBRepo) AuditEvents(filter models.AuditFilter) ([]models.AuditEvent, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var events []models.AuditEvent
+
+	var from, to sql.NullTime
+	if !filter.From.IsZero() {
+		from = sql.NullTime{Time: filter.From, Valid: true}
+	}
+	if !filter.To.IsZero() {
+		to = sql.NullTime{Time: filter.To, Valid: true}
+	}
+	limit := filter.Limit
+	if limit <= 0 {
+		limit = 500
+	}
+
+	query := `
+	select
+		e.id, coalesce(e.user_id, 0), e.action, e.entity, e.entity_id,
+		coalesce(e.before::text, ''), coalesce(e.after::text, ''), e.created_at,
+		coalesce(u.first_name, ''), coalesce(u.last_name, ''), coalesce(u.email, '')
+	from audit_events e
+	left join users u on (e.user_id = u.id)
+	where ($1 = 0 or e.user_id = $1)
+		and ($2 = '' or e.entity = $2)
+		and ($3::timestamp is null or e.created_at >= $3)
+		and ($4::timestamp is null or e.created_at < $4)
+	order by e.created_at desc, e.id desc
+	limit $5
+	`
+
+	rows, err := m.conn().QueryContext(ctx, query, filter.UserID, filter.Entity, from, to, limit)
+	if err != nil {
+		return events, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var e models.AuditEvent
+		err := rows.Scan(
+			&e.ID,
+			&e.UserID,
+			&e.Action,
+			&e.Entity,
+			&e.EntityID,
+			&e.Before,
+			&e.After,
+			&e.CreatedAt,
+			&e.User.FirstName,
+			&e.User.LastName,
+			&e.User.Email,
+		)
+		if err != nil {
+			return events, err
+		}
+		e.User.ID = e.UserID
+		events = append(events, e)
+	}
+
+	if err := rows.Err(); err != nil {
+		return events, err
+	}
+
+	return events, nil
+}
+
+// mailColumns are the columns scanned by scanMailMessage
+const mailColumns = `
+	id, to_address, from_address, reply_to, subject, content, text_content, template, coalesce(attachments::text, ''),
+	status, attempts, next_attempt_at, last_error, coalesce(sent_at, '0001-01-01'::timestamp), created_at, updated_at`
+
+// scanMailMessage scans a row of mailColumns
+func scanMailMessage(row interface{ Scan(...interface{}) error }) (models.MailMessage, error) {
+	var msg models.MailMessage
+	var attachments string
+	err := row.Scan(
+		&msg.ID,
+		&msg.To,
+		&msg.From,
+		&msg.ReplyTo,
+		&msg.Subject,
+		&msg.Content,
+		&msg.Text,
+		&msg.Template,
+		&attachments,
+		&msg.Status,
+		&msg.Attempts,
+		&msg.NextAttemptAt,
+		&msg.LastError,
+		&msg.SentAt,
+		&msg.CreatedAt,
+		&msg.UpdatedAt,
+	)
+	if err != nil {
+		return msg, err
+	}
+	if attachments != "" {
+		err = json.Unmarshal([]byte(attachments), &msg.Attachments)
+	}
+	return msg, err
+}
+
+// queueMail adds a message to the outbox within tx, to be sent by the mail worker
+func queueMail(ctx context.Context, tx *txn, msg models.MailData) error {
+	var attachments []byte
+	if len(msg.Attachments) > 0 {
+		var err error
+		attachments, err = json.Marshal(msg.Attachments)
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err := tx.ExecContext(ctx, `
+		insert into mail_outbox
+			(to_address, from_address, reply_to, subject, content, text_content, template, attachments, status,
+			next_attempt_at, created_at, updated_at)
+		values ($1, $2, $3, $4, $5, $6, $7, nullif($8, '')::jsonb, $9, $10, $10, $10)
+	`, msg.To, msg.From, msg.ReplyTo, msg.Subject, msg.Content, msg.Text, msg.Template, string(attachments),
+		models.MailPending, time.Now())
+	return err
+}
+
+// QueueMail adds a message to the outbox, to be sent by the mail worker
+func (m *postgresDBRepo) QueueMail(msg models.MailData) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := m.begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := queueMail(ctx, tx, msg); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// ClaimMail returns up to limit pending messages that are due, counting an attempt for each and locking them
+// for lease so that other workers pass over them. Messages of a worker that died are claimed again once
+// their lease is over
+func (m *postgresDBRepo) ClaimMail(limit int, lease time.Duration) ([]models.MailMessage, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var messages []models.MailMessage
+
+	now := time.Now()
+	rows, err := m.conn().QueryContext(ctx, `
+	update mail_outbox
+	set attempts = attempts + 1, locked_until = $2, updated_at = $1
+	where id in (
+		select id from mail_outbox
+		where status = $3 and next_attempt_at <= $1 and (locked_until is null or locked_until < $1)
+		order by next_attempt_at, id
+		limit $4
+		for update skip locked
+	)
+	returning `+mailColumns, now, now.Add(lease), models.MailPending, limit)
+	if err != nil {
+		return messages, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		msg, err := scanMailMessage(rows)
+		if err != nil {
+			return messages, err
+		}
+		messages = append(messages, msg)
+	}
+
+	if err := rows.Err(); err != nil {
+		return messages, err
+	}
+
+	sort.Slice(messages, func(i, j int) bool { return messages[i].ID < messages[j].ID })
+
+	return messages, nil
+}
+
+// UpdateMailDelivery records the outcome of an attempt to send a claimed message and releases it
+func (m *postgresDBRepo) UpdateMailDelivery(msg models.MailMessage) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var sentAt sql.NullTime
+	if !msg.SentAt.IsZero() {
+		sentAt = sql.NullTime{Time: msg.SentAt, Valid: true}
+	}
+
+	_, err := m.conn().ExecContext(ctx, `
+	update mail_outbox
+	set status = $1, next_attempt_at = $2, last_error = $3, sent_at = $4, locked_until = null, updated_at = $5
+	where id = $6
+	`, msg.Status, msg.NextAttemptAt, msg.LastError, sentAt, time.Now(), msg.ID)
+	return err
+}
+
+// MailMessages returns up to limit messages with status, or with any status if it is empty, newest first
+func (m *postgresDBRepo) MailMessages(status string, limit int) ([]models.MailMessage, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var messages []models.MailMessage
+
+	rows, err := m.conn().QueryContext(ctx, `
+	select `+mailColumns+`
+	from mail_outbox
+	where $1 = '' or status = $1
+	order by id desc
+	limit $2
+	`, status, limit)
+	if err != nil {
+		return messages, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		msg, err := scanMailMessage(rows)
+		if err != nil {
+			return messages, err
+		}
+		messages = append(messages, msg)
+	}
+
+	if err := rows.Err(); err != nil {
+		return messages, err
+	}
+
+	return messages, nil
+}
+
+// GetMailMessage returns a message of the outbox
+func (m *postgresDBRepo) GetMailMessage(id int) (models.MailMessage, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	row := m.conn().QueryRowContext(ctx, `select `+mailColumns+` from mail_outbox where id = $1`, id)
+	return scanMailMessage(row)
+}
+
+// ResendMail queues a message to be sent again on the next run of the mail worker, with a fresh set of attempts
+func (m *postgresDBRepo) ResendMail(id int) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	now := time.Now()
+	result, err := m.conn().ExecContext(ctx, `
+	update mail_outbox
+	set status = $1, attempts = 0, next_attempt_at = $2, last_error = '', sent_at = null, locked_until = null,
+		updated_at = $2
+	where id = $3 and status <> $1
+	`, models.MailPending, now, id)
+	if err != nil {
+		return err
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return sql.ErrNoRows
+	}
+
+	return nil
+}
+
+// GetPropertySettings returns the sender identity of the property and the staff addresses notified of each
+// event
+func (m *postgresDBRepo) GetPropertySettings() (models.PropertySettings, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	settings := models.PropertySettings{Recipients: make(map[string][]string)}
+	err := m.conn().QueryRowContext(ctx, `
+	select sender_name, sender_email, reply_to, updated_at
+	from property_settings
+	order by id
+	limit 1
+	`).Scan(&settings.SenderName, &settings.SenderEmail, &settings.ReplyTo, &settings.UpdatedAt)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return settings, err
+	}
+
+	rows, err := m.conn().QueryContext(ctx, `select event, email from notification_recipients order by event, email`)
+	if err != nil {
+		return settings, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var event, email string
+		if err := rows.Scan(&event, &email); err != nil {
+			return settings, err
+		}
+		settings.Recipients[event] = append(settings.Recipients[event], email)
+	}
+
+	return settings, rows.Err()
+}
+
+// UpdatePropertySettings saves the sender identity of the property and replaces the staff addresses notified
+// of each event
+func (m *postgresDBRepo) UpdatePropertySettings(settings models.PropertySettings) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := m.begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	now := time.Now()
+	result, err := tx.ExecContext(ctx, `
+	update property_settings set sender_name = $1, sender_email = $2, reply_to = $3, updated_at = $4
+	`, settings.SenderName, settings.SenderEmail, settings.ReplyTo, now)
+	if err != nil {
+		return err
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		_, err = tx.ExecContext(ctx, `
+		insert into property_settings (sender_name, sender_email, reply_to, created_at, updated_at)
+		values ($1, $2, $3, $4, $4)
+		`, settings.SenderName, settings.SenderEmail, settings.ReplyTo, now)
+		if err != nil {
+			return err
+		}
+	}
+
+	if _, err := tx.ExecContext(ctx, `delete from notification_recipients`); err != nil {
+		return err
+	}
+	for event, emails := range settings.Recipients {
+		for _, email := range emails {
+			_, err := tx.ExecContext(ctx, `
+			insert into notification_recipients (event, email, created_at, updated_at)
+			values ($1, $2, $3, $3)
+			on conflict (event, email) do nothing
+			`, event, email, now)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return tx.Commit()
+}
+
+// NotificationRecipients returns the staff addresses notified of event: the ones in the property settings
+// and those of the users who chose to be
+func (m *postgresDBRepo) NotificationRecipients(event string) ([]string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var recipients []string
+	rows, err := m.conn().QueryContext(ctx, `
+	select email from notification_recipients where event = $1
+	union
+	select u.email
+	from users u
+	join notification_preferences p on p.user_id = u.id
+	where p.event = $1
+	order by 1
+	`, event)
+	if err != nil {
+		return recipients, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var email string
+		if err := rows.Scan(&email); err != nil {
+			return recipients, err
+		}
+		recipients = append(recipients, email)
+	}
+
+	return recipients, rows.Err()
+}
+
+// NotificationPreferences returns the events a user chose to be notified of
+func (m *postgresDBRepo) NotificationPreferences(userID int) ([]string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var events []string
+	rows, err := m.conn().QueryContext(ctx, `
+	select event from notification_preferences where user_id = $1 order by event
+	`, userID)
+	if err != nil {
+		return events, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var event string
+		if err := rows.Scan(&event); err != nil {
+			return events, err
+		}
+		events = append(events, event)
+	}
+
+	return events, rows.Err()
+}
+
+// UpdateNotificationPreferences replaces the events a user is notified of
+func (m *postgresDBRepo) UpdateNotificationPreferences(userID int, events []string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := m.begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `delete from notification_preferences where user_id = $1`, userID); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	for _, event := range events {
+		_, err := tx.ExecContext(ctx, `
+		insert into notification_preferences (user_id, event, created_at, updated_at)
+		values ($1, $2, $3, $3)
+		on conflict (user_id, event) do nothing
+		`, userID, event, now)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+const jobColumns = `
+	id, name, enabled, offset_days, hour, next_run_at, coalesce(last_run_at, '0001-01-01'::timestamp),
+	last_result, last_error, created_at, updated_at`
+
+// scanScheduledJob scans a row of jobColumns
+func scanScheduledJob(row interface{ Scan(...interface{}) error }) (models.ScheduledJob, error) {
+	var job models.ScheduledJob
+	err := row.Scan(
+		&job.ID,
+		&job.Name,
+		&job.Enabled,
+		&job.OffsetDays,
+		&job.Hour,
+		&job.NextRunAt,
+		&job.LastRunAt,
+		&job.LastResult,
+		&job.LastError,
+		&job.CreatedAt,
+		&job.UpdatedAt,
+	)
+	return job, err
+}
+
+// ScheduledJobs returns the jobs of the scheduler
+func (m *postgresDBRepo) ScheduledJobs() ([]models.ScheduledJob, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var jobs []models.ScheduledJob
+	rows, err := m.conn().QueryContext(ctx, `select `+jobColumns+` from scheduled_jobs order by id`)
+	if err != nil {
+		return jobs, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		job, err := scanScheduledJob(rows)
+		if err != nil {
+			return jobs, err
+		}
+		jobs = append(jobs, job)
+	}
+
+	return jobs, rows.Err()
+}
+
+// GetScheduledJob returns a job of the scheduler by name
+func (m *postgresDBRepo) GetScheduledJob(name string) (models.ScheduledJob, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	return scanScheduledJob(m.conn().QueryRowContext(ctx, `select `+jobColumns+` from scheduled_jobs where name = $1`, name))
+}
+
+// UpdateScheduledJob saves whether a job is enabled, its offset, the hour it runs at and when it runs next
+func (m *postgresDBRepo) UpdateScheduledJob(job models.ScheduledJob) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	_, err := m.conn().ExecContext(ctx, `
+	update scheduled_jobs set enabled = $1, offset_days = $2, hour = $3, next_run_at = $4, updated_at = $5
+	where name = $6
+	`, job.Enabled, job.OffsetDays, job.Hour, job.NextRunAt, time.Now(), job.Name)
+	return err
+}
+
+// ClaimScheduledJob locks the named job for lease if it is enabled and due, so that only one instance of
+// the application runs it. It returns sql.ErrNoRows when the job isn't due or another instance holds it.
+// The job of an instance that died is claimed again once its lease is over
+func (m *postgresDBRepo) ClaimScheduledJob(name string, lease time.Duration) (models.ScheduledJob, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	now := time.Now()
+	return scanScheduledJob(m.conn().QueryRowContext(ctx, `
+	update scheduled_jobs
+	set locked_until = $2
+	where name = $3 and enabled and next_run_at <= $1 and (locked_until is null or locked_until < $1)
+	returning `+jobColumns, now, now.Add(lease), name))
+}
+
+// FinishScheduledJob records the outcome of a run of a claimed job, with when it runs next, and releases it
+func (m *postgresDBRepo) FinishScheduledJob(job models.ScheduledJob) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	_, err := m.conn().ExecContext(ctx, `
+	update scheduled_jobs
+	set next_run_at = $1, last_run_at = $2, last_result = $3, last_error = $4, locked_until = null, updated_at = $5
+	where name = $6
+	`, job.NextRunAt, job.LastRunAt, job.LastResult, job.LastError, time.Now(), job.Name)
+	return err
+}
+
+// guestEmailConditions select the reservations the guest emails of each job are due for, by the dates from
+// $1 to $2: reminders for those arriving and not checked in yet, follow-ups for those that left
+var guestEmailConditions = map[string]string{
+	models.JobGuestReminder: `r.start_date between $1 and $2 and r.checked_in_at is null`,
+	models.JobGuestFollowUp: `r.end_date between $1 and $2`,
+}
+
+// GuestEmailsDue returns the reservations the guest email of kind, a scheduled job, is due for: those
+// arriving from start to end for reminders, and those that left from start to end for follow-ups.
+// Cancelled, no-show, deleted and anonymized reservations are left out, as are those without an email
+// address or already sent the email
+func (m *postgresDBRepo) GuestEmailsDue(kind string, start, end time.Time) ([]models.Reservation, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var reservations []models.Reservation
+
+	condition, ok := guestEmailConditions[kind]
+	if !ok {
+		return reservations, fmt.Errorf("no guest email for job %q", kind)
 	}
 
+	query := `
+	select
+		r.id, r.first_name, r.last_name, r.email, r.phone, r.start_date, r.end_date, r.room_id, r.status,
+		r.sms_opt_in, rm.id, rm.room_name
+	from reservations r
+	left join rooms rm on (r.room_id = rm.id)
+	where ` + condition + `
+		and r.deleted_at is null and r.anonymized_at is null and r.email <> '' and r.status not in ($3, $4)
+		and not exists (select 1 from guest_emails g where g.reservation_id = r.id and g.kind = $5)
+	order by r.id
+	`
+
+	rows, err := m.conn().QueryContext(ctx, query, start, end, models.ReservationCancelled, models.ReservationNoShow, kind)
+	if err != nil {
+		return reservations, err
+	}
 	defer rows.Close()
 
 	for rows.Next() {
-		var r models.Room
-
+		var i models.Reservation
 		err := rows.Scan(
-			&r.ID,
-			&r.RoomName,
-			&r.CreatedAt,
-			&r.UpdatedAt,
+			&i.ID,
+			&i.FirstName,
+			&i.LastName,
+			&i.Email,
+			&i.Phone,
+			&i.StartDate,
+			&i.EndDate,
+			&i.RoomID,
+			&i.Status,
+			&i.SMSOptIn,
+			&i.Room.ID,
+			&i.Room.RoomName,
 		)
 		if err != nil {
-			return rooms, err
+			return reservations, err
 		}
-		rooms = append(rooms, r)
+		if err := m.openContact(&i); err != nil {
+			return reservations, err
+		}
+		reservations = append(reservations, i)
 	}
 
-	return rooms, nil
+	if err := rows.Err(); err != nil {
+		return reservations, err
+	}
+
+	return reservations, nil
 }
 
-// GetRestrictionsForRoomByDate gets restrcition from room_restrictions table for start, end date
-func (m *postgresDBRepo) GetRestrictionsForRoomByDate(roomID int, start, end time.Time) ([]models.RoomRestriction, error) {
+// QueueGuestEmail queues the guest email of kind for a reservation, with its text message when text has a
+// number, and records it as sent in the same transaction. It queues nothing if the email was sent already,
+// so that a job run twice messages guests once
+func (m *postgresDBRepo) QueueGuestEmail(reservationID int, kind string, msg models.MailData, text models.SMSData) error {
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
 
-	var restrictions []models.RoomRestriction
+	tx, err := m.begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
 
-	query := `
-	select id, coalesce(reservation_id, 0), restriction_id, room_id, start_date, end_date
-	from room_restrictions 
-	where room_id = $1 and $2 < end_date and $3 >= start_date
-	`
+	result, err := tx.ExecContext(ctx, `
+	insert into guest_emails (reservation_id, kind, created_at, updated_at)
+	values ($1, $2, $3, $3)
+	on conflict (reservation_id, kind) do nothing
+	`, reservationID, kind, time.Now())
+	if err != nil {
+		return err
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return nil
+	}
+
+	if err := queueMail(ctx, tx, msg); err != nil {
+		return err
+	}
+	if text.To != "" {
+		if err := queueSMS(ctx, tx, reservationID, text); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+const smsColumns = `
+	id, to_number, body, template, status, attempts, next_attempt_at, last_error,
+	coalesce(sent_at, '0001-01-01'::timestamp), created_at, updated_at`
+
+// scanSMSMessage scans a row of smsColumns
+func scanSMSMessage(row interface{ Scan(...interface{}) error }) (models.SMSMessage, error) {
+	var msg models.SMSMessage
+	err := row.Scan(
+		&msg.ID,
+		&msg.To,
+		&msg.Body,
+		&msg.Template,
+		&msg.Status,
+		&msg.Attempts,
+		&msg.NextAttemptAt,
+		&msg.LastError,
+		&msg.SentAt,
+		&msg.CreatedAt,
+		&msg.UpdatedAt,
+	)
+	return msg, err
+}
+
+// queueSMS adds a text message about a reservation to the SMS outbox within tx, to be sent by the SMS worker
+func queueSMS(ctx context.Context, tx *txn, reservationID int, msg models.SMSData) error {
+	_, err := tx.ExecContext(ctx, `
+		insert into sms_outbox (to_number, body, template, status, next_attempt_at, created_at, updated_at, reservation_id)
+		values ($1, $2, $3, $4, $5, $5, $5, $6)
+	`, msg.To, msg.Body, msg.Template, models.MailPending, time.Now(), reservationID)
+	return err
+}
 
-	rows, err := m.DB.QueryContext(ctx, query, roomID, start, end)
+// ClaimSMS returns up to limit pending text messages that are due, counting an attempt for each and locking
+// them for lease like ClaimMail does
+func (m *postgresDBRepo) ClaimSMS(limit int, lease time.Duration) ([]models.SMSMessage, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
 
+	var messages []models.SMSMessage
+
+	now := time.Now()
+	rows, err := m.conn().QueryContext(ctx, `
+	update sms_outbox
+	set attempts = attempts + 1, locked_until = $2, updated_at = $1
+	where id in (
+		select id from sms_outbox
+		where status = $3 and next_attempt_at <= $1 and (locked_until is null or locked_until < $1)
+		order by next_attempt_at, id
+		limit $4
+		for update skip locked
+	)
+	returning `+smsColumns, now, now.Add(lease), models.MailPending, limit)
 	if err != nil {
-		return restrictions, err
+		return messages, err
 	}
+	defer rows.Close()
 
 	for rows.Next() {
-		var r models.RoomRestriction
-		err := rows.Scan(
-			&r.ID,
-			&r.ReservationID,
-			&r.RestrictionID,
-			&r.RoomID,
-			&r.StartDate,
-			&r.EndDate,
-		)
+		msg, err := scanSMSMessage(rows)
 		if err != nil {
-			return restrictions, err
+			return messages, err
 		}
-		restrictions = append(restrictions, r)
+		messages = append(messages, msg)
 	}
 
-	if rows.Err() != nil {
-		return restrictions, rows.Err()
+	if err := rows.Err(); err != nil {
+		return messages, err
 	}
 
-	return restrictions, nil
+	sort.Slice(messages, func(i, j int) bool { return messages[i].ID < messages[j].ID })
+
+	return messages, nil
 }
 
-// InsertBlockForRoom inserts block for a particular room
-func (m *postgresDBRepo) InsertBlockForRoom(id int, startDate time.Time) error {
+// UpdateSMSDelivery records the outcome of an attempt to send a claimed text message and releases it
+func (m *postgresDBRepo) UpdateSMSDelivery(msg models.SMSMessage) error {
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
 
-	query := `
-		insert into 
-		room_restrictions(start_date,end_date, room_id, restriction_id, created_at, updated_at)
-		values($1, $2, $3, $4, $5, $6)
-	`
+	var sentAt sql.NullTime
+	if !msg.SentAt.IsZero() {
+		sentAt = sql.NullTime{Time: msg.SentAt, Valid: true}
+	}
 
-	_, err := m.DB.ExecContext(ctx, query,
-		startDate,
-		startDate.AddDate(0, 0, 1),
-		id,
-		2,
-		time.Now(),
-		time.Now(),
+	_, err := m.conn().ExecContext(ctx, `
+	update sms_outbox
+	set status = $1, next_attempt_at = $2, last_error = $3, sent_at = $4, locked_until = null, updated_at = $5
+	where id = $6
+	`, msg.Status, msg.NextAttemptAt, msg.LastError, sentAt, time.Now(), msg.ID)
+	return err
+}
+
+// webhookEndpointColumns are the columns scanned by scanWebhookEndpoint, from webhook_endpoints e grouped by
+// e.id and left joined to its webhook_subscriptions s
+const webhookEndpointColumns = `
+	e.id, e.url, e.secret, e.active, coalesce(string_agg(s.event, ',' order by s.event), ''),
+	e.created_at, e.updated_at`
+
+// scanWebhookEndpoint scans a row of webhookEndpointColumns
+func scanWebhookEndpoint(row interface{ Scan(...interface{}) error }) (models.WebhookEndpoint, error) {
+	var e models.WebhookEndpoint
+	var events string
+	err := row.Scan(
+		&e.ID,
+		&e.URL,
+		&e.Secret,
+		&e.Active,
+		&events,
+		&e.CreatedAt,
+		&e.UpdatedAt,
 	)
+	if err != nil {
+		return e, err
+	}
+	if events != "" {
+		e.Events = strings.Split(events, ",")
+	}
+	return e, nil
+}
 
+// WebhookEndpoints returns all webhook endpoints with the events they subscribe to
+func (m *postgresDBRepo) WebhookEndpoints() ([]models.WebhookEndpoint, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var endpoints []models.WebhookEndpoint
+
+	rows, err := m.conn().QueryContext(ctx, `
+	select `+webhookEndpointColumns+`
+	from webhook_endpoints e
+	left join webhook_subscriptions s on (s.endpoint_id = e.id)
+	group by e.id
+	order by e.id
+	`)
 	if err != nil {
+		return endpoints, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		e, err := scanWebhookEndpoint(rows)
+		if err != nil {
+			return endpoints, err
+		}
+		endpoints = append(endpoints, e)
+	}
+
+	return endpoints, rows.Err()
+}
+
+// GetWebhookEndpoint returns a webhook endpoint with the events it subscribes to
+func (m *postgresDBRepo) GetWebhookEndpoint(id int) (models.WebhookEndpoint, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	row := m.conn().QueryRowContext(ctx, `
+	select `+webhookEndpointColumns+`
+	from webhook_endpoints e
+	left join webhook_subscriptions s on (s.endpoint_id = e.id)
+	where e.id = $1
+	group by e.id
+	`, id)
+	return scanWebhookEndpoint(row)
+}
+
+// saveWebhookSubscriptions replaces the events the endpoint with id subscribes to within tx
+func saveWebhookSubscriptions(ctx context.Context, tx *txn, id int, events []string) error {
+	if _, err := tx.ExecContext(ctx, `delete from webhook_subscriptions where endpoint_id = $1`, id); err != nil {
 		return err
 	}
 
+	now := time.Now()
+	for _, event := range events {
+		_, err := tx.ExecContext(ctx, `
+		insert into webhook_subscriptions (endpoint_id, event, created_at, updated_at)
+		values ($1, $2, $3, $3)
+		on conflict (endpoint_id, event) do nothing
+		`, id, event, now)
+		if err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
 
-// DeleteBlockByID deletes block for a particular room
-func (m *postgresDBRepo) DeleteBlockByID(id int) error {
+// InsertWebhookEndpoint adds a webhook endpoint along with the events it subscribes to
+func (m *postgresDBRepo) InsertWebhookEndpoint(e models.WebhookEndpoint) (int, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
 
-	query := `
-		delete from room_restrictions where id = $1
-	`
+	tx, err := m.begin(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	var newID int
+	now := time.Now()
+	err = tx.QueryRowContext(ctx, `
+	insert into webhook_endpoints (url, secret, active, created_at, updated_at)
+	values ($1, $2, $3, $4, $4) returning id
+	`, e.URL, e.Secret, e.Active, now).Scan(&newID)
+	if err != nil {
+		return 0, err
+	}
+
+	if err := saveWebhookSubscriptions(ctx, tx, newID, e.Events); err != nil {
+		return 0, err
+	}
+
+	return newID, tx.Commit()
+}
 
-	_, err := m.DB.ExecContext(ctx, query, id)
+// UpdateWebhookEndpoint saves a webhook endpoint and replaces the events it subscribes to
+func (m *postgresDBRepo) UpdateWebhookEndpoint(e models.WebhookEndpoint) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
 
+	tx, err := m.begin(ctx)
 	if err != nil {
 		return err
 	}
+	defer tx.Rollback()
 
-	return nil
+	result, err := tx.ExecContext(ctx, `
+	update webhook_endpoints set url = $1, secret = $2, active = $3, updated_at = $4
+	where id = $5
+	`, e.URL, e.Secret, e.Active, time.Now(), e.ID)
+	if err != nil {
+		return err
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return sql.ErrNoRows
+	}
+
+	if err := saveWebhookSubscriptions(ctx, tx, e.ID, e.Events); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// DeleteWebhookEndpoint deletes a webhook endpoint along with its deliveries
+func (m *postgresDBRepo) DeleteWebhookEndpoint(id int) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	_, err := m.conn().ExecContext(ctx, `delete from webhook_endpoints where id = $1`, id)
+	return err
+}
+
+// webhookDeliveryColumns are the columns scanned by scanWebhookDelivery, from webhook_deliveries d joined to
+// its webhook_endpoints e
+const webhookDeliveryColumns = `
+	d.id, d.endpoint_id, e.url, e.secret, d.event, d.payload, d.status, d.attempts, d.next_attempt_at,
+	d.response_status, d.last_error, coalesce(d.delivered_at, '0001-01-01'::timestamp), d.created_at, d.updated_at`
+
+// scanWebhookDelivery scans a row of webhookDeliveryColumns
+func scanWebhookDelivery(row interface{ Scan(...interface{}) error }) (models.WebhookDelivery, error) {
+	var d models.WebhookDelivery
+	err := row.Scan(
+		&d.ID,
+		&d.EndpointID,
+		&d.URL,
+		&d.Secret,
+		&d.Event,
+		&d.Payload,
+		&d.Status,
+		&d.Attempts,
+		&d.NextAttemptAt,
+		&d.ResponseStatus,
+		&d.LastError,
+		&d.DeliveredAt,
+		&d.CreatedAt,
+		&d.UpdatedAt,
+	)
+	return d, err
+}
+
+// QueueWebhook queues the payload of event for every active endpoint subscribed to it, to be posted by the
+// webhook worker
+func (m *postgresDBRepo) QueueWebhook(event string, payload []byte) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	_, err := m.conn().ExecContext(ctx, `
+	insert into webhook_deliveries (endpoint_id, event, payload, status, next_attempt_at, created_at, updated_at)
+	select e.id, $1, $2, $3, $4, $4, $4
+	from webhook_endpoints e
+	join webhook_subscriptions s on (s.endpoint_id = e.id and s.event = $1)
+	where e.active
+	`, event, string(payload), models.WebhookPending, time.Now())
+	return err
+}
+
+// ClaimWebhooks returns up to limit pending deliveries that are due, counting an attempt for each and locking
+// them for lease like ClaimMail does
+func (m *postgresDBRepo) ClaimWebhooks(limit int, lease time.Duration) ([]models.WebhookDelivery, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var deliveries []models.WebhookDelivery
+
+	now := time.Now()
+	rows, err := m.conn().QueryContext(ctx, `
+	update webhook_deliveries d
+	set attempts = d.attempts + 1, locked_until = $2, updated_at = $1
+	from webhook_endpoints e
+	where e.id = d.endpoint_id and d.id in (
+		select id from webhook_deliveries
+		where status = $3 and next_attempt_at <= $1 and (locked_until is null or locked_until < $1)
+		order by next_attempt_at, id
+		limit $4
+		for update skip locked
+	)
+	returning `+webhookDeliveryColumns, now, now.Add(lease), models.WebhookPending, limit)
+	if err != nil {
+		return deliveries, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		d, err := scanWebhookDelivery(rows)
+		if err != nil {
+			return deliveries, err
+		}
+		deliveries = append(deliveries, d)
+	}
+
+	if err := rows.Err(); err != nil {
+		return deliveries, err
+	}
+
+	sort.Slice(deliveries, func(i, j int) bool { return deliveries[i].ID < deliveries[j].ID })
+
+	return deliveries, nil
+}
+
+// UpdateWebhookDelivery records the outcome of an attempt to post a claimed delivery and releases it
+func (m *postgresDBRepo) UpdateWebhookDelivery(d models.WebhookDelivery) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var deliveredAt sql.NullTime
+	if !d.DeliveredAt.IsZero() {
+		deliveredAt = sql.NullTime{Time: d.DeliveredAt, Valid: true}
+	}
+
+	_, err := m.conn().ExecContext(ctx, `
+	update webhook_deliveries
+	set status = $1, next_attempt_at = $2, response_status = $3, last_error = $4, delivered_at = $5,
+		locked_until = null, updated_at = $6
+	where id = $7
+	`, d.Status, d.NextAttemptAt, d.ResponseStatus, d.LastError, deliveredAt, time.Now(), d.ID)
+	return err
+}
+
+// WebhookDeliveries returns up to limit deliveries to the endpoint with endpointID, or to any endpoint if it
+// is 0, newest first
+func (m *postgresDBRepo) WebhookDeliveries(endpointID, limit int) ([]models.WebhookDelivery, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var deliveries []models.WebhookDelivery
+
+	rows, err := m.conn().QueryContext(ctx, `
+	select `+webhookDeliveryColumns+`
+	from webhook_deliveries d
+	join webhook_endpoints e on (e.id = d.endpoint_id)
+	where $1 = 0 or d.endpoint_id = $1
+	order by d.id desc
+	limit $2
+	`, endpointID, limit)
+	if err != nil {
+		return deliveries, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		d, err := scanWebhookDelivery(rows)
+		if err != nil {
+			return deliveries, err
+		}
+		deliveries = append(deliveries, d)
+	}
+
+	return deliveries, rows.Err()
+}
+
+// GetWebhookDelivery returns a delivery of the webhook log
+func (m *postgresDBRepo) GetWebhookDelivery(id int) (models.WebhookDelivery, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	row := m.conn().QueryRowContext(ctx, `
+	select `+webhookDeliveryColumns+`
+	from webhook_deliveries d
+	join webhook_endpoints e on (e.id = d.endpoint_id)
+	where d.id = $1
+	`, id)
+	return scanWebhookDelivery(row)
+}
+
+// RedeliverWebhook queues the payload of a delivery to be posted to its endpoint again, as a new delivery so
+// that the log keeps the outcome of the earlier one, and returns the id of the new delivery
+func (m *postgresDBRepo) RedeliverWebhook(id int) (int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var newID int
+	err := m.conn().QueryRowContext(ctx, `
+	insert into webhook_deliveries (endpoint_id, event, payload, status, next_attempt_at, created_at, updated_at)
+	select endpoint_id, event, payload, $1, $2, $2, $2
+	from webhook_deliveries
+	where id = $3
+	returning id
+	`, models.WebhookPending, time.Now(), id).Scan(&newID)
+	return newID, err
 }
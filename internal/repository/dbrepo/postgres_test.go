@@ -0,0 +1,68 @@
+package dbrepo
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/go-course/bookings/internal/models"
+)
+
+func TestImportReservationQuery(t *testing.T) {
+	var testCases = []struct {
+		status string
+		column string
+	}{
+		{models.ReservationPending, ""},
+		{models.ReservationConfirmed, "confirmed_at"},
+		{models.ReservationCancelled, "cancelled_at"},
+		{models.ReservationCheckedOut, "checked_out_at"},
+	}
+
+	for _, testCase := range testCases {
+		query := importReservationQuery(testCase.status)
+		columns := strings.Split(query[strings.Index(query, "(")+1:strings.Index(query, ")")], ", ")
+
+		seen := make(map[string]bool)
+		for _, c := range columns {
+			if seen[c] {
+				t.Errorf("%s: column %s listed twice in %s", testCase.status, c, query)
+			}
+			seen[c] = true
+		}
+		if testCase.column != "" && !seen[testCase.column] {
+			t.Errorf("%s: %s not set in %s", testCase.status, testCase.column, query)
+		}
+		if values := strings.Count(query[strings.Index(query, "values"):], "$"); values != len(columns) {
+			t.Errorf("%s: %d columns but %d values in %s", testCase.status, len(columns), values, query)
+		}
+	}
+}
+
+func TestMatchGuest(t *testing.T) {
+	candidates := []guestContact{
+		{ID: 1, Email: "anna@example.com", Phone: "15550100"},
+		{ID: 2, Email: "", Phone: "15550199"},
+		{ID: 3, Email: "ben@example.com", Phone: "15550199"},
+	}
+
+	var testCases = []struct {
+		name  string
+		email string
+		phone string
+		want  int
+	}{
+		{"Same email", "anna@example.com", "", 1},
+		{"Email before phone", "ben@example.com", "15550100", 3},
+		{"Same phone, no email", "", "15550100", 1},
+		{"Same phone, guest without email", "carl@example.com", "15550199", 2},
+		{"Same phone, different email", "dora@example.com", "15550100", 0},
+		{"No match", "erin@example.com", "15550111", 0},
+		{"Nothing to match on", "", "", 0},
+	}
+
+	for _, testCase := range testCases {
+		if got := matchGuest(candidates, testCase.email, testCase.phone); got != testCase.want {
+			t.Errorf("matchGuest for (%s): got %d, want %d", testCase.name, got, testCase.want)
+		}
+	}
+}
@@ -1,10 +1,14 @@
 package dbrepo
 
 import (
+	"database/sql"
 	"errors"
+	"fmt"
+	"strings"
 	"time"
 
 	"github.com/go-course/bookings/internal/models"
+	"github.com/go-course/bookings/internal/repository"
 )
 
 func (m *testDBRepo) AllUsers() bool {
@@ -26,6 +30,26 @@ func (m *testDBRepo) InsertRoomRestriction(res models.RoomRestriction) error {
 	return nil
 }
 
+func (m *testDBRepo) BookReservation(res models.Reservation, notify func(models.Reservation) ([]models.MailData, models.SMSData, error)) (int, error) {
+	if res.RoomID == 2 || res.RoomID == 100 {
+		return 0, errors.New("some error")
+	}
+	if models.NormalizeEmail(res.Email) == "guest@error.com" {
+		return 0, errors.New("can't save guest")
+	}
+	res.GuestID = 1
+	res.ID = 1
+	messages, text, err := notify(res)
+	if err != nil {
+		return 0, err
+	}
+	m.queue(messages...)
+	if text.To != "" {
+		m.queueSMS(text)
+	}
+	return res.ID, nil
+}
+
 // SearchAvailabilityByDates returns true if availability exists for roomID and false if no availability exists
 func (m *testDBRepo) SearchAvailabilityByDatesByRoomID(start, end time.Time, roomID int) (bool, error) {
 	if roomID > 2 {
@@ -84,24 +108,161 @@ func (m *testDBRepo) Authenticate(email, testPassword string) (int, string, erro
 	return id, hashedPassword, nil
 }
 
-// AllReservations returns a slice of all reservations
-func (m *testDBRepo) AllReservations() ([]models.Reservation, error) {
-	var reservations []models.Reservation
+func (m *testDBRepo) GetUserByFeedToken(token string) (models.User, error) {
+	var user models.User
+	if token != "valid-token" {
+		return user, errors.New("no such token")
+	}
+	user.ID = 1
+	user.FeedToken = token
+	return user, nil
+}
 
-	return reservations, nil
+func (m *testDBRepo) UpdateFeedTokenForUser(id int, token string) error {
+	if id > 2 {
+		return errors.New("user does not exist")
+	}
+	return nil
 }
 
-func (m *testDBRepo) AllNewReservations() ([]models.Reservation, error) {
+func (m *testDBRepo) SearchReservations(q models.ReservationQuery) ([]models.Reservation, int, error) {
 	var reservations []models.Reservation
+	if q.Search == "nobody" {
+		return reservations, 0, nil
+	}
+	if q.Status == "" || q.Status == models.ReservationConfirmed {
+		reservations = append(reservations, models.Reservation{ID: 1, LastName: "Smith", Status: models.ReservationConfirmed})
+	}
+	// pretend there are more pages
+	return reservations, 60, nil
+}
+
+func (m *testDBRepo) EachReservation(q models.ReservationQuery, fn func(models.Reservation) error) error {
+	if q.Search == "nobody" {
+		return nil
+	}
+	if q.Search == "broken" {
+		return errors.New("cursor failed")
+	}
+	start, _ := time.Parse("2006-01-02", "2050-01-01")
+	for id := 1; id <= 3; id++ {
+		err := fn(models.Reservation{
+			ID:        id,
+			FirstName: "John",
+			LastName:  "Smith",
+			StartDate: start,
+			EndDate:   start.AddDate(0, 0, 2),
+			Status:    models.ReservationConfirmed,
+			Room:      models.Room{ID: 1, RoomName: "Generals Quarters"},
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m *testDBRepo) EachRoomOccupancy(start, end time.Time, fn func(models.RoomOccupancy) error) error {
+	return fn(models.RoomOccupancy{
+		Room:           models.Room{ID: 1, RoomName: "Generals Quarters"},
+		Nights:         int(end.Sub(start).Hours() / 24),
+		ReservedNights: 10,
+		ExternalNights: 5,
+		BlockedNights:  1,
+		Reservations:   4,
+	})
+}
 
+func (m *testDBRepo) ImportBookings(rows []models.ImportRow) error {
+	for _, row := range rows {
+		if row.Valid() && row.Reservation.LastName == "Late" {
+			return fmt.Errorf("line %d: %w", row.Line, models.ErrRoomUnavailable)
+		}
+	}
+	return nil
+}
+
+func (m *testDBRepo) DashboardCounts(day time.Time) (models.DashboardCounts, error) {
+	return models.DashboardCounts{Arrivals: 3, Departures: 2, InHouse: 5, Pending: 7}, nil
+}
+
+func (m *testDBRepo) LeadTimes(since time.Time) ([]models.LeadTimeBucket, error) {
+	buckets := models.LeadTimeBuckets()
+	models.AddLeadTime(buckets, 10, 4)
+	return buckets, nil
+}
+
+func (m *testDBRepo) ReservationsArriving(start, end time.Time) ([]models.Reservation, error) {
+	var reservations []models.Reservation
+	if start.Year() < 2050 {
+		return reservations, nil
+	}
+	reservations = append(reservations, models.Reservation{
+		ID:        1,
+		StartDate: start,
+		EndDate:   start.AddDate(0, 0, 3),
+		RoomID:    1,
+		Status:    models.ReservationConfirmed,
+		Room:      models.Room{ID: 1, RoomName: "Generals Quarters"},
+	})
 	return reservations, nil
 }
 
+func (m *testDBRepo) RestrictionsBetween(start, end time.Time) ([]models.RoomRestriction, error) {
+	var restrictions = []models.RoomRestriction{
+		{
+			StartDate:     start,
+			EndDate:       start.AddDate(0, 0, 3),
+			RoomID:        1,
+			ReservationID: 1,
+			RestrictionID: models.RestrictionReservation,
+		},
+	}
+	return restrictions, nil
+}
+
+func (m *testDBRepo) RoomStatuses() ([]models.RoomStatus, error) {
+	var statuses = []models.RoomStatus{
+		{RoomID: 1, Status: models.RoomDirty, Room: models.Room{ID: 1, RoomName: "Generals Quarters"}},
+		{RoomID: 2, Status: models.RoomOutOfOrder, Note: "Broken window", Room: models.Room{ID: 2, RoomName: "Majors Suite"}},
+	}
+	return statuses, nil
+}
+
+func (m *testDBRepo) GetRoomStatus(roomID int) (models.RoomStatus, error) {
+	if roomID > 2 {
+		return models.RoomStatus{}, errors.New("room does not exist")
+	}
+	return models.RoomStatus{RoomID: roomID, Status: models.RoomClean}, nil
+}
+
+func (m *testDBRepo) UpdateRoomStatus(s models.RoomStatus) (models.RoomStatus, error) {
+	if s.RoomID > 2 {
+		return s, errors.New("room does not exist")
+	}
+	// room 2 is booked tonight, so no block is created for it
+	if s.Status == models.RoomOutOfOrder && s.RoomID == 1 {
+		s.BlockID = 5
+	}
+	return s, nil
+}
+
+func (m *testDBRepo) ExtendOutOfOrderBlocks(today time.Time) (int, error) {
+	return 1, nil
+}
+
 func (m *testDBRepo) GetReservationById(id int) (models.Reservation, error) {
 	var reservation models.Reservation
+	if id == 4 {
+		return reservation, sql.ErrNoRows
+	}
 	if id > 2 {
 		return reservation, errors.New("room does not exist")
 	}
+	reservation.ID = id
+	reservation.FirstName = "John"
+	reservation.Email = "john@smith.com"
+	reservation.Status = models.ReservationPending
 	return reservation, nil
 }
 
@@ -119,13 +280,202 @@ func (m *testDBRepo) DeleteReservation(id int) error {
 	return nil
 }
 
-func (m *testDBRepo) UpdateProcessedForReservation(id, processed int) error {
+func (m *testDBRepo) DeletedReservations() ([]models.Reservation, error) {
+	var reservations []models.Reservation
+	reservations = append(reservations, models.Reservation{
+		ID:        1,
+		LastName:  "Smith",
+		Status:    models.ReservationConfirmed,
+		DeletedAt: time.Now(),
+	})
+	return reservations, nil
+}
+
+func (m *testDBRepo) RestoreReservation(id int) error {
+	if id > 2 {
+		return errors.New("reservation does not exist")
+	}
+	if id == 2 {
+		return models.ErrRoomUnavailable
+	}
+	return nil
+}
+
+func (m *testDBRepo) PurgeDeletedReservations(before time.Time) (int, error) {
+	return 0, nil
+}
+
+func (m *testDBRepo) UpdateReservationStatus(id int, status string) error {
+	if id == 4 {
+		return sql.ErrNoRows
+	}
 	if id > 2 {
 		return errors.New("reservation does not exist")
 	}
+	if id == 2 {
+		return &models.TransitionError{From: models.ReservationCancelled, To: status}
+	}
+	return nil
+}
+
+func (m *testDBRepo) FrontDeskReservations(day time.Time) ([]models.Reservation, error) {
+	var reservations []models.Reservation
+	if day.Year() < 2050 {
+		return reservations, nil
+	}
+	reservations = append(reservations,
+		models.Reservation{
+			ID:        1,
+			FirstName: "John",
+			LastName:  "Smith",
+			StartDate: day,
+			EndDate:   day.AddDate(0, 0, 2),
+			RoomID:    1,
+			Status:    models.ReservationConfirmed,
+			Room:      models.Room{ID: 1, RoomName: "Generals Quarters"},
+		},
+		models.Reservation{
+			ID:          2,
+			FirstName:   "Jane",
+			LastName:    "Doe",
+			StartDate:   day.AddDate(0, 0, -3),
+			EndDate:     day,
+			RoomID:      2,
+			Status:      models.ReservationCheckedIn,
+			CheckedInAt: day.AddDate(0, 0, -3),
+			Room:        models.Room{ID: 2, RoomName: "Majors Suite"},
+		},
+	)
+	return reservations, nil
+}
+
+func (m *testDBRepo) CheckIn(id, userID int, card models.RegistrationCard) error {
+	return m.UpdateReservationStatus(id, models.ReservationCheckedIn)
+}
+
+func (m *testDBRepo) CheckOut(id, userID int) error {
+	return m.UpdateReservationStatus(id, models.ReservationCheckedOut)
+}
+
+func (m *testDBRepo) GetRegistrationCard(reservationID int) (models.RegistrationCard, error) {
+	if reservationID != 1 {
+		return models.RegistrationCard{}, sql.ErrNoRows
+	}
+	return models.RegistrationCard{
+		ID:            1,
+		ReservationID: 1,
+		Address:       "1 Main Street, Springfield",
+		IDType:        models.IDPassport,
+		IDNumber:      "X1234567",
+		VehiclePlate:  "AB-123-CD",
+	}, nil
+}
+
+func (m *testDBRepo) SaveRegistrationCard(card models.RegistrationCard) error {
+	if card.ReservationID > 2 {
+		return errors.New("reservation does not exist")
+	}
+	return nil
+}
+
+func (m *testDBRepo) SearchGuests(q string, limit int) ([]models.Guest, error) {
+	var guests = []models.Guest{
+		{ID: 1, FirstName: "John", LastName: "Smith", Email: "john@smith.com", Stays: 2},
+		{ID: 2, FirstName: "Jon", LastName: "Smith", Phone: "555-1234", Stays: 1},
+	}
+	if q == "nobody" {
+		return nil, nil
+	}
+	return guests, nil
+}
+
+func (m *testDBRepo) GetGuestByID(id int) (models.Guest, error) {
+	switch id {
+	case 1:
+		return models.Guest{ID: 1, FirstName: "John", LastName: "Smith", Email: "john@smith.com", Notes: "Likes a quiet room", Stays: 2}, nil
+	case 2:
+		return models.Guest{ID: 2, FirstName: "Jon", LastName: "Smith", Phone: "555-1234", Stays: 1}, nil
+	case 3:
+		return models.Guest{}, errors.New("some error")
+	}
+	return models.Guest{}, sql.ErrNoRows
+}
+
+func (m *testDBRepo) PossibleDuplicateGuests(g models.Guest) ([]models.Guest, error) {
+	if g.ID != 1 {
+		return nil, nil
+	}
+	return []models.Guest{{ID: 2, FirstName: "Jon", LastName: "Smith", Phone: "555-1234", Stays: 1}}, nil
+}
+
+func (m *testDBRepo) GuestReservations(guestID int) ([]models.Reservation, error) {
+	var reservations = []models.Reservation{
+		{
+			ID:        1,
+			StartDate: time.Date(2050, 1, 10, 0, 0, 0, 0, time.UTC),
+			EndDate:   time.Date(2050, 1, 12, 0, 0, 0, 0, time.UTC),
+			RoomID:    1,
+			Status:    models.ReservationConfirmed,
+			GuestID:   guestID,
+			Room:      models.Room{ID: 1, RoomName: "Generals Quarters"},
+		},
+	}
+	return reservations, nil
+}
+
+func (m *testDBRepo) UpdateGuest(g models.Guest) error {
+	if g.ID > 2 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+func (m *testDBRepo) MergeGuests(keepID, duplicateID int) error {
+	if keepID == duplicateID {
+		return errors.New("cannot merge a guest into itself")
+	}
+	if keepID > 2 || duplicateID > 2 {
+		return sql.ErrNoRows
+	}
 	return nil
 }
 
+func (m *testDBRepo) GuestDataByEmail(email string) (models.GuestData, error) {
+	data := models.GuestData{Email: models.NormalizeEmail(email)}
+	switch data.Email {
+	case "fail@error.com":
+		return data, errors.New("some error")
+	case "john@smith.com":
+		data.Guests = []models.Guest{{ID: 1, FirstName: "John", LastName: "Smith", Email: "john@smith.com"}}
+		data.Reservations = []models.Reservation{{ID: 1, FirstName: "John", LastName: "Smith", Email: "john@smith.com", GuestID: 1}}
+		data.RegistrationCards = []models.RegistrationCard{{ID: 1, ReservationID: 1, IDNumber: "X1234567"}}
+		data.TextMessages = []models.SMSMessage{{ID: 1, SMSData: models.SMSData{To: "+15550102030", Body: "Your stay is confirmed"}}}
+	}
+	return data, nil
+}
+
+func (m *testDBRepo) EraseGuestData(email string) (int, error) {
+	switch models.NormalizeEmail(email) {
+	case "fail@error.com":
+		return 0, errors.New("some error")
+	case "john@smith.com":
+		return 1, nil
+	}
+	return 0, nil
+}
+
+func (m *testDBRepo) AnonymizeReservationsBefore(before time.Time) (int, error) {
+	return 0, nil
+}
+
+func (m *testDBRepo) ReencryptReservations(batchSize int) (int, error) {
+	return 0, nil
+}
+
+func (m *testDBRepo) ReencryptGuests(batchSize int) (int, error) {
+	return 0, nil
+}
+
 func (m *testDBRepo) AllRooms() ([]models.Room, error) {
 	var rooms = []models.Room{
 		{
@@ -166,6 +516,17 @@ func (m *testDBRepo) GetRestrictionsForRoomByDate(roomID int, start, end time.Ti
 			CreatedAt:     time.Now(),
 			UpdatedAt:     time.Now(),
 		},
+		{
+			ID:            roomID + 10,
+			StartDate:     start.AddDate(0, 0, 3),
+			EndDate:       start.AddDate(0, 0, 5),
+			RoomID:        1,
+			RestrictionID: 3,
+			SourceID:      1,
+			SourceUID:     "stay-1@channel.example",
+			CreatedAt:     time.Now(),
+			UpdatedAt:     time.Now(),
+		},
 	}
 
 	return restrictions, nil
@@ -184,3 +545,526 @@ func (m *testDBRepo) DeleteBlockByID(id int) error {
 	}
 	return nil
 }
+
+func (m *testDBRepo) ApplyBlockChanges(changes []models.BlockChange) ([]models.BlockConflict, error) {
+	var conflicts []models.BlockConflict
+	for _, c := range changes {
+		if c.RoomID > 2 {
+			return conflicts, errors.New("room does not exist")
+		}
+		if c.Action == models.BlockActionRemove && c.Version > 1 {
+			conflicts = append(conflicts, models.BlockConflict{
+				Change: c,
+				Reason: "block was changed by someone else",
+			})
+		}
+	}
+	return conflicts, nil
+}
+
+func (m *testDBRepo) GetRestrictionsForFeed(roomID int, since time.Time) ([]models.RoomRestriction, error) {
+	var restrictions []models.RoomRestriction
+	if roomID > 2 {
+		return restrictions, nil
+	}
+
+	start := since.AddDate(0, 1, 0)
+	restrictions = append(restrictions,
+		models.RoomRestriction{
+			ID:            1,
+			StartDate:     start,
+			EndDate:       start.AddDate(0, 0, 2),
+			RoomID:        1,
+			ReservationID: 1,
+			RestrictionID: 1,
+			Room:          models.Room{ID: 1, RoomName: "Generals Quarters"},
+			Reservation:   models.Reservation{FirstName: "John", LastName: "Smith"},
+		},
+		models.RoomRestriction{
+			ID:            2,
+			StartDate:     start.AddDate(0, 0, 5),
+			EndDate:       start.AddDate(0, 0, 6),
+			RoomID:        1,
+			RestrictionID: 2,
+			Room:          models.Room{ID: 1, RoomName: "Generals Quarters"},
+			Restriction:   models.Restriction{ID: 2, RestrictionName: "Owner Block"},
+		},
+	)
+	return restrictions, nil
+}
+
+func (m *testDBRepo) AllICalSources() ([]models.ICalSource, error) {
+	var sources = []models.ICalSource{
+		{
+			ID:     1,
+			RoomID: 1,
+			Name:   "Booking site",
+			URL:    "https://example.com/room1.ics",
+			Room:   models.Room{ID: 1, RoomName: "Generals Quarters"},
+		},
+	}
+	return sources, nil
+}
+
+func (m *testDBRepo) GetICalSourceByID(id int) (models.ICalSource, error) {
+	var source models.ICalSource
+	if id > 2 {
+		return source, errors.New("source does not exist")
+	}
+	source.ID = id
+	source.RoomID = 1
+	source.Name = "Booking site"
+	// nothing listens here, so syncing this source fails
+	source.URL = "http://127.0.0.1:1/room1.ics"
+	return source, nil
+}
+
+func (m *testDBRepo) InsertICalSource(s models.ICalSource) (int, error) {
+	if s.RoomID > 2 {
+		return 0, errors.New("room does not exist")
+	}
+	return 1, nil
+}
+
+func (m *testDBRepo) DeleteICalSource(id int) error {
+	if id > 2 {
+		return errors.New("source does not exist")
+	}
+	return nil
+}
+
+func (m *testDBRepo) UpdateICalSourceStatus(id int, syncedAt time.Time, status string) error {
+	return nil
+}
+
+func (m *testDBRepo) SyncExternalBookings(source models.ICalSource, bookings []models.RoomRestriction) (models.ICalSyncResult, error) {
+	var result models.ICalSyncResult
+	result.Added = len(bookings)
+	return result, nil
+}
+
+func (m *testDBRepo) ChannelEventsAfter(afterPosition, limit int) ([]models.ChannelEvent, error) {
+	var events []models.ChannelEvent
+	return events, nil
+}
+
+func (m *testDBRepo) RoomAvailabilityByDate(roomID int, start, end time.Time) ([]models.RoomAvailability, error) {
+	var days []models.RoomAvailability
+	for d := start; d.Before(end); d = d.AddDate(0, 0, 1) {
+		days = append(days, models.RoomAvailability{RoomID: roomID, Date: d, Available: true})
+	}
+	return days, nil
+}
+
+func (m *testDBRepo) GetChannelCursor(channel string) (models.ChannelCursor, error) {
+	var c models.ChannelCursor
+	c.Channel = channel
+	return c, nil
+}
+
+func (m *testDBRepo) UpdateChannelCursor(c models.ChannelCursor) error {
+	return nil
+}
+
+func (m *testDBRepo) AllChannelCursors() ([]models.ChannelCursor, error) {
+	var cursors []models.ChannelCursor
+	cursors = append(cursors, models.ChannelCursor{
+		ID:           1,
+		Channel:      "mock",
+		LastPosition: 10,
+		Attempts:     2,
+		LastError:    "channel unavailable",
+		Pending:      3,
+	})
+	return cursors, nil
+}
+
+func (m *testDBRepo) RetryChannelNow(channel string) error {
+	if channel == "missing" {
+		return sql.ErrNoRows
+	}
+	if channel != "mock" {
+		return errors.New("channel does not exist")
+	}
+	return nil
+}
+
+func (m *testDBRepo) ListUsers() ([]models.User, error) {
+	var users []models.User
+	users = append(users, models.User{ID: 1, FirstName: "Admin", LastName: "User", Email: "admin@here.com"})
+	return users, nil
+}
+
+func (m *testDBRepo) Transaction(fn func(repo repository.DatabaseRepo) error) error {
+	return fn(m)
+}
+
+func (m *testDBRepo) InsertAuditEvent(e models.AuditEvent) error {
+	return nil
+}
+
+func (m *testDBRepo) AuditEvents(filter models.AuditFilter) ([]models.AuditEvent, error) {
+	var events []models.AuditEvent
+	if filter.Entity != "" && filter.Entity != "reservation" {
+		return events, nil
+	}
+	events = append(events, models.AuditEvent{
+		ID:       1,
+		UserID:   1,
+		Action:   "reservation.update",
+		Entity:   "reservation",
+		EntityID: "1",
+		Before:   `{"FirstName": "John"}`,
+		After:    `{"FirstName": "Jon"}`,
+		User:     models.User{ID: 1, FirstName: "Admin", LastName: "User"},
+	})
+	return events, nil
+}
+
+func (m *testDBRepo) QueueMail(msg models.MailData) error {
+	m.queue(msg)
+	return nil
+}
+
+// queue keeps messages in the outbox until they are claimed
+func (m *testDBRepo) queue(messages ...models.MailData) {
+	m.outbox.mu.Lock()
+	defer m.outbox.mu.Unlock()
+	m.outbox.messages = append(m.outbox.messages, messages...)
+}
+
+// ClaimMail takes up to limit of the queued messages out of the outbox
+func (m *testDBRepo) ClaimMail(limit int, lease time.Duration) ([]models.MailMessage, error) {
+	m.outbox.mu.Lock()
+	defer m.outbox.mu.Unlock()
+
+	var claimed []models.MailMessage
+	for len(m.outbox.messages) > 0 && len(claimed) < limit {
+		claimed = append(claimed, models.MailMessage{
+			ID:       len(claimed) + 1,
+			MailData: m.outbox.messages[0],
+			Status:   models.MailPending,
+			Attempts: 1,
+		})
+		m.outbox.messages = m.outbox.messages[1:]
+	}
+	return claimed, nil
+}
+
+func (m *testDBRepo) UpdateMailDelivery(msg models.MailMessage) error {
+	return nil
+}
+
+// testMailMessage is the outbox message with id 1, which failed
+func testMailMessage() models.MailMessage {
+	return models.MailMessage{
+		ID: 1,
+		MailData: models.MailData{
+			To:          "john@smith.com",
+			From:        "rajiv@mkcl.org",
+			Subject:     "Reservation Confirmation",
+			Content:     "<h2>Reservation Confirmation</h2>",
+			Text:        "Dear John,",
+			Template:    "confirmation",
+			Attachments: []models.MailAttachment{{Name: "reservation.ics", MimeType: "text/calendar"}},
+		},
+		Status:    models.MailFailed,
+		Attempts:  8,
+		LastError: "dial tcp: connection refused",
+	}
+}
+
+func (m *testDBRepo) MailMessages(status string, limit int) ([]models.MailMessage, error) {
+	var messages []models.MailMessage
+	if status == "" || status == models.MailFailed {
+		messages = append(messages, testMailMessage())
+	}
+	return messages, nil
+}
+
+func (m *testDBRepo) GetMailMessage(id int) (models.MailMessage, error) {
+	switch id {
+	case 1:
+		return testMailMessage(), nil
+	case 2:
+		msg := testMailMessage()
+		msg.ID = 2
+		msg.Status = models.MailPending
+		return msg, nil
+	case 3:
+		return models.MailMessage{}, errors.New("database unavailable")
+	}
+	return models.MailMessage{}, sql.ErrNoRows
+}
+
+func (m *testDBRepo) ResendMail(id int) error {
+	if id != 1 {
+		return errors.New("message can't be resent")
+	}
+	return nil
+}
+
+// testPropertySettings are the settings of the test property
+func testPropertySettings() models.PropertySettings {
+	return models.PropertySettings{
+		SenderName:  "Fort Smythe Bed and Breakfast",
+		SenderEmail: "rajiv@mkcl.org",
+		ReplyTo:     "frontdesk@fortsmythe.com",
+		Recipients: map[string][]string{
+			models.EventNewBooking:   {"rajiv@mkcl.org"},
+			models.EventCancellation: {"frontdesk@fortsmythe.com"},
+		},
+	}
+}
+
+func (m *testDBRepo) GetPropertySettings() (models.PropertySettings, error) {
+	return testPropertySettings(), nil
+}
+
+func (m *testDBRepo) UpdatePropertySettings(settings models.PropertySettings) error {
+	if settings.SenderEmail == "error@fortsmythe.com" {
+		return errors.New("can't save settings")
+	}
+	return nil
+}
+
+// NotificationRecipients returns the addresses of the settings, and admin@admin.com who chose to be told
+// of modifications
+func (m *testDBRepo) NotificationRecipients(event string) ([]string, error) {
+	recipients := testPropertySettings().Recipients[event]
+	if event == models.EventModification {
+		recipients = append(recipients, "admin@admin.com")
+	}
+	return recipients, nil
+}
+
+func (m *testDBRepo) NotificationPreferences(userID int) ([]string, error) {
+	if userID == 3 {
+		return nil, errors.New("user does not exist")
+	}
+	if userID == 1 {
+		return []string{models.EventModification}, nil
+	}
+	return nil, nil
+}
+
+func (m *testDBRepo) UpdateNotificationPreferences(userID int, events []string) error {
+	if userID == 3 {
+		return errors.New("user does not exist")
+	}
+	return nil
+}
+
+// testScheduledJobs are the jobs of the test scheduler
+func testScheduledJobs() []models.ScheduledJob {
+	return []models.ScheduledJob{
+		{ID: 1, Name: models.JobGuestReminder, Enabled: true, OffsetDays: 3, Hour: 9},
+		{ID: 2, Name: models.JobGuestFollowUp, Enabled: true, OffsetDays: 1, Hour: 10, LastError: "can't render email"},
+		{ID: 3, Name: models.JobOutOfOrder, Enabled: true, Hour: 0},
+	}
+}
+
+func (m *testDBRepo) ScheduledJobs() ([]models.ScheduledJob, error) {
+	return testScheduledJobs(), nil
+}
+
+func (m *testDBRepo) GetScheduledJob(name string) (models.ScheduledJob, error) {
+	for _, job := range testScheduledJobs() {
+		if job.Name == name {
+			return job, nil
+		}
+	}
+	return models.ScheduledJob{}, sql.ErrNoRows
+}
+
+func (m *testDBRepo) UpdateScheduledJob(job models.ScheduledJob) error {
+	if job.Hour == 23 {
+		return errors.New("can't save job")
+	}
+	return nil
+}
+
+func (m *testDBRepo) ClaimScheduledJob(name string, lease time.Duration) (models.ScheduledJob, error) {
+	return models.ScheduledJob{}, sql.ErrNoRows
+}
+
+func (m *testDBRepo) FinishScheduledJob(job models.ScheduledJob) error {
+	return nil
+}
+
+// GuestEmailsDue returns the reservation with id 1 for reminders, and no reservation for follow-ups
+func (m *testDBRepo) GuestEmailsDue(kind string, start, end time.Time) ([]models.Reservation, error) {
+	switch kind {
+	case models.JobGuestReminder:
+		res, _ := m.GetReservationById(1)
+		return []models.Reservation{res}, nil
+	case models.JobGuestFollowUp:
+		return nil, nil
+	}
+	return nil, fmt.Errorf("no guest email for job %q", kind)
+}
+
+func (m *testDBRepo) QueueGuestEmail(reservationID int, kind string, msg models.MailData, text models.SMSData) error {
+	m.queue(msg)
+	if text.To != "" {
+		m.queueSMS(text)
+	}
+	return nil
+}
+
+// queueSMS adds text messages to the outbox
+func (m *testDBRepo) queueSMS(texts ...models.SMSData) {
+	m.outbox.mu.Lock()
+	defer m.outbox.mu.Unlock()
+	m.outbox.texts = append(m.outbox.texts, texts...)
+}
+
+// ClaimSMS takes up to limit of the queued text messages out of the outbox
+func (m *testDBRepo) ClaimSMS(limit int, lease time.Duration) ([]models.SMSMessage, error) {
+	m.outbox.mu.Lock()
+	defer m.outbox.mu.Unlock()
+
+	var claimed []models.SMSMessage
+	for len(m.outbox.texts) > 0 && len(claimed) < limit {
+		claimed = append(claimed, models.SMSMessage{
+			ID:       len(claimed) + 1,
+			SMSData:  m.outbox.texts[0],
+			Status:   models.MailPending,
+			Attempts: 1,
+		})
+		m.outbox.texts = m.outbox.texts[1:]
+	}
+	return claimed, nil
+}
+
+func (m *testDBRepo) UpdateSMSDelivery(msg models.SMSMessage) error {
+	return nil
+}
+
+// testWebhookEndpoint is the webhook endpoint with id 1, subscribed to every event
+func testWebhookEndpoint() models.WebhookEndpoint {
+	return models.WebhookEndpoint{
+		ID:     1,
+		URL:    "https://crm.example.com/hooks",
+		Secret: "test-secret",
+		Events: models.WebhookEvents,
+		Active: true,
+	}
+}
+
+func (m *testDBRepo) WebhookEndpoints() ([]models.WebhookEndpoint, error) {
+	return []models.WebhookEndpoint{testWebhookEndpoint()}, nil
+}
+
+func (m *testDBRepo) GetWebhookEndpoint(id int) (models.WebhookEndpoint, error) {
+	switch id {
+	case 1:
+		return testWebhookEndpoint(), nil
+	case 3:
+		return models.WebhookEndpoint{}, errors.New("database unavailable")
+	}
+	return models.WebhookEndpoint{}, sql.ErrNoRows
+}
+
+func (m *testDBRepo) InsertWebhookEndpoint(e models.WebhookEndpoint) (int, error) {
+	if strings.Contains(e.URL, "error") {
+		return 0, errors.New("can't insert endpoint")
+	}
+	return 1, nil
+}
+
+func (m *testDBRepo) UpdateWebhookEndpoint(e models.WebhookEndpoint) error {
+	if strings.Contains(e.URL, "error") {
+		return errors.New("can't update endpoint")
+	}
+	return nil
+}
+
+func (m *testDBRepo) DeleteWebhookEndpoint(id int) error {
+	if id != 1 {
+		return errors.New("endpoint does not exist")
+	}
+	return nil
+}
+
+// QueueWebhook keeps a delivery of the payload in the outbox if the test endpoint subscribes to event
+func (m *testDBRepo) QueueWebhook(event string, payload []byte) error {
+	e := testWebhookEndpoint()
+	if !e.Active || !e.Subscribed(event) {
+		return nil
+	}
+
+	m.outbox.mu.Lock()
+	defer m.outbox.mu.Unlock()
+	m.outbox.webhooks = append(m.outbox.webhooks, models.WebhookDelivery{
+		EndpointID: e.ID,
+		URL:        e.URL,
+		Secret:     e.Secret,
+		Event:      event,
+		Payload:    string(payload),
+		Status:     models.WebhookPending,
+	})
+	return nil
+}
+
+// ClaimWebhooks takes up to limit of the queued deliveries out of the outbox
+func (m *testDBRepo) ClaimWebhooks(limit int, lease time.Duration) ([]models.WebhookDelivery, error) {
+	m.outbox.mu.Lock()
+	defer m.outbox.mu.Unlock()
+
+	var claimed []models.WebhookDelivery
+	for len(m.outbox.webhooks) > 0 && len(claimed) < limit {
+		d := m.outbox.webhooks[0]
+		d.ID = len(claimed) + 1
+		d.Attempts = 1
+		claimed = append(claimed, d)
+		m.outbox.webhooks = m.outbox.webhooks[1:]
+	}
+	return claimed, nil
+}
+
+func (m *testDBRepo) UpdateWebhookDelivery(d models.WebhookDelivery) error {
+	return nil
+}
+
+// testWebhookDelivery is the delivery with id 1, which the endpoint kept refusing
+func testWebhookDelivery() models.WebhookDelivery {
+	e := testWebhookEndpoint()
+	return models.WebhookDelivery{
+		ID:             1,
+		EndpointID:     e.ID,
+		URL:            e.URL,
+		Secret:         e.Secret,
+		Event:          models.WebhookReservationCreated,
+		Payload:        `{"event":"reservation.created","data":{"id":1}}`,
+		Status:         models.WebhookFailed,
+		Attempts:       8,
+		ResponseStatus: 500,
+		LastError:      "endpoint returned 500 Internal Server Error",
+	}
+}
+
+func (m *testDBRepo) WebhookDeliveries(endpointID, limit int) ([]models.WebhookDelivery, error) {
+	var deliveries []models.WebhookDelivery
+	if endpointID == 0 || endpointID == 1 {
+		deliveries = append(deliveries, testWebhookDelivery())
+	}
+	return deliveries, nil
+}
+
+func (m *testDBRepo) GetWebhookDelivery(id int) (models.WebhookDelivery, error) {
+	switch id {
+	case 1:
+		return testWebhookDelivery(), nil
+	case 3:
+		return models.WebhookDelivery{}, errors.New("database unavailable")
+	}
+	return models.WebhookDelivery{}, sql.ErrNoRows
+}
+
+func (m *testDBRepo) RedeliverWebhook(id int) (int, error) {
+	if id != 1 {
+		return 0, errors.New("delivery can't be redelivered")
+	}
+	return 2, nil
+}
@@ -0,0 +1,95 @@
+package dbrepo
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/go-course/bookings/internal/repository"
+)
+
+// querier runs statements on the database or within a transaction
+type querier interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// txn is a transaction begun by a repository method. Within a transaction handed out by Transaction
+// it is a savepoint, so the method still commits or rolls back its own changes only
+type txn struct {
+	*sql.Tx
+	savepoint bool
+	done      bool
+}
+
+// Commit commits the transaction, or releases the savepoint
+func (t *txn) Commit() error {
+	if !t.savepoint {
+		return t.Tx.Commit()
+	}
+	return t.end("release savepoint repo_method")
+}
+
+// Rollback rolls back the transaction, or the changes made since the savepoint
+func (t *txn) Rollback() error {
+	if !t.savepoint {
+		return t.Tx.Rollback()
+	}
+	return t.end("rollback to savepoint repo_method")
+}
+
+// end runs the statement ending the savepoint, once
+func (t *txn) end(query string) error {
+	if t.done {
+		return sql.ErrTxDone
+	}
+	t.done = true
+	_, err := t.Tx.ExecContext(context.Background(), query)
+	return err
+}
+
+// conn returns where statements run, the transaction of the repo if it has one
+func (m *postgresDBRepo) conn() querier {
+	if m.tx != nil {
+		return m.tx
+	}
+	return m.DB
+}
+
+// begin starts a transaction, or a savepoint when the repo already runs in one
+func (m *postgresDBRepo) begin(ctx context.Context) (*txn, error) {
+	if m.tx != nil {
+		_, err := m.tx.ExecContext(ctx, "savepoint repo_method")
+		if err != nil {
+			return nil, err
+		}
+		return &txn{Tx: m.tx, savepoint: true}, nil
+	}
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	return &txn{Tx: tx}, nil
+}
+
+// Transaction runs fn with a copy of the repo whose statements all run in one transaction, committed
+// if fn returns nil. The transaction itself has no deadline: the methods fn calls keep their own, so a
+// five minute import is not cut short by a limit meant for a single update
+func (m *postgresDBRepo) Transaction(fn func(repo repository.DatabaseRepo) error) error {
+	if m.tx != nil {
+		return fn(m)
+	}
+
+	tx, err := m.DB.BeginTx(context.Background(), nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := fn(&postgresDBRepo{App: m.App, DB: m.DB, tx: tx}); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
@@ -0,0 +1,101 @@
+package dbrepo
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-course/bookings/internal/config"
+	"github.com/go-course/bookings/internal/repository"
+)
+
+// fakeDriver is a database driver keeping the context its transactions were begun with and how they ended
+type fakeDriver struct {
+	mu        sync.Mutex
+	txCtx     context.Context
+	committed bool
+}
+
+func (d *fakeDriver) Open(name string) (driver.Conn, error) {
+	return &fakeConn{d: d}, nil
+}
+
+type fakeConn struct {
+	d *fakeDriver
+}
+
+func (c *fakeConn) Prepare(query string) (driver.Stmt, error) {
+	return nil, errors.New("not supported")
+}
+
+func (c *fakeConn) Close() error {
+	return nil
+}
+
+func (c *fakeConn) Begin() (driver.Tx, error) {
+	return c.BeginTx(context.Background(), driver.TxOptions{})
+}
+
+func (c *fakeConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	c.d.mu.Lock()
+	defer c.d.mu.Unlock()
+	c.d.txCtx = ctx
+	return &fakeTx{d: c.d}, nil
+}
+
+func (c *fakeConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	return driver.RowsAffected(0), nil
+}
+
+type fakeTx struct {
+	d *fakeDriver
+}
+
+func (t *fakeTx) Commit() error {
+	t.d.mu.Lock()
+	defer t.d.mu.Unlock()
+	t.d.committed = true
+	return nil
+}
+
+func (t *fakeTx) Rollback() error {
+	return nil
+}
+
+var testDriver = &fakeDriver{}
+
+func init() {
+	sql.Register("dbrepo-fake", testDriver)
+}
+
+func TestTransaction_KeepsLongCalls(t *testing.T) {
+	db, err := sql.Open("dbrepo-fake", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	repo := NewPostgresRepo(db, &config.AppConfig{})
+	err = repo.Transaction(func(tx repository.DatabaseRepo) error {
+		// a five minute import only keeps the limit of its own statements
+		if _, ok := testDriver.txCtx.Deadline(); ok {
+			t.Error("the transaction should have no deadline of its own")
+		}
+		time.Sleep(20 * time.Millisecond)
+		if testDriver.txCtx.Err() != nil {
+			return testDriver.txCtx.Err()
+		}
+		_, err := tx.(*postgresDBRepo).conn().ExecContext(context.Background(), "insert into reservations default values")
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !testDriver.committed {
+		t.Error("the transaction should be committed")
+	}
+}
@@ -9,8 +9,13 @@ import (
 type DatabaseRepo interface {
 	AllUsers() bool
 
+	// Transaction runs fn with a repository whose changes are saved together if fn returns nil, and
+	// rolled back otherwise
+	Transaction(fn func(repo DatabaseRepo) error) error
+
 	InsertReservation(res models.Reservation) (int, error)
 	InsertRoomRestriction(res models.RoomRestriction) error
+	BookReservation(res models.Reservation, notify func(models.Reservation) ([]models.MailData, models.SMSData, error)) (int, error)
 	SearchAvailabilityByDatesByRoomID(start, end time.Time, roomID int) (bool, error)
 	SearchAvailabilityForAllRooms(start, end time.Time) ([]models.Room, error)
 	GetRoomByID(id int) (models.Room, error)
@@ -18,15 +23,103 @@ type DatabaseRepo interface {
 	GetRestrictionsForRoomByDate(roomID int, start, end time.Time) ([]models.RoomRestriction, error)
 	InsertBlockForRoom(id int, startDate time.Time) error
 	DeleteBlockByID(id int) error
+	ApplyBlockChanges(changes []models.BlockChange) ([]models.BlockConflict, error)
+	GetRestrictionsForFeed(roomID int, since time.Time) ([]models.RoomRestriction, error)
+
+	AllICalSources() ([]models.ICalSource, error)
+	GetICalSourceByID(id int) (models.ICalSource, error)
+	InsertICalSource(s models.ICalSource) (int, error)
+	DeleteICalSource(id int) error
+	UpdateICalSourceStatus(id int, syncedAt time.Time, status string) error
+	SyncExternalBookings(source models.ICalSource, bookings []models.RoomRestriction) (models.ICalSyncResult, error)
+
+	ChannelEventsAfter(afterPosition, limit int) ([]models.ChannelEvent, error)
+	RoomAvailabilityByDate(roomID int, start, end time.Time) ([]models.RoomAvailability, error)
+	GetChannelCursor(channel string) (models.ChannelCursor, error)
+	UpdateChannelCursor(c models.ChannelCursor) error
+	AllChannelCursors() ([]models.ChannelCursor, error)
+	RetryChannelNow(channel string) error
 
 	GetUserById(id int) (models.User, error)
 	UpdateUser(u models.User) error
 	Authenticate(email, testPassword string) (int, string, error)
+	GetUserByFeedToken(token string) (models.User, error)
+	UpdateFeedTokenForUser(id int, token string) error
+	ListUsers() ([]models.User, error)
 
-	AllReservations() ([]models.Reservation, error)
-	AllNewReservations() ([]models.Reservation, error)
+	InsertAuditEvent(e models.AuditEvent) error
+	AuditEvents(filter models.AuditFilter) ([]models.AuditEvent, error)
+
+	SearchReservations(q models.ReservationQuery) ([]models.Reservation, int, error)
+	EachReservation(q models.ReservationQuery, fn func(models.Reservation) error) error
+	EachRoomOccupancy(start, end time.Time, fn func(models.RoomOccupancy) error) error
+	ImportBookings(rows []models.ImportRow) error
+	DashboardCounts(day time.Time) (models.DashboardCounts, error)
+	LeadTimes(since time.Time) ([]models.LeadTimeBucket, error)
+	ReservationsArriving(start, end time.Time) ([]models.Reservation, error)
+	RestrictionsBetween(start, end time.Time) ([]models.RoomRestriction, error)
+	RoomStatuses() ([]models.RoomStatus, error)
+	GetRoomStatus(roomID int) (models.RoomStatus, error)
+	UpdateRoomStatus(s models.RoomStatus) (models.RoomStatus, error)
+	ExtendOutOfOrderBlocks(today time.Time) (int, error)
 	GetReservationById(id int) (models.Reservation, error)
 	UpdateReservation(u models.Reservation) error
 	DeleteReservation(id int) error
-	UpdateProcessedForReservation(id, processed int) error
+	DeletedReservations() ([]models.Reservation, error)
+	RestoreReservation(id int) error
+	PurgeDeletedReservations(before time.Time) (int, error)
+	GuestDataByEmail(email string) (models.GuestData, error)
+	EraseGuestData(email string) (int, error)
+	AnonymizeReservationsBefore(before time.Time) (int, error)
+	ReencryptReservations(batchSize int) (int, error)
+	ReencryptGuests(batchSize int) (int, error)
+	UpdateReservationStatus(id int, status string) error
+	FrontDeskReservations(day time.Time) ([]models.Reservation, error)
+	CheckIn(id, userID int, card models.RegistrationCard) error
+	CheckOut(id, userID int) error
+	GetRegistrationCard(reservationID int) (models.RegistrationCard, error)
+	SaveRegistrationCard(card models.RegistrationCard) error
+
+	SearchGuests(q string, limit int) ([]models.Guest, error)
+	GetGuestByID(id int) (models.Guest, error)
+	PossibleDuplicateGuests(g models.Guest) ([]models.Guest, error)
+	GuestReservations(guestID int) ([]models.Reservation, error)
+	UpdateGuest(g models.Guest) error
+	MergeGuests(keepID, duplicateID int) error
+
+	QueueMail(msg models.MailData) error
+	ClaimMail(limit int, lease time.Duration) ([]models.MailMessage, error)
+	UpdateMailDelivery(msg models.MailMessage) error
+	MailMessages(status string, limit int) ([]models.MailMessage, error)
+	GetMailMessage(id int) (models.MailMessage, error)
+	ResendMail(id int) error
+
+	ClaimSMS(limit int, lease time.Duration) ([]models.SMSMessage, error)
+	UpdateSMSDelivery(msg models.SMSMessage) error
+
+	GetPropertySettings() (models.PropertySettings, error)
+	UpdatePropertySettings(settings models.PropertySettings) error
+	NotificationRecipients(event string) ([]string, error)
+	NotificationPreferences(userID int) ([]string, error)
+	UpdateNotificationPreferences(userID int, events []string) error
+
+	ScheduledJobs() ([]models.ScheduledJob, error)
+	GetScheduledJob(name string) (models.ScheduledJob, error)
+	UpdateScheduledJob(job models.ScheduledJob) error
+	ClaimScheduledJob(name string, lease time.Duration) (models.ScheduledJob, error)
+	FinishScheduledJob(job models.ScheduledJob) error
+	GuestEmailsDue(kind string, start, end time.Time) ([]models.Reservation, error)
+	QueueGuestEmail(reservationID int, kind string, msg models.MailData, text models.SMSData) error
+
+	WebhookEndpoints() ([]models.WebhookEndpoint, error)
+	GetWebhookEndpoint(id int) (models.WebhookEndpoint, error)
+	InsertWebhookEndpoint(e models.WebhookEndpoint) (int, error)
+	UpdateWebhookEndpoint(e models.WebhookEndpoint) error
+	DeleteWebhookEndpoint(id int) error
+	QueueWebhook(event string, payload []byte) error
+	ClaimWebhooks(limit int, lease time.Duration) ([]models.WebhookDelivery, error)
+	UpdateWebhookDelivery(d models.WebhookDelivery) error
+	WebhookDeliveries(endpointID, limit int) ([]models.WebhookDelivery, error)
+	GetWebhookDelivery(id int) (models.WebhookDelivery, error)
+	RedeliverWebhook(id int) (int, error)
 }
@@ -0,0 +1,113 @@
+package scheduler
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/go-course/bookings/internal/emails"
+	"github.com/go-course/bookings/internal/models"
+)
+
+// GuestEmailStore is the part of the repository the guest email jobs need
+type GuestEmailStore interface {
+	GetPropertySettings() (models.PropertySettings, error)
+	GuestEmailsDue(kind string, start, end time.Time) ([]models.Reservation, error)
+	QueueGuestEmail(reservationID int, kind string, msg models.MailData, text models.SMSData) error
+}
+
+// GuestEmails sends the emails of the guest jobs: pre-arrival reminders and post-stay thank-yous. Each
+// reservation is sent the email of a job once, however often the job runs
+type GuestEmails struct {
+	Store     GuestEmailStore
+	Templates *emails.Templates
+	// SMS is whether guests who opted in also get the text message of emails that have one
+	SMS bool
+	// CatchUpDays is how many days of departures before the due one are sent follow-ups, so that those
+	// missed while the scheduler was down still get one
+	CatchUpDays int
+}
+
+// NewGuestEmails creates the guest email jobs
+func NewGuestEmails(store GuestEmailStore, templates *emails.Templates) *GuestEmails {
+	return &GuestEmails{
+		Store:       store,
+		Templates:   templates,
+		CatchUpDays: 7,
+	}
+}
+
+// Register adds the guest email jobs to a scheduler
+func (g *GuestEmails) Register(s *Scheduler) {
+	s.Register(models.JobGuestReminder, g.Reminders)
+	s.Register(models.JobGuestFollowUp, g.FollowUps)
+}
+
+// Reminders sends the reminder to the guests arriving within the job's OffsetDays, including those who
+// booked less than OffsetDays ahead
+func (g *GuestEmails) Reminders(job models.ScheduledJob, now time.Time) (string, error) {
+	today := day(now)
+	return g.send(job.Name, emails.Reminder, today, today.AddDate(0, 0, job.OffsetDays))
+}
+
+// FollowUps sends the thank-you to the guests who left the job's OffsetDays ago, or up to CatchUpDays
+// before that
+func (g *GuestEmails) FollowUps(job models.ScheduledJob, now time.Time) (string, error) {
+	last := day(now).AddDate(0, 0, -job.OffsetDays)
+	return g.send(job.Name, emails.FollowUp, last.AddDate(0, 0, -g.CatchUpDays), last)
+}
+
+// send queues the named email for every reservation the job kind is due for between start and end. A
+// reservation that fails doesn't keep the others from being sent
+func (g *GuestEmails) send(kind, name string, start, end time.Time) (string, error) {
+	reservations, err := g.Store.GuestEmailsDue(kind, start, end)
+	if err != nil {
+		return "", err
+	}
+	if len(reservations) == 0 {
+		return "no emails due", nil
+	}
+
+	settings, err := g.Store.GetPropertySettings()
+	if err != nil {
+		return "", err
+	}
+
+	queued, failed := 0, 0
+	var lastErr error
+	for _, res := range reservations {
+		if err := g.queue(kind, name, res, settings); err != nil {
+			failed++
+			lastErr = fmt.Errorf("reservation %d: %w", res.ID, err)
+			continue
+		}
+		queued++
+	}
+
+	result := fmt.Sprintf("queued %d emails", queued)
+	if failed > 0 {
+		return result, fmt.Errorf("%d emails failed, the last with %w", failed, lastErr)
+	}
+	return result, nil
+}
+
+// queue renders the named email for a reservation and queues it from the property's sender, with its text
+// message if the guest opted in
+func (g *GuestEmails) queue(kind, name string, res models.Reservation, settings models.PropertySettings) error {
+	email, err := g.Templates.Render(name, emails.Data{Reservation: res})
+	if err != nil {
+		return err
+	}
+	msg := email.MailData(settings.From(), res.Email)
+	msg.ReplyTo = settings.ReplyTo
+
+	var text models.SMSData
+	if g.SMS && res.SMSOptIn && res.Phone != "" && email.SMS != "" {
+		text = email.SMSData(res.Phone)
+	}
+	return g.Store.QueueGuestEmail(res.ID, kind, msg, text)
+}
+
+// day returns the date of t, as dates are stored
+func day(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
@@ -0,0 +1,190 @@
+package scheduler
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-course/bookings/internal/emails"
+	"github.com/go-course/bookings/internal/models"
+)
+
+// memoryGuestEmails holds reservations in memory, recording the emails sent to them like the database does
+type memoryGuestEmails struct {
+	reservations []models.Reservation
+	sent         map[string]bool
+	queued       []models.MailData
+	texts        []models.SMSData
+	// start and end are the dates of the last GuestEmailsDue
+	start, end time.Time
+}
+
+func newMemoryGuestEmails(reservations ...models.Reservation) *memoryGuestEmails {
+	return &memoryGuestEmails{reservations: reservations, sent: make(map[string]bool)}
+}
+
+func (m *memoryGuestEmails) GetPropertySettings() (models.PropertySettings, error) {
+	return models.PropertySettings{SenderName: "Fort Smythe", SenderEmail: "desk@fortsmythe.com", ReplyTo: "frontdesk@fortsmythe.com"}, nil
+}
+
+func (m *memoryGuestEmails) GuestEmailsDue(kind string, start, end time.Time) ([]models.Reservation, error) {
+	m.start, m.end = start, end
+	var due []models.Reservation
+	for _, res := range m.reservations {
+		if !m.sent[kind+res.Email] {
+			due = append(due, res)
+		}
+	}
+	return due, nil
+}
+
+func (m *memoryGuestEmails) QueueGuestEmail(reservationID int, kind string, msg models.MailData, text models.SMSData) error {
+	if msg.To == "error@smith.com" {
+		return errors.New("can't queue email")
+	}
+	m.sent[kind+msg.To] = true
+	m.queued = append(m.queued, msg)
+	if text.To != "" {
+		m.texts = append(m.texts, text)
+	}
+	return nil
+}
+
+func newTestGuestEmails(t *testing.T, store GuestEmailStore) *GuestEmails {
+	templates, err := emails.New("../../email-templates", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return NewGuestEmails(store, templates)
+}
+
+var guest = models.Reservation{
+	ID:        1,
+	FirstName: "John",
+	Email:     "john@smith.com",
+	StartDate: time.Date(2050, 1, 4, 0, 0, 0, 0, time.UTC),
+	EndDate:   time.Date(2050, 1, 6, 0, 0, 0, 0, time.UTC),
+	Room:      models.Room{RoomName: "Generals Quarters"},
+}
+
+func TestGuestEmails_Reminders(t *testing.T) {
+	store := newMemoryGuestEmails(guest)
+	g := newTestGuestEmails(t, store)
+	job := models.ScheduledJob{Name: models.JobGuestReminder, OffsetDays: 3}
+	now := time.Date(2050, 1, 1, 9, 0, 0, 0, time.Local)
+
+	result, err := g.Reminders(job, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result != "queued 1 emails" {
+		t.Errorf("wrong result %q", result)
+	}
+	if want := time.Date(2050, 1, 1, 0, 0, 0, 0, time.UTC); !store.start.Equal(want) {
+		t.Errorf("reminders should start with arrivals on %s, got %s", want, store.start)
+	}
+	if want := time.Date(2050, 1, 4, 0, 0, 0, 0, time.UTC); !store.end.Equal(want) {
+		t.Errorf("reminders should end with arrivals on %s, got %s", want, store.end)
+	}
+
+	if len(store.queued) != 1 {
+		t.Fatalf("expected one email, got %d", len(store.queued))
+	}
+	msg := store.queued[0]
+	if msg.To != "john@smith.com" || msg.From != `"Fort Smythe" <desk@fortsmythe.com>` || msg.ReplyTo != "frontdesk@fortsmythe.com" {
+		t.Errorf("wrong addresses %+v", msg)
+	}
+	if msg.Template != emails.Reminder || !strings.Contains(msg.Text, "Check-in is from 3:00 pm on 2050-01-04") {
+		t.Errorf("wrong reminder %s:\n%s", msg.Template, msg.Text)
+	}
+
+	if result, _ := g.Reminders(job, now); result != "no emails due" || len(store.queued) != 1 {
+		t.Errorf("a guest should get a reminder once, got %q", result)
+	}
+}
+
+func TestGuestEmails_FollowUps(t *testing.T) {
+	store := newMemoryGuestEmails(guest)
+	g := newTestGuestEmails(t, store)
+	job := models.ScheduledJob{Name: models.JobGuestFollowUp, OffsetDays: 1}
+
+	if _, err := g.FollowUps(job, time.Date(2050, 1, 7, 10, 0, 0, 0, time.UTC)); err != nil {
+		t.Fatal(err)
+	}
+	if want := time.Date(2049, 12, 30, 0, 0, 0, 0, time.UTC); !store.start.Equal(want) {
+		t.Errorf("follow-ups should catch up with departures from %s, got %s", want, store.start)
+	}
+	if want := time.Date(2050, 1, 6, 0, 0, 0, 0, time.UTC); !store.end.Equal(want) {
+		t.Errorf("follow-ups should end with departures on %s, got %s", want, store.end)
+	}
+	if len(store.queued) != 1 || store.queued[0].Template != emails.FollowUp {
+		t.Fatalf("expected a follow-up, got %+v", store.queued)
+	}
+}
+
+func TestGuestEmails_Failures(t *testing.T) {
+	failing := guest
+	failing.ID = 2
+	failing.Email = "error@smith.com"
+	other := guest
+	other.ID = 3
+	other.Email = "jane@smith.com"
+
+	store := newMemoryGuestEmails(failing, other)
+	g := newTestGuestEmails(t, store)
+
+	result, err := g.Reminders(models.ScheduledJob{Name: models.JobGuestReminder, OffsetDays: 3}, time.Now())
+	if err == nil || !strings.Contains(err.Error(), "reservation 2: can't queue email") {
+		t.Errorf("the failed reservation should be reported, got %v", err)
+	}
+	if result != "queued 1 emails" || len(store.queued) != 1 || store.queued[0].To != "jane@smith.com" {
+		t.Errorf("a failure should not keep the other guests from being sent, got %q", result)
+	}
+}
+
+func TestGuestEmails_Texts(t *testing.T) {
+	optedIn := guest
+	optedIn.Phone = "+15555550123"
+	optedIn.SMSOptIn = true
+	noPhone := guest
+	noPhone.Email = "jane@smith.com"
+	noPhone.SMSOptIn = true
+	optedOut := guest
+	optedOut.Email = "jim@smith.com"
+	optedOut.Phone = "+15555550124"
+
+	store := newMemoryGuestEmails(optedIn, noPhone, optedOut)
+	g := newTestGuestEmails(t, store)
+	now := time.Date(2050, 1, 1, 9, 0, 0, 0, time.UTC)
+
+	if _, err := g.Reminders(models.ScheduledJob{Name: models.JobGuestReminder, OffsetDays: 3}, now); err != nil {
+		t.Fatal(err)
+	}
+	if len(store.texts) != 0 {
+		t.Errorf("no text should be sent while SMS is off, got %+v", store.texts)
+	}
+
+	store = newMemoryGuestEmails(optedIn, noPhone, optedOut)
+	g = newTestGuestEmails(t, store)
+	g.SMS = true
+	if _, err := g.Reminders(models.ScheduledJob{Name: models.JobGuestReminder, OffsetDays: 3}, now); err != nil {
+		t.Fatal(err)
+	}
+	if len(store.queued) != 3 {
+		t.Errorf("every guest should get the email, got %d", len(store.queued))
+	}
+	if len(store.texts) != 1 || store.texts[0].To != "+15555550123" || !strings.Contains(store.texts[0].Body, "see you on 2050-01-04") {
+		t.Errorf("only the guest who opted in should get a text, got %+v", store.texts)
+	}
+
+	store = newMemoryGuestEmails(optedIn)
+	g = newTestGuestEmails(t, store)
+	g.SMS = true
+	if _, err := g.FollowUps(models.ScheduledJob{Name: models.JobGuestFollowUp, OffsetDays: 1}, now); err != nil {
+		t.Fatal(err)
+	}
+	if len(store.texts) != 0 {
+		t.Errorf("the follow-up has no text message, got %+v", store.texts)
+	}
+}
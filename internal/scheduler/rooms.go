@@ -0,0 +1,28 @@
+package scheduler
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/go-course/bookings/internal/models"
+)
+
+// OutOfOrderStore is the part of the repository the out of order job needs
+type OutOfOrderStore interface {
+	ExtendOutOfOrderBlocks(today time.Time) (int, error)
+}
+
+// RegisterOutOfOrder adds the job keeping the rooms that are out of order blocked for the coming nights,
+// until they are back in order
+func RegisterOutOfOrder(s *Scheduler, store OutOfOrderStore) {
+	s.Register(models.JobOutOfOrder, func(job models.ScheduledJob, now time.Time) (string, error) {
+		n, err := store.ExtendOutOfOrderBlocks(day(now))
+		if err != nil {
+			return "", err
+		}
+		if n == 0 {
+			return "no rooms out of order", nil
+		}
+		return fmt.Sprintf("kept %d rooms out of order blocked", n), nil
+	})
+}
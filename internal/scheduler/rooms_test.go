@@ -0,0 +1,38 @@
+package scheduler
+
+import (
+	"testing"
+	"time"
+
+	"github.com/go-course/bookings/internal/models"
+)
+
+// outOfOrderRooms counts out of order rooms and keeps the day the job extended their blocks from
+type outOfOrderRooms struct {
+	rooms int
+	today time.Time
+}
+
+func (o *outOfOrderRooms) ExtendOutOfOrderBlocks(today time.Time) (int, error) {
+	o.today = today
+	return o.rooms, nil
+}
+
+func TestRegisterOutOfOrder(t *testing.T) {
+	c := &clock{t: time.Date(2050, 1, 1, 0, 30, 0, 0, time.UTC)}
+	store := newMemoryJobs(c, models.ScheduledJob{Name: models.JobOutOfOrder, Enabled: true, NextRunAt: c.t})
+	rooms := &outOfOrderRooms{rooms: 2}
+
+	s := newTestScheduler(store, c)
+	RegisterOutOfOrder(s, rooms)
+
+	if ran := s.RunDue(); ran != 1 {
+		t.Fatalf("the out of order job should run, ran %d", ran)
+	}
+	if want := time.Date(2050, 1, 1, 0, 0, 0, 0, time.UTC); !rooms.today.Equal(want) {
+		t.Errorf("blocks should be extended from %s, got %s", want, rooms.today)
+	}
+	if job := store.jobs[models.JobOutOfOrder]; job.LastResult != "kept 2 rooms out of order blocked" {
+		t.Errorf("wrong result %q", job.LastResult)
+	}
+}
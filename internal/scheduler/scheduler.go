@@ -0,0 +1,113 @@
+// Package scheduler runs the jobs of the scheduled_jobs table. Every instance of the application runs a
+// scheduler, and a job is claimed in the database before it runs so that only one instance runs it at a time
+package scheduler
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/go-course/bookings/internal/config"
+	"github.com/go-course/bookings/internal/models"
+)
+
+// Store is the part of the repository the scheduler needs
+type Store interface {
+	ClaimScheduledJob(name string, lease time.Duration) (models.ScheduledJob, error)
+	FinishScheduledJob(job models.ScheduledJob) error
+}
+
+// Func runs a claimed job at now and returns a summary of what it did
+type Func func(job models.ScheduledJob, now time.Time) (string, error)
+
+// Scheduler runs the registered jobs when they are due. A job that fails is run again after RetryAfter,
+// otherwise at its next hour
+type Scheduler struct {
+	Store Store
+	// Lease is how long a claimed job is kept from other instances while it runs
+	Lease      time.Duration
+	RetryAfter time.Duration
+	InfoLog    *log.Logger
+	ErrorLog   *log.Logger
+	names      []string
+	funcs      map[string]Func
+	now        func() time.Time
+}
+
+// New creates a scheduler without jobs, using the loggers of the app config
+func New(store Store, a *config.AppConfig) *Scheduler {
+	return &Scheduler{
+		Store:      store,
+		Lease:      10 * time.Minute,
+		RetryAfter: time.Hour,
+		InfoLog:    a.InfoLog,
+		ErrorLog:   a.ErrorLog,
+		funcs:      make(map[string]Func),
+		now:        time.Now,
+	}
+}
+
+// Register adds the job of the table named name, run by fn
+func (s *Scheduler) Register(name string, fn Func) {
+	if _, ok := s.funcs[name]; !ok {
+		s.names = append(s.names, name)
+	}
+	s.funcs[name] = fn
+}
+
+// Start runs the jobs due now and then every interval, until stop is closed
+func (s *Scheduler) Start(interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		s.RunDue()
+		select {
+		case <-ticker.C:
+		case <-stop:
+			return
+		}
+	}
+}
+
+// RunDue runs the registered jobs that are due and not held by another instance, and returns how many ran
+func (s *Scheduler) RunDue() int {
+	ran := 0
+	for _, name := range s.names {
+		job, err := s.Store.ClaimScheduledJob(name, s.Lease)
+		if errors.Is(err, sql.ErrNoRows) {
+			continue
+		}
+		if err != nil {
+			s.ErrorLog.Println(err)
+			continue
+		}
+
+		s.run(job)
+		ran++
+	}
+	return ran
+}
+
+// run runs a claimed job and records the outcome with when it runs next
+func (s *Scheduler) run(job models.ScheduledJob) {
+	now := s.now()
+	result, err := s.funcs[job.Name](job, now)
+
+	job.LastRunAt = now
+	job.LastResult = result
+	job.LastError = ""
+	job.NextRunAt = job.NextRun(now)
+	if err != nil {
+		job.LastError = err.Error()
+		job.NextRunAt = now.Add(s.RetryAfter)
+		s.ErrorLog.Printf("job %s: %s, retrying at %s", job.Name, err, job.NextRunAt.Format(time.RFC3339))
+	} else if result != "" {
+		s.InfoLog.Printf("job %s: %s", job.Name, result)
+	}
+
+	if err := s.Store.FinishScheduledJob(job); err != nil {
+		s.ErrorLog.Println(err)
+	}
+}
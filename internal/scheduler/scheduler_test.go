@@ -0,0 +1,207 @@
+package scheduler
+
+import (
+	"database/sql"
+	"errors"
+	"io"
+	"log"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-course/bookings/internal/models"
+)
+
+// clock is a settable time source
+type clock struct {
+	t time.Time
+}
+
+func (c *clock) now() time.Time {
+	return c.t
+}
+
+// memoryJobs keeps the jobs table in memory, claiming jobs like the database does
+type memoryJobs struct {
+	mu     sync.Mutex
+	clock  *clock
+	jobs   map[string]models.ScheduledJob
+	locked map[string]time.Time
+}
+
+func newMemoryJobs(c *clock, jobs ...models.ScheduledJob) *memoryJobs {
+	m := &memoryJobs{clock: c, jobs: make(map[string]models.ScheduledJob), locked: make(map[string]time.Time)}
+	for _, job := range jobs {
+		m.jobs[job.Name] = job
+	}
+	return m
+}
+
+func (m *memoryJobs) ClaimScheduledJob(name string, lease time.Duration) (models.ScheduledJob, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	job, ok := m.jobs[name]
+	if !ok || !job.Enabled || job.NextRunAt.After(m.clock.t) || m.locked[name].After(m.clock.t) {
+		return models.ScheduledJob{}, sql.ErrNoRows
+	}
+	m.locked[name] = m.clock.t.Add(lease)
+	return job, nil
+}
+
+func (m *memoryJobs) FinishScheduledJob(job models.ScheduledJob) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.jobs[job.Name] = job
+	delete(m.locked, job.Name)
+	return nil
+}
+
+func newTestScheduler(store Store, c *clock) *Scheduler {
+	return &Scheduler{
+		Store:      store,
+		Lease:      10 * time.Minute,
+		RetryAfter: time.Hour,
+		InfoLog:    log.New(io.Discard, "", 0),
+		ErrorLog:   log.New(io.Discard, "", 0),
+		funcs:      make(map[string]Func),
+		now:        c.now,
+	}
+}
+
+func TestScheduler_RunDue(t *testing.T) {
+	c := &clock{t: time.Date(2050, 1, 1, 9, 30, 0, 0, time.UTC)}
+	store := newMemoryJobs(c,
+		models.ScheduledJob{Name: "due", Enabled: true, Hour: 9, NextRunAt: c.t.Add(-time.Minute)},
+		models.ScheduledJob{Name: "later", Enabled: true, Hour: 9, NextRunAt: c.t.Add(time.Hour)},
+		models.ScheduledJob{Name: "disabled", Enabled: false, Hour: 9, NextRunAt: c.t.Add(-time.Minute)},
+	)
+
+	runs := make(map[string]int)
+	s := newTestScheduler(store, c)
+	for _, name := range []string{"due", "later", "disabled"} {
+		s.Register(name, func(job models.ScheduledJob, now time.Time) (string, error) {
+			runs[job.Name]++
+			return "done", nil
+		})
+	}
+
+	if ran := s.RunDue(); ran != 1 || runs["due"] != 1 {
+		t.Fatalf("only the due job should run, ran %d: %v", ran, runs)
+	}
+
+	job := store.jobs["due"]
+	if !job.LastRunAt.Equal(c.t) || job.LastResult != "done" || job.LastError != "" {
+		t.Errorf("the run should be recorded: %+v", job)
+	}
+	if want := time.Date(2050, 1, 2, 9, 0, 0, 0, time.UTC); !job.NextRunAt.Equal(want) {
+		t.Errorf("the job should run next at %s, got %s", want, job.NextRunAt)
+	}
+
+	if ran := s.RunDue(); ran != 0 {
+		t.Errorf("no job should be due again the same day, ran %d", ran)
+	}
+}
+
+func TestScheduler_OneInstanceRunsAJob(t *testing.T) {
+	c := &clock{t: time.Date(2050, 1, 1, 9, 0, 0, 0, time.UTC)}
+	store := newMemoryJobs(c, models.ScheduledJob{Name: "job", Enabled: true, Hour: 9, NextRunAt: c.t})
+
+	var mu sync.Mutex
+	runs := 0
+	started := make(chan struct{})
+	release := make(chan struct{})
+
+	first := newTestScheduler(store, c)
+	first.Register("job", func(job models.ScheduledJob, now time.Time) (string, error) {
+		mu.Lock()
+		runs++
+		mu.Unlock()
+		close(started)
+		<-release
+		return "", nil
+	})
+	second := newTestScheduler(store, c)
+	second.Register("job", func(job models.ScheduledJob, now time.Time) (string, error) {
+		mu.Lock()
+		runs++
+		mu.Unlock()
+		return "", nil
+	})
+
+	done := make(chan int)
+	go func() { done <- first.RunDue() }()
+	<-started
+
+	if ran := second.RunDue(); ran != 0 {
+		t.Errorf("a job running in another instance should not run, ran %d", ran)
+	}
+	close(release)
+	if ran := <-done; ran != 1 {
+		t.Errorf("the first instance should have run the job, ran %d", ran)
+	}
+	if ran := second.RunDue(); ran != 0 {
+		t.Errorf("a job that ran should not run again before its next hour, ran %d", ran)
+	}
+	if runs != 1 {
+		t.Errorf("the job should have run once, ran %d times", runs)
+	}
+}
+
+func TestScheduler_RetriesFailedJobs(t *testing.T) {
+	c := &clock{t: time.Date(2050, 1, 1, 9, 0, 0, 0, time.UTC)}
+	store := newMemoryJobs(c, models.ScheduledJob{Name: "job", Enabled: true, Hour: 9, NextRunAt: c.t})
+
+	fail := true
+	s := newTestScheduler(store, c)
+	s.Register("job", func(job models.ScheduledJob, now time.Time) (string, error) {
+		if fail {
+			return "queued 1 emails", errors.New("1 emails failed")
+		}
+		return "queued 2 emails", nil
+	})
+
+	s.RunDue()
+	job := store.jobs["job"]
+	if job.LastError != "1 emails failed" || !job.NextRunAt.Equal(c.t.Add(time.Hour)) {
+		t.Fatalf("a failed job should be retried after an hour: %+v", job)
+	}
+
+	fail = false
+	c.t = c.t.Add(time.Hour)
+	if ran := s.RunDue(); ran != 1 {
+		t.Fatalf("the failed job should run again, ran %d", ran)
+	}
+	job = store.jobs["job"]
+	if job.LastError != "" || job.LastResult != "queued 2 emails" {
+		t.Errorf("a successful run should clear the error: %+v", job)
+	}
+	if want := time.Date(2050, 1, 2, 9, 0, 0, 0, time.UTC); !job.NextRunAt.Equal(want) {
+		t.Errorf("the job should run next at %s, got %s", want, job.NextRunAt)
+	}
+}
+
+func TestScheduler_ExpiredLease(t *testing.T) {
+	c := &clock{t: time.Date(2050, 1, 1, 9, 0, 0, 0, time.UTC)}
+	store := newMemoryJobs(c, models.ScheduledJob{Name: "job", Enabled: true, Hour: 9, NextRunAt: c.t})
+
+	// an instance that died while running the job
+	if _, err := store.ClaimScheduledJob("job", 10*time.Minute); err != nil {
+		t.Fatal(err)
+	}
+
+	runs := 0
+	s := newTestScheduler(store, c)
+	s.Register("job", func(job models.ScheduledJob, now time.Time) (string, error) {
+		runs++
+		return "", nil
+	})
+
+	s.RunDue()
+	c.t = c.t.Add(11 * time.Minute)
+	s.RunDue()
+	if runs != 1 {
+		t.Errorf("the job should run once its lease is over, ran %d times", runs)
+	}
+}
@@ -0,0 +1,79 @@
+package sms
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-course/bookings/internal/models"
+)
+
+// HTTPConfig describes the HTTP API of an SMS provider
+type HTTPConfig struct {
+	// URL is where messages are posted
+	URL string
+	// Token is sent as a bearer token, if there is one
+	Token string
+	// From is the number or sender name the messages come from
+	From    string
+	Timeout time.Duration
+}
+
+// HTTP sends messages through the HTTP API of a provider, posting each one as a JSON object with from, to
+// and body. Any 2xx response means the provider took the message
+type HTTP struct {
+	Config HTTPConfig
+	Client *http.Client
+}
+
+// NewHTTP returns a sender for the API c describes
+func NewHTTP(c HTTPConfig) (*HTTP, error) {
+	if c.URL == "" {
+		return nil, errors.New("the sms provider url is missing")
+	}
+	if c.Timeout == 0 {
+		c.Timeout = 10 * time.Second
+	}
+	return &HTTP{Config: c, Client: &http.Client{Timeout: c.Timeout}}, nil
+}
+
+// request is the body posted for a message
+type request struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+	Body string `json:"body"`
+}
+
+// Send posts the message to the provider
+func (h *HTTP) Send(msg models.SMSData) error {
+	body, err := json.Marshal(request{From: h.Config.From, To: msg.To, Body: msg.Body})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, h.Config.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if h.Config.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+h.Config.Token)
+	}
+
+	resp, err := h.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		detail, _ := io.ReadAll(io.LimitReader(resp.Body, 200))
+		return fmt.Errorf("sms provider returned %s: %s", resp.Status, strings.TrimSpace(string(detail)))
+	}
+	return nil
+}
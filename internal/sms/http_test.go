@@ -0,0 +1,60 @@
+package sms
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestHTTP_Send(t *testing.T) {
+	var got request
+	var auth, contentType string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth = r.Header.Get("Authorization")
+		contentType = r.Header.Get("Content-Type")
+		if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer srv.Close()
+
+	sender, err := NewHTTP(HTTPConfig{URL: srv.URL, Token: "secret", From: "FortSmythe"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := sender.Send(reminder); err != nil {
+		t.Fatal(err)
+	}
+
+	if got.From != "FortSmythe" || got.To != reminder.To || got.Body != reminder.Body {
+		t.Errorf("wrong request %+v", got)
+	}
+	if auth != "Bearer secret" || contentType != "application/json" {
+		t.Errorf("wrong headers: Authorization %q, Content-Type %q", auth, contentType)
+	}
+}
+
+func TestHTTP_SendFails(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "" {
+			t.Error("no token should be sent without one")
+		}
+		http.Error(w, "invalid number", http.StatusUnprocessableEntity)
+	}))
+	defer srv.Close()
+
+	sender, _ := NewHTTP(HTTPConfig{URL: srv.URL})
+	err := sender.Send(reminder)
+	if err == nil || !strings.Contains(err.Error(), "422") || !strings.Contains(err.Error(), "invalid number") {
+		t.Errorf("expected the provider's error, got %v", err)
+	}
+
+	srv.Close()
+	if err := sender.Send(reminder); err == nil {
+		t.Error("expected an error when the provider is down")
+	}
+}
@@ -0,0 +1,40 @@
+package sms
+
+import (
+	"sync"
+
+	"github.com/go-course/bookings/internal/models"
+)
+
+// Memory keeps the messages sent in memory, so tests can inspect them
+type Memory struct {
+	mu   sync.Mutex
+	sent []models.SMSData
+}
+
+// NewMemory returns an empty in-memory sender
+func NewMemory() *Memory {
+	return &Memory{}
+}
+
+// Send keeps the message
+func (m *Memory) Send(msg models.SMSData) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.sent = append(m.sent, msg)
+	return nil
+}
+
+// Messages returns the messages sent so far, oldest first
+func (m *Memory) Messages() []models.SMSData {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]models.SMSData(nil), m.sent...)
+}
+
+// Reset forgets the messages sent so far
+func (m *Memory) Reset() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.sent = nil
+}
@@ -0,0 +1,36 @@
+// Package sms sends the text messages of the SMS outbox through a pluggable provider, retrying failures
+// like the mail worker does
+package sms
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/go-course/bookings/internal/models"
+)
+
+// Names of the providers an SMSSender can be created for
+const (
+	ProviderLog    = "log"
+	ProviderHTTP   = "http"
+	ProviderMemory = "memory"
+)
+
+// SMSSender sends a single text message
+type SMSSender interface {
+	Send(msg models.SMSData) error
+}
+
+// NewSender returns the sender for the named provider: the HTTP API c describes, a log of the messages
+// written to logger, or an in-memory capture
+func NewSender(provider string, c HTTPConfig, logger *log.Logger) (SMSSender, error) {
+	switch provider {
+	case ProviderLog:
+		return NewLog(logger), nil
+	case ProviderHTTP:
+		return NewHTTP(c)
+	case ProviderMemory:
+		return NewMemory(), nil
+	}
+	return nil, fmt.Errorf("unknown sms provider %q", provider)
+}
@@ -0,0 +1,61 @@
+package sms
+
+import (
+	"bytes"
+	"log"
+	"strings"
+	"testing"
+
+	"github.com/go-course/bookings/internal/models"
+)
+
+// reminder is a message like the one sent to guests
+var reminder = models.SMSData{
+	To:       "+15555550123",
+	Body:     "Fort Smythe B&B: see you on 2050-01-01!",
+	Template: "reminder",
+}
+
+func TestNewSender(t *testing.T) {
+	var testCases = []struct {
+		name      string
+		provider  string
+		config    HTTPConfig
+		expectErr bool
+	}{
+		{"Log", ProviderLog, HTTPConfig{}, false},
+		{"HTTP", ProviderHTTP, HTTPConfig{URL: "https://sms.example.com/messages"}, false},
+		{"HTTP without url", ProviderHTTP, HTTPConfig{}, true},
+		{"Memory", ProviderMemory, HTTPConfig{}, false},
+		{"Unknown", "pigeon", HTTPConfig{}, true},
+	}
+
+	for _, testCase := range testCases {
+		_, err := NewSender(testCase.provider, testCase.config, log.Default())
+		if (err != nil) != testCase.expectErr {
+			t.Errorf("NewSender for (%s): got error %v", testCase.name, err)
+		}
+	}
+}
+
+func TestLog_Send(t *testing.T) {
+	var buf bytes.Buffer
+	if err := NewLog(log.New(&buf, "", 0)).Send(reminder); err != nil {
+		t.Fatal(err)
+	}
+	if got := buf.String(); !strings.Contains(got, "sms to +15555550123: Fort Smythe B&B: see you on 2050-01-01!") {
+		t.Errorf("wrong log %q", got)
+	}
+}
+
+func TestMemory_Send(t *testing.T) {
+	m := NewMemory()
+	m.Send(reminder)
+	if sent := m.Messages(); len(sent) != 1 || sent[0] != reminder {
+		t.Errorf("wrong messages %+v", sent)
+	}
+	m.Reset()
+	if len(m.Messages()) != 0 {
+		t.Error("Reset should forget the messages")
+	}
+}
@@ -0,0 +1,95 @@
+package sms
+
+import (
+	"log"
+	"time"
+
+	"github.com/go-course/bookings/internal/config"
+	"github.com/go-course/bookings/internal/models"
+	"github.com/go-course/bookings/internal/outbox"
+)
+
+// Store is the part of the repository the worker needs
+type Store interface {
+	ClaimSMS(limit int, lease time.Duration) ([]models.SMSMessage, error)
+	UpdateSMSDelivery(msg models.SMSMessage) error
+}
+
+// Worker sends the text messages due in the SMS outbox one after the other. A message that fails is retried
+// with a growing delay, and marked failed after MaxAttempts
+type Worker struct {
+	Store       Store
+	Sender      SMSSender
+	BatchSize   int
+	MaxAttempts int
+	MinBackoff  time.Duration
+	MaxBackoff  time.Duration
+	// Lease is how long a claimed message is kept from other workers while it is being sent
+	Lease    time.Duration
+	InfoLog  *log.Logger
+	ErrorLog *log.Logger
+	now      func() time.Time
+}
+
+// New creates a worker using the loggers of the app config
+func New(store Store, sender SMSSender, a *config.AppConfig) *Worker {
+	return &Worker{
+		Store:       store,
+		Sender:      sender,
+		BatchSize:   20,
+		MaxAttempts: 5,
+		MinBackoff:  time.Minute,
+		MaxBackoff:  time.Hour,
+		Lease:       5 * time.Minute,
+		InfoLog:     a.InfoLog,
+		ErrorLog:    a.ErrorLog,
+		now:         time.Now,
+	}
+}
+
+// Start sends the messages due now and then every interval, until stop is closed
+func (w *Worker) Start(interval time.Duration, stop <-chan struct{}) {
+	outbox.Start(interval, stop, w.DeliverDue)
+}
+
+// DeliverDue sends the messages due in the outbox and returns how many were sent
+func (w *Worker) DeliverDue() (int, error) {
+	sent, err := outbox.DeliverDue(w.BatchSize, 1, func(limit int) ([]models.SMSMessage, error) {
+		return w.Store.ClaimSMS(limit, w.Lease)
+	}, w.deliver)
+	if err != nil {
+		w.ErrorLog.Println(err)
+	}
+
+	if sent > 0 {
+		w.InfoLog.Printf("sms: sent %d messages", sent)
+	}
+
+	return sent, err
+}
+
+// deliver sends a claimed message and records the outcome, reporting whether it was sent
+func (w *Worker) deliver(msg models.SMSMessage) bool {
+	err := w.Sender.Send(msg.SMSData)
+	switch {
+	case err == nil:
+		msg.Status = models.MailSent
+		msg.SentAt = w.now()
+		msg.LastError = ""
+	case msg.Attempts >= w.MaxAttempts:
+		msg.Status = models.MailFailed
+		msg.LastError = err.Error()
+		w.ErrorLog.Printf("sms %d: giving up after %d attempts: %s", msg.ID, msg.Attempts, err)
+	default:
+		msg.LastError = err.Error()
+		msg.NextAttemptAt = w.now().Add(outbox.Backoff(msg.Attempts, w.MinBackoff, w.MaxBackoff))
+		w.ErrorLog.Printf("sms %d: attempt %d failed, retrying at %s: %s",
+			msg.ID, msg.Attempts, msg.NextAttemptAt.Format(time.RFC3339), err)
+	}
+
+	if err := w.Store.UpdateSMSDelivery(msg); err != nil {
+		w.ErrorLog.Println(err)
+	}
+
+	return msg.Status == models.MailSent
+}
@@ -0,0 +1,143 @@
+package sms
+
+import (
+	"errors"
+	"io"
+	"log"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-course/bookings/internal/models"
+)
+
+// clock is a settable time source
+type clock struct {
+	t time.Time
+}
+
+func (c *clock) now() time.Time {
+	return c.t
+}
+
+// memoryOutbox keeps the SMS outbox in memory, claiming messages like the database does
+type memoryOutbox struct {
+	mu       sync.Mutex
+	clock    *clock
+	messages map[int]models.SMSMessage
+}
+
+func newMemoryOutbox(c *clock, n int) *memoryOutbox {
+	o := &memoryOutbox{clock: c, messages: make(map[int]models.SMSMessage)}
+	for id := 1; id <= n; id++ {
+		o.messages[id] = models.SMSMessage{
+			ID:            id,
+			SMSData:       reminder,
+			Status:        models.MailPending,
+			NextAttemptAt: c.t,
+		}
+	}
+	return o
+}
+
+func (o *memoryOutbox) ClaimSMS(limit int, lease time.Duration) ([]models.SMSMessage, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	var claimed []models.SMSMessage
+	for id := 1; id <= len(o.messages) && len(claimed) < limit; id++ {
+		msg := o.messages[id]
+		if msg.Status != models.MailPending || msg.NextAttemptAt.After(o.clock.t) {
+			continue
+		}
+		msg.Attempts++
+		o.messages[id] = msg
+		claimed = append(claimed, msg)
+	}
+	return claimed, nil
+}
+
+func (o *memoryOutbox) UpdateSMSDelivery(msg models.SMSMessage) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	o.messages[msg.ID] = msg
+	return nil
+}
+
+// fakeSender counts the messages sent, failing with err when it is set
+type fakeSender struct {
+	err  error
+	sent int
+}
+
+func (s *fakeSender) Send(msg models.SMSData) error {
+	if s.err != nil {
+		return s.err
+	}
+	s.sent++
+	return nil
+}
+
+func newTestWorker(store Store, sender SMSSender, c *clock) *Worker {
+	return &Worker{
+		Store:       store,
+		Sender:      sender,
+		BatchSize:   2,
+		MaxAttempts: 3,
+		MinBackoff:  time.Minute,
+		MaxBackoff:  time.Hour,
+		Lease:       time.Minute,
+		InfoLog:     log.New(io.Discard, "", 0),
+		ErrorLog:    log.New(io.Discard, "", 0),
+		now:         c.now,
+	}
+}
+
+func TestWorker_DeliverDue(t *testing.T) {
+	c := &clock{t: time.Date(2050, 1, 1, 12, 0, 0, 0, time.UTC)}
+	outbox := newMemoryOutbox(c, 3)
+	sender := &fakeSender{}
+	w := newTestWorker(outbox, sender, c)
+
+	n, err := w.DeliverDue()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 3 || sender.sent != 3 {
+		t.Errorf("expected 3 messages sent, got %d (sender saw %d)", n, sender.sent)
+	}
+	for id, msg := range outbox.messages {
+		if msg.Status != models.MailSent || !msg.SentAt.Equal(c.t) {
+			t.Errorf("message %d not recorded as sent: %+v", id, msg)
+		}
+	}
+
+	if n, _ := w.DeliverDue(); n != 0 || sender.sent != 3 {
+		t.Errorf("expected nothing sent twice, got %d", n)
+	}
+}
+
+func TestWorker_DeliverBacksOff(t *testing.T) {
+	c := &clock{t: time.Date(2050, 1, 1, 12, 0, 0, 0, time.UTC)}
+	outbox := newMemoryOutbox(c, 1)
+	sender := &fakeSender{err: errors.New("sms provider returned 503 Service Unavailable")}
+	w := newTestWorker(outbox, sender, c)
+
+	for i, wait := range []time.Duration{time.Minute, 2 * time.Minute} {
+		w.DeliverDue()
+		msg := outbox.messages[1]
+		if msg.Status != models.MailPending || msg.Attempts != i+1 || msg.LastError == "" {
+			t.Errorf("attempt %d: wrong message %+v", i+1, msg)
+		}
+		if got := msg.NextAttemptAt.Sub(c.t); got != wait {
+			t.Errorf("attempt %d: got backoff %s, want %s", i+1, got, wait)
+		}
+		c.t = c.t.Add(wait)
+	}
+
+	w.DeliverDue()
+	if msg := outbox.messages[1]; msg.Status != models.MailFailed || msg.Attempts != 3 {
+		t.Errorf("expected message to fail after 3 attempts, got %+v", msg)
+	}
+}
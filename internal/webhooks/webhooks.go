@@ -0,0 +1,91 @@
+// Package webhooks posts reservation and calendar events to the endpoints subscribed to them, as JSON
+// signed with the secret of the endpoint, retrying failures like the mail worker does
+package webhooks
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"strconv"
+	"time"
+
+	"github.com/go-course/bookings/internal/models"
+)
+
+// Headers posted with every delivery
+const (
+	HeaderEvent     = "X-Webhook-Event"
+	HeaderDelivery  = "X-Webhook-Delivery"
+	HeaderTimestamp = "X-Webhook-Timestamp"
+	HeaderSignature = "X-Webhook-Signature"
+)
+
+// Sign returns the signature of a delivery: "sha256=" and the hex encoded HMAC-SHA256, keyed with secret, of
+// the unix timestamp, a dot and the body. Receivers compute the same to check that the body came from us, and
+// reject old timestamps so that a delivery can't be replayed
+func Sign(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Event is the JSON body posted for an event
+type Event struct {
+	Event      string      `json:"event"`
+	OccurredAt time.Time   `json:"occurred_at"`
+	Data       interface{} `json:"data"`
+}
+
+// Reservation is the data of the reservation events
+type Reservation struct {
+	ID        int    `json:"id"`
+	Status    string `json:"status"`
+	RoomID    int    `json:"room_id"`
+	RoomName  string `json:"room_name"`
+	StartDate string `json:"start_date"`
+	EndDate   string `json:"end_date"`
+	FirstName string `json:"first_name"`
+	LastName  string `json:"last_name"`
+	Email     string `json:"email"`
+	Phone     string `json:"phone"`
+	Deleted   bool   `json:"deleted"`
+}
+
+// Block is the data of block.created, a day an admin closed a room on
+type Block struct {
+	RoomID int    `json:"room_id"`
+	Date   string `json:"date"`
+}
+
+// ReservationPayload returns the body posted for a reservation event that occurred at t
+func ReservationPayload(event string, res models.Reservation, t time.Time) ([]byte, error) {
+	return json.Marshal(Event{
+		Event:      event,
+		OccurredAt: t.UTC(),
+		Data: Reservation{
+			ID:        res.ID,
+			Status:    res.Status,
+			RoomID:    res.RoomID,
+			RoomName:  res.Room.RoomName,
+			StartDate: res.StartDate.Format("2006-01-02"),
+			EndDate:   res.EndDate.Format("2006-01-02"),
+			FirstName: res.FirstName,
+			LastName:  res.LastName,
+			Email:     res.Email,
+			Phone:     res.Phone,
+			Deleted:   !res.DeletedAt.IsZero(),
+		},
+	})
+}
+
+// BlockPayload returns the body posted for block.created when date was blocked in a room at t
+func BlockPayload(roomID int, date, t time.Time) ([]byte, error) {
+	return json.Marshal(Event{
+		Event:      models.WebhookBlockCreated,
+		OccurredAt: t.UTC(),
+		Data:       Block{RoomID: roomID, Date: date.Format("2006-01-02")},
+	})
+}
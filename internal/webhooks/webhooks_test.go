@@ -0,0 +1,88 @@
+package webhooks
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/go-course/bookings/internal/models"
+)
+
+func TestSign(t *testing.T) {
+	body := []byte(`{"event":"block.created"}`)
+
+	mac := hmac.New(sha256.New, []byte("secret"))
+	mac.Write([]byte("2524608000." + string(body)))
+	want := "sha256=" + hex.EncodeToString(mac.Sum(nil))
+
+	if got := Sign("secret", 2524608000, body); got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+	if Sign("other", 2524608000, body) == want {
+		t.Error("the signature should depend on the secret")
+	}
+	if Sign("secret", 2524608001, body) == want {
+		t.Error("the signature should depend on the timestamp")
+	}
+}
+
+func TestReservationPayload(t *testing.T) {
+	res := models.Reservation{
+		ID:        7,
+		FirstName: "John",
+		LastName:  "Smith",
+		Email:     "john@smith.com",
+		StartDate: time.Date(2050, 1, 1, 0, 0, 0, 0, time.UTC),
+		EndDate:   time.Date(2050, 1, 3, 0, 0, 0, 0, time.UTC),
+		RoomID:    1,
+		Status:    models.ReservationCancelled,
+		Room:      models.Room{ID: 1, RoomName: "General's Quarters"},
+	}
+	at := time.Date(2049, 12, 1, 9, 30, 0, 0, time.FixedZone("EST", -5*3600))
+
+	body, err := ReservationPayload(models.WebhookReservationCancelled, res, at)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got struct {
+		Event      string      `json:"event"`
+		OccurredAt string      `json:"occurred_at"`
+		Data       Reservation `json:"data"`
+	}
+	if err := json.Unmarshal(body, &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Event != models.WebhookReservationCancelled || got.OccurredAt != "2049-12-01T14:30:00Z" {
+		t.Errorf("wrong event %s at %s", got.Event, got.OccurredAt)
+	}
+	want := Reservation{
+		ID:        7,
+		Status:    models.ReservationCancelled,
+		RoomID:    1,
+		RoomName:  "General's Quarters",
+		StartDate: "2050-01-01",
+		EndDate:   "2050-01-03",
+		FirstName: "John",
+		LastName:  "Smith",
+		Email:     "john@smith.com",
+	}
+	if got.Data != want {
+		t.Errorf("got %+v, want %+v", got.Data, want)
+	}
+}
+
+func TestBlockPayload(t *testing.T) {
+	body, err := BlockPayload(2, time.Date(2050, 2, 14, 0, 0, 0, 0, time.UTC), time.Date(2050, 1, 1, 0, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := `{"event":"block.created","occurred_at":"2050-01-01T00:00:00Z","data":{"room_id":2,"date":"2050-02-14"}}`
+	if string(body) != want {
+		t.Errorf("got %s, want %s", body, want)
+	}
+}
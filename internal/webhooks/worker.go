@@ -0,0 +1,138 @@
+package webhooks
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-course/bookings/internal/config"
+	"github.com/go-course/bookings/internal/models"
+	"github.com/go-course/bookings/internal/outbox"
+)
+
+// Store is the part of the repository the worker needs
+type Store interface {
+	ClaimWebhooks(limit int, lease time.Duration) ([]models.WebhookDelivery, error)
+	UpdateWebhookDelivery(d models.WebhookDelivery) error
+}
+
+// Worker posts the deliveries due in the webhook log one after the other. Any 2xx response means the
+// endpoint took the event; anything else is retried with a growing delay, and marked failed after MaxAttempts
+type Worker struct {
+	Store       Store
+	Client      *http.Client
+	BatchSize   int
+	MaxAttempts int
+	MinBackoff  time.Duration
+	MaxBackoff  time.Duration
+	// Lease is how long a claimed delivery is kept from other workers while it is being posted
+	Lease    time.Duration
+	InfoLog  *log.Logger
+	ErrorLog *log.Logger
+	now      func() time.Time
+}
+
+// New creates a worker using the loggers of the app config
+func New(store Store, a *config.AppConfig) *Worker {
+	return &Worker{
+		Store: store,
+		Client: &http.Client{
+			Timeout: 10 * time.Second,
+			// a redirect is not an answer from the endpoint, it has to be fixed in its url
+			CheckRedirect: func(req *http.Request, via []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+		BatchSize:   20,
+		MaxAttempts: 8,
+		MinBackoff:  time.Minute,
+		MaxBackoff:  6 * time.Hour,
+		Lease:       5 * time.Minute,
+		InfoLog:     a.InfoLog,
+		ErrorLog:    a.ErrorLog,
+		now:         time.Now,
+	}
+}
+
+// Start posts the deliveries due now and then every interval, until stop is closed
+func (w *Worker) Start(interval time.Duration, stop <-chan struct{}) {
+	outbox.Start(interval, stop, w.DeliverDue)
+}
+
+// DeliverDue posts the deliveries due and returns how many the endpoints took
+func (w *Worker) DeliverDue() (int, error) {
+	delivered, err := outbox.DeliverDue(w.BatchSize, 1, func(limit int) ([]models.WebhookDelivery, error) {
+		return w.Store.ClaimWebhooks(limit, w.Lease)
+	}, w.deliver)
+	if err != nil {
+		w.ErrorLog.Println(err)
+	}
+
+	if delivered > 0 {
+		w.InfoLog.Printf("webhooks: delivered %d events", delivered)
+	}
+
+	return delivered, err
+}
+
+// deliver posts a claimed delivery and records the outcome, reporting whether the endpoint took it
+func (w *Worker) deliver(d models.WebhookDelivery) bool {
+	status, err := w.post(d)
+	d.ResponseStatus = status
+	switch {
+	case err == nil:
+		d.Status = models.WebhookDelivered
+		d.DeliveredAt = w.now()
+		d.LastError = ""
+	case d.Attempts >= w.MaxAttempts:
+		d.Status = models.WebhookFailed
+		d.LastError = err.Error()
+		w.ErrorLog.Printf("webhook %d: giving up after %d attempts: %s", d.ID, d.Attempts, err)
+	default:
+		d.LastError = err.Error()
+		d.NextAttemptAt = w.now().Add(outbox.Backoff(d.Attempts, w.MinBackoff, w.MaxBackoff))
+		w.ErrorLog.Printf("webhook %d: attempt %d failed, retrying at %s: %s",
+			d.ID, d.Attempts, d.NextAttemptAt.Format(time.RFC3339), err)
+	}
+
+	if err := w.Store.UpdateWebhookDelivery(d); err != nil {
+		w.ErrorLog.Println(err)
+	}
+
+	return d.Status == models.WebhookDelivered
+}
+
+// post sends the payload of d to its endpoint, signed with the endpoint's secret, and returns the status of
+// the response, 0 if there was none
+func (w *Worker) post(d models.WebhookDelivery) (int, error) {
+	body := []byte(d.Payload)
+	timestamp := w.now().Unix()
+
+	req, err := http.NewRequest(http.MethodPost, d.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "bookings-webhooks")
+	req.Header.Set(HeaderEvent, d.Event)
+	req.Header.Set(HeaderDelivery, strconv.Itoa(d.ID))
+	req.Header.Set(HeaderTimestamp, strconv.FormatInt(timestamp, 10))
+	req.Header.Set(HeaderSignature, Sign(d.Secret, timestamp, body))
+
+	resp, err := w.Client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		detail, _ := io.ReadAll(io.LimitReader(resp.Body, 200))
+		return resp.StatusCode, fmt.Errorf("endpoint returned %s: %s", resp.Status, strings.TrimSpace(string(detail)))
+	}
+	return resp.StatusCode, nil
+}
@@ -0,0 +1,209 @@
+package webhooks
+
+import (
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-course/bookings/internal/config"
+	"github.com/go-course/bookings/internal/models"
+)
+
+// clock is a settable time source
+type clock struct {
+	t time.Time
+}
+
+func (c *clock) now() time.Time {
+	return c.t
+}
+
+// memoryLog keeps the webhook deliveries in memory, claiming them like the database does
+type memoryLog struct {
+	mu         sync.Mutex
+	clock      *clock
+	deliveries map[int]models.WebhookDelivery
+}
+
+func newMemoryLog(c *clock, url string, n int) *memoryLog {
+	l := &memoryLog{clock: c, deliveries: make(map[int]models.WebhookDelivery)}
+	for id := 1; id <= n; id++ {
+		l.deliveries[id] = models.WebhookDelivery{
+			ID:            id,
+			EndpointID:    1,
+			URL:           url,
+			Secret:        "secret",
+			Event:         models.WebhookBlockCreated,
+			Payload:       `{"event":"block.created","data":{"room_id":1,"date":"2050-01-01"}}`,
+			Status:        models.WebhookPending,
+			NextAttemptAt: c.t,
+		}
+	}
+	return l
+}
+
+func (l *memoryLog) ClaimWebhooks(limit int, lease time.Duration) ([]models.WebhookDelivery, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	var claimed []models.WebhookDelivery
+	for id := 1; id <= len(l.deliveries) && len(claimed) < limit; id++ {
+		d := l.deliveries[id]
+		if d.Status != models.WebhookPending || d.NextAttemptAt.After(l.clock.t) {
+			continue
+		}
+		d.Attempts++
+		l.deliveries[id] = d
+		claimed = append(claimed, d)
+	}
+	return claimed, nil
+}
+
+func (l *memoryLog) UpdateWebhookDelivery(d models.WebhookDelivery) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.deliveries[d.ID] = d
+	return nil
+}
+
+// endpoint is a webhook receiver that checks the signature of what it gets, answering with status
+type endpoint struct {
+	mu       sync.Mutex
+	status   int
+	received []*http.Request
+	bodies   []string
+	invalid  int
+}
+
+func (e *endpoint) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	timestamp, _ := strconv.ParseInt(r.Header.Get(HeaderTimestamp), 10, 64)
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.received = append(e.received, r)
+	e.bodies = append(e.bodies, string(body))
+	if r.Header.Get(HeaderSignature) != Sign("secret", timestamp, body) {
+		e.invalid++
+	}
+	w.WriteHeader(e.status)
+}
+
+func newTestWorker(store Store, c *clock) *Worker {
+	w := New(store, &config.AppConfig{})
+	w.BatchSize = 2
+	w.MaxAttempts = 3
+	w.InfoLog = log.New(io.Discard, "", 0)
+	w.ErrorLog = log.New(io.Discard, "", 0)
+	w.now = c.now
+	return w
+}
+
+func TestWorker_DeliverDue(t *testing.T) {
+	c := &clock{t: time.Date(2050, 1, 1, 12, 0, 0, 0, time.UTC)}
+	e := &endpoint{status: http.StatusNoContent}
+	srv := httptest.NewServer(e)
+	defer srv.Close()
+	deliveries := newMemoryLog(c, srv.URL, 3)
+	w := newTestWorker(deliveries, c)
+
+	n, err := w.DeliverDue()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 3 || len(e.received) != 3 {
+		t.Fatalf("expected 3 deliveries, got %d (endpoint saw %d)", n, len(e.received))
+	}
+	if e.invalid != 0 {
+		t.Errorf("%d deliveries were not signed with the secret", e.invalid)
+	}
+
+	r := e.received[0]
+	if r.Method != http.MethodPost || r.Header.Get("Content-Type") != "application/json" {
+		t.Errorf("wrong request %s with Content-Type %q", r.Method, r.Header.Get("Content-Type"))
+	}
+	if r.Header.Get(HeaderEvent) != models.WebhookBlockCreated || r.Header.Get(HeaderDelivery) != "1" {
+		t.Errorf("wrong headers %v", r.Header)
+	}
+	if r.Header.Get(HeaderTimestamp) != strconv.FormatInt(c.t.Unix(), 10) {
+		t.Errorf("wrong timestamp %s", r.Header.Get(HeaderTimestamp))
+	}
+	if e.bodies[0] != deliveries.deliveries[1].Payload {
+		t.Errorf("wrong body %s", e.bodies[0])
+	}
+
+	for id, d := range deliveries.deliveries {
+		if d.Status != models.WebhookDelivered || d.ResponseStatus != http.StatusNoContent || !d.DeliveredAt.Equal(c.t) {
+			t.Errorf("delivery %d not recorded as delivered: %+v", id, d)
+		}
+	}
+
+	if n, _ := w.DeliverDue(); n != 0 || len(e.received) != 3 {
+		t.Errorf("expected nothing delivered twice, got %d", n)
+	}
+}
+
+func TestWorker_DeliverBacksOff(t *testing.T) {
+	c := &clock{t: time.Date(2050, 1, 1, 12, 0, 0, 0, time.UTC)}
+	e := &endpoint{status: http.StatusServiceUnavailable}
+	srv := httptest.NewServer(e)
+	defer srv.Close()
+	deliveries := newMemoryLog(c, srv.URL, 1)
+	w := newTestWorker(deliveries, c)
+
+	for i, wait := range []time.Duration{time.Minute, 2 * time.Minute} {
+		w.DeliverDue()
+		d := deliveries.deliveries[1]
+		if d.Status != models.WebhookPending || d.Attempts != i+1 || d.ResponseStatus != http.StatusServiceUnavailable || d.LastError == "" {
+			t.Errorf("attempt %d: wrong delivery %+v", i+1, d)
+		}
+		if got := d.NextAttemptAt.Sub(c.t); got != wait {
+			t.Errorf("attempt %d: got backoff %s, want %s", i+1, got, wait)
+		}
+		c.t = c.t.Add(wait)
+	}
+
+	w.DeliverDue()
+	if d := deliveries.deliveries[1]; d.Status != models.WebhookFailed || d.Attempts != 3 {
+		t.Errorf("expected delivery to fail after 3 attempts, got %+v", d)
+	}
+}
+
+func TestWorker_DeliverUnreachable(t *testing.T) {
+	c := &clock{t: time.Date(2050, 1, 1, 12, 0, 0, 0, time.UTC)}
+	// nothing listens here
+	deliveries := newMemoryLog(c, "http://127.0.0.1:1/hooks", 1)
+	w := newTestWorker(deliveries, c)
+
+	if n, _ := w.DeliverDue(); n != 0 {
+		t.Errorf("expected nothing delivered, got %d", n)
+	}
+	if d := deliveries.deliveries[1]; d.Status != models.WebhookPending || d.ResponseStatus != 0 || d.LastError == "" {
+		t.Errorf("expected the failure to be recorded, got %+v", d)
+	}
+}
+
+func TestWorker_DeliverDoesNotFollowRedirects(t *testing.T) {
+	c := &clock{t: time.Date(2050, 1, 1, 12, 0, 0, 0, time.UTC)}
+	e := &endpoint{status: http.StatusNoContent}
+	target := httptest.NewServer(e)
+	defer target.Close()
+	srv := httptest.NewServer(http.RedirectHandler(target.URL, http.StatusFound))
+	defer srv.Close()
+	deliveries := newMemoryLog(c, srv.URL, 1)
+	w := newTestWorker(deliveries, c)
+
+	w.DeliverDue()
+	if d := deliveries.deliveries[1]; d.Status != models.WebhookPending || d.ResponseStatus != http.StatusFound {
+		t.Errorf("a redirect should count as a failure, got %+v", d)
+	}
+	if len(e.received) != 0 {
+		t.Error("the redirect should not be followed")
+	}
+}
@@ -0,0 +1,3 @@
+INSERT INTO public.restrictions (id,restriction_name,created_at,updated_at) VALUES
+	 (3,'External Booking','2026-10-19 00:00:00.000','2026-10-19 00:00:00.000');
+SELECT setval('public.restrictions_id_seq', (SELECT max(id) FROM public.restrictions));
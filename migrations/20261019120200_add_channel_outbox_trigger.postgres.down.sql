@@ -0,0 +1,2 @@
+DROP TRIGGER room_restrictions_channel_outbox ON public.room_restrictions;
+DROP FUNCTION public.record_room_restriction_change();
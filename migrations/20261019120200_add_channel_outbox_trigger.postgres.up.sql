@@ -0,0 +1,25 @@
+CREATE FUNCTION public.record_room_restriction_change() RETURNS trigger
+    LANGUAGE plpgsql
+    AS $$
+BEGIN
+    IF (TG_OP = 'DELETE' OR TG_OP = 'UPDATE') THEN
+        INSERT INTO public.channel_outbox (action, room_id, start_date, end_date, room_restriction_id, created_at, updated_at)
+        VALUES (lower(TG_OP), OLD.room_id, OLD.start_date, OLD.end_date, OLD.id, now(), now());
+    END IF;
+
+    IF (TG_OP = 'DELETE') THEN
+        RETURN OLD;
+    END IF;
+
+    IF (TG_OP = 'INSERT' OR OLD.room_id <> NEW.room_id OR OLD.start_date <> NEW.start_date OR OLD.end_date <> NEW.end_date) THEN
+        INSERT INTO public.channel_outbox (action, room_id, start_date, end_date, room_restriction_id, created_at, updated_at)
+        VALUES (lower(TG_OP), NEW.room_id, NEW.start_date, NEW.end_date, NEW.id, now(), now());
+    END IF;
+
+    RETURN NEW;
+END;
+$$;
+
+CREATE TRIGGER room_restrictions_channel_outbox
+    AFTER INSERT OR UPDATE OR DELETE ON public.room_restrictions
+    FOR EACH ROW EXECUTE FUNCTION public.record_room_restriction_change();
@@ -0,0 +1,3 @@
+ALTER TABLE public.reservations ADD COLUMN processed integer DEFAULT 0 NOT NULL;
+
+UPDATE public.reservations SET processed = 1 WHERE status <> 'pending';
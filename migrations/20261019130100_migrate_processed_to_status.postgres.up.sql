@@ -0,0 +1,3 @@
+UPDATE public.reservations SET status = 'confirmed', confirmed_at = updated_at WHERE processed = 1;
+
+ALTER TABLE public.reservations DROP COLUMN processed;
@@ -0,0 +1,12 @@
+INSERT INTO public.guests (first_name, last_name, email, phone, email_normalized, phone_normalized, created_at, updated_at)
+SELECT DISTINCT ON (lower(trim(email)))
+    first_name, last_name, trim(email), phone, lower(trim(email)),
+    CASE WHEN trim(phone) LIKE '+%' THEN '+' ELSE '' END || regexp_replace(phone, '[^0-9]', '', 'g'),
+    now(), now()
+FROM public.reservations
+WHERE trim(email) <> ''
+ORDER BY lower(trim(email)), created_at DESC;
+
+UPDATE public.reservations r SET guest_id = g.id
+FROM public.guests g
+WHERE trim(r.email) <> '' AND g.email_normalized = lower(trim(r.email));
@@ -0,0 +1,3 @@
+DELETE FROM public.notification_recipients;
+
+DELETE FROM public.property_settings;
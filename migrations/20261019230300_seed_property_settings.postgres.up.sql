@@ -0,0 +1,5 @@
+INSERT INTO public.property_settings (sender_name, sender_email, reply_to, created_at, updated_at)
+VALUES ('Fort Smythe Bed and Breakfast', 'rajiv@mkcl.org', '', now(), now());
+
+INSERT INTO public.notification_recipients (event, email, created_at, updated_at)
+VALUES ('new_booking', 'rajiv@mkcl.org', now(), now());
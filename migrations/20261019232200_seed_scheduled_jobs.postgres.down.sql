@@ -0,0 +1 @@
+DELETE FROM public.scheduled_jobs;
@@ -0,0 +1,4 @@
+INSERT INTO public.scheduled_jobs (name, enabled, offset_days, hour, next_run_at, created_at, updated_at)
+VALUES
+    ('guest_reminder', true, 3, 9, now(), now(), now()),
+    ('guest_follow_up', true, 1, 10, now(), now(), now());
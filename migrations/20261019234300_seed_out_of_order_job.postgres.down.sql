@@ -0,0 +1 @@
+DELETE FROM public.scheduled_jobs WHERE name = 'out_of_order_blocks';
@@ -0,0 +1,2 @@
+INSERT INTO public.scheduled_jobs (name, enabled, offset_days, hour, next_run_at, created_at, updated_at)
+VALUES ('out_of_order_blocks', true, 0, 0, now(), now(), now());
@@ -0,0 +1 @@
+-- the contact details removed from the audit log cannot be restored
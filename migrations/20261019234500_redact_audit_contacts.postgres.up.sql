@@ -0,0 +1,9 @@
+UPDATE public.audit_events SET before = before || jsonb_build_object(
+    'Email', CASE WHEN before->>'Email' <> '' THEN '(set)' ELSE '' END,
+    'Phone', CASE WHEN before->>'Phone' <> '' THEN '(set)' ELSE '' END
+) WHERE entity IN ('reservation', 'guest') AND before ? 'Email';
+
+UPDATE public.audit_events SET after = after || jsonb_build_object(
+    'Email', CASE WHEN after->>'Email' <> '' THEN '(set)' ELSE '' END,
+    'Phone', CASE WHEN after->>'Phone' <> '' THEN '(set)' ELSE '' END
+) WHERE entity IN ('reservation', 'guest') AND after ? 'Email';
@@ -0,0 +1 @@
+UPDATE public.webhook_deliveries SET status = 'sent' WHERE status = 'delivered';
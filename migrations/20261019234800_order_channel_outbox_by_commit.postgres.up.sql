@@ -0,0 +1,45 @@
+ALTER TABLE public.channel_outbox ADD COLUMN xid xid8 DEFAULT pg_current_xact_id() NOT NULL;
+ALTER TABLE public.channel_outbox ADD COLUMN "position" integer;
+
+CREATE SEQUENCE public.channel_outbox_position_seq
+    AS integer
+    START WITH 1
+    INCREMENT BY 1
+    NO MINVALUE
+    NO MAXVALUE
+    CACHE 1;
+ALTER SEQUENCE public.channel_outbox_position_seq OWNED BY public.channel_outbox."position";
+
+UPDATE public.channel_outbox o SET "position" = n.position
+FROM (SELECT id, row_number() OVER (ORDER BY id) AS position FROM public.channel_outbox) n
+WHERE o.id = n.id;
+SELECT setval('public.channel_outbox_position_seq', coalesce((SELECT max("position") FROM public.channel_outbox), 0) + 1, false);
+
+CREATE UNIQUE INDEX channel_outbox_position_idx ON public.channel_outbox USING btree ("position");
+CREATE INDEX channel_outbox_unpositioned_idx ON public.channel_outbox USING btree (xid, id) WHERE ("position" IS NULL);
+
+ALTER TABLE public.channel_cursors RENAME COLUMN last_event_id TO last_position;
+UPDATE public.channel_cursors c
+SET last_position = coalesce((SELECT max(o."position") FROM public.channel_outbox o WHERE o.id <= c.last_position), 0);
+
+CREATE OR REPLACE FUNCTION public.record_room_restriction_change() RETURNS trigger
+    LANGUAGE plpgsql
+    AS $$
+BEGIN
+    IF (TG_OP = 'DELETE' OR TG_OP = 'UPDATE') THEN
+        INSERT INTO public.channel_outbox (action, room_id, start_date, end_date, room_restriction_id, created_at, updated_at)
+        VALUES (lower(TG_OP), OLD.room_id, OLD.start_date, OLD.end_date, OLD.id, clock_timestamp(), clock_timestamp());
+    END IF;
+
+    IF (TG_OP = 'DELETE') THEN
+        RETURN OLD;
+    END IF;
+
+    IF (TG_OP = 'INSERT' OR OLD.room_id <> NEW.room_id OR OLD.start_date <> NEW.start_date OR OLD.end_date <> NEW.end_date) THEN
+        INSERT INTO public.channel_outbox (action, room_id, start_date, end_date, room_restriction_id, created_at, updated_at)
+        VALUES (lower(TG_OP), NEW.room_id, NEW.start_date, NEW.end_date, NEW.id, clock_timestamp(), clock_timestamp());
+    END IF;
+
+    RETURN NEW;
+END;
+$$;
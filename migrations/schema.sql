@@ -16,36 +16,63 @@ SET xmloption = content;
 SET client_min_messages = warning;
 SET row_security = off;
 
+--
+-- Name: record_room_restriction_change(); Type: FUNCTION; Schema: public; Owner: postgres
+--
+
+CREATE FUNCTION public.record_room_restriction_change() RETURNS trigger
+    LANGUAGE plpgsql
+    AS $$
+BEGIN
+    IF (TG_OP = 'DELETE' OR TG_OP = 'UPDATE') THEN
+        INSERT INTO public.channel_outbox (action, room_id, start_date, end_date, room_restriction_id, created_at, updated_at)
+        VALUES (lower(TG_OP), OLD.room_id, OLD.start_date, OLD.end_date, OLD.id, clock_timestamp(), clock_timestamp());
+    END IF;
+
+    IF (TG_OP = 'DELETE') THEN
+        RETURN OLD;
+    END IF;
+
+    IF (TG_OP = 'INSERT' OR OLD.room_id <> NEW.room_id OR OLD.start_date <> NEW.start_date OR OLD.end_date <> NEW.end_date) THEN
+        INSERT INTO public.channel_outbox (action, room_id, start_date, end_date, room_restriction_id, created_at, updated_at)
+        VALUES (lower(TG_OP), NEW.room_id, NEW.start_date, NEW.end_date, NEW.id, clock_timestamp(), clock_timestamp());
+    END IF;
+
+    RETURN NEW;
+END;
+$$;
+
+
+ALTER FUNCTION public.record_room_restriction_change() OWNER TO postgres;
+
 SET default_tablespace = '';
 
 SET default_table_access_method = heap;
 
 --
--- Name: reservations; Type: TABLE; Schema: public; Owner: postgres
+-- Name: audit_events; Type: TABLE; Schema: public; Owner: postgres
 --
 
-CREATE TABLE public.reservations (
+CREATE TABLE public.audit_events (
     id integer NOT NULL,
-    first_name character varying(255) DEFAULT ''::character varying NOT NULL,
-    last_name character varying(255) DEFAULT ''::character varying NOT NULL,
-    email character varying(255) NOT NULL,
-    phone character varying(255) DEFAULT ''::character varying NOT NULL,
-    start_date date NOT NULL,
-    end_date date NOT NULL,
-    room_id integer NOT NULL,
+    user_id integer,
+    action character varying(255) NOT NULL,
+    entity character varying(255) NOT NULL,
+    entity_id character varying(255) NOT NULL,
+    before jsonb,
+    after jsonb,
     created_at timestamp without time zone NOT NULL,
-    updated_at timestamp without time zone NOT NULL,
-    processed integer DEFAULT 0 NOT NULL
+    updated_at timestamp without time zone NOT NULL
 );
 
 
-ALTER TABLE public.reservations OWNER TO postgres;
+ALTER TABLE public.audit_events OWNER TO postgres;
 
 --
--- Name: reservations_id_seq; Type: SEQUENCE; Schema: public; Owner: postgres
+-- Name: audit_events_id_seq; Type: SEQUENCE; Schema: public; Owner: postgres
 --
 
-CREATE SEQUENCE public.reservations_id_seq
+CREATE SEQUENCE public.audit_events_id_seq
     AS integer
     START WITH 1
     INCREMENT BY 1
@@ -54,34 +81,39 @@ CREATE SEQUENCE public.reservations_id_seq
     CACHE 1;
 
 
-ALTER TABLE public.reservations_id_seq OWNER TO postgres;
+ALTER TABLE public.audit_events_id_seq OWNER TO postgres;
 
 --
--- Name: reservations_id_seq; Type: SEQUENCE OWNED BY; Schema: public; Owner: postgres
+-- Name: audit_events_id_seq; Type: SEQUENCE OWNED BY; Schema: public; Owner: postgres
 --
 
-ALTER SEQUENCE public.reservations_id_seq OWNED BY public.reservations.id;
+ALTER SEQUENCE public.audit_events_id_seq OWNED BY public.audit_events.id;
 
 
 --
--- Name: restrictions; Type: TABLE; Schema: public; Owner: postgres
+-- Name: channel_cursors; Type: TABLE; Schema: public; Owner: postgres
 --
 
-CREATE TABLE public.restrictions (
+CREATE TABLE public.channel_cursors (
     id integer NOT NULL,
-    restriction_name character varying(255) DEFAULT ''::character varying NOT NULL,
+    channel character varying(255) NOT NULL,
+    last_position integer DEFAULT 0 NOT NULL,
+    attempts integer DEFAULT 0 NOT NULL,
+    next_attempt_at timestamp without time zone,
+    last_error text DEFAULT ''::text NOT NULL,
+    last_synced_at timestamp without time zone,
     created_at timestamp without time zone NOT NULL,
     updated_at timestamp without time zone NOT NULL
 );
 
 
-ALTER TABLE public.restrictions OWNER TO postgres;
+ALTER TABLE public.channel_cursors OWNER TO postgres;
 
 --
--- Name: restrictions_id_seq; Type: SEQUENCE; Schema: public; Owner: postgres
+-- Name: channel_cursors_id_seq; Type: SEQUENCE; Schema: public; Owner: postgres
 --
 
-CREATE SEQUENCE public.restrictions_id_seq
+CREATE SEQUENCE public.channel_cursors_id_seq
     AS integer
     START WITH 1
     INCREMENT BY 1
@@ -90,38 +122,40 @@ CREATE SEQUENCE public.restrictions_id_seq
     CACHE 1;
 
 
-ALTER TABLE public.restrictions_id_seq OWNER TO postgres;
+ALTER TABLE public.channel_cursors_id_seq OWNER TO postgres;
 
 --
--- Name: restrictions_id_seq; Type: SEQUENCE OWNED BY; Schema: public; Owner: postgres
+-- Name: channel_cursors_id_seq; Type: SEQUENCE OWNED BY; Schema: public; Owner: postgres
 --
 
-ALTER SEQUENCE public.restrictions_id_seq OWNED BY public.restrictions.id;
+ALTER SEQUENCE public.channel_cursors_id_seq OWNED BY public.channel_cursors.id;
 
 
 --
--- Name: room_restrictions; Type: TABLE; Schema: public; Owner: postgres
+-- Name: channel_outbox; Type: TABLE; Schema: public; Owner: postgres
 --
 
-CREATE TABLE public.room_restrictions (
+CREATE TABLE public.channel_outbox (
     id integer NOT NULL,
+    action character varying(255) NOT NULL,
+    room_id integer NOT NULL,
     start_date date NOT NULL,
     end_date date NOT NULL,
-    room_id integer NOT NULL,
-    reservation_id integer,
-    restriction_id integer NOT NULL,
+    room_restriction_id integer NOT NULL,
     created_at timestamp without time zone NOT NULL,
-    updated_at timestamp without time zone NOT NULL
+    updated_at timestamp without time zone NOT NULL,
+    xid xid8 DEFAULT pg_current_xact_id() NOT NULL,
+    "position" integer
 );
 
 
-ALTER TABLE public.room_restrictions OWNER TO postgres;
+ALTER TABLE public.channel_outbox OWNER TO postgres;
 
 --
--- Name: room_restrictions_id_seq; Type: SEQUENCE; Schema: public; Owner: postgres
+-- Name: channel_outbox_id_seq; Type: SEQUENCE; Schema: public; Owner: postgres
 --
 
-CREATE SEQUENCE public.room_restrictions_id_seq
+CREATE SEQUENCE public.channel_outbox_id_seq
     AS integer
     START WITH 1
     INCREMENT BY 1
@@ -130,34 +164,57 @@ CREATE SEQUENCE public.room_restrictions_id_seq
     CACHE 1;
 
 
-ALTER TABLE public.room_restrictions_id_seq OWNER TO postgres;
+ALTER TABLE public.channel_outbox_id_seq OWNER TO postgres;
 
 --
--- Name: room_restrictions_id_seq; Type: SEQUENCE OWNED BY; Schema: public; Owner: postgres
+-- Name: channel_outbox_id_seq; Type: SEQUENCE OWNED BY; Schema: public; Owner: postgres
 --
 
-ALTER SEQUENCE public.room_restrictions_id_seq OWNED BY public.room_restrictions.id;
+ALTER SEQUENCE public.channel_outbox_id_seq OWNED BY public.channel_outbox.id;
 
 
 --
--- Name: rooms; Type: TABLE; Schema: public; Owner: postgres
+-- Name: channel_outbox_position_seq; Type: SEQUENCE; Schema: public; Owner: postgres
 --
 
-CREATE TABLE public.rooms (
+CREATE SEQUENCE public.channel_outbox_position_seq
+    AS integer
+    START WITH 1
+    INCREMENT BY 1
+    NO MINVALUE
+    NO MAXVALUE
+    CACHE 1;
+
+
+ALTER TABLE public.channel_outbox_position_seq OWNER TO postgres;
+
+--
+-- Name: channel_outbox_position_seq; Type: SEQUENCE OWNED BY; Schema: public; Owner: postgres
+--
+
+ALTER SEQUENCE public.channel_outbox_position_seq OWNED BY public.channel_outbox."position";
+
+
+--
+-- Name: guest_emails; Type: TABLE; Schema: public; Owner: postgres
+--
+
+CREATE TABLE public.guest_emails (
     id integer NOT NULL,
-    room_name character varying(255) DEFAULT ''::character varying NOT NULL,
+    reservation_id integer NOT NULL,
+    kind character varying(255) NOT NULL,
     created_at timestamp without time zone NOT NULL,
     updated_at timestamp without time zone NOT NULL
 );
 
 
-ALTER TABLE public.rooms OWNER TO postgres;
+ALTER TABLE public.guest_emails OWNER TO postgres;
 
 --
--- Name: rooms_id_seq; Type: SEQUENCE; Schema: public; Owner: postgres
+-- Name: guest_emails_id_seq; Type: SEQUENCE; Schema: public; Owner: postgres
 --
 
-CREATE SEQUENCE public.rooms_id_seq
+CREATE SEQUENCE public.guest_emails_id_seq
     AS integer
     START WITH 1
     INCREMENT BY 1
@@ -166,49 +223,40 @@ CREATE SEQUENCE public.rooms_id_seq
     CACHE 1;
 
 
-ALTER TABLE public.rooms_id_seq OWNER TO postgres;
-
---
--- Name: rooms_id_seq; Type: SEQUENCE OWNED BY; Schema: public; Owner: postgres
---
-
-ALTER SEQUENCE public.rooms_id_seq OWNED BY public.rooms.id;
-
+ALTER TABLE public.guest_emails_id_seq OWNER TO postgres;
 
 --
--- Name: schema_migration; Type: TABLE; Schema: public; Owner: postgres
+-- Name: guest_emails_id_seq; Type: SEQUENCE OWNED BY; Schema: public; Owner: postgres
 --
 
-CREATE TABLE public.schema_migration (
-    version character varying(14) NOT NULL
-);
-
+ALTER SEQUENCE public.guest_emails_id_seq OWNED BY public.guest_emails.id;
 
-ALTER TABLE public.schema_migration OWNER TO postgres;
 
 --
--- Name: users; Type: TABLE; Schema: public; Owner: postgres
+-- Name: guests; Type: TABLE; Schema: public; Owner: postgres
 --
 
-CREATE TABLE public.users (
+CREATE TABLE public.guests (
     id integer NOT NULL,
     first_name character varying(255) DEFAULT ''::character varying NOT NULL,
     last_name character varying(255) DEFAULT ''::character varying NOT NULL,
-    email character varying(255) NOT NULL,
-    password character varying(60) NOT NULL,
-    access_level integer DEFAULT 1 NOT NULL,
+    email text DEFAULT ''::text NOT NULL,
+    phone text DEFAULT ''::text NOT NULL,
+    email_index character varying(255) DEFAULT ''::character varying NOT NULL,
+    phone_index character varying(255) DEFAULT ''::character varying NOT NULL,
+    notes text DEFAULT ''::text NOT NULL,
     created_at timestamp without time zone NOT NULL,
     updated_at timestamp without time zone NOT NULL
 );
 
 
-ALTER TABLE public.users OWNER TO postgres;
+ALTER TABLE public.guests OWNER TO postgres;
 
 --
--- Name: users_id_seq; Type: SEQUENCE; Schema: public; Owner: postgres
+-- Name: guests_id_seq; Type: SEQUENCE; Schema: public; Owner: postgres
 --
 
-CREATE SEQUENCE public.users_id_seq
+CREATE SEQUENCE public.guests_id_seq
     AS integer
     START WITH 1
     INCREMENT BY 1
@@ -217,172 +265,1448 @@ CREATE SEQUENCE public.users_id_seq
     CACHE 1;
 
 
-ALTER TABLE public.users_id_seq OWNER TO postgres;
+ALTER TABLE public.guests_id_seq OWNER TO postgres;
 
 --
--- Name: users_id_seq; Type: SEQUENCE OWNED BY; Schema: public; Owner: postgres
+-- Name: guests_id_seq; Type: SEQUENCE OWNED BY; Schema: public; Owner: postgres
 --
 
-ALTER SEQUENCE public.users_id_seq OWNED BY public.users.id;
+ALTER SEQUENCE public.guests_id_seq OWNED BY public.guests.id;
 
 
 --
--- Name: reservations id; Type: DEFAULT; Schema: public; Owner: postgres
+-- Name: ical_sources; Type: TABLE; Schema: public; Owner: postgres
 --
 
-ALTER TABLE ONLY public.reservations ALTER COLUMN id SET DEFAULT nextval('public.reservations_id_seq'::regclass);
+CREATE TABLE public.ical_sources (
+    id integer NOT NULL,
+    room_id integer NOT NULL,
+    name character varying(255) DEFAULT ''::character varying NOT NULL,
+    url text NOT NULL,
+    last_synced_at timestamp without time zone,
+    last_status text DEFAULT ''::text NOT NULL,
+    created_at timestamp without time zone NOT NULL,
+    updated_at timestamp without time zone NOT NULL
+);
+
 
+ALTER TABLE public.ical_sources OWNER TO postgres;
 
 --
--- Name: restrictions id; Type: DEFAULT; Schema: public; Owner: postgres
+-- Name: ical_sources_id_seq; Type: SEQUENCE; Schema: public; Owner: postgres
 --
 
-ALTER TABLE ONLY public.restrictions ALTER COLUMN id SET DEFAULT nextval('public.restrictions_id_seq'::regclass);
+CREATE SEQUENCE public.ical_sources_id_seq
+    AS integer
+    START WITH 1
+    INCREMENT BY 1
+    NO MINVALUE
+    NO MAXVALUE
+    CACHE 1;
 
 
+ALTER TABLE public.ical_sources_id_seq OWNER TO postgres;
+
 --
--- Name: room_restrictions id; Type: DEFAULT; Schema: public; Owner: postgres
+-- Name: ical_sources_id_seq; Type: SEQUENCE OWNED BY; Schema: public; Owner: postgres
 --
 
-ALTER TABLE ONLY public.room_restrictions ALTER COLUMN id SET DEFAULT nextval('public.room_restrictions_id_seq'::regclass);
+ALTER SEQUENCE public.ical_sources_id_seq OWNED BY public.ical_sources.id;
 
 
 --
--- Name: rooms id; Type: DEFAULT; Schema: public; Owner: postgres
+-- Name: mail_outbox; Type: TABLE; Schema: public; Owner: postgres
 --
 
-ALTER TABLE ONLY public.rooms ALTER COLUMN id SET DEFAULT nextval('public.rooms_id_seq'::regclass);
+CREATE TABLE public.mail_outbox (
+    id integer NOT NULL,
+    to_address character varying(255) NOT NULL,
+    from_address character varying(255) NOT NULL,
+    subject character varying(255) DEFAULT ''::character varying NOT NULL,
+    content text DEFAULT ''::text NOT NULL,
+    template character varying(255) DEFAULT ''::character varying NOT NULL,
+    attachments jsonb,
+    status character varying(255) DEFAULT 'pending'::character varying NOT NULL,
+    attempts integer DEFAULT 0 NOT NULL,
+    next_attempt_at timestamp without time zone NOT NULL,
+    locked_until timestamp without time zone,
+    last_error text DEFAULT ''::text NOT NULL,
+    sent_at timestamp without time zone,
+    created_at timestamp without time zone NOT NULL,
+    updated_at timestamp without time zone NOT NULL,
+    text_content text DEFAULT ''::text NOT NULL,
+    reply_to character varying(255) DEFAULT ''::character varying NOT NULL
+);
+
 
+ALTER TABLE public.mail_outbox OWNER TO postgres;
 
 --
--- Name: users id; Type: DEFAULT; Schema: public; Owner: postgres
+-- Name: mail_outbox_id_seq; Type: SEQUENCE; Schema: public; Owner: postgres
 --
 
-ALTER TABLE ONLY public.users ALTER COLUMN id SET DEFAULT nextval('public.users_id_seq'::regclass);
+CREATE SEQUENCE public.mail_outbox_id_seq
+    AS integer
+    START WITH 1
+    INCREMENT BY 1
+    NO MINVALUE
+    NO MAXVALUE
+    CACHE 1;
 
 
+ALTER TABLE public.mail_outbox_id_seq OWNER TO postgres;
+
 --
--- Name: reservations reservations_pkey; Type: CONSTRAINT; Schema: public; Owner: postgres
+-- Name: mail_outbox_id_seq; Type: SEQUENCE OWNED BY; Schema: public; Owner: postgres
 --
 
-ALTER TABLE ONLY public.reservations
-    ADD CONSTRAINT reservations_pkey PRIMARY KEY (id);
+ALTER SEQUENCE public.mail_outbox_id_seq OWNED BY public.mail_outbox.id;
 
 
 --
--- Name: restrictions restrictions_pkey; Type: CONSTRAINT; Schema: public; Owner: postgres
+-- Name: notification_preferences; Type: TABLE; Schema: public; Owner: postgres
 --
 
-ALTER TABLE ONLY public.restrictions
-    ADD CONSTRAINT restrictions_pkey PRIMARY KEY (id);
+CREATE TABLE public.notification_preferences (
+    id integer NOT NULL,
+    user_id integer NOT NULL,
+    event character varying(255) NOT NULL,
+    created_at timestamp without time zone NOT NULL,
+    updated_at timestamp without time zone NOT NULL
+);
+
 
+ALTER TABLE public.notification_preferences OWNER TO postgres;
 
 --
--- Name: room_restrictions room_restrictions_pkey; Type: CONSTRAINT; Schema: public; Owner: postgres
+-- Name: notification_preferences_id_seq; Type: SEQUENCE; Schema: public; Owner: postgres
 --
 
-ALTER TABLE ONLY public.room_restrictions
-    ADD CONSTRAINT room_restrictions_pkey PRIMARY KEY (id);
+CREATE SEQUENCE public.notification_preferences_id_seq
+    AS integer
+    START WITH 1
+    INCREMENT BY 1
+    NO MINVALUE
+    NO MAXVALUE
+    CACHE 1;
+
 
+ALTER TABLE public.notification_preferences_id_seq OWNER TO postgres;
 
 --
--- Name: rooms rooms_pkey; Type: CONSTRAINT; Schema: public; Owner: postgres
+-- Name: notification_preferences_id_seq; Type: SEQUENCE OWNED BY; Schema: public; Owner: postgres
 --
 
-ALTER TABLE ONLY public.rooms
-    ADD CONSTRAINT rooms_pkey PRIMARY KEY (id);
+ALTER SEQUENCE public.notification_preferences_id_seq OWNED BY public.notification_preferences.id;
 
 
 --
--- Name: users users_pkey; Type: CONSTRAINT; Schema: public; Owner: postgres
+-- Name: notification_recipients; Type: TABLE; Schema: public; Owner: postgres
 --
 
-ALTER TABLE ONLY public.users
-    ADD CONSTRAINT users_pkey PRIMARY KEY (id);
+CREATE TABLE public.notification_recipients (
+    id integer NOT NULL,
+    event character varying(255) NOT NULL,
+    email character varying(255) NOT NULL,
+    created_at timestamp without time zone NOT NULL,
+    updated_at timestamp without time zone NOT NULL
+);
+
 
+ALTER TABLE public.notification_recipients OWNER TO postgres;
 
 --
--- Name: reservations_email_idx; Type: INDEX; Schema: public; Owner: postgres
+-- Name: notification_recipients_id_seq; Type: SEQUENCE; Schema: public; Owner: postgres
 --
 
-CREATE INDEX reservations_email_idx ON public.reservations USING btree (email);
+CREATE SEQUENCE public.notification_recipients_id_seq
+    AS integer
+    START WITH 1
+    INCREMENT BY 1
+    NO MINVALUE
+    NO MAXVALUE
+    CACHE 1;
+
 
+ALTER TABLE public.notification_recipients_id_seq OWNER TO postgres;
 
 --
--- Name: reservations_last_name_idx; Type: INDEX; Schema: public; Owner: postgres
+-- Name: notification_recipients_id_seq; Type: SEQUENCE OWNED BY; Schema: public; Owner: postgres
 --
 
-CREATE INDEX reservations_last_name_idx ON public.reservations USING btree (last_name);
+ALTER SEQUENCE public.notification_recipients_id_seq OWNED BY public.notification_recipients.id;
 
 
 --
--- Name: room_restrictions_reservation_id_idx; Type: INDEX; Schema: public; Owner: postgres
+-- Name: property_settings; Type: TABLE; Schema: public; Owner: postgres
 --
 
-CREATE INDEX room_restrictions_reservation_id_idx ON public.room_restrictions USING btree (reservation_id);
+CREATE TABLE public.property_settings (
+    id integer NOT NULL,
+    sender_name character varying(255) DEFAULT ''::character varying NOT NULL,
+    sender_email character varying(255) DEFAULT ''::character varying NOT NULL,
+    reply_to character varying(255) DEFAULT ''::character varying NOT NULL,
+    created_at timestamp without time zone NOT NULL,
+    updated_at timestamp without time zone NOT NULL
+);
+
 
+ALTER TABLE public.property_settings OWNER TO postgres;
 
 --
--- Name: room_restrictions_room_id_idx; Type: INDEX; Schema: public; Owner: postgres
+-- Name: property_settings_id_seq; Type: SEQUENCE; Schema: public; Owner: postgres
 --
 
-CREATE INDEX room_restrictions_room_id_idx ON public.room_restrictions USING btree (room_id);
+CREATE SEQUENCE public.property_settings_id_seq
+    AS integer
+    START WITH 1
+    INCREMENT BY 1
+    NO MINVALUE
+    NO MAXVALUE
+    CACHE 1;
 
 
+ALTER TABLE public.property_settings_id_seq OWNER TO postgres;
+
 --
--- Name: room_restrictions_start_date_end_date_idx; Type: INDEX; Schema: public; Owner: postgres
+-- Name: property_settings_id_seq; Type: SEQUENCE OWNED BY; Schema: public; Owner: postgres
 --
 
-CREATE INDEX room_restrictions_start_date_end_date_idx ON public.room_restrictions USING btree (start_date, end_date);
+ALTER SEQUENCE public.property_settings_id_seq OWNED BY public.property_settings.id;
 
 
 --
--- Name: schema_migration_version_idx; Type: INDEX; Schema: public; Owner: postgres
+-- Name: registration_cards; Type: TABLE; Schema: public; Owner: postgres
 --
 
-CREATE UNIQUE INDEX schema_migration_version_idx ON public.schema_migration USING btree (version);
+CREATE TABLE public.registration_cards (
+    id integer NOT NULL,
+    reservation_id integer NOT NULL,
+    address text DEFAULT ''::text NOT NULL,
+    id_type character varying(255) DEFAULT ''::character varying NOT NULL,
+    id_number character varying(255) DEFAULT ''::character varying NOT NULL,
+    vehicle_plate character varying(255) DEFAULT ''::character varying NOT NULL,
+    created_at timestamp without time zone NOT NULL,
+    updated_at timestamp without time zone NOT NULL
+);
+
 
+ALTER TABLE public.registration_cards OWNER TO postgres;
 
 --
--- Name: users_email_idx; Type: INDEX; Schema: public; Owner: postgres
+-- Name: registration_cards_id_seq; Type: SEQUENCE; Schema: public; Owner: postgres
 --
 
-CREATE UNIQUE INDEX users_email_idx ON public.users USING btree (email);
+CREATE SEQUENCE public.registration_cards_id_seq
+    AS integer
+    START WITH 1
+    INCREMENT BY 1
+    NO MINVALUE
+    NO MAXVALUE
+    CACHE 1;
 
 
+ALTER TABLE public.registration_cards_id_seq OWNER TO postgres;
+
 --
--- Name: reservations reservations_rooms_id_fk; Type: FK CONSTRAINT; Schema: public; Owner: postgres
+-- Name: registration_cards_id_seq; Type: SEQUENCE OWNED BY; Schema: public; Owner: postgres
 --
 
-ALTER TABLE ONLY public.reservations
-    ADD CONSTRAINT reservations_rooms_id_fk FOREIGN KEY (room_id) REFERENCES public.rooms(id) ON UPDATE CASCADE ON DELETE CASCADE;
+ALTER SEQUENCE public.registration_cards_id_seq OWNED BY public.registration_cards.id;
 
 
 --
--- Name: room_restrictions room_restrictions_reservations_id_fk; Type: FK CONSTRAINT; Schema: public; Owner: postgres
+-- Name: reservations; Type: TABLE; Schema: public; Owner: postgres
 --
 
-ALTER TABLE ONLY public.room_restrictions
-    ADD CONSTRAINT room_restrictions_reservations_id_fk FOREIGN KEY (reservation_id) REFERENCES public.reservations(id) ON UPDATE CASCADE ON DELETE CASCADE;
+CREATE TABLE public.reservations (
+    id integer NOT NULL,
+    first_name character varying(255) DEFAULT ''::character varying NOT NULL,
+    last_name character varying(255) DEFAULT ''::character varying NOT NULL,
+    email text NOT NULL,
+    phone text DEFAULT ''::text NOT NULL,
+    start_date date NOT NULL,
+    end_date date NOT NULL,
+    room_id integer NOT NULL,
+    created_at timestamp without time zone NOT NULL,
+    updated_at timestamp without time zone NOT NULL,
+    status character varying(255) DEFAULT 'pending'::character varying NOT NULL,
+    confirmed_at timestamp without time zone,
+    checked_in_at timestamp without time zone,
+    checked_out_at timestamp without time zone,
+    cancelled_at timestamp without time zone,
+    no_show_at timestamp without time zone,
+    deleted_at timestamp without time zone,
+    checked_in_by integer,
+    checked_out_by integer,
+    guest_id integer,
+    anonymized_at timestamp without time zone,
+    email_index character varying(255) DEFAULT ''::character varying NOT NULL,
+    sms_opt_in boolean DEFAULT false NOT NULL
+);
 
 
+ALTER TABLE public.reservations OWNER TO postgres;
+
 --
--- Name: room_restrictions room_restrictions_restrictions_id_fk; Type: FK CONSTRAINT; Schema: public; Owner: postgres
+-- Name: reservations_id_seq; Type: SEQUENCE; Schema: public; Owner: postgres
 --
 
-ALTER TABLE ONLY public.room_restrictions
-    ADD CONSTRAINT room_restrictions_restrictions_id_fk FOREIGN KEY (restriction_id) REFERENCES public.restrictions(id) ON UPDATE CASCADE ON DELETE CASCADE;
+CREATE SEQUENCE public.reservations_id_seq
+    AS integer
+    START WITH 1
+    INCREMENT BY 1
+    NO MINVALUE
+    NO MAXVALUE
+    CACHE 1;
+
 
+ALTER TABLE public.reservations_id_seq OWNER TO postgres;
 
 --
--- Name: room_restrictions room_restrictions_rooms_id_fk; Type: FK CONSTRAINT; Schema: public; Owner: postgres
+-- Name: reservations_id_seq; Type: SEQUENCE OWNED BY; Schema: public; Owner: postgres
 --
 
-ALTER TABLE ONLY public.room_restrictions
-    ADD CONSTRAINT room_restrictions_rooms_id_fk FOREIGN KEY (room_id) REFERENCES public.rooms(id) ON UPDATE CASCADE ON DELETE CASCADE;
+ALTER SEQUENCE public.reservations_id_seq OWNED BY public.reservations.id;
 
 
 --
--- PostgreSQL database dump complete
+-- Name: restrictions; Type: TABLE; Schema: public; Owner: postgres
 --
 
+CREATE TABLE public.restrictions (
+    id integer NOT NULL,
+    restriction_name character varying(255) DEFAULT ''::character varying NOT NULL,
+    created_at timestamp without time zone NOT NULL,
+    updated_at timestamp without time zone NOT NULL
+);
+
+
+ALTER TABLE public.restrictions OWNER TO postgres;
+
+--
+-- Name: restrictions_id_seq; Type: SEQUENCE; Schema: public; Owner: postgres
+--
+
+CREATE SEQUENCE public.restrictions_id_seq
+    AS integer
+    START WITH 1
+    INCREMENT BY 1
+    NO MINVALUE
+    NO MAXVALUE
+    CACHE 1;
+
+
+ALTER TABLE public.restrictions_id_seq OWNER TO postgres;
+
+--
+-- Name: restrictions_id_seq; Type: SEQUENCE OWNED BY; Schema: public; Owner: postgres
+--
+
+ALTER SEQUENCE public.restrictions_id_seq OWNED BY public.restrictions.id;
+
+
+--
+-- Name: room_restrictions; Type: TABLE; Schema: public; Owner: postgres
+--
+
+CREATE TABLE public.room_restrictions (
+    id integer NOT NULL,
+    start_date date NOT NULL,
+    end_date date NOT NULL,
+    room_id integer NOT NULL,
+    reservation_id integer,
+    restriction_id integer NOT NULL,
+    created_at timestamp without time zone NOT NULL,
+    updated_at timestamp without time zone NOT NULL,
+    version integer DEFAULT 1 NOT NULL,
+    source_id integer,
+    source_uid character varying(255) DEFAULT ''::character varying NOT NULL
+);
+
+
+ALTER TABLE public.room_restrictions OWNER TO postgres;
+
+--
+-- Name: room_restrictions_id_seq; Type: SEQUENCE; Schema: public; Owner: postgres
+--
+
+CREATE SEQUENCE public.room_restrictions_id_seq
+    AS integer
+    START WITH 1
+    INCREMENT BY 1
+    NO MINVALUE
+    NO MAXVALUE
+    CACHE 1;
+
+
+ALTER TABLE public.room_restrictions_id_seq OWNER TO postgres;
+
+--
+-- Name: room_restrictions_id_seq; Type: SEQUENCE OWNED BY; Schema: public; Owner: postgres
+--
+
+ALTER SEQUENCE public.room_restrictions_id_seq OWNED BY public.room_restrictions.id;
+
+
+--
+-- Name: room_statuses; Type: TABLE; Schema: public; Owner: postgres
+--
+
+CREATE TABLE public.room_statuses (
+    id integer NOT NULL,
+    room_id integer NOT NULL,
+    status character varying(255) DEFAULT 'clean'::character varying NOT NULL,
+    note text DEFAULT ''::text NOT NULL,
+    block_id integer,
+    updated_by integer,
+    created_at timestamp without time zone NOT NULL,
+    updated_at timestamp without time zone NOT NULL
+);
+
+
+ALTER TABLE public.room_statuses OWNER TO postgres;
+
+--
+-- Name: room_statuses_id_seq; Type: SEQUENCE; Schema: public; Owner: postgres
+--
+
+CREATE SEQUENCE public.room_statuses_id_seq
+    AS integer
+    START WITH 1
+    INCREMENT BY 1
+    NO MINVALUE
+    NO MAXVALUE
+    CACHE 1;
+
+
+ALTER TABLE public.room_statuses_id_seq OWNER TO postgres;
+
+--
+-- Name: room_statuses_id_seq; Type: SEQUENCE OWNED BY; Schema: public; Owner: postgres
+--
+
+ALTER SEQUENCE public.room_statuses_id_seq OWNED BY public.room_statuses.id;
+
+
+--
+-- Name: rooms; Type: TABLE; Schema: public; Owner: postgres
+--
+
+CREATE TABLE public.rooms (
+    id integer NOT NULL,
+    room_name character varying(255) DEFAULT ''::character varying NOT NULL,
+    created_at timestamp without time zone NOT NULL,
+    updated_at timestamp without time zone NOT NULL
+);
+
+
+ALTER TABLE public.rooms OWNER TO postgres;
+
+--
+-- Name: rooms_id_seq; Type: SEQUENCE; Schema: public; Owner: postgres
+--
+
+CREATE SEQUENCE public.rooms_id_seq
+    AS integer
+    START WITH 1
+    INCREMENT BY 1
+    NO MINVALUE
+    NO MAXVALUE
+    CACHE 1;
+
+
+ALTER TABLE public.rooms_id_seq OWNER TO postgres;
+
+--
+-- Name: rooms_id_seq; Type: SEQUENCE OWNED BY; Schema: public; Owner: postgres
+--
+
+ALTER SEQUENCE public.rooms_id_seq OWNED BY public.rooms.id;
+
+
+--
+-- Name: scheduled_jobs; Type: TABLE; Schema: public; Owner: postgres
+--
+
+CREATE TABLE public.scheduled_jobs (
+    id integer NOT NULL,
+    name character varying(255) NOT NULL,
+    enabled boolean DEFAULT true NOT NULL,
+    offset_days integer DEFAULT 0 NOT NULL,
+    hour integer DEFAULT 9 NOT NULL,
+    next_run_at timestamp without time zone NOT NULL,
+    locked_until timestamp without time zone,
+    last_run_at timestamp without time zone,
+    last_result character varying(255) DEFAULT ''::character varying NOT NULL,
+    last_error text DEFAULT ''::text NOT NULL,
+    created_at timestamp without time zone NOT NULL,
+    updated_at timestamp without time zone NOT NULL
+);
+
+
+ALTER TABLE public.scheduled_jobs OWNER TO postgres;
+
+--
+-- Name: scheduled_jobs_id_seq; Type: SEQUENCE; Schema: public; Owner: postgres
+--
+
+CREATE SEQUENCE public.scheduled_jobs_id_seq
+    AS integer
+    START WITH 1
+    INCREMENT BY 1
+    NO MINVALUE
+    NO MAXVALUE
+    CACHE 1;
+
+
+ALTER TABLE public.scheduled_jobs_id_seq OWNER TO postgres;
+
+--
+-- Name: scheduled_jobs_id_seq; Type: SEQUENCE OWNED BY; Schema: public; Owner: postgres
+--
+
+ALTER SEQUENCE public.scheduled_jobs_id_seq OWNED BY public.scheduled_jobs.id;
+
+
+--
+-- Name: schema_migration; Type: TABLE; Schema: public; Owner: postgres
+--
+
+CREATE TABLE public.schema_migration (
+    version character varying(14) NOT NULL
+);
+
+
+ALTER TABLE public.schema_migration OWNER TO postgres;
+
+--
+-- Name: sms_outbox; Type: TABLE; Schema: public; Owner: postgres
+--
+
+CREATE TABLE public.sms_outbox (
+    id integer NOT NULL,
+    to_number character varying(255) NOT NULL,
+    body text DEFAULT ''::text NOT NULL,
+    template character varying(255) DEFAULT ''::character varying NOT NULL,
+    status character varying(255) DEFAULT 'pending'::character varying NOT NULL,
+    attempts integer DEFAULT 0 NOT NULL,
+    next_attempt_at timestamp without time zone NOT NULL,
+    locked_until timestamp without time zone,
+    last_error text DEFAULT ''::text NOT NULL,
+    sent_at timestamp without time zone,
+    created_at timestamp without time zone NOT NULL,
+    updated_at timestamp without time zone NOT NULL,
+    reservation_id integer
+);
+
+
+ALTER TABLE public.sms_outbox OWNER TO postgres;
+
+--
+-- Name: sms_outbox_id_seq; Type: SEQUENCE; Schema: public; Owner: postgres
+--
+
+CREATE SEQUENCE public.sms_outbox_id_seq
+    AS integer
+    START WITH 1
+    INCREMENT BY 1
+    NO MINVALUE
+    NO MAXVALUE
+    CACHE 1;
+
+
+ALTER TABLE public.sms_outbox_id_seq OWNER TO postgres;
+
+--
+-- Name: sms_outbox_id_seq; Type: SEQUENCE OWNED BY; Schema: public; Owner: postgres
+--
+
+ALTER SEQUENCE public.sms_outbox_id_seq OWNED BY public.sms_outbox.id;
+
+
+--
+-- Name: users; Type: TABLE; Schema: public; Owner: postgres
+--
+
+CREATE TABLE public.users (
+    id integer NOT NULL,
+    first_name character varying(255) DEFAULT ''::character varying NOT NULL,
+    last_name character varying(255) DEFAULT ''::character varying NOT NULL,
+    email character varying(255) NOT NULL,
+    password character varying(60) NOT NULL,
+    access_level integer DEFAULT 1 NOT NULL,
+    created_at timestamp without time zone NOT NULL,
+    updated_at timestamp without time zone NOT NULL,
+    feed_token character varying(255) DEFAULT ''::character varying NOT NULL
+);
+
+
+ALTER TABLE public.users OWNER TO postgres;
+
+--
+-- Name: webhook_deliveries; Type: TABLE; Schema: public; Owner: postgres
+--
+
+CREATE TABLE public.webhook_deliveries (
+    id integer NOT NULL,
+    endpoint_id integer NOT NULL,
+    event character varying(255) NOT NULL,
+    payload text NOT NULL,
+    status character varying(255) DEFAULT 'pending'::character varying NOT NULL,
+    attempts integer DEFAULT 0 NOT NULL,
+    next_attempt_at timestamp without time zone NOT NULL,
+    locked_until timestamp without time zone,
+    response_status integer DEFAULT 0 NOT NULL,
+    last_error text DEFAULT ''::text NOT NULL,
+    delivered_at timestamp without time zone,
+    created_at timestamp without time zone NOT NULL,
+    updated_at timestamp without time zone NOT NULL
+);
+
+
+ALTER TABLE public.webhook_deliveries OWNER TO postgres;
+
+--
+-- Name: webhook_deliveries_id_seq; Type: SEQUENCE; Schema: public; Owner: postgres
+--
+
+CREATE SEQUENCE public.webhook_deliveries_id_seq
+    AS integer
+    START WITH 1
+    INCREMENT BY 1
+    NO MINVALUE
+    NO MAXVALUE
+    CACHE 1;
+
+
+ALTER TABLE public.webhook_deliveries_id_seq OWNER TO postgres;
+
+--
+-- Name: webhook_deliveries_id_seq; Type: SEQUENCE OWNED BY; Schema: public; Owner: postgres
+--
+
+ALTER SEQUENCE public.webhook_deliveries_id_seq OWNED BY public.webhook_deliveries.id;
+
+
+--
+-- Name: webhook_endpoints; Type: TABLE; Schema: public; Owner: postgres
+--
+
+CREATE TABLE public.webhook_endpoints (
+    id integer NOT NULL,
+    url text NOT NULL,
+    secret character varying(255) NOT NULL,
+    active boolean DEFAULT true NOT NULL,
+    created_at timestamp without time zone NOT NULL,
+    updated_at timestamp without time zone NOT NULL
+);
+
+
+ALTER TABLE public.webhook_endpoints OWNER TO postgres;
+
+--
+-- Name: webhook_subscriptions; Type: TABLE; Schema: public; Owner: postgres
+--
+
+CREATE TABLE public.webhook_subscriptions (
+    id integer NOT NULL,
+    endpoint_id integer NOT NULL,
+    event character varying(255) NOT NULL,
+    created_at timestamp without time zone NOT NULL,
+    updated_at timestamp without time zone NOT NULL
+);
+
+
+ALTER TABLE public.webhook_subscriptions OWNER TO postgres;
+
+--
+-- Name: webhook_subscriptions_id_seq; Type: SEQUENCE; Schema: public; Owner: postgres
+--
+
+CREATE SEQUENCE public.webhook_subscriptions_id_seq
+    AS integer
+    START WITH 1
+    INCREMENT BY 1
+    NO MINVALUE
+    NO MAXVALUE
+    CACHE 1;
+
+
+ALTER TABLE public.webhook_subscriptions_id_seq OWNER TO postgres;
+
+--
+-- Name: webhook_subscriptions_id_seq; Type: SEQUENCE OWNED BY; Schema: public; Owner: postgres
+--
+
+ALTER SEQUENCE public.webhook_subscriptions_id_seq OWNED BY public.webhook_subscriptions.id;
+
+
+--
+-- Name: webhook_endpoints_id_seq; Type: SEQUENCE; Schema: public; Owner: postgres
+--
+
+CREATE SEQUENCE public.webhook_endpoints_id_seq
+    AS integer
+    START WITH 1
+    INCREMENT BY 1
+    NO MINVALUE
+    NO MAXVALUE
+    CACHE 1;
+
+
+ALTER TABLE public.webhook_endpoints_id_seq OWNER TO postgres;
+
+--
+-- Name: webhook_endpoints_id_seq; Type: SEQUENCE OWNED BY; Schema: public; Owner: postgres
+--
+
+ALTER SEQUENCE public.webhook_endpoints_id_seq OWNED BY public.webhook_endpoints.id;
+
+
+--
+-- Name: users_id_seq; Type: SEQUENCE; Schema: public; Owner: postgres
+--
+
+CREATE SEQUENCE public.users_id_seq
+    AS integer
+    START WITH 1
+    INCREMENT BY 1
+    NO MINVALUE
+    NO MAXVALUE
+    CACHE 1;
+
+
+ALTER TABLE public.users_id_seq OWNER TO postgres;
+
+--
+-- Name: users_id_seq; Type: SEQUENCE OWNED BY; Schema: public; Owner: postgres
+--
+
+ALTER SEQUENCE public.users_id_seq OWNED BY public.users.id;
+
+
+--
+-- Name: audit_events id; Type: DEFAULT; Schema: public; Owner: postgres
+--
+
+ALTER TABLE ONLY public.audit_events ALTER COLUMN id SET DEFAULT nextval('public.audit_events_id_seq'::regclass);
+
+
+--
+-- Name: channel_cursors id; Type: DEFAULT; Schema: public; Owner: postgres
+--
+
+ALTER TABLE ONLY public.channel_cursors ALTER COLUMN id SET DEFAULT nextval('public.channel_cursors_id_seq'::regclass);
+
+
+--
+-- Name: channel_outbox id; Type: DEFAULT; Schema: public; Owner: postgres
+--
+
+ALTER TABLE ONLY public.channel_outbox ALTER COLUMN id SET DEFAULT nextval('public.channel_outbox_id_seq'::regclass);
+
+
+--
+-- Name: guest_emails id; Type: DEFAULT; Schema: public; Owner: postgres
+--
+
+ALTER TABLE ONLY public.guest_emails ALTER COLUMN id SET DEFAULT nextval('public.guest_emails_id_seq'::regclass);
+
+
+--
+-- Name: guests id; Type: DEFAULT; Schema: public; Owner: postgres
+--
+
+ALTER TABLE ONLY public.guests ALTER COLUMN id SET DEFAULT nextval('public.guests_id_seq'::regclass);
+
+
+--
+-- Name: ical_sources id; Type: DEFAULT; Schema: public; Owner: postgres
+--
+
+ALTER TABLE ONLY public.ical_sources ALTER COLUMN id SET DEFAULT nextval('public.ical_sources_id_seq'::regclass);
+
+
+--
+-- Name: mail_outbox id; Type: DEFAULT; Schema: public; Owner: postgres
+--
+
+ALTER TABLE ONLY public.mail_outbox ALTER COLUMN id SET DEFAULT nextval('public.mail_outbox_id_seq'::regclass);
+
+
+--
+-- Name: notification_preferences id; Type: DEFAULT; Schema: public; Owner: postgres
+--
+
+ALTER TABLE ONLY public.notification_preferences ALTER COLUMN id SET DEFAULT nextval('public.notification_preferences_id_seq'::regclass);
+
+
+--
+-- Name: notification_recipients id; Type: DEFAULT; Schema: public; Owner: postgres
+--
+
+ALTER TABLE ONLY public.notification_recipients ALTER COLUMN id SET DEFAULT nextval('public.notification_recipients_id_seq'::regclass);
+
+
+--
+-- Name: property_settings id; Type: DEFAULT; Schema: public; Owner: postgres
+--
+
+ALTER TABLE ONLY public.property_settings ALTER COLUMN id SET DEFAULT nextval('public.property_settings_id_seq'::regclass);
+
+
+--
+-- Name: registration_cards id; Type: DEFAULT; Schema: public; Owner: postgres
+--
+
+ALTER TABLE ONLY public.registration_cards ALTER COLUMN id SET DEFAULT nextval('public.registration_cards_id_seq'::regclass);
+
+
+--
+-- Name: reservations id; Type: DEFAULT; Schema: public; Owner: postgres
+--
+
+ALTER TABLE ONLY public.reservations ALTER COLUMN id SET DEFAULT nextval('public.reservations_id_seq'::regclass);
+
+
+--
+-- Name: restrictions id; Type: DEFAULT; Schema: public; Owner: postgres
+--
+
+ALTER TABLE ONLY public.restrictions ALTER COLUMN id SET DEFAULT nextval('public.restrictions_id_seq'::regclass);
+
+
+--
+-- Name: room_restrictions id; Type: DEFAULT; Schema: public; Owner: postgres
+--
+
+ALTER TABLE ONLY public.room_restrictions ALTER COLUMN id SET DEFAULT nextval('public.room_restrictions_id_seq'::regclass);
+
+
+--
+-- Name: room_statuses id; Type: DEFAULT; Schema: public; Owner: postgres
+--
+
+ALTER TABLE ONLY public.room_statuses ALTER COLUMN id SET DEFAULT nextval('public.room_statuses_id_seq'::regclass);
+
+
+--
+-- Name: rooms id; Type: DEFAULT; Schema: public; Owner: postgres
+--
+
+ALTER TABLE ONLY public.rooms ALTER COLUMN id SET DEFAULT nextval('public.rooms_id_seq'::regclass);
+
+
+--
+-- Name: scheduled_jobs id; Type: DEFAULT; Schema: public; Owner: postgres
+--
+
+ALTER TABLE ONLY public.scheduled_jobs ALTER COLUMN id SET DEFAULT nextval('public.scheduled_jobs_id_seq'::regclass);
+
+
+--
+-- Name: sms_outbox id; Type: DEFAULT; Schema: public; Owner: postgres
+--
+
+ALTER TABLE ONLY public.sms_outbox ALTER COLUMN id SET DEFAULT nextval('public.sms_outbox_id_seq'::regclass);
+
+
+--
+-- Name: users id; Type: DEFAULT; Schema: public; Owner: postgres
+--
+
+ALTER TABLE ONLY public.users ALTER COLUMN id SET DEFAULT nextval('public.users_id_seq'::regclass);
+
+
+--
+-- Name: webhook_deliveries id; Type: DEFAULT; Schema: public; Owner: postgres
+--
+
+ALTER TABLE ONLY public.webhook_deliveries ALTER COLUMN id SET DEFAULT nextval('public.webhook_deliveries_id_seq'::regclass);
+
+
+--
+-- Name: webhook_endpoints id; Type: DEFAULT; Schema: public; Owner: postgres
+--
+
+ALTER TABLE ONLY public.webhook_endpoints ALTER COLUMN id SET DEFAULT nextval('public.webhook_endpoints_id_seq'::regclass);
+
+
+--
+-- Name: webhook_subscriptions id; Type: DEFAULT; Schema: public; Owner: postgres
+--
+
+ALTER TABLE ONLY public.webhook_subscriptions ALTER COLUMN id SET DEFAULT nextval('public.webhook_subscriptions_id_seq'::regclass);
+
+
+--
+-- Name: audit_events audit_events_pkey; Type: CONSTRAINT; Schema: public; Owner: postgres
+--
+
+ALTER TABLE ONLY public.audit_events
+    ADD CONSTRAINT audit_events_pkey PRIMARY KEY (id);
+
+
+--
+-- Name: channel_cursors channel_cursors_pkey; Type: CONSTRAINT; Schema: public; Owner: postgres
+--
+
+ALTER TABLE ONLY public.channel_cursors
+    ADD CONSTRAINT channel_cursors_pkey PRIMARY KEY (id);
+
+
+--
+-- Name: channel_outbox channel_outbox_pkey; Type: CONSTRAINT; Schema: public; Owner: postgres
+--
+
+ALTER TABLE ONLY public.channel_outbox
+    ADD CONSTRAINT channel_outbox_pkey PRIMARY KEY (id);
+
+
+--
+-- Name: guest_emails guest_emails_pkey; Type: CONSTRAINT; Schema: public; Owner: postgres
+--
+
+ALTER TABLE ONLY public.guest_emails
+    ADD CONSTRAINT guest_emails_pkey PRIMARY KEY (id);
+
+
+--
+-- Name: guests guests_pkey; Type: CONSTRAINT; Schema: public; Owner: postgres
+--
+
+ALTER TABLE ONLY public.guests
+    ADD CONSTRAINT guests_pkey PRIMARY KEY (id);
+
+
+--
+-- Name: ical_sources ical_sources_pkey; Type: CONSTRAINT; Schema: public; Owner: postgres
+--
+
+ALTER TABLE ONLY public.ical_sources
+    ADD CONSTRAINT ical_sources_pkey PRIMARY KEY (id);
+
+
+--
+-- Name: mail_outbox mail_outbox_pkey; Type: CONSTRAINT; Schema: public; Owner: postgres
+--
+
+ALTER TABLE ONLY public.mail_outbox
+    ADD CONSTRAINT mail_outbox_pkey PRIMARY KEY (id);
+
+
+--
+-- Name: notification_preferences notification_preferences_pkey; Type: CONSTRAINT; Schema: public; Owner: postgres
+--
+
+ALTER TABLE ONLY public.notification_preferences
+    ADD CONSTRAINT notification_preferences_pkey PRIMARY KEY (id);
+
+
+--
+-- Name: notification_recipients notification_recipients_pkey; Type: CONSTRAINT; Schema: public; Owner: postgres
+--
+
+ALTER TABLE ONLY public.notification_recipients
+    ADD CONSTRAINT notification_recipients_pkey PRIMARY KEY (id);
+
+
+--
+-- Name: property_settings property_settings_pkey; Type: CONSTRAINT; Schema: public; Owner: postgres
+--
+
+ALTER TABLE ONLY public.property_settings
+    ADD CONSTRAINT property_settings_pkey PRIMARY KEY (id);
+
+
+--
+-- Name: registration_cards registration_cards_pkey; Type: CONSTRAINT; Schema: public; Owner: postgres
+--
+
+ALTER TABLE ONLY public.registration_cards
+    ADD CONSTRAINT registration_cards_pkey PRIMARY KEY (id);
+
+
+--
+-- Name: reservations reservations_pkey; Type: CONSTRAINT; Schema: public; Owner: postgres
+--
+
+ALTER TABLE ONLY public.reservations
+    ADD CONSTRAINT reservations_pkey PRIMARY KEY (id);
+
+
+--
+-- Name: restrictions restrictions_pkey; Type: CONSTRAINT; Schema: public; Owner: postgres
+--
+
+ALTER TABLE ONLY public.restrictions
+    ADD CONSTRAINT restrictions_pkey PRIMARY KEY (id);
+
+
+--
+-- Name: room_restrictions room_restrictions_pkey; Type: CONSTRAINT; Schema: public; Owner: postgres
+--
+
+ALTER TABLE ONLY public.room_restrictions
+    ADD CONSTRAINT room_restrictions_pkey PRIMARY KEY (id);
+
+
+--
+-- Name: room_statuses room_statuses_pkey; Type: CONSTRAINT; Schema: public; Owner: postgres
+--
+
+ALTER TABLE ONLY public.room_statuses
+    ADD CONSTRAINT room_statuses_pkey PRIMARY KEY (id);
+
+
+--
+-- Name: rooms rooms_pkey; Type: CONSTRAINT; Schema: public; Owner: postgres
+--
+
+ALTER TABLE ONLY public.rooms
+    ADD CONSTRAINT rooms_pkey PRIMARY KEY (id);
+
+
+--
+-- Name: scheduled_jobs scheduled_jobs_pkey; Type: CONSTRAINT; Schema: public; Owner: postgres
+--
+
+ALTER TABLE ONLY public.scheduled_jobs
+    ADD CONSTRAINT scheduled_jobs_pkey PRIMARY KEY (id);
+
+
+--
+-- Name: sms_outbox sms_outbox_pkey; Type: CONSTRAINT; Schema: public; Owner: postgres
+--
+
+ALTER TABLE ONLY public.sms_outbox
+    ADD CONSTRAINT sms_outbox_pkey PRIMARY KEY (id);
+
+
+--
+-- Name: users users_pkey; Type: CONSTRAINT; Schema: public; Owner: postgres
+--
+
+ALTER TABLE ONLY public.users
+    ADD CONSTRAINT users_pkey PRIMARY KEY (id);
+
+
+--
+-- Name: webhook_deliveries webhook_deliveries_pkey; Type: CONSTRAINT; Schema: public; Owner: postgres
+--
+
+ALTER TABLE ONLY public.webhook_deliveries
+    ADD CONSTRAINT webhook_deliveries_pkey PRIMARY KEY (id);
+
+
+--
+-- Name: webhook_endpoints webhook_endpoints_pkey; Type: CONSTRAINT; Schema: public; Owner: postgres
+--
+
+ALTER TABLE ONLY public.webhook_endpoints
+    ADD CONSTRAINT webhook_endpoints_pkey PRIMARY KEY (id);
+
+
+--
+-- Name: webhook_subscriptions webhook_subscriptions_pkey; Type: CONSTRAINT; Schema: public; Owner: postgres
+--
+
+ALTER TABLE ONLY public.webhook_subscriptions
+    ADD CONSTRAINT webhook_subscriptions_pkey PRIMARY KEY (id);
+
+
+--
+-- Name: audit_events_created_at_idx; Type: INDEX; Schema: public; Owner: postgres
+--
+
+CREATE INDEX audit_events_created_at_idx ON public.audit_events USING btree (created_at);
+
+
+--
+-- Name: audit_events_entity_entity_id_idx; Type: INDEX; Schema: public; Owner: postgres
+--
+
+CREATE INDEX audit_events_entity_entity_id_idx ON public.audit_events USING btree (entity, entity_id);
+
+
+--
+-- Name: audit_events_user_id_idx; Type: INDEX; Schema: public; Owner: postgres
+--
+
+CREATE INDEX audit_events_user_id_idx ON public.audit_events USING btree (user_id);
+
+
+--
+-- Name: channel_cursors_channel_idx; Type: INDEX; Schema: public; Owner: postgres
+--
+
+CREATE UNIQUE INDEX channel_cursors_channel_idx ON public.channel_cursors USING btree (channel);
+
+
+--
+-- Name: channel_outbox_position_idx; Type: INDEX; Schema: public; Owner: postgres
+--
+
+CREATE UNIQUE INDEX channel_outbox_position_idx ON public.channel_outbox USING btree ("position");
+
+
+--
+-- Name: channel_outbox_room_id_idx; Type: INDEX; Schema: public; Owner: postgres
+--
+
+CREATE INDEX channel_outbox_room_id_idx ON public.channel_outbox USING btree (room_id);
+
+
+--
+-- Name: channel_outbox_unpositioned_idx; Type: INDEX; Schema: public; Owner: postgres
+--
+
+CREATE INDEX channel_outbox_unpositioned_idx ON public.channel_outbox USING btree (xid, id) WHERE ("position" IS NULL);
+
+
+--
+-- Name: guest_emails_reservation_id_kind_idx; Type: INDEX; Schema: public; Owner: postgres
+--
+
+CREATE UNIQUE INDEX guest_emails_reservation_id_kind_idx ON public.guest_emails USING btree (reservation_id, kind);
+
+
+--
+-- Name: guests_email_index_idx; Type: INDEX; Schema: public; Owner: postgres
+--
+
+CREATE INDEX guests_email_index_idx ON public.guests USING btree (email_index);
+
+
+--
+-- Name: guests_phone_index_idx; Type: INDEX; Schema: public; Owner: postgres
+--
+
+CREATE INDEX guests_phone_index_idx ON public.guests USING btree (phone_index);
+
+
+--
+-- Name: mail_outbox_status_next_attempt_at_idx; Type: INDEX; Schema: public; Owner: postgres
+--
+
+CREATE INDEX mail_outbox_status_next_attempt_at_idx ON public.mail_outbox USING btree (status, next_attempt_at);
+
+
+--
+-- Name: notification_preferences_user_id_event_idx; Type: INDEX; Schema: public; Owner: postgres
+--
+
+CREATE UNIQUE INDEX notification_preferences_user_id_event_idx ON public.notification_preferences USING btree (user_id, event);
+
+
+--
+-- Name: notification_recipients_event_email_idx; Type: INDEX; Schema: public; Owner: postgres
+--
+
+CREATE UNIQUE INDEX notification_recipients_event_email_idx ON public.notification_recipients USING btree (event, email);
+
+
+--
+-- Name: registration_cards_reservation_id_idx; Type: INDEX; Schema: public; Owner: postgres
+--
+
+CREATE UNIQUE INDEX registration_cards_reservation_id_idx ON public.registration_cards USING btree (reservation_id);
+
+
+--
+-- Name: reservations_deleted_at_idx; Type: INDEX; Schema: public; Owner: postgres
+--
+
+CREATE INDEX reservations_deleted_at_idx ON public.reservations USING btree (deleted_at);
+
+
+--
+-- Name: reservations_email_index_idx; Type: INDEX; Schema: public; Owner: postgres
+--
+
+CREATE INDEX reservations_email_index_idx ON public.reservations USING btree (email_index);
+
+
+--
+-- Name: reservations_guest_id_idx; Type: INDEX; Schema: public; Owner: postgres
+--
+
+CREATE INDEX reservations_guest_id_idx ON public.reservations USING btree (guest_id);
+
+
+--
+-- Name: reservations_last_name_idx; Type: INDEX; Schema: public; Owner: postgres
+--
+
+CREATE INDEX reservations_last_name_idx ON public.reservations USING btree (last_name);
+
+
+--
+-- Name: reservations_status_idx; Type: INDEX; Schema: public; Owner: postgres
+--
+
+CREATE INDEX reservations_status_idx ON public.reservations USING btree (status);
+
+
+--
+-- Name: room_restrictions_reservation_id_idx; Type: INDEX; Schema: public; Owner: postgres
+--
+
+CREATE INDEX room_restrictions_reservation_id_idx ON public.room_restrictions USING btree (reservation_id);
+
+
+--
+-- Name: room_restrictions_room_id_idx; Type: INDEX; Schema: public; Owner: postgres
+--
+
+CREATE INDEX room_restrictions_room_id_idx ON public.room_restrictions USING btree (room_id);
+
+
+--
+-- Name: room_restrictions_source_id_source_uid_idx; Type: INDEX; Schema: public; Owner: postgres
+--
+
+CREATE INDEX room_restrictions_source_id_source_uid_idx ON public.room_restrictions USING btree (source_id, source_uid);
+
+
+--
+-- Name: room_restrictions_start_date_end_date_idx; Type: INDEX; Schema: public; Owner: postgres
+--
+
+CREATE INDEX room_restrictions_start_date_end_date_idx ON public.room_restrictions USING btree (start_date, end_date);
+
+
+--
+-- Name: room_statuses_room_id_idx; Type: INDEX; Schema: public; Owner: postgres
+--
+
+CREATE UNIQUE INDEX room_statuses_room_id_idx ON public.room_statuses USING btree (room_id);
+
+
+--
+-- Name: scheduled_jobs_name_idx; Type: INDEX; Schema: public; Owner: postgres
+--
+
+CREATE UNIQUE INDEX scheduled_jobs_name_idx ON public.scheduled_jobs USING btree (name);
+
+
+--
+-- Name: schema_migration_version_idx; Type: INDEX; Schema: public; Owner: postgres
+--
+
+CREATE UNIQUE INDEX schema_migration_version_idx ON public.schema_migration USING btree (version);
+
+
+--
+-- Name: sms_outbox_reservation_id_idx; Type: INDEX; Schema: public; Owner: postgres
+--
+
+CREATE INDEX sms_outbox_reservation_id_idx ON public.sms_outbox USING btree (reservation_id);
+
+
+--
+-- Name: sms_outbox_status_next_attempt_at_idx; Type: INDEX; Schema: public; Owner: postgres
+--
+
+CREATE INDEX sms_outbox_status_next_attempt_at_idx ON public.sms_outbox USING btree (status, next_attempt_at);
+
+
+--
+-- Name: users_email_idx; Type: INDEX; Schema: public; Owner: postgres
+--
+
+CREATE UNIQUE INDEX users_email_idx ON public.users USING btree (email);
+
+
+--
+-- Name: users_feed_token_idx; Type: INDEX; Schema: public; Owner: postgres
+--
+
+CREATE INDEX users_feed_token_idx ON public.users USING btree (feed_token);
+
+
+--
+-- Name: webhook_deliveries_endpoint_id_idx; Type: INDEX; Schema: public; Owner: postgres
+--
+
+CREATE INDEX webhook_deliveries_endpoint_id_idx ON public.webhook_deliveries USING btree (endpoint_id);
+
+
+--
+-- Name: webhook_deliveries_status_next_attempt_at_idx; Type: INDEX; Schema: public; Owner: postgres
+--
+
+CREATE INDEX webhook_deliveries_status_next_attempt_at_idx ON public.webhook_deliveries USING btree (status, next_attempt_at);
+
+
+--
+-- Name: webhook_subscriptions_endpoint_id_event_idx; Type: INDEX; Schema: public; Owner: postgres
+--
+
+CREATE UNIQUE INDEX webhook_subscriptions_endpoint_id_event_idx ON public.webhook_subscriptions USING btree (endpoint_id, event);
+
+
+--
+-- Name: room_restrictions room_restrictions_channel_outbox; Type: TRIGGER; Schema: public; Owner: postgres
+--
+
+CREATE TRIGGER room_restrictions_channel_outbox AFTER INSERT OR DELETE OR UPDATE ON public.room_restrictions FOR EACH ROW EXECUTE FUNCTION public.record_room_restriction_change();
+
+
+--
+-- Name: audit_events audit_events_users_id_fk; Type: FK CONSTRAINT; Schema: public; Owner: postgres
+--
+
+ALTER TABLE ONLY public.audit_events
+    ADD CONSTRAINT audit_events_users_id_fk FOREIGN KEY (user_id) REFERENCES public.users(id) ON UPDATE CASCADE ON DELETE SET NULL;
+
+
+--
+-- Name: guest_emails guest_emails_reservations_id_fk; Type: FK CONSTRAINT; Schema: public; Owner: postgres
+--
+
+ALTER TABLE ONLY public.guest_emails
+    ADD CONSTRAINT guest_emails_reservations_id_fk FOREIGN KEY (reservation_id) REFERENCES public.reservations(id) ON UPDATE CASCADE ON DELETE CASCADE;
+
+
+--
+-- Name: ical_sources ical_sources_rooms_id_fk; Type: FK CONSTRAINT; Schema: public; Owner: postgres
+--
+
+ALTER TABLE ONLY public.ical_sources
+    ADD CONSTRAINT ical_sources_rooms_id_fk FOREIGN KEY (room_id) REFERENCES public.rooms(id) ON UPDATE CASCADE ON DELETE CASCADE;
+
+
+--
+-- Name: notification_preferences notification_preferences_users_id_fk; Type: FK CONSTRAINT; Schema: public; Owner: postgres
+--
+
+ALTER TABLE ONLY public.notification_preferences
+    ADD CONSTRAINT notification_preferences_users_id_fk FOREIGN KEY (user_id) REFERENCES public.users(id) ON UPDATE CASCADE ON DELETE CASCADE;
+
+
+--
+-- Name: registration_cards registration_cards_reservations_id_fk; Type: FK CONSTRAINT; Schema: public; Owner: postgres
+--
+
+ALTER TABLE ONLY public.registration_cards
+    ADD CONSTRAINT registration_cards_reservations_id_fk FOREIGN KEY (reservation_id) REFERENCES public.reservations(id) ON UPDATE CASCADE ON DELETE CASCADE;
+
+
+--
+-- Name: reservations reservations_checked_in_by_fk; Type: FK CONSTRAINT; Schema: public; Owner: postgres
+--
+
+ALTER TABLE ONLY public.reservations
+    ADD CONSTRAINT reservations_checked_in_by_fk FOREIGN KEY (checked_in_by) REFERENCES public.users(id) ON UPDATE CASCADE ON DELETE SET NULL;
+
+
+--
+-- Name: reservations reservations_checked_out_by_fk; Type: FK CONSTRAINT; Schema: public; Owner: postgres
+--
+
+ALTER TABLE ONLY public.reservations
+    ADD CONSTRAINT reservations_checked_out_by_fk FOREIGN KEY (checked_out_by) REFERENCES public.users(id) ON UPDATE CASCADE ON DELETE SET NULL;
+
+
+--
+-- Name: reservations reservations_guests_id_fk; Type: FK CONSTRAINT; Schema: public; Owner: postgres
+--
+
+ALTER TABLE ONLY public.reservations
+    ADD CONSTRAINT reservations_guests_id_fk FOREIGN KEY (guest_id) REFERENCES public.guests(id) ON UPDATE CASCADE ON DELETE SET NULL;
+
+
+--
+-- Name: reservations reservations_rooms_id_fk; Type: FK CONSTRAINT; Schema: public; Owner: postgres
+--
+
+ALTER TABLE ONLY public.reservations
+    ADD CONSTRAINT reservations_rooms_id_fk FOREIGN KEY (room_id) REFERENCES public.rooms(id) ON UPDATE CASCADE ON DELETE CASCADE;
+
+
+--
+-- Name: room_restrictions room_restrictions_ical_sources_id_fk; Type: FK CONSTRAINT; Schema: public; Owner: postgres
+--
+
+ALTER TABLE ONLY public.room_restrictions
+    ADD CONSTRAINT room_restrictions_ical_sources_id_fk FOREIGN KEY (source_id) REFERENCES public.ical_sources(id) ON UPDATE CASCADE ON DELETE CASCADE;
+
+
+--
+-- Name: room_restrictions room_restrictions_reservations_id_fk; Type: FK CONSTRAINT; Schema: public; Owner: postgres
+--
+
+ALTER TABLE ONLY public.room_restrictions
+    ADD CONSTRAINT room_restrictions_reservations_id_fk FOREIGN KEY (reservation_id) REFERENCES public.reservations(id) ON UPDATE CASCADE ON DELETE CASCADE;
+
+
+--
+-- Name: room_restrictions room_restrictions_restrictions_id_fk; Type: FK CONSTRAINT; Schema: public; Owner: postgres
+--
+
+ALTER TABLE ONLY public.room_restrictions
+    ADD CONSTRAINT room_restrictions_restrictions_id_fk FOREIGN KEY (restriction_id) REFERENCES public.restrictions(id) ON UPDATE CASCADE ON DELETE CASCADE;
+
+
+--
+-- Name: room_restrictions room_restrictions_rooms_id_fk; Type: FK CONSTRAINT; Schema: public; Owner: postgres
+--
+
+ALTER TABLE ONLY public.room_restrictions
+    ADD CONSTRAINT room_restrictions_rooms_id_fk FOREIGN KEY (room_id) REFERENCES public.rooms(id) ON UPDATE CASCADE ON DELETE CASCADE;
+
+
+--
+-- PostgreSQL database dump complete
+--
+
+
+--
+-- Name: room_statuses room_statuses_room_restrictions_id_fk; Type: FK CONSTRAINT; Schema: public; Owner: postgres
+--
+
+ALTER TABLE ONLY public.room_statuses
+    ADD CONSTRAINT room_statuses_room_restrictions_id_fk FOREIGN KEY (block_id) REFERENCES public.room_restrictions(id) ON UPDATE CASCADE ON DELETE SET NULL;
+
+
+--
+-- Name: room_statuses room_statuses_rooms_id_fk; Type: FK CONSTRAINT; Schema: public; Owner: postgres
+--
+
+ALTER TABLE ONLY public.room_statuses
+    ADD CONSTRAINT room_statuses_rooms_id_fk FOREIGN KEY (room_id) REFERENCES public.rooms(id) ON UPDATE CASCADE ON DELETE CASCADE;
+
+
+--
+-- Name: room_statuses room_statuses_users_id_fk; Type: FK CONSTRAINT; Schema: public; Owner: postgres
+--
+
+ALTER TABLE ONLY public.room_statuses
+    ADD CONSTRAINT room_statuses_users_id_fk FOREIGN KEY (updated_by) REFERENCES public.users(id) ON UPDATE CASCADE ON DELETE SET NULL;
+
+
+--
+-- Name: sms_outbox sms_outbox_reservations_id_fk; Type: FK CONSTRAINT; Schema: public; Owner: postgres
+--
+
+ALTER TABLE ONLY public.sms_outbox
+    ADD CONSTRAINT sms_outbox_reservations_id_fk FOREIGN KEY (reservation_id) REFERENCES public.reservations(id) ON UPDATE CASCADE ON DELETE CASCADE;
+
+
+--
+-- Name: webhook_deliveries webhook_deliveries_webhook_endpoints_id_fk; Type: FK CONSTRAINT; Schema: public; Owner: postgres
+--
+
+ALTER TABLE ONLY public.webhook_deliveries
+    ADD CONSTRAINT webhook_deliveries_webhook_endpoints_id_fk FOREIGN KEY (endpoint_id) REFERENCES public.webhook_endpoints(id) ON UPDATE CASCADE ON DELETE CASCADE;
+
+
+--
+-- Name: webhook_subscriptions webhook_subscriptions_webhook_endpoints_id_fk; Type: FK CONSTRAINT; Schema: public; Owner: postgres
+--
+
+ALTER TABLE ONLY public.webhook_subscriptions
+    ADD CONSTRAINT webhook_subscriptions_webhook_endpoints_id_fk FOREIGN KEY (endpoint_id) REFERENCES public.webhook_endpoints(id) ON UPDATE CASCADE ON DELETE CASCADE;
+